@@ -31,6 +31,9 @@
 #define DEFAULT_DEV     "/dev/ttyRPMSG0"
 #define TIMEOUT_MS      1000            /* Response timeout */
 
+#define CLIENT_VERSION  "1.1.0"
+#define JSON_PROTOCOL   1               /* --json output format version (bump on breaking change) */
+
 /* Device path (can be overridden with --device) */
 static const char *g_device = DEFAULT_DEV;
 
@@ -508,7 +511,8 @@ static void print_usage(const char *prog)
     printf("  status                          Get DMX status\n");
     printf("  blackout                        Set all channels to 0\n");
     printf("  timing [fps] [break] [mab]      Set timing (0=unchanged)\n");
-    printf("  timing                          Get current timing config\n\n");
+    printf("  timing                          Get current timing config\n");
+    printf("  version                         Show client and JSON protocol version\n\n");
 
     printf("FLAGS:\n");
     printf("  -d, --device <path>             Device path (default: %s)\n", DEFAULT_DEV);
@@ -544,6 +548,10 @@ static void print_usage(const char *prog)
     printf("    {\"status\":\"ok\",\"command\":\"get_timing\",\"break_us\":400,\n");
     printf("     \"mab_us\":40,\"latency_us\":251}\n\n");
 
+    printf("  version:\n");
+    printf("    {\"status\":\"ok\",\"command\":\"version\",\"version\":\"%s\",\"protocol\":%d}\n\n",
+           CLIENT_VERSION, JSON_PROTOCOL);
+
     printf("  timing (set):\n");
     printf("    {\"status\":\"ok\",\"command\":\"set_timing\",\"break_us\":400,\n");
     printf("     \"mab_us\":40,\"latency_us\":247}\n\n");
@@ -603,6 +611,23 @@ int main(int argc, char *argv[])
         return 0;
     }
 
+    /* Version does not need the device (used by the gateway for negotiation) */
+    if (strcmp(argv[1], "version") == 0) {
+        switch (g_output_format) {
+            case OUTPUT_JSON:
+                printf("{\"status\":\"ok\",\"command\":\"version\",\"version\":\"%s\",\"protocol\":%d}\n",
+                       CLIENT_VERSION, JSON_PROTOCOL);
+                break;
+            case OUTPUT_QUIET:
+                break;
+            case OUTPUT_HUMAN:
+            default:
+                printf("dmx_client %s (JSON protocol %d)\n", CLIENT_VERSION, JSON_PROTOCOL);
+                break;
+        }
+        return 0;
+    }
+
     /* Open RPMSG device */
     int fd = open(g_device, O_RDWR | O_NOCTTY);
     if (fd < 0) {
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RGB color input
+// Set commands accept a hex color ("#FF8800", "#F80") or HSV (h 0-360, s/v 0-100)
+// for lights with red/green/blue channels, converted here so clients don't have to.
+
+// colorValues converts the request color (hex or HSV) to red/green/blue channel values
+func colorValues(req *Request) (map[string]uint8, error) {
+	var r, g, b uint8
+	switch {
+	case req.Color != "":
+		if req.H != nil || req.S != nil || req.V != nil {
+			return nil, fmt.Errorf("use either color or h/s/v")
+		}
+		var err error
+		if r, g, b, err = parseHexColor(req.Color); err != nil {
+			return nil, err
+		}
+	default:
+		h, s, v := 0.0, 100.0, 100.0
+		if req.H != nil {
+			h = *req.H
+		}
+		if req.S != nil {
+			s = *req.S
+		}
+		if req.V != nil {
+			v = *req.V
+		}
+		if h < 0 || h > 360 || s < 0 || s > 100 || v < 0 || v > 100 {
+			return nil, fmt.Errorf("hsv out of range (h 0-360, s/v 0-100)")
+		}
+		r, g, b = hsvToRGB(h, s/100, v/100)
+	}
+	return map[string]uint8{"red": r, "green": g, "blue": b}, nil
+}
+
+// parseHexColor parses "#RRGGBB" or "#RGB" (leading # optional)
+func parseHexColor(s string) (r, g, b uint8, err error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return 0, 0, 0, fmt.Errorf("invalid color %q (use #RRGGBB)", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid color %q (use #RRGGBB)", s)
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), nil
+}
+
+// hsvToRGB converts hue (degrees), saturation and value (0-1) to 8-bit RGB
+func hsvToRGB(h, s, v float64) (r, g, b uint8) {
+	c := v * s
+	h6 := math.Mod(h, 360) / 60
+	x := c * (1 - math.Abs(math.Mod(h6, 2)-1))
+	var rf, gf, bf float64
+	switch int(h6) {
+	case 0:
+		rf, gf = c, x
+	case 1:
+		rf, gf = x, c
+	case 2:
+		gf, bf = c, x
+	case 3:
+		gf, bf = x, c
+	case 4:
+		rf, bf = x, c
+	default:
+		rf, bf = c, x
+	}
+	m := v - c
+	to8 := func(f float64) uint8 { return uint8(math.Round((f + m) * 255)) }
+	return to8(rf), to8(gf), to8(bf)
+}
+
+// hasRGB reports whether target has a light with red, green and blue channels
+func (h *Handler) hasRGB(target string) bool {
+	cfg := h.state.GetConfig()
+	group, light := parseTarget(target)
+	lights := []string{light}
+	if light == "" {
+		lights = cfg.GetGroupLights(group)
+	}
+	for _, name := range lights {
+		found := 0
+		for _, ch := range cfg.GetLight(group, name) {
+			if ch.Name == "red" || ch.Name == "green" || ch.Name == "blue" {
+				found++
+			}
+		}
+		if found == 3 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"dmx-gateway/internal/dmx"
+)
+
+// Structured errors
+// Every failure, whatever the protocol, is reported as the same object:
+//   {"code": "not_found", "message": "scene not found", "target": "evening"}
+// in the "error" field of unified API responses and of REST error bodies. Codes
+// are stable and map to one HTTP status each: invalid requests are bad_request,
+// missing lights, groups, scenes... not_found, commands that clash with the current
+// state (already exists, nothing to undo, not recording) conflict, and failures of
+// the DMX output itself backend.
+
+// Error codes
+const (
+	CodeBadRequest       = "bad_request"        // 400: invalid JSON, parameters or values
+	CodeUnauthorized     = "unauthorized"       // 401: missing or unknown credentials
+	CodeForbidden        = "forbidden"          // 403: role too low
+	CodeNotFound         = "not_found"          // 404: unknown light, group, scene, route...
+	CodeMethodNotAllowed = "method_not_allowed" // 405
+	CodeConflict         = "conflict"           // 409: not possible in the current state
+	CodeRateLimited      = "rate_limited"       // 429
+	CodeInternal         = "internal"           // 500: file or encoding failure
+	CodeBackend          = "backend"            // 502: DMX output backend failure
+	CodeUnavailable      = "unavailable"        // 503: feature not configured
+)
+
+// Error is a machine-readable failure
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Target  string `json:"target,omitempty"`  // Light, group, scene... concerned
+	Details any    `json:"details,omitempty"` // Extra context (e.g. the role required)
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError returns a failure with an explicit code
+func NewError(code, target, message string) *Error {
+	return &Error{Code: code, Message: message, Target: target}
+}
+
+// ErrorOf classifies err (see Code)
+func ErrorOf(err error, target string) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: Code(err), Message: err.Error(), Target: target}
+}
+
+var (
+	notFound = []error{
+		dmx.ErrLightNotFound, dmx.ErrGroupNotFound, dmx.ErrSceneNotFound, dmx.ErrPresetNotFound,
+		dmx.ErrCueListNotFound, dmx.ErrEffectNotFound, dmx.ErrShowNotFound, dmx.ErrRecordingNotFound,
+		dmx.ErrVariableNotFound,
+	}
+	conflict = []error{
+		dmx.ErrLightExists, dmx.ErrGroupExists, dmx.ErrCueListEnd, dmx.ErrNothingToUndo,
+		dmx.ErrNotRecording, dmx.ErrNotPlaying, dmx.ErrShowNotRunning, dmx.ErrNoTunableWhite,
+	}
+)
+
+// Code returns the error code of err; unknown errors are invalid requests
+func Code(err error) string {
+	var e *Error
+	var backend *dmx.BackendError
+	var path *fs.PathError
+	switch {
+	case errors.As(err, &e):
+		return e.Code
+	case errors.As(err, &backend):
+		return CodeBackend
+	case errors.Is(err, dmx.ErrRecorderDisabled):
+		return CodeUnavailable
+	case errors.As(err, &path):
+		return CodeInternal
+	}
+	for _, target := range notFound {
+		if errors.Is(err, target) {
+			return CodeNotFound
+		}
+	}
+	for _, target := range conflict {
+		if errors.Is(err, target) {
+			return CodeConflict
+		}
+	}
+	return CodeBadRequest
+}
+
+// Status returns the HTTP status of an error code
+func Status(code string) int {
+	switch code {
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case CodeConflict:
+		return http.StatusConflict
+	case CodeRateLimited:
+		return http.StatusTooManyRequests
+	case CodeInternal:
+		return http.StatusInternalServerError
+	case CodeBackend:
+		return http.StatusBadGateway
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
+// StatusCode returns the error code of an HTTP status
+func StatusCode(status int) string {
+	for _, code := range []string{
+		CodeUnauthorized, CodeForbidden, CodeNotFound, CodeMethodNotAllowed,
+		CodeConflict, CodeRateLimited, CodeInternal, CodeBackend, CodeUnavailable,
+	} {
+		if Status(code) == status {
+			return code
+		}
+	}
+	return CodeBadRequest
+}
+
+// errorResponse is the unified API answer to a failure
+func errorResponse(err error, target string) *Response {
+	return &Response{Type: "error", Target: target, Error: ErrorOf(err, target)}
+}
+
+// failResponse is the unified API answer to an invalid request
+func failResponse(code, target, message string) *Response {
+	return &Response{Type: "error", Target: target, Error: NewError(code, target, message)}
+}
@@ -6,7 +6,9 @@ package api
 import (
 	"encoding/json"
 	"strings"
+	"time"
 
+	"dmx-gateway/internal/config"
 	"dmx-gateway/internal/dmx"
 	"dmx-gateway/internal/metrics"
 )
@@ -14,9 +16,60 @@ import (
 // Request is the unified JSON request format for all protocols
 // Used by: HTTP POST /api, WebSocket, MQTT
 type Request struct {
-	Cmd    string           `json:"cmd"`              // enable, disable, blackout, set, get, status
-	Target string           `json:"target,omitempty"` // "group" or "group/light"
-	Values map[string]uint8 `json:"values,omitempty"` // channel values
+	Cmd        string             `json:"cmd"`                   // enable, disable, blackout, set, get, status, scene_*
+	Target     string             `json:"target,omitempty"`      // "group" or "group/light"
+	Values     map[string]uint8   `json:"values,omitempty"`      // channel values
+	Values16   map[string]uint16  `json:"values16,omitempty"`    // set: 0-65535 values (16-bit pairs)
+	ValuesPct  map[string]float64 `json:"values_pct,omitempty"`  // set: 0-100% values (explicit values win)
+	CCT        int                `json:"cct,omitempty"`         // set: tunable white color temperature (Kelvin)
+	Brightness *uint8             `json:"brightness,omitempty"`  // set: tunable white level (omitted = keep)
+	Color      string             `json:"color,omitempty"`       // set: RGB lights, "#RRGGBB"
+	H          *float64           `json:"h,omitempty"`           // set: RGB lights, hue 0-360
+	S          *float64           `json:"s,omitempty"`           // set: RGB lights, saturation 0-100 (default 100)
+	V          *float64           `json:"v,omitempty"`           // set: RGB lights, value 0-100 (default 100)
+	FadeMs     int                `json:"fade_ms,omitempty"`     // set/scene_recall: ramp duration (0 = immediate)
+	Name       string             `json:"name,omitempty"`        // scene or preset name
+	Targets    []string           `json:"targets,omitempty"`     // scene_save: subset of lights (empty = all)
+	Steps      int                `json:"steps,omitempty"`       // undo: number of changes to roll back (default 1)
+	Effect     *dmx.EffectParams  `json:"effect,omitempty"`      // effect_start parameters
+	Cue        int                `json:"cue,omitempty"`         // cue_goto: 1-based cue number
+	Loop       bool               `json:"loop,omitempty"`        // play: repeat until stopped
+	Action     string             `json:"action,omitempty"`      // show: start (default), stop, load, status
+	Show       *config.Show       `json:"show,omitempty"`        // show load: timeline steps
+	Park       map[int]uint8      `json:"park,omitempty"`        // park: DMX channel -> held value
+	Channels   []int              `json:"channels,omitempty"`    // unpark: DMX channels (empty = all)
+	DurationMs int                `json:"duration_ms,omitempty"` // locate: flash duration (default 5000)
+	Limit      int                `json:"limit,omitempty"`       // history: most recent entries (0 = all)
+	Items      []dmx.TargetValues `json:"items,omitempty"`       // set_multi: targets applied all or nothing
+	RequestID  string             `json:"request_id,omitempty"`  // correlation id (generated when omitted)
+}
+
+// Commands lists the unified API commands (the cmd enum of /api/openapi.json)
+var Commands = []string{
+	"enable", "disable", "blackout", "set", "set_multi", "get", "status", "lights", "groups",
+	"scenes", "scene_save", "scene_recall", "scene_delete", "preset", "presets", "snapshot", "undo", "release",
+	"cues", "cue_go", "cue_back", "cue_goto", "show",
+	"record_start", "record_stop", "play", "play_stop", "recordings",
+	"effect_start", "effect_stop", "effects", "crossfade", "crossfade_abort", "locate",
+	"park", "unpark", "parked", "freeze", "unfreeze", "history", "mask", "unmask", "masked",
+	"variables",
+}
+
+// readOnlyCmds are the commands that only query state (allowed to viewers)
+var readOnlyCmds = map[string]bool{
+	"get": true, "status": true, "lights": true, "groups": true, "scenes": true, "presets": true,
+	"cues": true, "recordings": true, "effects": true, "parked": true, "history": true, "masked": true,
+	"variables": true,
+}
+
+// ReadOnly reports whether the request only queries state
+func (r *Request) ReadOnly() bool {
+	return readOnlyCmds[r.Cmd] || (r.Cmd == "show" && r.Action == "status")
+}
+
+// Admin reports whether the request is reserved to administrators (output on/off)
+func (r *Request) Admin() bool {
+	return r.Cmd == "enable" || r.Cmd == "disable"
 }
 
 // Response is the unified JSON response format
@@ -24,21 +77,45 @@ type Response struct {
 	Type   string      `json:"type"`             // status, light, lights, groups, error, ok
 	Target string      `json:"target,omitempty"` // echoes request target
 	Data   interface{} `json:"data,omitempty"`
-	Error  string      `json:"error,omitempty"`
+	Error  *Error      `json:"error,omitempty"` // Set when type is "error" (see errors.go)
+
+	RequestID string `json:"request_id,omitempty"` // Echoes or assigns the request id
 }
 
 // Handler processes unified API requests
 type Handler struct {
 	state *dmx.State
+	src   *dmx.Source // Writes are tagged with the protocol source for arbitration
 }
 
-// NewHandler creates a new API handler
-func NewHandler(state *dmx.State) *Handler {
-	return &Handler{state: state}
+// NewHandler creates a new API handler for a protocol source (dmx.SourceHTTP, ...)
+func NewHandler(state *dmx.State, source string) *Handler {
+	return &Handler{state: state, src: state.Source(source)}
 }
 
-// Handle processes a request and returns a response
+// Commands that change output capture an undo point first
+var undoable = map[string]bool{
+	"blackout": true, "set": true, "set_multi": true, "scene_recall": true, "preset": true,
+	"cue_go": true, "cue_back": true, "cue_goto": true,
+}
+
+// Handle processes a request and returns a response. The request id (generated when
+// missing) traces the writes it makes in history and change events.
 func (h *Handler) Handle(req *Request) *Response {
+	if req.RequestID == "" {
+		req.RequestID = dmx.NewRequestID()
+	}
+	traced := &Handler{state: h.state, src: h.src.WithRequest(req.RequestID)}
+	resp := traced.handle(req)
+	resp.RequestID = req.RequestID
+	return resp
+}
+
+func (h *Handler) handle(req *Request) *Response {
+	if undoable[req.Cmd] {
+		h.state.CaptureUndo()
+	}
+
 	switch req.Cmd {
 	case "enable":
 		return h.handleEnable()
@@ -47,7 +124,23 @@ func (h *Handler) Handle(req *Request) *Response {
 	case "blackout":
 		return h.handleBlackout()
 	case "set":
-		return h.handleSet(req.Target, req.Values)
+		if len(req.ValuesPct) > 0 {
+			if err := mergePercent(req); err != nil {
+				return errorResponse(err, req.Target)
+			}
+		}
+		if req.CCT > 0 || req.Brightness != nil {
+			return h.handleCCT(req.Target, req.CCT, req.Brightness, time.Duration(req.FadeMs)*time.Millisecond)
+		}
+		if req.Color != "" || req.H != nil || req.S != nil || req.V != nil {
+			return h.handleSetColor(req)
+		}
+		if len(req.Values16) > 0 {
+			return h.handleSet16(req.Target, req.Values16, time.Duration(req.FadeMs)*time.Millisecond)
+		}
+		return h.handleSet(req.Target, req.Values, time.Duration(req.FadeMs)*time.Millisecond)
+	case "set_multi":
+		return h.handleSetMulti(req.Items)
 	case "get":
 		return h.handleGet(req.Target)
 	case "status":
@@ -56,22 +149,129 @@ func (h *Handler) Handle(req *Request) *Response {
 		return h.handleLights()
 	case "groups":
 		return h.handleGroups()
+	case "scenes":
+		return &Response{Type: "scenes", Data: h.state.Scenes()}
+	case "scene_save":
+		return h.handleSceneSave(req.Name, req.Targets)
+	case "scene_recall":
+		return h.handleSceneRecall(req.Name, time.Duration(req.FadeMs)*time.Millisecond)
+	case "scene_delete":
+		return h.handleSceneDelete(req.Name)
+	case "preset":
+		return h.handlePreset(req.Target, req.Name, time.Duration(req.FadeMs)*time.Millisecond)
+	case "presets":
+		return &Response{Type: "presets", Data: h.state.Presets()}
+	case "snapshot":
+		return &Response{Type: "ok", Data: map[string]int{"depth": h.state.Snapshot()}}
+	case "undo":
+		return h.handleUndo(req.Steps)
+	case "release":
+		h.src.Release()
+		return &Response{Type: "ok"}
+	case "cues":
+		return &Response{Type: "cues", Data: h.state.CueLists()}
+	case "cue_go", "cue_back", "cue_goto":
+		return h.handleCue(req.Cmd, req.Name, req.Cue)
+	case "show":
+		return h.handleShow(req.Action, req.Name, req.Show)
+	case "record_start":
+		return h.handleRecorder(req.Cmd, req.Name, h.state.StartRecording(req.Name))
+	case "record_stop":
+		frames, err := h.state.StopRecording()
+		if err != nil {
+			return h.handleRecorder(req.Cmd, "", err)
+		}
+		metrics.CommandsTotal.WithLabelValues(req.Cmd).Inc()
+		return &Response{Type: "ok", Data: map[string]int{"frames": frames}}
+	case "play":
+		return h.handleRecorder(req.Cmd, req.Name, h.state.Play(req.Name, req.Loop))
+	case "play_stop":
+		return h.handleRecorder(req.Cmd, "", h.state.StopPlayback())
+	case "recordings":
+		status, err := h.state.Recorder()
+		if err != nil {
+			return errorResponse(err, "")
+		}
+		return &Response{Type: "recordings", Data: status}
+	case "effect_start":
+		return h.handleEffectStart(req.Target, req.Effect)
+	case "effect_stop":
+		return h.handleEffectStop(req.Target)
+	case "effects":
+		return &Response{Type: "effects", Data: h.state.Effects()}
+	case "crossfade":
+		return &Response{Type: "crossfade", Data: h.state.Crossfade()}
+	case "crossfade_abort":
+		if !h.state.AbortCrossfade() {
+			return failResponse(CodeConflict, "", "no crossfade in progress")
+		}
+		metrics.CommandsTotal.WithLabelValues("crossfade_abort").Inc()
+		return &Response{Type: "ok"}
+	case "locate":
+		if err := h.src.Locate(req.Target, time.Duration(req.DurationMs)*time.Millisecond); err != nil {
+			metrics.ErrorsTotal.WithLabelValues("locate").Inc()
+			return errorResponse(err, req.Target)
+		}
+		metrics.CommandsTotal.WithLabelValues("locate").Inc()
+		return &Response{Type: "ok", Target: req.Target}
+	case "park":
+		return h.handlePark(req)
+	case "unpark":
+		h.state.Unpark(req.Channels)
+		metrics.CommandsTotal.WithLabelValues("unpark").Inc()
+		return &Response{Type: "ok"}
+	case "parked":
+		return &Response{Type: "parked", Data: h.state.Parked()}
+	case "freeze":
+		h.state.Freeze()
+		metrics.CommandsTotal.WithLabelValues("freeze").Inc()
+		return &Response{Type: "ok"}
+	case "unfreeze":
+		if err := h.state.Unfreeze(); err != nil {
+			metrics.ErrorsTotal.WithLabelValues("unfreeze").Inc()
+			return errorResponse(err, "")
+		}
+		metrics.CommandsTotal.WithLabelValues("unfreeze").Inc()
+		return &Response{Type: "ok"}
+	case "variables":
+		if req.Name == "" {
+			return &Response{Type: "variables", Data: h.state.Variables()}
+		}
+		v, ok := h.state.Variable(req.Name)
+		if !ok {
+			return errorResponse(dmx.ErrVariableNotFound, req.Name)
+		}
+		return &Response{Type: "variable", Target: req.Name, Data: v}
+	case "history":
+		return &Response{Type: "history", Target: req.Target, Data: h.state.History(dmx.HistoryQuery{Limit: req.Limit, Target: req.Target})}
+	case "mask", "unmask":
+		return h.handleMask(req)
+	case "masked":
+		return &Response{Type: "masked", Data: h.state.Masked()}
 	default:
-		return &Response{Type: "error", Error: "unknown command: " + req.Cmd}
+		return failResponse(CodeBadRequest, "", "unknown command: "+req.Cmd)
 	}
 }
 
 // HandleJSON parses JSON and returns JSON response
 func (h *Handler) HandleJSON(data []byte) []byte {
+	out, _ := json.Marshal(h.HandleData(data, ""))
+	return out
+}
+
+// HandleData parses a JSON request and returns the response (HTTP maps its error to a
+// status). requestID is used when the request has no request_id (e.g. from a header).
+func (h *Handler) HandleData(data []byte, requestID string) *Response {
 	var req Request
 	if err := json.Unmarshal(data, &req); err != nil {
-		resp := &Response{Type: "error", Error: "invalid JSON: " + err.Error()}
-		out, _ := json.Marshal(resp)
-		return out
+		resp := failResponse(CodeBadRequest, "", "invalid JSON: "+err.Error())
+		resp.RequestID = requestID
+		return resp
 	}
-	resp := h.Handle(&req)
-	out, _ := json.Marshal(resp)
-	return out
+	if req.RequestID == "" {
+		req.RequestID = requestID
+	}
+	return h.Handle(&req)
 }
 
 // Pre-allocated response data (avoid allocations for common responses)
@@ -81,9 +281,9 @@ var (
 )
 
 func (h *Handler) handleEnable() *Response {
-	if err := h.state.Enable(); err != nil {
+	if err := h.src.Enable(); err != nil {
 		metrics.ErrorsTotal.WithLabelValues("enable").Inc()
-		return &Response{Type: "error", Error: err.Error()}
+		return errorResponse(err, "")
 	}
 	metrics.SetEnabled(true)
 	metrics.CommandsTotal.WithLabelValues("enable").Inc()
@@ -91,9 +291,9 @@ func (h *Handler) handleEnable() *Response {
 }
 
 func (h *Handler) handleDisable() *Response {
-	if err := h.state.Disable(); err != nil {
+	if err := h.src.Disable(); err != nil {
 		metrics.ErrorsTotal.WithLabelValues("disable").Inc()
-		return &Response{Type: "error", Error: err.Error()}
+		return errorResponse(err, "")
 	}
 	metrics.SetEnabled(false)
 	metrics.CommandsTotal.WithLabelValues("disable").Inc()
@@ -101,20 +301,20 @@ func (h *Handler) handleDisable() *Response {
 }
 
 func (h *Handler) handleBlackout() *Response {
-	if err := h.state.Blackout(); err != nil {
+	if err := h.src.Blackout(); err != nil {
 		metrics.ErrorsTotal.WithLabelValues("blackout").Inc()
-		return &Response{Type: "error", Error: err.Error()}
+		return errorResponse(err, "")
 	}
 	metrics.CommandsTotal.WithLabelValues("blackout").Inc()
 	return &Response{Type: "ok"}
 }
 
-func (h *Handler) handleSet(target string, values map[string]uint8) *Response {
+func (h *Handler) handleSet(target string, values map[string]uint8, fade time.Duration) *Response {
 	if target == "" {
-		return &Response{Type: "error", Error: "target required"}
+		return failResponse(CodeBadRequest, "", "target required")
 	}
 	if len(values) == 0 {
-		return &Response{Type: "error", Error: "values required"}
+		return failResponse(CodeBadRequest, "", "values required")
 	}
 
 	group, light := parseTarget(target)
@@ -122,15 +322,15 @@ func (h *Handler) handleSet(target string, values map[string]uint8) *Response {
 	var err error
 	if light == "" {
 		// Set entire group
-		err = h.state.SetGroup(group, values)
+		err = h.src.FadeGroup(group, values, fade)
 	} else {
 		// Set specific light
-		err = h.state.SetLight(group, light, values)
+		err = h.src.FadeLight(group, light, values, fade)
 	}
 
 	if err != nil {
 		metrics.ErrorsTotal.WithLabelValues("set").Inc()
-		return &Response{Type: "error", Target: target, Error: err.Error()}
+		return errorResponse(err, target)
 	}
 
 	metrics.CommandsTotal.WithLabelValues("set").Inc()
@@ -141,6 +341,80 @@ func (h *Handler) handleSet(target string, values map[string]uint8) *Response {
 	return &Response{Type: "ok", Target: target}
 }
 
+// handleSetMulti applies several targets as one change (nothing is applied if one is invalid)
+func (h *Handler) handleSetMulti(items []dmx.TargetValues) *Response {
+	if err := h.src.SetMulti(items); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("set_multi").Inc()
+		return errorResponse(err, "")
+	}
+
+	metrics.CommandsTotal.WithLabelValues("set_multi").Inc()
+	for _, item := range items {
+		values, _ := dmx.PercentValues(item.ValuesPct)
+		for k, v := range item.Values {
+			values[k] = v
+		}
+		h.updateChannelMetrics(item.Target, values)
+	}
+	return &Response{Type: "ok"}
+}
+
+// mergePercent converts values_pct into the request's 0-255 values (explicit values win)
+func mergePercent(req *Request) error {
+	values, err := dmx.PercentValues(req.ValuesPct)
+	if err != nil {
+		return err
+	}
+	for k, v := range req.Values {
+		values[k] = v
+	}
+	req.Values = values
+	return nil
+}
+
+// handleSetColor converts hex/HSV to red/green/blue values (explicit values for other channels are kept)
+func (h *Handler) handleSetColor(req *Request) *Response {
+	rgb, err := colorValues(req)
+	if err != nil {
+		return errorResponse(err, req.Target)
+	}
+	if !h.hasRGB(req.Target) {
+		return failResponse(CodeBadRequest, req.Target, "no light with red/green/blue channels")
+	}
+
+	values := make(map[string]uint8, len(req.Values)+len(rgb))
+	for k, v := range req.Values {
+		values[k] = v
+	}
+	for k, v := range rgb {
+		values[k] = v
+	}
+	return h.handleSet(req.Target, values, time.Duration(req.FadeMs)*time.Millisecond)
+}
+
+// handleSet16 sets 16-bit values (8-bit channels receive the high byte)
+func (h *Handler) handleSet16(target string, values map[string]uint16, fade time.Duration) *Response {
+	if target == "" {
+		return failResponse(CodeBadRequest, "", "target required")
+	}
+
+	group, light := parseTarget(target)
+
+	var err error
+	if light == "" {
+		err = h.src.FadeGroup16(group, values, fade)
+	} else {
+		err = h.src.FadeLight16(group, light, values, fade)
+	}
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues("set").Inc()
+		return errorResponse(err, target)
+	}
+
+	metrics.CommandsTotal.WithLabelValues("set").Inc()
+	return &Response{Type: "ok", Target: target}
+}
+
 func (h *Handler) handleGet(target string) *Response {
 	if target == "" {
 		// Return all lights (zero allocation - returns pre-allocated map)
@@ -153,7 +427,7 @@ func (h *Handler) handleGet(target string) *Response {
 		// Get all lights in group - build minimal response
 		lights := h.state.GetConfig().GetGroupLights(group)
 		if lights == nil {
-			return &Response{Type: "error", Target: target, Error: "group not found"}
+			return failResponse(CodeNotFound, target, "group not found")
 		}
 		// Only allocate the result map (lights themselves are pre-allocated)
 		result := make(map[string]*dmx.LightState, len(lights))
@@ -167,7 +441,7 @@ func (h *Handler) handleGet(target string) *Response {
 	// Get specific light (zero allocation - returns pre-allocated struct)
 	data := h.state.GetLight(group, light)
 	if data == nil {
-		return &Response{Type: "error", Target: target, Error: "light not found"}
+		return failResponse(CodeNotFound, target, "light not found")
 	}
 	return &Response{Type: "light", Target: target, Data: data}
 }
@@ -193,6 +467,191 @@ func (h *Handler) handleGroups() *Response {
 	return &Response{Type: "groups", Data: h.state.GetGroups()}
 }
 
+func (h *Handler) handleSceneSave(name string, targets []string) *Response {
+	sc, err := h.src.SaveScene(name, targets)
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues("scene_save").Inc()
+		return errorResponse(err, name)
+	}
+	metrics.CommandsTotal.WithLabelValues("scene_save").Inc()
+	return &Response{Type: "scene", Target: name, Data: sc}
+}
+
+func (h *Handler) handleSceneRecall(name string, fade time.Duration) *Response {
+	if err := h.src.RecallScene(name, fade); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("scene_recall").Inc()
+		return errorResponse(err, name)
+	}
+	metrics.CommandsTotal.WithLabelValues("scene_recall").Inc()
+	return &Response{Type: "ok", Target: name}
+}
+
+func (h *Handler) handleSceneDelete(name string) *Response {
+	if err := h.state.DeleteScene(name); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("scene_delete").Inc()
+		return errorResponse(err, name)
+	}
+	metrics.CommandsTotal.WithLabelValues("scene_delete").Inc()
+	return &Response{Type: "ok", Target: name}
+}
+
+func (h *Handler) handleCCT(target string, cct int, brightness *uint8, fade time.Duration) *Response {
+	level := -1
+	if brightness != nil {
+		level = int(*brightness)
+	}
+	if err := h.src.SetCCT(target, cct, level, fade); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("set").Inc()
+		return errorResponse(err, target)
+	}
+	metrics.CommandsTotal.WithLabelValues("set").Inc()
+	return &Response{Type: "ok", Target: target}
+}
+
+func (h *Handler) handlePreset(target, name string, fade time.Duration) *Response {
+	if err := h.src.RecallPreset(target, name, fade); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("preset").Inc()
+		return errorResponse(err, target)
+	}
+	metrics.CommandsTotal.WithLabelValues("preset").Inc()
+	return &Response{Type: "ok", Target: target}
+}
+
+func (h *Handler) handleUndo(steps int) *Response {
+	undone, err := h.src.Undo(steps)
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues("undo").Inc()
+		return errorResponse(err, "")
+	}
+	metrics.CommandsTotal.WithLabelValues("undo").Inc()
+	return &Response{Type: "ok", Data: map[string]int{"undone": undone, "depth": h.state.UndoDepth()}}
+}
+
+func (h *Handler) handleCue(cmd, list string, number int) *Response {
+	var err error
+	switch cmd {
+	case "cue_go":
+		number, err = h.src.CueGo(list)
+	case "cue_back":
+		number, err = h.src.CueBack(list)
+	default:
+		err = h.src.CueGoto(list, number)
+	}
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues(cmd).Inc()
+		return errorResponse(err, list)
+	}
+	metrics.CommandsTotal.WithLabelValues(cmd).Inc()
+	return &Response{Type: "ok", Target: list, Data: map[string]int{"cue": number}}
+}
+
+func (h *Handler) handleShow(action, name string, show *config.Show) *Response {
+	var err error
+	switch action {
+	case "", "start":
+		err = h.state.StartShow(name)
+	case "stop":
+		err = h.state.StopShow()
+	case "load":
+		err = h.state.LoadShow(name, show)
+	case "status":
+		return &Response{Type: "show", Data: h.state.Shows()}
+	default:
+		return failResponse(CodeBadRequest, "", "unknown show action: "+action)
+	}
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues("show").Inc()
+		return errorResponse(err, name)
+	}
+	metrics.CommandsTotal.WithLabelValues("show").Inc()
+	return &Response{Type: "ok", Target: name}
+}
+
+// handleRecorder builds the response of a recorder/player command
+func (h *Handler) handleRecorder(cmd, name string, err error) *Response {
+	if err != nil {
+		metrics.ErrorsTotal.WithLabelValues(cmd).Inc()
+		return errorResponse(err, name)
+	}
+	metrics.CommandsTotal.WithLabelValues(cmd).Inc()
+	return &Response{Type: "ok", Target: name}
+}
+
+func (h *Handler) handleEffectStart(target string, params *dmx.EffectParams) *Response {
+	if params == nil {
+		return failResponse(CodeBadRequest, target, "effect parameters required")
+	}
+	p := *params
+	if target != "" {
+		p.Target = target
+	}
+	if err := h.src.StartEffect(p); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("effect_start").Inc()
+		return errorResponse(err, p.Target)
+	}
+	metrics.CommandsTotal.WithLabelValues("effect_start").Inc()
+	return &Response{Type: "ok", Target: p.Target}
+}
+
+func (h *Handler) handleEffectStop(target string) *Response {
+	if err := h.state.StopEffect(target); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("effect_stop").Inc()
+		return errorResponse(err, target)
+	}
+	metrics.CommandsTotal.WithLabelValues("effect_stop").Inc()
+	return &Response{Type: "ok", Target: target}
+}
+
+// handlePark parks DMX channels (park) or a target's named channels (target + values)
+func (h *Handler) handlePark(req *Request) *Response {
+	values := make(map[int]uint8, len(req.Park))
+	for ch, v := range req.Park {
+		values[ch] = v
+	}
+	if req.Target != "" {
+		cfg := h.state.GetConfig()
+		group, light := parseTarget(req.Target)
+		if !cfg.HasTarget(req.Target) {
+			return failResponse(CodeNotFound, req.Target, "target not found")
+		}
+		lights := []string{light}
+		if light == "" {
+			lights = cfg.GetGroupLights(group)
+		}
+		for _, name := range lights {
+			for _, ch := range cfg.GetLight(group, name) {
+				if v, ok := req.Values[ch.Name]; ok {
+					values[ch.Ch] = v
+				}
+			}
+		}
+	}
+	if len(values) == 0 {
+		return failResponse(CodeBadRequest, req.Target, "park or target values required")
+	}
+
+	if err := h.state.Park(values); err != nil {
+		metrics.ErrorsTotal.WithLabelValues("park").Inc()
+		return errorResponse(err, req.Target)
+	}
+	metrics.CommandsTotal.WithLabelValues("park").Inc()
+	return &Response{Type: "ok", Target: req.Target}
+}
+
+// handleMask takes a light out of service (mask) or back in service (unmask)
+func (h *Handler) handleMask(req *Request) *Response {
+	group, light := parseTarget(req.Target)
+	if light == "" {
+		return failResponse(CodeBadRequest, req.Target, "light target required (group/light)")
+	}
+	if err := h.state.Mask(group, light, req.Cmd == "mask"); err != nil {
+		metrics.ErrorsTotal.WithLabelValues(req.Cmd).Inc()
+		return errorResponse(err, req.Target)
+	}
+	metrics.CommandsTotal.WithLabelValues(req.Cmd).Inc()
+	return &Response{Type: "ok", Target: req.Target}
+}
+
 // parseTarget splits "group/light" or returns (group, "")
 func parseTarget(target string) (group, light string) {
 	parts := strings.SplitN(target, "/", 2)
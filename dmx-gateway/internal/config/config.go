@@ -5,14 +5,42 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"dmx-gateway/internal/cron"
+)
+
+// Color temperature range accepted for channels and curves (Kelvin)
+const (
+	minCCT = 1000
+	maxCCT = 20000
 )
 
 // Load reads and parses the configuration file
 func Load(path string) (*Config, error) {
+	return load(path, false)
+}
+
+// LoadStrict reads the configuration file as Load, its warnings being errors
+func LoadStrict(path string) (*Config, error) {
+	return load(path, true)
+}
+
+// Reload reads the file c was loaded from again, strict if c is
+func (c *Config) Reload() (*Config, error) {
+	return load(c.path, c.strict)
+}
+
+func load(path string, strict bool) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read config file: %w", err)
@@ -24,14 +52,120 @@ func Load(path string) (*Config, error) {
 	}
 
 	cfg.applyDefaults()
+	cfg.strict = strict
 
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("validate config: %w", err)
 	}
 
+	cfg.path = path
 	return &cfg, nil
 }
 
+// Path returns the file the config was loaded from (empty if not loaded from a file)
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Clone returns a copy whose lights can be changed without affecting c
+// Other sections are shared and must be treated as read-only.
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Lights = make(map[string]map[string][]Channel, len(c.Lights))
+	for group, lights := range c.Lights {
+		clone.Lights[group] = make(map[string][]Channel, len(lights))
+		for name, channels := range lights {
+			clone.Lights[group][name] = slices.Clone(channels)
+		}
+	}
+	return &clone
+}
+
+// redactedValue replaces secrets in Redacted output
+const redactedValue = "[redacted]"
+
+// Redacted returns the config as a generic document (config file keys) with secrets
+// (API keys, JWT secret, MQTT password, webhook headers) replaced, for display over the API
+func (c *Config) Redacted() (map[string]any, error) {
+	clone := *c.stored()
+	if a := c.Auth; a != nil {
+		auth := *a
+		auth.Keys = slices.Clone(a.Keys)
+		for i := range auth.Keys {
+			auth.Keys[i].Key = redactedValue
+		}
+		if a.JWT != nil {
+			jwt := *a.JWT
+			jwt.Secret = redactedValue
+			auth.JWT = &jwt
+		}
+		clone.Auth = &auth
+	}
+	if m := c.MQTT; m != nil {
+		mqtt := *m
+		if mqtt.Password != "" {
+			mqtt.Password = redactedValue
+		}
+		mqtt.Brokers = slices.Clone(m.Brokers)
+		for i := range mqtt.Brokers {
+			if mqtt.Brokers[i].Password != "" {
+				mqtt.Brokers[i].Password = redactedValue
+			}
+		}
+		clone.MQTT = &mqtt
+	}
+	if len(c.Webhooks) > 0 {
+		clone.Webhooks = slices.Clone(c.Webhooks)
+		for i, wh := range clone.Webhooks {
+			if len(wh.Headers) > 0 {
+				headers := make(map[string]string, len(wh.Headers))
+				for k := range wh.Headers {
+					headers[k] = redactedValue
+				}
+				clone.Webhooks[i].Headers = headers
+			}
+		}
+	}
+
+	data, err := yaml.Marshal(&clone)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return stringKeys(doc).(map[string]any), nil
+}
+
+// stringKeys converts YAML maps with non-string keys (park channels) to string-keyed maps
+func stringKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = stringKeys(e)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+	}
+	return v
+}
+
+// SaveLights rewrites the lights section of the config file
+// Other sections and their comments are kept; comments inside lights are lost.
+func (c *Config) SaveLights() error {
+	return c.Save([]string{"lights"})
+}
+
 // applyDefaults sets default values for missing config
 func (c *Config) applyDefaults() {
 	if c.Server.HTTP == "" {
@@ -46,6 +180,139 @@ func (c *Config) applyDefaults() {
 	if c.DMX.TimeoutMs == 0 {
 		c.DMX.TimeoutMs = 500
 	}
+	if c.DMX.StatusPollMs == 0 {
+		c.DMX.StatusPollMs = 1000
+	}
+	if c.DMX.WatchdogFailures == 0 {
+		c.DMX.WatchdogFailures = 5
+	}
+	if c.DMX.Backend == "" {
+		c.DMX.Backend = "rpmsg"
+	}
+	if c.Persist != nil && c.Persist.IntervalSec == 0 {
+		c.Persist.IntervalSec = 10
+	}
+	if c.DMX.Failover != nil && c.DMX.Failover.AfterSec == 0 {
+		c.DMX.Failover.AfterSec = 10
+	}
+	if c.DMX.PowerUp != nil && c.DMX.PowerUp.GroupDelayMs == 0 {
+		c.DMX.PowerUp.GroupDelayMs = 500
+	}
+	if c.Server.WSPingSec == 0 {
+		c.Server.WSPingSec = 30
+	}
+	if rl := c.Server.RateLimit; rl != nil {
+		if rl.Burst == 0 {
+			rl.Burst = max(1, int(2*rl.RPS))
+		}
+		if rl.WSBurst == 0 {
+			rl.WSBurst = max(1, int(2*rl.WSRPS))
+		}
+	}
+	if c.Auth != nil && c.Auth.WSGraceSec == 0 {
+		c.Auth.WSGraceSec = 5
+	}
+	if c.Auth != nil && c.Auth.JWT != nil && c.Auth.JWT.RoleClaim == "" {
+		c.Auth.JWT.RoleClaim = "role"
+	}
+	if c.Auth != nil {
+		for i := range c.Auth.Keys {
+			if c.Auth.Keys[i].Scope == "" {
+				c.Auth.Keys[i].Scope = "write"
+			}
+		}
+	}
+	for i := range c.Webhooks {
+		wh := &c.Webhooks[i]
+		if wh.Retries == 0 {
+			wh.Retries = 3
+		}
+		if wh.BackoffMs == 0 {
+			wh.BackoffMs = 1000
+		}
+		if wh.TimeoutMs == 0 {
+			wh.TimeoutMs = 5000
+		}
+	}
+	if c.Schedule != nil {
+		// Events without an id get the lowest free number
+		used := make(map[string]bool, len(c.Schedule.Events))
+		for _, e := range c.Schedule.Events {
+			used[e.ID] = true
+		}
+		n := 1
+		for i := range c.Schedule.Events {
+			if c.Schedule.Events[i].ID != "" {
+				continue
+			}
+			for used[strconv.Itoa(n)] {
+				n++
+			}
+			c.Schedule.Events[i].ID = strconv.Itoa(n)
+			used[strconv.Itoa(n)] = true
+		}
+	}
+	if c.Modbus != nil && c.Modbus.Map != nil {
+		m := c.Modbus.Map
+		if m.Scene == nil {
+			scene := m.Channels + 512
+			m.Scene = &scene
+		}
+		if m.Fade == nil {
+			fade := *m.Scene + 1
+			m.Fade = &fade
+		}
+	}
+	if c.Modbus != nil {
+		for i := range c.Modbus.Poll {
+			d := &c.Modbus.Poll[i]
+			if d.UnitID == 0 {
+				d.UnitID = 1
+			}
+			if d.IntervalMs == 0 {
+				d.IntervalMs = 5000
+			}
+			if d.TimeoutMs == 0 {
+				d.TimeoutMs = 1000
+			}
+		}
+	}
+	if c.Modbus != nil && c.Modbus.RTU != nil {
+		rtu := c.Modbus.RTU
+		if rtu.Baud == 0 {
+			rtu.Baud = 19200
+		}
+		if rtu.Parity == "" {
+			rtu.Parity = "E"
+		}
+		if rtu.StopBits == 0 {
+			rtu.StopBits = 1
+			if rtu.Parity == "N" {
+				rtu.StopBits = 2 // Keeps the 11-bit character of the RTU spec
+			}
+		}
+		if rtu.UnitID == 0 {
+			rtu.UnitID = 1
+		}
+	}
+	if c.Schedule != nil && c.Schedule.Photoperiod != nil && c.Schedule.Photoperiod.IntervalSec == 0 {
+		c.Schedule.Photoperiod.IntervalSec = 10
+	}
+	if c.Schedule != nil && c.Schedule.Circadian != nil {
+		cc := c.Schedule.Circadian
+		if cc.WarmK == 0 {
+			cc.WarmK = 2700
+		}
+		if cc.CoolK == 0 {
+			cc.CoolK = 6500
+		}
+		if cc.Level == 0 {
+			cc.Level = 255
+		}
+		if cc.IntervalSec == 0 {
+			cc.IntervalSec = 10
+		}
+	}
 }
 
 // Validate checks the configuration for errors
@@ -54,6 +321,80 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("no lights defined")
 	}
 
+	if c.DMX.FPS < 0 || c.DMX.FPS > 44 {
+		return fmt.Errorf("dmx fps %d out of range (1-44, 0 = firmware default)", c.DMX.FPS)
+	}
+
+	if err := c.validateBackend(c.DMX.Backend); err != nil {
+		return err
+	}
+	if f := c.DMX.Failover; f != nil {
+		if f.Backend == "" || f.Backend == c.DMX.Backend {
+			return fmt.Errorf("failover: backend must differ from primary %q", c.DMX.Backend)
+		}
+		if err := c.validateBackend(f.Backend); err != nil {
+			return fmt.Errorf("failover: %w", err)
+		}
+		if f.AfterSec < 0 {
+			return fmt.Errorf("failover: after_sec must be positive")
+		}
+	}
+	for _, origin := range c.Server.AllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			return fmt.Errorf("server allowed_origins: %q is not an origin (scheme://host[:port])", origin)
+		}
+	}
+	if rl := c.Server.RateLimit; rl != nil && (rl.RPS <= 0 || rl.WSRPS < 0 || rl.Burst < 1 || rl.WSBurst < 1) {
+		return fmt.Errorf("server rate_limit: rps must be positive, ws_rps and bursts not negative")
+	}
+	if c.Server.WSPingSec < 0 || c.Server.WSIdleSec < 0 {
+		return fmt.Errorf("server ws_ping_sec and ws_idle_sec must not be negative")
+	}
+	if t := c.Server.TLS; t != nil && (t.Cert == "" || t.Key == "") {
+		return fmt.Errorf("server tls: cert and key required")
+	}
+	if c := c.Server.Compression; c != "" && c != "gzip" && c != "off" {
+		return fmt.Errorf("server compression %q: gzip or off", c)
+	}
+	if c.History != nil && c.History.Size < 0 {
+		return fmt.Errorf("history: size must be positive")
+	}
+	for i, wh := range c.Webhooks {
+		if u, err := url.Parse(wh.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %d: http(s) url required", i+1)
+		}
+		for _, event := range wh.Events {
+			if !slices.Contains(WebhookEvents, event) {
+				return fmt.Errorf("webhook %d: unknown event %q (%s)", i+1, event, strings.Join(WebhookEvents, ", "))
+			}
+		}
+		if wh.Retries < 0 || wh.BackoffMs < 0 || wh.TimeoutMs < 0 {
+			return fmt.Errorf("webhook %d: retries, backoff_ms and timeout_ms must be positive", i+1)
+		}
+	}
+	if p := c.DMX.PowerUp; p != nil {
+		if p.GroupDelayMs < 0 {
+			return fmt.Errorf("power_up: group_delay_ms must be positive")
+		}
+		for _, group := range p.Order {
+			if _, ok := c.Lights[group]; !ok {
+				return fmt.Errorf("power_up: unknown group %q", group)
+			}
+		}
+	}
+	if c.DMX.ArtNet != nil {
+		if c.DMX.ArtNet.Address == "" {
+			return fmt.Errorf("artnet: address required")
+		}
+		if c.DMX.ArtNet.Universe < 0 || c.DMX.ArtNet.Universe > 32767 {
+			return fmt.Errorf("artnet: universe %d out of range (0-32767)", c.DMX.ArtNet.Universe)
+		}
+	}
+
+	if err := c.expandProfiles(); err != nil {
+		return err
+	}
+
 	usedChannels := make(map[int]string)
 
 	for groupName, lights := range c.Lights {
@@ -68,6 +409,9 @@ func (c *Config) Validate() error {
 			}
 
 			for _, ch := range channels {
+				if ch.Alias {
+					continue // Checked once every owner is known
+				}
 				if ch.Ch < 1 || ch.Ch > 512 {
 					return fmt.Errorf("light %q: channel %d out of range (1-512)", fullName, ch.Ch)
 				}
@@ -76,14 +420,787 @@ func (c *Config) Validate() error {
 					return fmt.Errorf("light %q: channel %d missing color", fullName, ch.Ch)
 				}
 
+				if ch.Max != 0 && ch.Min > ch.Max {
+					return fmt.Errorf("light %q: channel %d min %d above max %d", fullName, ch.Ch, ch.Min, ch.Max)
+				}
+
+				if ch.CCT != 0 && (ch.CCT < minCCT || ch.CCT > maxCCT) {
+					return fmt.Errorf("light %q: channel %d cct %d out of range (%d-%d)", fullName, ch.Ch, ch.CCT, minCCT, maxCCT)
+				}
+
+				if _, err := ch.OutputCurve(); err != nil {
+					return fmt.Errorf("light %q: channel %d: %w", fullName, ch.Ch, err)
+				}
+
 				if existing, ok := usedChannels[ch.Ch]; ok {
 					return fmt.Errorf("channel %d used by both %q and %q", ch.Ch, existing, fullName)
 				}
 				usedChannels[ch.Ch] = fullName
+
+				if ch.FineCh != 0 {
+					if ch.FineCh < 1 || ch.FineCh > 512 {
+						return fmt.Errorf("light %q: fine channel %d out of range (1-512)", fullName, ch.FineCh)
+					}
+					if existing, ok := usedChannels[ch.FineCh]; ok {
+						return fmt.Errorf("channel %d used by both %q and %q", ch.FineCh, existing, fullName)
+					}
+					usedChannels[ch.FineCh] = fullName
+				}
+			}
+		}
+	}
+
+	if err := c.resolveAliases(usedChannels); err != nil {
+		return err
+	}
+
+	if err := c.validateArbitration(); err != nil {
+		return fmt.Errorf("arbitration: %w", err)
+	}
+	if err := c.validateAuth(); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+
+	if p := c.Persist; p != nil {
+		if p.File == "" {
+			return fmt.Errorf("persist: file required")
+		}
+		if p.IntervalSec < 0 {
+			return fmt.Errorf("persist: interval_sec must be positive")
+		}
+	}
+
+	for name, show := range c.Shows {
+		if err := c.ValidateShow(show); err != nil {
+			return fmt.Errorf("shows: %s: %w", name, err)
+		}
+	}
+
+	if c.Schedule != nil {
+		ids := make(map[string]bool, len(c.Schedule.Events))
+		for i, e := range c.Schedule.Events {
+			if e.ID != "" && ids[e.ID] {
+				return fmt.Errorf("schedule: duplicate event id %q", e.ID)
+			}
+			ids[e.ID] = true
+			if e.Cron != "" {
+				if e.Time != "" || len(e.Days) > 0 {
+					return fmt.Errorf("schedule: event %d: cron excludes time and days", i+1)
+				}
+				sched, err := cron.Parse(e.Cron)
+				if err != nil {
+					return fmt.Errorf("schedule: event %d: %w", i+1, err)
+				}
+				if sched.Next(time.Now()).IsZero() {
+					return fmt.Errorf("schedule: event %d: cron %q never runs", i+1, e.Cron)
+				}
+			} else if _, err := time.Parse("15:04", e.Time); err != nil {
+				if _, err := time.Parse("15:04:05", e.Time); err != nil {
+					return fmt.Errorf("schedule: event %d: invalid time %q (HH:MM or HH:MM:SS)", i+1, e.Time)
+				}
+			}
+			for _, d := range e.Days {
+				if !slices.Contains(Weekdays, d) {
+					return fmt.Errorf("schedule: event %d: unknown day %q (%s)", i+1, d, strings.Join(Weekdays, ", "))
+				}
+			}
+			if e.Jitter != "" {
+				if e.Cron != "" {
+					return fmt.Errorf("schedule: event %d: jitter applies to time events", i+1)
+				}
+				if d, err := time.ParseDuration(e.Jitter); err != nil || d < 0 || d > 12*time.Hour {
+					return fmt.Errorf("schedule: event %d: jitter %q: duration up to 12h (\"30m\")", i+1, e.Jitter)
+				}
+			}
+			for j, cond := range e.When {
+				if err := c.validateCondition(cond); err != nil {
+					return fmt.Errorf("schedule: event %d: when %d: %w", i+1, j+1, err)
+				}
+			}
+			if e.Holiday != "" && !slices.Contains(HolidayModes, e.Holiday) {
+				return fmt.Errorf("schedule: event %d: holiday %q (%s)", i+1, e.Holiday, strings.Join(HolidayModes, ", "))
+			}
+			if e.Timezone != "" {
+				if _, err := c.Schedule.EventLocation(e.Timezone); err != nil {
+					return fmt.Errorf("schedule: event %d: timezone %q: %w", i+1, e.Timezone, err)
+				}
+			}
+		}
+		for name, tz := range c.Schedule.Zones {
+			if _, err := time.LoadLocation(tz); err != nil {
+				return fmt.Errorf("schedule: zone %s: %w", name, err)
+			}
+		}
+		for i, h := range c.Schedule.Holidays {
+			if err := validateHoliday(h); err != nil {
+				return fmt.Errorf("schedule: holiday %d: %w", i+1, err)
+			}
+		}
+	}
+
+	if c.Schedule != nil && c.Schedule.Circadian != nil {
+		if err := c.validateCircadian(c.Schedule.Circadian); err != nil {
+			return fmt.Errorf("circadian: %w", err)
+		}
+	}
+	if c.Schedule != nil && c.Schedule.Photoperiod != nil {
+		if err := c.validatePhotoperiod(c.Schedule.Photoperiod); err != nil {
+			return fmt.Errorf("photoperiod: %w", err)
+		}
+	}
+
+	for ch := range c.Park {
+		if ch < 1 || ch > 512 {
+			return fmt.Errorf("park: channel %d out of range (1-512)", ch)
+		}
+	}
+
+	if m := c.Modbus; m != nil && m.RTU != nil {
+		rtu := m.RTU
+		if rtu.Device == "" {
+			return fmt.Errorf("modbus rtu: device required")
+		}
+		if rtu.Baud < 0 {
+			return fmt.Errorf("modbus rtu: baud must be positive")
+		}
+		if rtu.Parity != "" && rtu.Parity != "N" && rtu.Parity != "E" && rtu.Parity != "O" {
+			return fmt.Errorf("modbus rtu: parity %q: N, E or O", rtu.Parity)
+		}
+		if rtu.StopBits < 0 || rtu.StopBits > 2 {
+			return fmt.Errorf("modbus rtu: stop_bits %d: 1 or 2", rtu.StopBits)
+		}
+		if rtu.UnitID > 247 {
+			return fmt.Errorf("modbus rtu: unit_id %d out of range (1-247)", rtu.UnitID)
+		}
+	}
+
+	if c.Modbus != nil {
+		for i, coil := range c.Modbus.Coils {
+			if !c.HasTarget(coil.Target) {
+				return fmt.Errorf("modbus coils: coil %d: unknown target %q", i+2, coil.Target)
+			}
+			if _, ok := c.Preset(coil.Target, coil.Preset); !ok {
+				return fmt.Errorf("modbus coils: coil %d: unknown preset %q on %q", i+2, coil.Preset, coil.Target)
+			}
+			if coil.FadeMs < 0 {
+				return fmt.Errorf("modbus coils: coil %d: fade_ms must be positive", i+2)
+			}
+		}
+		if u := c.Modbus.UnitID; u > 247 && u != 255 {
+			return fmt.Errorf("modbus unit_id %d out of range (1-247 or 255, 0 = any)", u)
+		}
+		if o := c.Modbus.OtherUnits; o != "" && o != "ignore" && o != "reject" {
+			return fmt.Errorf("modbus other_units %q: ignore or reject", o)
+		}
+		if sc := c.Modbus.Scale; sc != "" && sc != "raw" && sc != "percent" {
+			return fmt.Errorf("modbus scale %q: raw or percent", sc)
+		}
+		if err := c.validateModbusMap(c.Modbus); err != nil {
+			return fmt.Errorf("modbus map: %w", err)
+		}
+		for i, d := range c.Modbus.Poll {
+			if err := validateModbusDevice(d); err != nil {
+				return fmt.Errorf("modbus poll: device %d: %w", i+1, err)
+			}
+		}
+	}
+
+	if c.MQTT != nil {
+		seen := make(map[[2]string]bool)
+		for i, m := range c.MQTT.Connections() {
+			name := "mqtt"
+			if i > 0 {
+				name = fmt.Sprintf("mqtt: brokers[%d]", i-1)
+				if m.Broker == "" {
+					return fmt.Errorf("%s: broker required", name)
+				}
+				if len(c.MQTT.Brokers[i-1].Brokers) > 0 {
+					return fmt.Errorf("%s: nested brokers not allowed", name)
+				}
+			}
+			id := [2]string{m.Broker, m.ClientID}
+			if seen[id] {
+				return fmt.Errorf("%s: duplicate connection to %s (set a different client_id)", name, m.Broker)
+			}
+			seen[id] = true
+			if err := c.validateMQTT(&m); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	// A variable has one writer: an MQTT input or a polled Modbus point
+	names := make(map[string]bool)
+	if c.Modbus != nil {
+		for _, d := range c.Modbus.Poll {
+			for _, p := range d.Points {
+				if names[p.Name] {
+					return fmt.Errorf("modbus poll: duplicate variable %q", p.Name)
+				}
+				names[p.Name] = true
+			}
+		}
+	}
+	if c.MQTT != nil {
+		for _, m := range c.MQTT.Connections() {
+			for _, in := range m.Inputs {
+				if names[in.Name] {
+					return fmt.Errorf("mqtt: inputs: duplicate variable %q", in.Name)
+				}
+				names[in.Name] = true
+			}
+		}
+	}
+
+	if r := c.Recorder; r != nil && r.Dir == "" {
+		return fmt.Errorf("recorder: dir required")
+	}
+
+	if st := c.Startup; st != nil {
+		if st.FadeMs < 0 {
+			return fmt.Errorf("startup: fade_ms must be positive")
+		}
+		for target, preset := range st.Preset {
+			if _, ok := c.Preset(target, preset); !ok {
+				return fmt.Errorf("startup: unknown preset %q on %q", preset, target)
+			}
+		}
+		for target := range st.Set {
+			if !c.HasTarget(target) {
+				return fmt.Errorf("startup: unknown target %q", target)
 			}
 		}
 	}
 
+	for list, cues := range c.Cues {
+		if len(cues) == 0 {
+			return fmt.Errorf("cues: list %q is empty", list)
+		}
+		for i, cue := range cues {
+			if cue.Scene == "" {
+				return fmt.Errorf("cues: %s cue %d: scene required", list, i+1)
+			}
+			if cue.FadeMs < 0 || cue.FollowMs < 0 {
+				return fmt.Errorf("cues: %s cue %d: fade_ms and follow_ms must be positive", list, i+1)
+			}
+		}
+	}
+
+	for target, presets := range c.Presets {
+		if !c.HasTarget(target) {
+			return fmt.Errorf("presets: unknown target %q", target)
+		}
+		for name := range presets {
+			if name == "" {
+				return fmt.Errorf("presets: empty preset name on %q", target)
+			}
+		}
+	}
+
+	for tag, targets := range c.Tags {
+		if tag == "" {
+			return fmt.Errorf("tags: empty tag name")
+		}
+		for _, target := range targets {
+			if !c.HasTarget(target) {
+				return fmt.Errorf("tags: %s: unknown target %q", tag, target)
+			}
+		}
+	}
+
+	for target := range c.Metadata {
+		if !c.HasTarget(target) {
+			return fmt.Errorf("metadata: unknown target %q", target)
+		}
+	}
+
+	c.warnings = c.lint()
+	if c.strict && len(c.warnings) > 0 {
+		return fmt.Errorf("strict: %s", strings.Join(c.warnings, "; "))
+	}
+	return nil
+}
+
+// validateArbitration checks merge policies, groups, channels and source names
+func (c *Config) validateArbitration() error {
+	a := c.Arbitration
+	if a == nil {
+		return nil
+	}
+	if err := validatePolicy(a.Policy); err != nil {
+		return err
+	}
+	for group, policy := range a.Groups {
+		if _, ok := c.Lights[group]; !ok {
+			return fmt.Errorf("unknown group %q", group)
+		}
+		if err := validatePolicy(policy); err != nil {
+			return fmt.Errorf("group %q: %w", group, err)
+		}
+	}
+	for ch, policy := range a.Channels {
+		if ch < 1 || ch > 512 {
+			return fmt.Errorf("channel %d out of range (1-512)", ch)
+		}
+		if err := validatePolicy(policy); err != nil {
+			return fmt.Errorf("channel %d: %w", ch, err)
+		}
+	}
+	for source := range a.Priorities {
+		if !slices.Contains(ArbitrationSources, source) {
+			return fmt.Errorf("unknown source %q (%s)", source, strings.Join(ArbitrationSources, ", "))
+		}
+	}
+	return nil
+}
+
+// validateAuth checks API keys (set, unique, known scope) and the JWT secret
+func (c *Config) validateAuth() error {
+	a := c.Auth
+	if a == nil {
+		return nil
+	}
+	if len(a.Keys) == 0 && a.JWT == nil {
+		return fmt.Errorf("no keys or jwt defined")
+	}
+	if a.WSGraceSec < 0 {
+		return fmt.Errorf("ws_grace_sec must not be negative")
+	}
+	if a.JWT != nil && len(a.JWT.Secret) < 16 {
+		return fmt.Errorf("jwt: secret must be at least 16 characters")
+	}
+	seen := make(map[string]bool, len(a.Keys))
+	for _, k := range a.Keys {
+		if k.Key == "" {
+			return fmt.Errorf("key %q: key required", k.Name)
+		}
+		if seen[k.Key] {
+			return fmt.Errorf("key %q: duplicate key", k.Name)
+		}
+		seen[k.Key] = true
+		if k.Scope != "read" && k.Scope != "write" && k.Scope != "admin" {
+			return fmt.Errorf("key %q: unknown scope %q (read, write, admin)", k.Name, k.Scope)
+		}
+	}
+	return nil
+}
+
+func validatePolicy(policy string) error {
+	switch policy {
+	case "", "ltp", "htp":
+		return nil
+	}
+	return fmt.Errorf("unknown policy %q (ltp, htp)", policy)
+}
+
+// resolveAliases checks alias channels point at an owned channel and copies the owner's settings
+// (limits, curve, 16-bit pair, cct) so every view of a DMX channel behaves the same.
+func (c *Config) resolveAliases(owners map[int]string) error {
+	owned := make(map[int]Channel, len(owners))
+	for _, lights := range c.Lights {
+		for _, channels := range lights {
+			for _, ch := range channels {
+				if !ch.Alias {
+					owned[ch.Ch] = ch
+				}
+			}
+		}
+	}
+
+	for groupName, lights := range c.Lights {
+		for lightName, channels := range lights {
+			fullName := groupName + "/" + lightName
+			for i, ch := range channels {
+				if !ch.Alias {
+					continue
+				}
+				owner, ok := owned[ch.Ch]
+				if !ok {
+					return fmt.Errorf("light %q: alias channel %d is not owned by any light", fullName, ch.Ch)
+				}
+				if ch.Color == "" {
+					return fmt.Errorf("light %q: channel %d missing color", fullName, ch.Ch)
+				}
+				if (ch.FineCh != 0 && ch.FineCh != owner.FineCh) || (ch.Min != 0 && ch.Min != owner.Min) ||
+					(ch.Max != 0 && ch.Max != owner.Max) || (ch.Curve != "" && ch.Curve != owner.Curve) ||
+					(ch.CCT != 0 && ch.CCT != owner.CCT) {
+					return fmt.Errorf("light %q: alias channel %d inherits its settings from %q", fullName, ch.Ch, owners[ch.Ch])
+				}
+				channels[i].FineCh = owner.FineCh
+				channels[i].Min = owner.Min
+				channels[i].Max = owner.Max
+				channels[i].Curve = owner.Curve
+				channels[i].CurveTable = owner.CurveTable
+				channels[i].CCT = owner.CCT
+			}
+		}
+	}
+	return nil
+}
+
+// validateCircadian checks targets, CCT range and curve points
+func (c *Config) validateCircadian(cc *CircadianConfig) error {
+	if len(cc.Targets) == 0 {
+		return fmt.Errorf("targets required")
+	}
+	if cc.IntervalSec < 0 {
+		return fmt.Errorf("interval_sec must be positive")
+	}
+	for _, target := range cc.Targets {
+		if !c.HasTarget(target) {
+			return fmt.Errorf("unknown target %q", target)
+		}
+		if !c.HasTunableWhite(target) {
+			return fmt.Errorf("target %q has no tunable white light (channels with cct)", target)
+		}
+	}
+	if cc.WarmK <= 0 || cc.CoolK <= cc.WarmK {
+		return fmt.Errorf("cool_k (%d) must be above warm_k (%d)", cc.CoolK, cc.WarmK)
+	}
+	for _, p := range cc.Curve {
+		if _, err := time.Parse("15:04", p.Time); err != nil {
+			if _, err := time.Parse("15:04:05", p.Time); err != nil {
+				return fmt.Errorf("invalid curve time %q", p.Time)
+			}
+		}
+		if p.CCT < minCCT || p.CCT > maxCCT {
+			return fmt.Errorf("curve %s: cct %d outside %d-%d", p.Time, p.CCT, minCCT, maxCCT)
+		}
+	}
+	if len(cc.Curve) == 0 && (cc.Latitude < -90 || cc.Latitude > 90 || cc.Longitude < -180 || cc.Longitude > 180) {
+		return fmt.Errorf("invalid latitude/longitude")
+	}
+	return nil
+}
+
+// validatePhotoperiod checks the cycle and its spectra
+func (c *Config) validatePhotoperiod(p *PhotoperiodConfig) error {
+	if _, err := time.Parse("15:04", p.On); err != nil {
+		if _, err := time.Parse("15:04:05", p.On); err != nil {
+			return fmt.Errorf("invalid on %q (HH:MM or HH:MM:SS)", p.On)
+		}
+	}
+	if p.Hours <= 0 || p.Hours > 24 {
+		return fmt.Errorf("hours %g out of range (0-24)", p.Hours)
+	}
+	if p.SunriseMin < 0 || p.SunsetMin < 0 || float64(p.SunriseMin+p.SunsetMin) > p.Hours*60 {
+		return fmt.Errorf("sunrise_min and sunset_min must be positive and fit in hours")
+	}
+	if p.IntervalSec < 0 {
+		return fmt.Errorf("interval_sec must be positive")
+	}
+	if len(p.Spectra) == 0 {
+		return fmt.Errorf("spectra required")
+	}
+	if d := p.DLI; d != nil && (d.Target <= 0 || d.PPFDMax <= 0 || d.MinPercent < 0 || d.MinPercent > 100) {
+		return fmt.Errorf("dli: target and ppfd_max must be positive, min_percent 0-100")
+	}
+	for target := range p.Spectra {
+		if !c.HasTarget(target) {
+			return fmt.Errorf("spectra: unknown target %q", target)
+		}
+	}
+	return nil
+}
+
+// validateCondition checks that a condition tests one thing
+func (c *Config) validateCondition(cond Condition) error {
+	n := 0
+	for _, set := range []bool{cond.Variable != "", cond.Level != "", cond.Enabled != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("one of variable, level or enabled required")
+	}
+	compares := cond.Below != nil || cond.Above != nil || cond.Equals != nil
+	if cond.Enabled != nil {
+		if compares {
+			return fmt.Errorf("enabled takes no below, above or equals")
+		}
+		return nil
+	}
+	if !compares {
+		return fmt.Errorf("below, above or equals required")
+	}
+	if cond.Level != "" && !c.HasTarget(cond.Level) {
+		return fmt.Errorf("unknown target %q", cond.Level)
+	}
+	if cond.Color != "" && cond.Level == "" {
+		return fmt.Errorf("color applies to level")
+	}
+	return nil
+}
+
+// EventLocation returns the timezone of an event: tz, a zone name or a timezone name,
+// else the schedule timezone (nil = local)
+func (c *ScheduleConfig) EventLocation(tz string) (*time.Location, error) {
+	if zone, ok := c.Zones[tz]; ok {
+		tz = zone
+	}
+	if tz == "" {
+		tz = c.Timezone
+	}
+	if tz == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(tz)
+}
+
+// validateHoliday checks the date and period end of a holiday
+func validateHoliday(h Holiday) error {
+	layout := "2006-01-02"
+	if len(h.Date) == len("01-02") {
+		layout = "01-02" // Every year
+	}
+	if _, err := time.Parse(layout, h.Date); err != nil {
+		return fmt.Errorf("invalid date %q (YYYY-MM-DD or MM-DD)", h.Date)
+	}
+	if h.To == "" {
+		return nil
+	}
+	if _, err := time.Parse(layout, h.To); err != nil || len(h.To) != len(h.Date) {
+		return fmt.Errorf("invalid to %q (same form as date %q)", h.To, h.Date)
+	}
+	if layout == "2006-01-02" && h.To < h.Date {
+		return fmt.Errorf("to %s before date %s", h.To, h.Date) // Yearly periods may wrap (12-24 to 01-02)
+	}
+	return nil
+}
+
+// validateMQTT checks one MQTT broker connection
+func (c *Config) validateMQTT(m *MQTTConfig) error {
+	for _, target := range m.Targets {
+		if !c.HasTarget(target) {
+			return fmt.Errorf("unknown target %q", target)
+		}
+	}
+	for class, qos := range m.QoS {
+		if !slices.Contains(MQTTQoSClasses, class) {
+			return fmt.Errorf("qos: unknown topic class %q (%s)", class, strings.Join(MQTTQoSClasses, ", "))
+		}
+		if qos > 2 {
+			return fmt.Errorf("qos: %s: %d out of range (0-2)", class, qos)
+		}
+	}
+	if m.EventIntervalMs < 0 {
+		return fmt.Errorf("event_interval_ms must be >= 0")
+	}
+	for _, in := range m.Inputs {
+		if in.Topic == "" {
+			return fmt.Errorf("inputs: %q: topic required", in.Name)
+		}
+		if !validVariableName(in.Name) {
+			return fmt.Errorf("inputs: %q: name must be letters, digits, _ . or -", in.Name)
+		}
+	}
+	for class := range m.Retain {
+		if !slices.Contains(MQTTRetainClasses, class) {
+			return fmt.Errorf("retain: unknown topic class %q (%s)", class, strings.Join(MQTTRetainClasses, ", "))
+		}
+	}
+	return nil
+}
+
+// validateModbusMap checks that the registers of the map fit and do not overlap
+func (c *Config) validateModbusMap(m *ModbusConfig) error {
+	if m.Map == nil {
+		return nil
+	}
+	type block struct {
+		name        string
+		first, size int
+	}
+	blocks := []block{{"channels", m.Map.Channels, 512}}
+	if m.Map.Scene != nil {
+		blocks = append(blocks, block{"scene", *m.Map.Scene, 1})
+	}
+	if m.Map.Fade != nil {
+		blocks = append(blocks, block{"fade", *m.Map.Fade, 1})
+	}
+	for _, key := range slices.Sorted(maps.Keys(m.Map.Lights)) {
+		first := m.Map.Lights[key]
+		group, light, ok := strings.Cut(key, "/")
+		if !ok || c.Lights[group][light] == nil {
+			return fmt.Errorf("unknown light %q", key)
+		}
+		blocks = append(blocks, block{key, first, len(c.Lights[group][light])})
+	}
+	for i, b := range blocks {
+		if b.first < 0 || b.first+b.size > 0x10000 {
+			return fmt.Errorf("%s: registers %d-%d out of range (0-65535)", b.name, b.first, b.first+b.size-1)
+		}
+		for _, o := range blocks[:i] {
+			if b.first < o.first+o.size && o.first < b.first+b.size {
+				return fmt.Errorf("%s overlaps %s", b.name, o.name)
+			}
+		}
+	}
+	if coils := 2 + len(m.Coils); m.Map.Coils < 0 || m.Map.Coils+coils > 0x10000 {
+		return fmt.Errorf("coils: %d-%d out of range (0-65535)", m.Map.Coils, m.Map.Coils+coils-1)
+	}
+	return nil
+}
+
+// validateModbusDevice checks a polled device and its points
+func validateModbusDevice(d ModbusDevice) error {
+	if _, _, err := net.SplitHostPort(d.Address); err != nil {
+		return fmt.Errorf("address %q: host:port required", d.Address)
+	}
+	if d.IntervalMs < 0 || d.TimeoutMs < 0 {
+		return fmt.Errorf("interval_ms and timeout_ms must be positive")
+	}
+	if len(d.Points) == 0 {
+		return fmt.Errorf("no points")
+	}
+	for _, p := range d.Points {
+		if !validVariableName(p.Name) {
+			return fmt.Errorf("point %q: name must be letters, digits, _ . or -", p.Name)
+		}
+		if p.Type != "" && p.Type != "holding" && p.Type != "input" {
+			return fmt.Errorf("point %q: type %q: holding or input", p.Name, p.Type)
+		}
+		if p.Format != "" && !slices.Contains(ModbusPointFormats, p.Format) {
+			return fmt.Errorf("point %q: format %q (%s)", p.Name, p.Format, strings.Join(ModbusPointFormats, ", "))
+		}
+		words := 1
+		if strings.HasSuffix(p.Format, "32") {
+			words = 2
+		}
+		if p.Register < 0 || p.Register+words > 0x10000 {
+			return fmt.Errorf("point %q: register %d out of range (0-65535)", p.Name, p.Register)
+		}
+	}
+	return nil
+}
+
+// validVariableName reports whether name can be a variable (and API path) name
+func validVariableName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateShow checks show steps against configured targets and presets
+func (c *Config) ValidateShow(show *Show) error {
+	if show == nil || len(show.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+	length := 0
+	for i, step := range show.Steps {
+		if step.AtMs < 0 || step.FadeMs < 0 {
+			return fmt.Errorf("step %d: at_ms and fade_ms must be positive", i+1)
+		}
+		for target, preset := range step.Preset {
+			if _, ok := c.Preset(target, preset); !ok {
+				return fmt.Errorf("step %d: unknown preset %q on %q", i+1, preset, target)
+			}
+		}
+		for target := range step.Set {
+			if !c.HasTarget(target) {
+				return fmt.Errorf("step %d: unknown target %q", i+1, target)
+			}
+		}
+		length = max(length, step.AtMs+step.FadeMs)
+	}
+	if show.Loop && length == 0 {
+		return fmt.Errorf("looping show needs a non-zero length")
+	}
+	return nil
+}
+
+// HasTunableWhite reports whether target has a light with at least two cct channels
+func (c *Config) HasTunableWhite(target string) bool {
+	group, light, _ := strings.Cut(target, "/")
+	for name, channels := range c.Lights[group] {
+		if light != "" && name != light {
+			continue
+		}
+		n := 0
+		for _, ch := range channels {
+			if ch.CCT > 0 {
+				n++
+			}
+		}
+		if n >= 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTarget reports whether target ("group" or "group/light") exists
+func (c *Config) HasTarget(target string) bool {
+	group, light, _ := strings.Cut(target, "/")
+	lights, ok := c.Lights[group]
+	if !ok {
+		return false
+	}
+	if light == "" {
+		return true
+	}
+	_, ok = lights[light]
+	return ok
+}
+
+// LightTags returns the tags given to a light or to its group, sorted
+func (c *Config) LightTags(group, name string) []string {
+	var tags []string
+	for tag, targets := range c.Tags {
+		if slices.Contains(targets, group) || slices.Contains(targets, LightKey(group, name)) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// LightMeta returns the metadata of a light: its location and notes, else its group's
+func (c *Config) LightMeta(group, name string) LightMeta {
+	meta := LightMeta{Location: c.Metadata[group].Location, Notes: c.Metadata[group].Notes}
+	own := c.Metadata[LightKey(group, name)]
+	if own.Location != "" {
+		meta.Location = own.Location
+	}
+	if own.Notes != "" {
+		meta.Notes = own.Notes
+	}
+	return meta
+}
+
+// Preset returns preset values for a target, falling back to the light's group
+func (c *Config) Preset(target, name string) (map[string]uint8, bool) {
+	if values, ok := c.Presets[target][name]; ok {
+		return values, true
+	}
+	group, light, _ := strings.Cut(target, "/")
+	if light != "" {
+		values, ok := c.Presets[group][name]
+		return values, ok
+	}
+	return nil, false
+}
+
+// validateBackend checks a backend name against the configured backends
+func (c *Config) validateBackend(name string) error {
+	switch name {
+	case "", "rpmsg", "mock":
+	case "artnet":
+		if c.DMX.ArtNet == nil {
+			return fmt.Errorf("dmx backend %q requires an artnet section", name)
+		}
+	default:
+		return fmt.Errorf("unknown dmx backend %q (rpmsg, mock, artnet)", name)
+	}
 	return nil
 }
 
@@ -117,10 +1234,14 @@ func (c *Config) ResolveLights() []ResolvedLight {
 				}
 
 				rl.Channels[i] = ResolvedChannel{
-					Ch:    ch.Ch,
-					Color: ResolveColor(ch.Color),
-					Name:  channelName,
-					Value: 0,
+					Ch:     ch.Ch,
+					Color:  ResolveColor(ch.Color),
+					Name:   channelName,
+					Value:  0,
+					FineCh: ch.FineCh,
+					Min:    ch.Min,
+					Max:    ch.Max,
+					CCT:    ch.CCT,
 				}
 			}
 
@@ -149,9 +1270,13 @@ func (c *Config) GetLight(group, name string) []ResolvedChannel {
 			channelName = ch.Color
 		}
 		result[i] = ResolvedChannel{
-			Ch:    ch.Ch,
-			Color: ResolveColor(ch.Color),
-			Name:  channelName,
+			Ch:     ch.Ch,
+			Color:  ResolveColor(ch.Color),
+			Name:   channelName,
+			FineCh: ch.FineCh,
+			Min:    ch.Min,
+			Max:    ch.Max,
+			CCT:    ch.CCT,
 		}
 	}
 	return result
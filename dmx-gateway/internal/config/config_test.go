@@ -4,9 +4,15 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadValidConfig(t *testing.T) {
@@ -52,6 +58,24 @@ lights:
 	if cfg.DMX.ThrottleMs != 25 {
 		t.Errorf("expected default throttle 25, got %d", cfg.DMX.ThrottleMs)
 	}
+
+	if cfg.DMX.StatusPollMs != 1000 {
+		t.Errorf("expected default status poll 1000, got %d", cfg.DMX.StatusPollMs)
+	}
+}
+
+func TestLoadStatusPollDisabled(t *testing.T) {
+	cfg := loadFromString(t, `
+dmx:
+  status_poll_ms: -1
+lights:
+  test:
+    light1:
+      - { ch: 1, color: white }
+`)
+	if cfg.DMX.StatusPollMs != -1 {
+		t.Errorf("expected status poll kept disabled, got %d", cfg.DMX.StatusPollMs)
+	}
 }
 
 func TestValidateNoLights(t *testing.T) {
@@ -117,6 +141,37 @@ lights:
 	}
 }
 
+func TestValidateFailoverBackend(t *testing.T) {
+	yaml := `
+dmx:
+  failover:
+    backend: artnet
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	_, err := loadFromStringErr(yaml)
+	if err == nil {
+		t.Error("expected error for artnet failover without artnet section")
+	}
+
+	yaml = `
+dmx:
+  artnet: { address: "10.0.0.5" }
+  failover:
+    backend: artnet
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, yaml)
+	if cfg.DMX.Failover.AfterSec != 10 {
+		t.Errorf("expected default after_sec 10, got %d", cfg.DMX.Failover.AfterSec)
+	}
+}
+
 func TestResolveColor(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -243,6 +298,672 @@ func TestLightKey(t *testing.T) {
 
 // Helper functions
 
+func TestLightMeta(t *testing.T) {
+	yaml := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+    level2:
+      - { ch: 2, color: blue }
+tags:
+  veg: [rack1]
+metadata:
+  rack1: { location: Greenhouse 2, notes: North side }
+  rack1/level2: { location: "Greenhouse 2, Row 4" }
+`
+	cfg := loadFromString(t, yaml)
+	if meta := cfg.LightMeta("rack1", "level1"); meta.Location != "Greenhouse 2" || meta.Notes != "North side" {
+		t.Errorf("expected the group metadata, got %+v", meta)
+	}
+	if meta := cfg.LightMeta("rack1", "level2"); meta.Location != "Greenhouse 2, Row 4" || meta.Notes != "North side" {
+		t.Errorf("expected the light location over its group's, got %+v", meta)
+	}
+
+	for _, bad := range []string{"  rack9: { location: Nowhere }\n", "  rack1/level9: { notes: Spare }\n"} {
+		if _, err := loadFromStringErr(yaml + bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestPresetsLightOverridesGroup(t *testing.T) {
+	yaml := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+    level2:
+      - { ch: 2, color: blue }
+presets:
+  rack1:
+    veg: { blue: 200 }
+  rack1/level1:
+    veg: { blue: 100 }
+`
+	cfg := loadFromString(t, yaml)
+
+	if v, ok := cfg.Preset("rack1/level1", "veg"); !ok || v["blue"] != 100 {
+		t.Errorf("expected light preset blue=100, got %v (found=%v)", v, ok)
+	}
+	if v, ok := cfg.Preset("rack1/level2", "veg"); !ok || v["blue"] != 200 {
+		t.Errorf("expected inherited group preset blue=200, got %v (found=%v)", v, ok)
+	}
+	if _, ok := cfg.Preset("rack1", "bloom"); ok {
+		t.Error("expected unknown preset to be missing")
+	}
+
+	_, err := loadFromStringErr(yaml + `
+  rack9:
+    veg: { blue: 1 }
+`)
+	if err == nil {
+		t.Error("expected error for preset on unknown target")
+	}
+}
+
+func TestLightTags(t *testing.T) {
+	yaml := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+    level2:
+      - { ch: 2, color: blue }
+tags:
+  veg: [rack1]
+  bloom: [rack1/level2]
+`
+	cfg := loadFromString(t, yaml)
+
+	if tags := cfg.LightTags("rack1", "level1"); !slices.Equal(tags, []string{"veg"}) {
+		t.Errorf("expected group tag, got %v", tags)
+	}
+	if tags := cfg.LightTags("rack1", "level2"); !slices.Equal(tags, []string{"bloom", "veg"}) {
+		t.Errorf("expected light and group tags sorted, got %v", tags)
+	}
+
+	if _, err := loadFromStringErr(yaml + "  dry: [rack9]\n"); err == nil {
+		t.Error("expected error for tag on unknown target")
+	}
+}
+
+func TestValidateArbitration(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+`
+arbitration:
+  policy: htp
+  priorities: { modbus: 100, scheduler: 10 }
+  groups: { rack1: ltp }
+`)
+	if cfg.Arbitration.Priorities["modbus"] != 100 {
+		t.Errorf("expected modbus priority 100, got %d", cfg.Arbitration.Priorities["modbus"])
+	}
+
+	for _, bad := range []string{
+		"arbitration: { policy: max }",
+		"arbitration: { priorities: { console: 1 } }",
+		"arbitration: { groups: { rack9: htp } }",
+		"arbitration: { channels: { 600: htp } }",
+	} {
+		if _, err := loadFromStringErr(base + bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestValidateChannelMinMax(t *testing.T) {
+	_, err := loadFromStringErr(`
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue, min: 100, max: 50 }
+`)
+	if err == nil {
+		t.Error("expected error for min above max")
+	}
+}
+
+func TestChannelOutputCurve(t *testing.T) {
+	table, err := Channel{Curve: "gamma2.2"}.OutputCurve()
+	if err != nil {
+		t.Fatalf("gamma2.2: %v", err)
+	}
+	if table[0] != 0 || table[1] != 1 || table[128] != 56 || table[255] != 255 {
+		t.Errorf("unexpected gamma2.2 table: 0->%d 1->%d 128->%d 255->%d", table[0], table[1], table[128], table[255])
+	}
+
+	table, err = Channel{Curve: "custom", CurveTable: []uint8{0, 50, 255}}.OutputCurve()
+	if err != nil {
+		t.Fatalf("custom: %v", err)
+	}
+	if table[0] != 0 || table[255] != 255 || table[64] != 25 {
+		t.Errorf("unexpected custom table: 0->%d 64->%d 255->%d", table[0], table[64], table[255])
+	}
+
+	if table, err := (Channel{}).OutputCurve(); table != nil || err != nil {
+		t.Errorf("expected linear (nil) curve, got %v / %v", table, err)
+	}
+	for _, bad := range []Channel{{Curve: "s-curve"}, {Curve: "gammax"}, {Curve: "custom"}} {
+		if _, err := bad.OutputCurve(); err == nil {
+			t.Errorf("expected error for curve %q", bad.Curve)
+		}
+	}
+}
+
+func TestValidateFineChannelConflict(t *testing.T) {
+	_, err := loadFromStringErr(`
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue, fine_ch: 2 }
+      - { ch: 2, color: red }
+`)
+	if err == nil {
+		t.Error("expected error for fine channel reused as coarse channel")
+	}
+}
+
+func TestValidateCues(t *testing.T) {
+	cfg := loadFromString(t, `
+cues:
+  show:
+    - { scene: dawn, fade_ms: 5000, follow_ms: 1000 }
+    - { scene: day }
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`)
+	if len(cfg.Cues["show"]) != 2 || cfg.Cues["show"][0].FollowMs != 1000 {
+		t.Errorf("unexpected cues: %+v", cfg.Cues)
+	}
+
+	_, err := loadFromStringErr(`
+cues:
+  show:
+    - { fade_ms: 5000 }
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`)
+	if err == nil {
+		t.Error("expected error for cue without scene")
+	}
+}
+
+func TestValidateShows(t *testing.T) {
+	_, err := loadFromStringErr(`
+shows:
+  demo:
+    steps:
+      - { at_ms: 0, set: { rack9: { blue: 255 } } }
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`)
+	if err == nil {
+		t.Error("expected error for show step on unknown target")
+	}
+
+	_, err = loadFromStringErr(`
+shows:
+  demo:
+    loop: true
+    steps:
+      - { at_ms: 0, blackout: true }
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`)
+	if err == nil {
+		t.Error("expected error for zero-length looping show")
+	}
+}
+
+func TestValidateCircadian(t *testing.T) {
+	cfg := loadFromString(t, `
+schedule:
+  circadian:
+    targets: [rack1]
+    curve:
+      - { time: "06:00", cct: 2700, level: 0 }
+      - { time: "12:00", cct: 6500, level: 255 }
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: orange, name: warm, cct: 2700 }
+      - { ch: 2, color: white, name: cool, cct: 6500 }
+`)
+	cc := cfg.Schedule.Circadian
+	if cc.WarmK != 2700 || cc.CoolK != 6500 || cc.IntervalSec != 10 {
+		t.Errorf("expected circadian defaults, got %+v", cc)
+	}
+
+	_, err := loadFromStringErr(`
+schedule:
+  circadian:
+    targets: [rack1]
+    curve:
+      - { time: "06:00", cct: 2700, level: 0 }
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: orange, name: warm }
+`)
+	if err == nil {
+		t.Error("expected error for circadian target without cct channels")
+	}
+}
+
+func TestValidateScheduleDays(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+`schedule: { events: [{ time: "08:00", days: [mon, fri], blackout: true }] }`)
+	if days := cfg.Schedule.Events[0].Days; len(days) != 2 || days[1] != "fri" {
+		t.Errorf("expected days mon, fri, got %v", days)
+	}
+	if _, err := loadFromStringErr(base + `schedule: { events: [{ time: "08:00", days: [monday], blackout: true }] }`); err == nil {
+		t.Error("expected error for day monday")
+	}
+}
+
+func TestValidateScheduleCron(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	loadFromString(t, base+`schedule: { events: [{ cron: "0 8 * * mon#1", blackout: true }] }`)
+	for _, bad := range []string{
+		`{ cron: "0 8 * *", blackout: true }`,                  // 4 fields
+		`{ cron: "0 8 * * *", time: "08:00", blackout: true }`, // Both
+		`{ cron: "0 8 * * *", days: [mon], blackout: true }`,
+		`{ cron: "0 0 31 feb *", blackout: true }`, // Never runs
+	} {
+		if _, err := loadFromStringErr(base + "schedule: { events: [" + bad + "] }"); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestValidateScheduleHolidays(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	loadFromString(t, base+`schedule:
+  events: [{ time: "08:00", holiday: only, jitter: 30m, blackout: true }]
+  holidays: [{ date: "2025-12-25" }, { date: "12-24", to: "01-02", name: Winter }, { date: "02-29" }]
+`)
+	for _, bad := range []string{
+		`{ events: [{ time: "08:00", holiday: never, blackout: true }] }`,
+		`{ events: [{ time: "08:00", jitter: 13h, blackout: true }] }`,
+		`{ events: [{ cron: "0 8 * * *", jitter: 30m, blackout: true }] }`,
+		`{ holidays: [{ date: "2025-13-01" }] }`,
+		`{ holidays: [{ date: "2025-12-25", to: "12-31" }] }`,      // Mixed forms
+		`{ holidays: [{ date: "2025-12-25", to: "2025-12-24" }] }`, // Reversed
+	} {
+		if _, err := loadFromStringErr(base + "schedule: " + bad); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestValidateScheduleZones(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	loadFromString(t, base+`schedule:
+  zones: { site_b: America/New_York }
+  events: [{ time: "08:00", timezone: site_b, blackout: true }, { time: "09:00", timezone: UTC, blackout: true }]
+`)
+	for _, bad := range []string{
+		`{ events: [{ time: "08:00", timezone: Mars/Olympus, blackout: true }] }`,
+		`{ zones: { site_b: Mars/Olympus }, events: [] }`,
+	} {
+		if _, err := loadFromStringErr(base + "schedule: " + bad); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestValidateScheduleConditions(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	loadFromString(t, base+`schedule: { events: [{ time: "07:00", blackout: true, when: [{ variable: lux, below: 200 }, { level: rack1, color: blue, equals: 0 }, { enabled: true }] }] }`)
+	for _, bad := range []string{
+		`{ variable: lux }`,                         // No comparison
+		`{ variable: lux, level: rack1, below: 1 }`, // Two subjects
+		`{ enabled: true, below: 1 }`,
+		`{ level: rack9, below: 1 }`,
+		`{ variable: lux, color: blue, below: 1 }`,
+	} {
+		if _, err := loadFromStringErr(base + `schedule: { events: [{ time: "07:00", blackout: true, when: [` + bad + `] }] }`); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestValidatePhotoperiod(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+`schedule: { photoperiod: { on: "06:00", hours: 18, sunrise_min: 30, spectra: { rack1: { blue: 200 } } } }`)
+	if cfg.Schedule.Photoperiod.IntervalSec != 10 {
+		t.Errorf("expected default interval 10, got %d", cfg.Schedule.Photoperiod.IntervalSec)
+	}
+	for _, bad := range []string{
+		`{ on: "06:00", hours: 0, spectra: { rack1: { blue: 200 } } }`,
+		`{ on: "6h", hours: 12, spectra: { rack1: { blue: 200 } } }`,
+		`{ on: "06:00", hours: 1, sunrise_min: 40, sunset_min: 40, spectra: { rack1: { blue: 200 } } }`, // Ramps longer than the day
+		`{ on: "06:00", hours: 12 }`,
+		`{ on: "06:00", hours: 12, spectra: { rack9: { blue: 200 } } }`,
+	} {
+		if _, err := loadFromStringErr(base + "schedule: { photoperiod: " + bad + " }"); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestValidateAliasChannels(t *testing.T) {
+	cfg := loadFromString(t, `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue, max: 200 }
+  all:
+    blue:
+      - { ch: 1, color: blue, alias: true }
+`)
+	if ch := cfg.Lights["all"]["blue"][0]; ch.Max != 200 {
+		t.Errorf("expected alias to inherit max 200, got %d", ch.Max)
+	}
+
+	_, err := loadFromStringErr(`
+lights:
+  all:
+    blue:
+      - { ch: 1, color: blue, alias: true }
+`)
+	if err == nil {
+		t.Error("expected error for alias of an unowned channel")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	cfg := loadFromString(t, `
+profiles:
+  rgbw:
+    - { color: red }
+    - { color: green }
+    - { color: blue }
+    - { ch: 5, color: white, fine_ch: 6 }
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+  bar:
+    left: [{ profile: rgbw, start: 33 }]
+  all:
+    blue:
+      - { ch: 1, color: blue, alias: true }
+`)
+	got := cfg.Lights["bar"]["left"]
+	if len(got) != 4 || got[0].Ch != 33 || got[2].Ch != 35 || got[3].Ch != 37 || got[3].FineCh != 38 {
+		t.Errorf("expected channels 33, 34, 35, 37 (fine 38), got %+v", got)
+	}
+
+	patch := cfg.Patch()
+	if len(patch.Lights) != 3 || patch.Lights[2] != (PatchRange{Light: "bar/left", First: 33, Last: 38, Channels: 5}) {
+		t.Errorf("unexpected lights %+v", patch.Lights)
+	}
+	if patch.Used != 6 {
+		t.Errorf("expected 6 channels used, got %d", patch.Used)
+	}
+	if want := []PatchGap{{2, 32}, {36, 36}}; !slices.Equal(patch.Gaps, want) {
+		t.Errorf("expected gaps %v, got %v", want, patch.Gaps)
+	}
+	if len(patch.Overlaps) != 1 || patch.Overlaps[0].Ch != 1 || !slices.Equal(patch.Overlaps[0].Lights, []string{"all/blue", "rack1/level1"}) {
+		t.Errorf("expected channel 1 shared by the alias, got %+v", patch.Overlaps)
+	}
+
+	base := "profiles: { rgb: [{ color: red }, { color: green }, { color: blue }] }\nlights:\n  bar:\n    left: "
+	loadFromString(t, base+"[{ profile: rgb, start: 510 }]\n")
+	for _, bad := range []string{
+		`[{ profile: rgbw, start: 1 }]`,                       // Unknown
+		`[{ profile: rgb }]`,                                  // No start
+		`[{ profile: rgb, start: 1 }, { ch: 9, color: red }]`, // Mixed
+		`[{ profile: rgb, start: 511 }]`,                      // Past 512
+	} {
+		if _, err := loadFromStringErr(base + bad + "\n"); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	yaml := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+    level2:
+      - { ch: 40, color: blue }
+schedule:
+  events:
+    - { time: "08:00", set: { rack1: { blue: 10 }, rack9: { blue: 10 } } }
+`
+	cfg := loadFromString(t, yaml)
+	want := []string{
+		`schedule: event 1: unknown target "rack9"`,
+		"lights: channels 2-39 unpatched (38)",
+	}
+	if !slices.Equal(cfg.Warnings(), want) {
+		t.Errorf("expected warnings %q, got %q", want, cfg.Warnings())
+	}
+
+	// A group over 60 % of the universe
+	var b strings.Builder
+	b.WriteString("lights:\n  big:\n    bar:\n")
+	for ch := 1; ch <= 320; ch++ {
+		fmt.Fprintf(&b, "      - { ch: %d, color: white }\n", ch)
+	}
+	if cfg := loadFromString(t, b.String()); len(cfg.Warnings()) != 1 || !strings.Contains(cfg.Warnings()[0], `group "big"`) {
+		t.Errorf("expected the group share warned, got %q", cfg.Warnings())
+	}
+
+	// Strict: errors, for the configs derived from it too
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(yaml), 0644)
+	if _, err := LoadStrict(path); err == nil || !strings.Contains(err.Error(), "rack9") {
+		t.Errorf("expected strict error, got %v", err)
+	}
+	os.WriteFile(path, []byte("lights: { rack1: { level1: [{ ch: 1, color: blue }] } }\n"), 0644)
+	strict, err := LoadStrict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.Derive([]byte(yaml)); err == nil {
+		t.Error("expected a strict derived config")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema()
+	defs := schema["$defs"].(map[string]any)
+	if enum := defs["ScheduleEvent"].(map[string]any)["properties"].(map[string]any)["holiday"].(map[string]any)["enum"]; !slices.Equal(enum.([]string), HolidayModes) {
+		t.Errorf("expected holiday modes enum, got %v", enum)
+	}
+
+	// Every key of a config is described
+	var doc map[string]any
+	if err := yaml.Unmarshal([]byte(`
+server: { http: ":8080", rate_limit: { rps: 5 } }
+dmx: { client: mock, fps: 30 }
+mqtt: { broker: "tcp://localhost:1883", qos: { command: 1 } }
+schedule:
+  timezone: UTC
+  events: [{ time: "08:00", days: [mon], set: { rack1: { blue: 10 } }, when: [{ variable: lux, below: 2 }] }]
+park: { 40: 255 }
+webhooks: [{ url: "http://hook", events: [blackout] }]
+lights: { rack1: { level1: [{ ch: 1, color: blue, curve: gamma2.2 }] } }
+`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	var check func(s map[string]any, v any, path string)
+	check = func(s map[string]any, v any, path string) {
+		if ref, ok := s["$ref"].(string); ok {
+			s = defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				if props, ok := s["properties"].(map[string]any); ok {
+					prop, ok := props[key]
+					if !ok {
+						t.Errorf("%s: key %q not in the schema", path, key)
+						continue
+					}
+					check(prop.(map[string]any), value, path+"."+key)
+				} else {
+					check(s["additionalProperties"].(map[string]any), value, path+"."+key)
+				}
+			}
+		case []any:
+			for _, item := range v {
+				check(s["items"].(map[string]any), item, path+"[]")
+			}
+		}
+	}
+	check(schema, doc, "")
+}
+
+func TestSaveLights(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := `server:
+  http: ":9090" # Keep me
+
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: red }
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	next := cfg.Clone()
+	next.Lights["rack2"] = map[string][]Channel{"level1": {{Ch: 10, Color: "blue", Max: 200}}}
+	if len(cfg.Lights) != 1 {
+		t.Fatal("expected Clone to copy lights")
+	}
+	if err := next.SaveLights(); err != nil {
+		t.Fatalf("SaveLights: %v", err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), "# Keep me") {
+		t.Errorf("expected comments outside lights kept, got:\n%s", saved)
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if reloaded.Server.HTTP != ":9090" || reloaded.Lights["rack2"]["level1"][0].Max != 200 {
+		t.Errorf("unexpected reloaded config: %+v", reloaded)
+	}
+}
+
+func TestSaveProfileLights(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `profiles:
+  rgb: [{ color: red }, { color: green }, { color: blue }]
+lights:
+  bar:
+    left: [{ profile: rgb, start: 33 }]
+    right: [{ profile: rgb, start: 36 }]
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// Right repatched by hand: saved as its channels
+	next := cfg.Clone()
+	next.Lights["bar"]["right"] = []Channel{{Ch: 40, Color: "white"}}
+	if err := next.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := next.SaveLights(); err != nil {
+		t.Fatalf("SaveLights: %v", err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Lights map[string]map[string][]Channel `yaml:"lights"`
+	}
+	if err := yaml.Unmarshal(saved, &file); err != nil {
+		t.Fatal(err)
+	}
+	if left := file.Lights["bar"]["left"]; len(left) != 1 || left[0].Profile != "rgb" || left[0].Start != 33 {
+		t.Errorf("expected the profile entry kept, got:\n%s", saved)
+	}
+	if right := file.Lights["bar"]["right"]; len(right) != 1 || right[0].Ch != 40 {
+		t.Errorf("expected the repatched channels, got:\n%s", saved)
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if left := reloaded.Lights["bar"]["left"]; len(left) != 3 || left[0].Ch != 33 || left[2].Ch != 35 {
+		t.Errorf("expected the profile expanded again, got %+v", left)
+	}
+	if doc, _ := reloaded.Redacted(); !strings.Contains(fmt.Sprint(doc["lights"]), "profile:rgb") {
+		t.Errorf("expected the profile entry in the document, got %v", doc["lights"])
+	}
+}
+
 func loadFromString(t *testing.T, yaml string) *Config {
 	t.Helper()
 	cfg, err := loadFromStringErr(yaml)
@@ -266,3 +987,444 @@ func loadFromStringErr(yaml string) (*Config, error) {
 
 	return Load(path)
 }
+
+func TestValidateAuth(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+`
+auth:
+  keys:
+    - { name: bms, key: k1, scope: read }
+    - { name: console, key: k2 }
+  jwt:
+    secret: 0123456789abcdef
+`)
+	if cfg.Auth.Keys[1].Scope != "write" || cfg.Auth.JWT.RoleClaim != "role" {
+		t.Errorf("expected default scope write, got %q", cfg.Auth.Keys[1].Scope)
+	}
+
+	for _, bad := range []string{
+		"auth: { keys: [] }",
+		"auth: { keys: [ { name: a } ] }",
+		"auth: { keys: [ { name: a, key: k }, { name: b, key: k } ] }",
+		"auth: { keys: [ { name: a, key: k, scope: root } ] }",
+		"auth: { jwt: { secret: short } }",
+	} {
+		if _, err := loadFromStringErr(base + bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestValidateAllowedOrigins(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+"server: { allowed_origins: [\"https://bms.example:8443\"] }")
+	if len(cfg.Server.AllowedOrigins) != 1 {
+		t.Errorf("expected 1 origin, got %v", cfg.Server.AllowedOrigins)
+	}
+	for _, bad := range []string{"bms.example", "https://bms.example/ui"} {
+		if _, err := loadFromStringErr(base + "server: { allowed_origins: [\"" + bad + "\"] }"); err == nil {
+			t.Errorf("expected error for origin %q", bad)
+		}
+	}
+}
+
+func TestValidateMQTTQoS(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+"mqtt: { broker: \"tcp://b:1883\", qos: { event: 1 }, retain: { status: false } }")
+	if cfg.MQTT.QoS["event"] != 1 || cfg.MQTT.Retain["status"] {
+		t.Errorf("unexpected mqtt qos/retain: %v %v", cfg.MQTT.QoS, cfg.MQTT.Retain)
+	}
+	for _, bad := range []string{"qos: { event: 3 }", "qos: { cmd: 1 }", "retain: { response: true }"} {
+		if _, err := loadFromStringErr(base + "mqtt: { broker: \"tcp://b:1883\", " + bad + " }"); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestValidateMQTTBrokers(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+mqtt:
+  broker: "tcp://local:1883"
+`
+	cfg := loadFromString(t, base+"  brokers: [{ broker: \"ssl://cloud:8883\", topic_prefix: site1 }]\n")
+	conns := cfg.MQTT.Connections()
+	if len(conns) != 2 || conns[0].Broker != "tcp://local:1883" || conns[0].Brokers != nil || conns[1].TopicPrefix != "site1" {
+		t.Errorf("unexpected connections: %+v", conns)
+	}
+	for _, bad := range []string{
+		"[{ topic_prefix: site1 }]",                             // No broker
+		"[{ broker: \"tcp://local:1883\" }]",                    // Same broker and client id
+		"[{ broker: \"ssl://cloud:8883\", qos: { event: 5 } }]", // Checked like the main connection
+	} {
+		if _, err := loadFromStringErr(base + "  brokers: " + bad + "\n"); err == nil {
+			t.Errorf("expected error for brokers %s", bad)
+		}
+	}
+}
+
+func TestWebSocketKeepaliveDefaults(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base)
+	if cfg.Server.WSPingSec != 30 || cfg.Server.WSIdleSec != 0 {
+		t.Errorf("expected ping 30s and no idle timeout, got %d/%d", cfg.Server.WSPingSec, cfg.Server.WSIdleSec)
+	}
+	if _, err := loadFromStringErr(base + "server: { ws_idle_sec: -1 }"); err == nil {
+		t.Error("expected error for a negative idle timeout")
+	}
+}
+
+func TestAuthWSGrace(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+auth:
+  keys: [{ name: ui, key: k }]
+`
+	if cfg := loadFromString(t, base); cfg.Auth.WSGraceSec != 5 {
+		t.Errorf("expected default grace 5s, got %d", cfg.Auth.WSGraceSec)
+	}
+	if _, err := loadFromStringErr(base + "  ws_grace_sec: -1\n"); err == nil {
+		t.Error("expected error for a negative grace period")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := loadFromString(t, `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+park:
+  40: 255
+mqtt:
+  broker: tcp://localhost:1883
+  password: hunter2
+  brokers: [{ broker: "ssl://cloud:8883", password: cloud-pass }]
+auth:
+  keys: [{ name: bms, key: secret-key }]
+  jwt: { secret: "0123456789abcdef" }
+`)
+	doc, err := cfg.Redacted()
+	if err != nil {
+		t.Fatalf("Redacted: %v", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	out := string(data)
+	for _, secret := range []string{"hunter2", "cloud-pass", "secret-key", "0123456789abcdef"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("secret %q not redacted: %s", secret, out)
+		}
+	}
+	for _, want := range []string{`"park":{"40":255}`, `"broker":"tcp://localhost:1883"`, `"name":"bms"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in %s", want, out)
+		}
+	}
+	if cfg.MQTT.Password != "hunter2" || cfg.MQTT.Brokers[0].Password != "cloud-pass" || cfg.Auth.Keys[0].Key != "secret-key" {
+		t.Error("Redacted changed the live config")
+	}
+}
+
+func TestDeriveAndSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := `# Gateway config
+server:
+  http: ":9090" # Keep me
+
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: red }
+
+mqtt:
+  broker: tcp://localhost:1883
+  password: hunter2
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	// Secrets sent back redacted keep their value
+	next, err := cfg.DeriveSection("mqtt", []byte(`{"broker": "tcp://broker:1883", "password": "[redacted]"}`))
+	if err != nil {
+		t.Fatalf("DeriveSection: %v", err)
+	}
+	if next.MQTT.Broker != "tcp://broker:1883" || next.MQTT.Password != "hunter2" {
+		t.Errorf("unexpected mqtt section: %+v", next.MQTT)
+	}
+	changed, err := cfg.Changed(next)
+	if err != nil {
+		t.Fatalf("Changed: %v", err)
+	}
+	if !slices.Equal(changed, []string{"mqtt"}) {
+		t.Errorf("expected [mqtt] changed, got %v", changed)
+	}
+	if err := next.Save(changed); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	saved, _ := os.ReadFile(path)
+	if !strings.Contains(string(saved), "# Keep me") || !strings.Contains(string(saved), "# Gateway config") {
+		t.Errorf("expected comments outside mqtt kept, got:\n%s", saved)
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if reloaded.MQTT.Broker != "tcp://broker:1883" || reloaded.MQTT.Password != "hunter2" {
+		t.Errorf("unexpected reloaded mqtt: %+v", reloaded.MQTT)
+	}
+
+	// null removes an optional section
+	next, err = cfg.DeriveSection("mqtt", []byte("null"))
+	if err != nil || next.MQTT != nil {
+		t.Errorf("expected mqtt removed, got %+v, %v", next, err)
+	}
+
+	for name, body := range map[string]string{
+		"unknown key":     `{"lights": {"rack1": {"level1": [{"ch": 1, "color": "red"}]}}, "bogus": 1}`,
+		"invalid config":  `{"lights": {}}`,
+		"channel overlap": `{"lights": {"rack1": {"a": [{"ch": 1, "color": "red"}], "b": [{"ch": 1, "color": "red"}]}}}`,
+	} {
+		if _, err := cfg.Derive([]byte(body)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := cfg.DeriveSection("nope", []byte("{}")); err == nil {
+		t.Error("expected error for unknown section")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	cfg := loadFromString(t, `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+    level2:
+      - { ch: 2, color: blue }
+park:
+  40: 255
+`)
+	next := loadFromString(t, `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: red }
+    level3:
+      - { ch: 3, color: blue }
+park:
+  40: 255
+  41: 10
+mqtt:
+  broker: tcp://localhost:1883
+`)
+	diff, err := cfg.Diff(next)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	want := []Change{
+		{"mqtt", "added"},
+		{"park.41", "added"},
+		{"lights.rack1.level1", "changed"},
+		{"lights.rack1.level2", "removed"},
+		{"lights.rack1.level3", "added"},
+	}
+	if !slices.Equal(diff, want) {
+		t.Errorf("expected %v, got %v", want, diff)
+	}
+	if diff, _ := cfg.Diff(cfg); len(diff) != 0 {
+		t.Errorf("expected no diff, got %v", diff)
+	}
+}
+
+func TestValidateWebhooks(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+`
+webhooks:
+  - url: https://hooks.example/dmx
+    events: [blackout, backend]
+    headers: { Authorization: "Bearer t0k3n" }
+`)
+	wh := cfg.Webhooks[0]
+	if wh.Retries != 3 || wh.BackoffMs != 1000 || wh.TimeoutMs != 5000 {
+		t.Errorf("expected defaults 3/1000/5000, got %+v", wh)
+	}
+	doc, _ := cfg.Redacted()
+	if data, _ := json.Marshal(doc); strings.Contains(string(data), "t0k3n") {
+		t.Errorf("expected webhook headers redacted: %s", data)
+	}
+
+	for _, bad := range []string{
+		"webhooks: [ { url: '' } ]",
+		"webhooks: [ { url: 'ftp://host/x' } ]",
+		"webhooks: [ { url: 'http://host/x', events: [reboot] } ]",
+		"webhooks: [ { url: 'http://host/x', retries: -1 } ]",
+	} {
+		if _, err := loadFromStringErr(base + bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestValidateModbusRTU(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+"modbus: { rtu: { device: /dev/ttyS3, parity: N } }")
+	if rtu := cfg.Modbus.RTU; rtu.Baud != 19200 || rtu.StopBits != 2 || rtu.UnitID != 1 {
+		t.Errorf("unexpected rtu defaults: %+v", rtu)
+	}
+	for _, bad := range []string{"{ parity: E }", "{ device: /dev/ttyS3, parity: X }", "{ device: /dev/ttyS3, unit_id: 248 }", "{ device: /dev/ttyS3, stop_bits: 3 }"} {
+		if _, err := loadFromStringErr(base + "modbus: { rtu: " + bad + " }"); err == nil {
+			t.Errorf("expected error for rtu %s", bad)
+		}
+	}
+}
+
+func TestValidateModbusCoils(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+presets:
+  rack1: { full: { blue: 255 } }
+`
+	cfg := loadFromString(t, base+"modbus: { coils: [{ target: rack1/level1, preset: full, fade_ms: 500 }] }")
+	if len(cfg.Modbus.Coils) != 1 || cfg.Modbus.Coils[0].FadeMs != 500 {
+		t.Errorf("unexpected coils: %+v", cfg.Modbus.Coils)
+	}
+	for _, bad := range []string{"{ target: rack2, preset: full }", "{ target: rack1, preset: half }", "{ target: rack1, preset: full, fade_ms: -1 }"} {
+		if _, err := loadFromStringErr(base + "modbus: { coils: [" + bad + "] }"); err == nil {
+			t.Errorf("expected error for coil %s", bad)
+		}
+	}
+}
+
+func TestValidateModbusMap(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: red }
+      - { ch: 2, color: blue }
+`
+	cfg := loadFromString(t, base+"modbus: { map: { channels: 1000, lights: { rack1/level1: 10 } } }")
+	if m := cfg.Modbus.Map; *m.Scene != 1512 || *m.Fade != 1513 || m.Lights["rack1/level1"] != 10 {
+		t.Errorf("unexpected map: %+v", m)
+	}
+	for _, bad := range []string{
+		"{ lights: { rack1/level2: 600 } }",             // Unknown light
+		"{ lights: { rack1/level1: 511 } }",             // Overlaps the channels
+		"{ scene: 600, lights: { rack1/level1: 599 } }", // Overlaps the scene
+		"{ channels: 65100 }",                           // Past 65535
+		"{ coils: 65535 }",                              // Blackout past 65535
+		"{ scene: 600, fade: 600 }",                     // Fade on the scene register
+	} {
+		if _, err := loadFromStringErr(base + "modbus: { map: " + bad + " }"); err == nil {
+			t.Errorf("expected error for map %s", bad)
+		}
+	}
+}
+
+func TestValidateModbusScale(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	if cfg := loadFromString(t, base+"modbus: { scale: percent }"); cfg.Modbus.Scale != "percent" {
+		t.Errorf("expected percent scale, got %q", cfg.Modbus.Scale)
+	}
+	if _, err := loadFromStringErr(base + "modbus: { scale: pct }"); err == nil {
+		t.Error("expected error for scale pct")
+	}
+}
+
+func TestValidateModbusUnit(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	if cfg := loadFromString(t, base+"modbus: { unit_id: 5, other_units: reject }"); cfg.Modbus.UnitID != 5 {
+		t.Errorf("expected unit_id 5, got %d", cfg.Modbus.UnitID)
+	}
+	for _, bad := range []string{"{ unit_id: 250 }", "{ unit_id: 5, other_units: drop }"} {
+		if _, err := loadFromStringErr(base + "modbus: " + bad); err == nil {
+			t.Errorf("expected error for modbus %s", bad)
+		}
+	}
+}
+
+func TestValidateModbusPoll(t *testing.T) {
+	base := `
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	cfg := loadFromString(t, base+"modbus: { poll: [{ address: \"10.0.0.5:502\", points: [{ name: par, register: 3, format: float32 }] }] }")
+	if d := cfg.Modbus.Poll[0]; d.UnitID != 1 || d.IntervalMs != 5000 || d.TimeoutMs != 1000 {
+		t.Errorf("unexpected poll defaults: %+v", d)
+	}
+	for _, bad := range []string{
+		`[{ address: "10.0.0.5", points: [{ name: par }] }]`,                   // No port
+		`[{ address: "10.0.0.5:502" }]`,                                        // No points
+		`[{ address: "10.0.0.5:502", points: [{ name: par, format: int8 }] }]`, // Unknown format
+		`[{ address: "10.0.0.5:502", points: [{ name: par, type: coil }] }]`,   // Unknown type
+		`[{ address: "10.0.0.5:502", points: [{ name: par, register: 65535, format: uint32 }] }]`,
+		`[{ address: "10.0.0.5:502", points: [{ name: par }, { name: par, register: 1 }] }]`, // Duplicate variable
+	} {
+		if _, err := loadFromStringErr(base + "modbus: { poll: " + bad + " }"); err == nil {
+			t.Errorf("expected error for poll %s", bad)
+		}
+	}
+	if _, err := loadFromStringErr(base + "modbus: { poll: [{ address: \"10.0.0.5:502\", points: [{ name: par }] }] }\nmqtt: { broker: \"tcp://b:1883\", inputs: [{ topic: t, name: par }] }"); err == nil {
+		t.Error("expected error for a variable fed by MQTT and Modbus")
+	}
+}
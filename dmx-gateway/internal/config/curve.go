@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Dimming curves
+// A curve maps the logical 0-255 value used by the API to the DMX output value.
+//   linear      output = value (default)
+//   gamma<g>    output = 255 * (value/255)^g, e.g. gamma2.2
+//   log         output = 255 * (1000^(value/255) - 1) / 999 (three decades, DALI-like)
+//   custom      curve_table points spread evenly over 0-255, linearly interpolated
+// Non-zero inputs never map to 0, so a light that is on stays on.
+
+// OutputCurve returns the 256-entry lookup table for a channel (nil = linear)
+func (ch Channel) OutputCurve() (*[256]uint8, error) {
+	var f func(x float64) float64 // x in 0-1, result in 0-1
+
+	switch name := ch.Curve; {
+	case name == "" || name == "linear":
+		if len(ch.CurveTable) > 0 {
+			return nil, fmt.Errorf("curve_table requires curve: custom")
+		}
+		return nil, nil
+	case name == "log":
+		f = func(x float64) float64 { return (math.Pow(1000, x) - 1) / 999 }
+	case strings.HasPrefix(name, "gamma"):
+		g, err := strconv.ParseFloat(strings.TrimPrefix(name, "gamma"), 64)
+		if err != nil || g <= 0 || g > 10 {
+			return nil, fmt.Errorf("invalid gamma curve %q (e.g. gamma2.2)", name)
+		}
+		f = func(x float64) float64 { return math.Pow(x, g) }
+	case name == "custom":
+		points := ch.CurveTable
+		if len(points) < 2 || len(points) > 256 {
+			return nil, fmt.Errorf("custom curve needs 2-256 curve_table points, got %d", len(points))
+		}
+		f = func(x float64) float64 {
+			pos := x * float64(len(points)-1)
+			i := int(pos)
+			if i >= len(points)-1 {
+				return float64(points[len(points)-1]) / 255
+			}
+			frac := pos - float64(i)
+			return (float64(points[i]) + (float64(points[i+1])-float64(points[i]))*frac) / 255
+		}
+	default:
+		return nil, fmt.Errorf("unknown curve %q (linear, gammaX.Y, log, custom)", name)
+	}
+
+	var table [256]uint8
+	for v := 1; v < 256; v++ {
+		out := math.Round(f(float64(v)/255) * 255)
+		table[v] = uint8(math.Max(1, math.Min(255, out)))
+	}
+	return &table, nil
+}
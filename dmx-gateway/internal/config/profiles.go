@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package config
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+)
+
+// Fixture profiles
+// A profile is the channel layout of a fixture model, numbered from 1. A light patched
+// from one lists a single entry, { profile: rgbw, start: 33 }: the profile channels are
+// copied with their numbers moved to start (ch 1 -> 33, fine_ch too). A profile channel
+// without ch takes the slot after the previous one. Lights are expanded when the config
+// is validated, so the state, the API and the patch report see the channels, and the
+// profile entry is kept: the config file and documents (saved lights, GET /api/config)
+// show it again as long as the light still has the profile channels.
+
+// expandProfiles replaces the profile entries of lights with the profile channels
+func (c *Config) expandProfiles() error {
+	for name, layout := range c.Profiles {
+		if len(layout) == 0 {
+			return fmt.Errorf("profile %q has no channels", name)
+		}
+		for _, ch := range layout {
+			if ch.Profile != "" || ch.Start != 0 || ch.Alias {
+				return fmt.Errorf("profile %q: channels take no profile, start or alias", name)
+			}
+		}
+	}
+	profiled := maps.Clone(c.profiled) // Of a previous expansion (derived config)
+	if profiled == nil {
+		profiled = make(map[string]Channel)
+	}
+	for group, lights := range c.Lights {
+		for name, channels := range lights {
+			ref := -1
+			for i, ch := range channels {
+				if ch.Profile != "" {
+					ref = i
+				}
+			}
+			if ref < 0 {
+				continue
+			}
+			fullName := group + "/" + name
+			if len(channels) > 1 {
+				return fmt.Errorf("light %q: a profile excludes other channels", fullName)
+			}
+			layout, ok := c.Profiles[channels[ref].Profile]
+			if !ok {
+				return fmt.Errorf("light %q: unknown profile %q", fullName, channels[ref].Profile)
+			}
+			if channels[ref].Start < 1 {
+				return fmt.Errorf("light %q: profile %q needs a start channel", fullName, channels[ref].Profile)
+			}
+			lights[name] = patchProfile(layout, channels[ref].Start)
+			profiled[LightKey(group, name)] = channels[ref]
+		}
+	}
+	c.profiled = profiled
+	return nil
+}
+
+// stored returns c with the lights patched from a profile written as their profile
+// entry, as in the config file (c itself when there are none)
+func (c *Config) stored() *Config {
+	if len(c.profiled) == 0 {
+		return c
+	}
+	stored := *c
+	stored.Lights = make(map[string]map[string][]Channel, len(c.Lights))
+	for group, lights := range c.Lights {
+		stored.Lights[group] = make(map[string][]Channel, len(lights))
+		for name, channels := range lights {
+			ref, ok := c.profiled[LightKey(group, name)]
+			if layout, known := c.Profiles[ref.Profile]; ok && known &&
+				reflect.DeepEqual(channels, patchProfile(layout, ref.Start)) {
+				channels = []Channel{ref} // Not repatched since
+			}
+			stored.Lights[group][name] = channels
+		}
+	}
+	return &stored
+}
+
+// patchProfile returns the channels of a profile numbered from start
+func patchProfile(layout []Channel, start int) []Channel {
+	channels := make([]Channel, len(layout))
+	next := 1
+	for i, ch := range layout {
+		if ch.Ch == 0 {
+			ch.Ch = next
+		}
+		next = max(ch.Ch, ch.FineCh) + 1
+		ch.Ch += start - 1
+		if ch.FineCh != 0 {
+			ch.FineCh += start - 1
+		}
+		channels[i] = ch
+	}
+	return channels
+}
+
+// Patch report
+// The span of each light in the universe, the unpatched gaps between them and the
+// channels several lights use (aliases, two owners are rejected by Validate).
+
+// PatchReport describes how the lights use the universe
+type PatchReport struct {
+	Lights   []PatchRange   `json:"lights"`   // In channel order
+	Used     int            `json:"used"`     // Channels patched
+	Gaps     []PatchGap     `json:"gaps"`     // Unpatched spans between patched channels
+	Overlaps []PatchOverlap `json:"overlaps"` // Channels used by several lights (aliases)
+}
+
+// PatchRange is the channel span of a light
+type PatchRange struct {
+	Light    string `json:"light"` // "group/light"
+	First    int    `json:"first"`
+	Last     int    `json:"last"`
+	Channels int    `json:"channels"` // Slots used in the span (fine included)
+}
+
+// PatchGap is a span of unpatched channels
+type PatchGap struct {
+	First int `json:"first"`
+	Last  int `json:"last"`
+}
+
+// PatchOverlap is a channel used by several lights
+type PatchOverlap struct {
+	Ch     int      `json:"ch"`
+	Lights []string `json:"lights"`
+}
+
+// Patch returns the patch report of the lights
+func (c *Config) Patch() PatchReport {
+	report := PatchReport{Lights: []PatchRange{}, Gaps: []PatchGap{}, Overlaps: []PatchOverlap{}}
+	users := make(map[int][]string)
+	for group, lights := range c.Lights {
+		for name, channels := range lights {
+			r := PatchRange{Light: LightKey(group, name)}
+			for _, ch := range channels {
+				for _, slot := range []int{ch.Ch, ch.FineCh} {
+					if slot < 1 || slot > 512 {
+						continue
+					}
+					users[slot] = append(users[slot], r.Light)
+					if r.First == 0 || slot < r.First {
+						r.First = slot
+					}
+					r.Last = max(r.Last, slot)
+					r.Channels++
+				}
+			}
+			if r.Channels > 0 {
+				report.Lights = append(report.Lights, r)
+			}
+		}
+	}
+	sort.Slice(report.Lights, func(i, j int) bool {
+		a, b := report.Lights[i], report.Lights[j]
+		if a.First != b.First {
+			return a.First < b.First
+		}
+		return a.Light < b.Light
+	})
+
+	last := 0 // Last patched channel
+	for ch := 1; ch <= 512; ch++ {
+		lights := users[ch]
+		if len(lights) == 0 {
+			continue
+		}
+		report.Used++
+		if last > 0 && ch > last+1 {
+			report.Gaps = append(report.Gaps, PatchGap{First: last + 1, Last: ch - 1})
+		}
+		last = ch
+		if len(lights) > 1 {
+			sort.Strings(lights)
+			report.Overlaps = append(report.Overlaps, PatchOverlap{Ch: ch, Lights: lights})
+		}
+	}
+	return report
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// JSON Schema
+// Schema describes the config file for editors (yaml-language-server) and provisioning
+// pipelines (-schema, GET /api/config/schema). It is reflected from the yaml tags of
+// Config, so it follows the code: named structs are $defs, unknown keys are rejected
+// as by a config update, and the names the config checks (weekdays, holiday modes,
+// webhook events...) are enums. Only the structure is described, Validate checks the rest.
+
+// schemaEnums lists the values of string fields ("Type.key"; items of a list)
+var schemaEnums = map[string][]string{
+	"ScheduleEvent.days":    Weekdays,
+	"ScheduleEvent.holiday": HolidayModes,
+	"WebhookConfig.events":  WebhookEvents,
+	"ModbusPoint.format":    ModbusPointFormats,
+}
+
+// Schema returns the JSON Schema (draft 2020-12) of the config file
+func Schema() map[string]any {
+	defs := map[string]any{}
+	root := schemaObject(reflect.TypeFor[Config](), defs)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "DMX Gateway configuration"
+	root["required"] = []string{"lights"}
+	root["$defs"] = defs
+	return root
+}
+
+// schemaOf returns the schema of t as yaml.v3 decodes it
+func schemaOf(t reflect.Type, defs map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Uint8:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": 255}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		s := map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), defs)}
+		if k := t.Key().Kind(); k >= reflect.Int && k <= reflect.Uint64 {
+			s["propertyNames"] = map[string]any{"pattern": "^[0-9]+$"} // Channel numbers
+		}
+		return s
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = map[string]any{} // Placeholder for recursive types
+			defs[t.Name()] = schemaObject(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name()}
+	}
+	return map[string]any{} // Any value
+}
+
+// schemaObject returns the object schema of a struct, its keys from the yaml tags
+func schemaObject(t reflect.Type, defs map[string]any) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		s := schemaOf(f.Type, defs)
+		if enum, ok := schemaEnums[t.Name()+"."+name]; ok {
+			if items, ok := s["items"].(map[string]any); ok {
+				items["enum"] = enum
+			} else {
+				s["enum"] = enum
+			}
+		}
+		props[name] = s
+	}
+	return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+}
@@ -10,27 +10,297 @@ type Config struct {
 	DMX      DMXConfig                         `yaml:"dmx"`
 	Modbus   *ModbusConfig                     `yaml:"modbus,omitempty"`
 	MQTT     *MQTTConfig                       `yaml:"mqtt,omitempty"`
+	Remoteproc *RemoteprocConfig               `yaml:"remoteproc,omitempty"`
 	Schedule *ScheduleConfig                   `yaml:"schedule,omitempty"`
+	Scenes   *ScenesConfig                     `yaml:"scenes,omitempty"`
+	Presets  map[string]map[string]map[string]uint8 `yaml:"presets,omitempty"` // target -> preset -> channel -> value
+	Tags     map[string][]string               `yaml:"tags,omitempty"`    // tag -> targets ("group" or "group/light")
+	Metadata map[string]LightMeta              `yaml:"metadata,omitempty"` // target -> location and notes (see LightMeta)
+	Arbitration *ArbitrationConfig             `yaml:"arbitration,omitempty"`
+	Startup  *StartupConfig                    `yaml:"startup,omitempty"`
+	Persist  *PersistConfig                    `yaml:"persist,omitempty"`
+	Cues     map[string][]Cue                  `yaml:"cues,omitempty"` // cue list -> ordered cues
+	Recorder *RecorderConfig                   `yaml:"recorder,omitempty"`
+	Shows    map[string]*Show                  `yaml:"shows,omitempty"` // name -> timeline
+	Park     map[int]uint8                     `yaml:"park,omitempty"`  // DMX channel -> value held from startup
+	History  *HistoryConfig                    `yaml:"history,omitempty"`
+	Auth     *AuthConfig                       `yaml:"auth,omitempty"` // Presence requires credentials on /api and /ws
+	Webhooks []WebhookConfig                   `yaml:"webhooks,omitempty"`
+	Profiles map[string][]Channel              `yaml:"profiles,omitempty"` // name -> fixture channel layout (see profiles.go)
 	Lights   map[string]map[string][]Channel   `yaml:"lights"` // group -> light -> channels
+
+	path     string             // File loaded from (see SaveLights)
+	profiled map[string]Channel // "group/light" -> profile entry it was patched from
+	strict   bool               // Warnings are errors (see warnings.go)
+	warnings []string           // Of the last validation
+}
+
+// AuthConfig defines the credentials accepted by the HTTP API and WebSocket
+type AuthConfig struct {
+	Keys []APIKey  `yaml:"keys,omitempty"`
+	JWT  *JWTConfig `yaml:"jwt,omitempty"` // Presence accepts bearer tokens
+
+	WSGraceSec int `yaml:"ws_grace_sec,omitempty"` // WebSocket first-message authentication deadline (default 5)
+}
+
+// APIKey is a static key sent in the X-API-Key header or the api_key query parameter
+type APIKey struct {
+	Name  string `yaml:"name"`            // Shown in logs
+	Key   string `yaml:"key"`
+	Scope string `yaml:"scope,omitempty"` // read (viewer), write (operator, default) or admin
+}
+
+// JWTConfig defines how bearer tokens are verified (HS256)
+type JWTConfig struct {
+	Secret    string `yaml:"secret"`               // Shared HMAC secret
+	Issuer    string `yaml:"issuer,omitempty"`     // Required iss claim (empty = any)
+	RoleClaim string `yaml:"role_claim,omitempty"` // Claim holding viewer/operator/admin (default "role")
+}
+
+// ScenesConfig defines scene persistence
+// Without this section scenes are kept in memory only
+type ScenesConfig struct {
+	File string `yaml:"file"` // JSON file, e.g. "/etc/dmx-gw/scenes.json"
+}
+
+// ArbitrationConfig defines how writes from different sources are merged per channel
+// Presence of this section enables source tracking (otherwise the last write wins)
+type ArbitrationConfig struct {
+	Policy     string            `yaml:"policy"`     // ltp (default) or htp
+	Priorities map[string]int    `yaml:"priorities"` // source -> priority (default 0), highest priority sources win
+	Groups     map[string]string `yaml:"groups"`     // group -> policy override
+	Channels   map[int]string    `yaml:"channels"`   // DMX channel -> policy override
+}
+
+// Write sources known to arbitration
+var ArbitrationSources = []string{"local", "http", "ws", "mqtt", "modbus", "scheduler", "effect", "player", "show"}
+
+// PersistConfig defines last-state persistence
+// Presence of this section enables periodic snapshots
+type PersistConfig struct {
+	File          string `yaml:"file"`            // e.g. "/var/lib/dmx-gateway/state.json"
+	IntervalSec   int    `yaml:"interval_sec"`    // Snapshot check interval (default 10)
+	RestoreOnBoot bool   `yaml:"restore_on_boot"` // Restore channels/enabled on startup (replaces startup values)
+}
+
+// Show is a timeline of steps run relative to the show start
+// With loop, the show restarts once the last step (at_ms + fade_ms) completes.
+type Show struct {
+	Loop  bool       `yaml:"loop,omitempty" json:"loop,omitempty"`
+	Steps []ShowStep `yaml:"steps" json:"steps"`
+}
+
+// ShowStep is one timeline action, applied in order: scene, presets, values, blackout
+type ShowStep struct {
+	AtMs     int                         `yaml:"at_ms" json:"at_ms"` // Offset from show start
+	Scene    string                      `yaml:"scene,omitempty" json:"scene,omitempty"`
+	Preset   map[string]string           `yaml:"preset,omitempty" json:"preset,omitempty"` // target -> preset name
+	Set      map[string]map[string]uint8 `yaml:"set,omitempty" json:"set,omitempty"`       // target -> channel -> value
+	FadeMs   int                         `yaml:"fade_ms,omitempty" json:"fade_ms,omitempty"`
+	Blackout bool                        `yaml:"blackout,omitempty" json:"blackout,omitempty"`
+}
+
+// RecorderConfig defines where show recordings are stored
+// Presence of this section enables record/playback
+type RecorderConfig struct {
+	Dir      string `yaml:"dir"`                // One <name>.jsonl file per recording
+	Autoplay string `yaml:"autoplay,omitempty"` // Recording played on boot (unattended demos)
+	Loop     bool   `yaml:"loop,omitempty"`     // Loop the autoplay recording
+}
+
+// StartupConfig defines power-on values applied after auto-enable
+// Applied in order: scene, presets, then explicit values.
+type StartupConfig struct {
+	Scene  string                      `yaml:"scene,omitempty"`  // Named scene to recall
+	Preset map[string]string           `yaml:"preset,omitempty"` // target -> preset name
+	Set    map[string]map[string]uint8 `yaml:"set,omitempty"`    // target -> channel -> value
+	FadeMs int                         `yaml:"fade_ms,omitempty"` // Ramp up instead of jumping
+}
+
+// WebhookConfig posts events as JSON to a URL (see internal/webhook)
+type WebhookConfig struct {
+	URL       string            `yaml:"url"`
+	Events    []string          `yaml:"events,omitempty"`     // See WebhookEvents (empty = all)
+	Headers   map[string]string `yaml:"headers,omitempty"`    // e.g. Authorization (values redacted in GET /api/config)
+	Retries   int               `yaml:"retries,omitempty"`    // Attempts after the first failure (default 3)
+	BackoffMs int               `yaml:"backoff_ms,omitempty"` // Delay before the first retry, doubled each time (default 1000)
+	TimeoutMs int               `yaml:"timeout_ms,omitempty"` // Per attempt (default 5000)
+}
+
+// Events a webhook can subscribe to
+var WebhookEvents = []string{"enable", "disable", "blackout", "schedule", "backend"}
+
+// HistoryConfig sizes the change history (recorded even without this section)
+type HistoryConfig struct {
+	Size int `yaml:"size"` // Changes kept (default 500)
+}
+
+// Cue is one step of a cue list: a scene recalled with its own fade time
+type Cue struct {
+	Scene    string `yaml:"scene" json:"scene"`
+	FadeMs   int    `yaml:"fade_ms,omitempty" json:"fade_ms,omitempty"`
+	FollowMs int    `yaml:"follow_ms,omitempty" json:"follow_ms,omitempty"` // Auto-advance this long after the cue starts (0 = wait for go)
 }
 
 // ScheduleConfig defines scheduler settings
 type ScheduleConfig struct {
-	Timezone string          `yaml:"timezone"` // e.g. "Europe/Paris", defaults to local
-	Events   []ScheduleEvent `yaml:"events"`
+	Timezone  string            `yaml:"timezone"` // e.g. "Europe/Paris", defaults to local
+	Events    []ScheduleEvent   `yaml:"events"`
+	Circadian *CircadianConfig  `yaml:"circadian,omitempty"`
+	Holidays  []Holiday         `yaml:"holidays,omitempty"` // Days when events run their holiday program
+	Calendar  string            `yaml:"calendar,omitempty"` // iCal file (.ics) whose events are holidays too
+	CatchUp   bool              `yaml:"catch_up,omitempty"` // At startup, run the last event due earlier today
+	Zones     map[string]string `yaml:"zones,omitempty"`    // Name -> timezone of events sharing it ("site_b": "America/New_York")
+
+	Photoperiod *PhotoperiodConfig `yaml:"photoperiod,omitempty"`
+}
+
+// PhotoperiodConfig drives grow lights through a daily cycle: dawn ramp, day, dusk ramp, night
+type PhotoperiodConfig struct {
+	On          string                      `yaml:"on"`                     // Lights on, start of the dawn ramp ("06:00")
+	Hours       float64                     `yaml:"hours"`                  // Lights-on duration, ramps included (18 = 18/6)
+	SunriseMin  int                         `yaml:"sunrise_min,omitempty"`  // Dawn ramp length (0 = switch on)
+	SunsetMin   int                         `yaml:"sunset_min,omitempty"`   // Dusk ramp length (0 = switch off)
+	Spectra     map[string]map[string]uint8 `yaml:"spectra"`                // target -> color -> day value (0 at night)
+	IntervalSec int                         `yaml:"interval_sec,omitempty"` // Ramp update period, faded (default 10)
+	DLI         *DLIConfig                  `yaml:"dli,omitempty"`          // Optional: dim the spectra to reach a daily light integral
+}
+
+// DLIConfig sets the daily light integral the photoperiod dims its spectra to
+type DLIConfig struct {
+	Target     float64 `yaml:"target"`                // mol/m²/day
+	PPFDMax    float64 `yaml:"ppfd_max"`              // µmol/m²/s at the canopy with the full spectra
+	Sensor     string  `yaml:"sensor,omitempty"`      // Variable with the measured PPFD (mqtt.inputs, modbus.poll), sunlight included
+	MinPercent int     `yaml:"min_percent,omitempty"` // Lowest scale of the spectra (default 0)
+}
+
+// Holiday is a day or period off (office closed, public holiday)
+type Holiday struct {
+	Date string `yaml:"date"`           // "YYYY-MM-DD", or "MM-DD" every year
+	To   string `yaml:"to,omitempty"`   // Last day of a period, same form as date
+	Name string `yaml:"name,omitempty"` // Shown in /api/schedule
+}
+
+// CircadianConfig drives tunable-white lights (channels with cct) along a daily CCT/intensity curve
+// Without curve points, the curve follows sunrise/sunset at latitude/longitude.
+type CircadianConfig struct {
+	Targets     []string         `yaml:"targets"`               // "group" or "group/light"
+	WarmK       int              `yaml:"warm_k,omitempty"`      // Sun mode: night/horizon CCT (default 2700)
+	CoolK       int              `yaml:"cool_k,omitempty"`      // Sun mode: noon CCT (default 6500)
+	Curve       []CircadianPoint `yaml:"curve,omitempty"`       // Interpolated linearly, wraps at midnight
+	Latitude    float64          `yaml:"latitude,omitempty"`    // Sun mode
+	Longitude   float64          `yaml:"longitude,omitempty"`   // Sun mode
+	Level       uint8            `yaml:"level,omitempty"`       // Sun mode: noon intensity (default 255)
+	IntervalSec int              `yaml:"interval_sec,omitempty"` // Update period, faded (default 10)
+}
+
+// CircadianPoint is a curve key point
+type CircadianPoint struct {
+	Time  string `yaml:"time"`  // "HH:MM" or "HH:MM:SS"
+	CCT   int    `yaml:"cct"`   // Kelvin
+	Level uint8  `yaml:"level"` // Intensity 0-255
 }
 
 // ScheduleEvent defines a scheduled action
 type ScheduleEvent struct {
-	Time     string                       `yaml:"time"`              // "HH:MM:SS"
-	Set      map[string]map[string]uint8  `yaml:"set,omitempty"`     // target -> color -> value
-	Blackout bool                         `yaml:"blackout,omitempty"`
+	ID       string                      `yaml:"id,omitempty" json:"id"`                 // Lowest free number when missing (see /api/schedule/events)
+	Time     string                      `yaml:"time,omitempty" json:"time,omitempty"`   // "HH:MM:SS" or "HH:MM"
+	Cron     string                      `yaml:"cron,omitempty" json:"cron,omitempty"`   // Instead of time: "*/15 * * * *", "0 8 * * mon#1" (see cron package)
+	Days     []string                    `yaml:"days,omitempty" json:"days,omitempty"`   // Weekdays it runs ("mon"... "sun", empty = every day)
+	Set      map[string]map[string]uint8 `yaml:"set,omitempty" json:"set,omitempty"`     // target -> color -> value
+	Blackout bool                        `yaml:"blackout,omitempty" json:"blackout,omitempty"`
+	Scene    string                      `yaml:"scene,omitempty" json:"scene,omitempty"` // Recall a named scene
+	Preset   map[string]string           `yaml:"preset,omitempty" json:"preset,omitempty"` // target -> preset name
+	Disabled bool                        `yaml:"disabled,omitempty" json:"disabled,omitempty"` // Kept but not run
+	Holiday  string                      `yaml:"holiday,omitempty" json:"holiday,omitempty"`   // On holidays: "skip" (default), "only" (alternate program) or "also"
+	Jitter   string                      `yaml:"jitter,omitempty" json:"jitter,omitempty"`     // "30m": runs up to this much earlier or later, drawn each day (time events, max 12h)
+	When     []Condition                 `yaml:"when,omitempty" json:"when,omitempty"`         // All must hold when due, else the run is skipped
+	Timezone string                      `yaml:"timezone,omitempty" json:"timezone,omitempty"` // Runs in this timezone (name or one of zones) instead of the schedule's
+}
+
+// Condition tests a variable, the level of a target or the enabled flag when an event is due
+// Exactly one of variable, level and enabled; variable and level take below, above or equals.
+type Condition struct {
+	Variable string   `yaml:"variable,omitempty" json:"variable,omitempty"` // Variable name (mqtt.inputs, modbus.poll), false while never received
+	Level    string   `yaml:"level,omitempty" json:"level,omitempty"`       // Target "group" or "group/light": its highest channel value (0-255)
+	Color    string   `yaml:"color,omitempty" json:"color,omitempty"`       // Level of this channel only
+	Enabled  *bool    `yaml:"enabled,omitempty" json:"enabled,omitempty"`   // DMX output enabled
+	Below    *float64 `yaml:"below,omitempty" json:"below,omitempty"`
+	Above    *float64 `yaml:"above,omitempty" json:"above,omitempty"`
+	Equals   *float64 `yaml:"equals,omitempty" json:"equals,omitempty"`
 }
 
+// HolidayModes lists the values of ScheduleEvent.Holiday
+var HolidayModes = []string{"skip", "only", "also"}
+
+// Weekdays lists the day names of ScheduleEvent.Days, indexed by time.Weekday
+var Weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
 // ModbusConfig defines Modbus TCP server settings
 // Presence of this section enables Modbus
 type ModbusConfig struct {
-	Port string `yaml:"port"` // ":502" or ":5020"
+	Port       string           `yaml:"port"`            // ":502" or ":5020" (default ":502", no TCP when only rtu is set)
+	UnitID     uint8            `yaml:"unit_id"`         // TCP unit id answered (0 = any, default)
+	OtherUnits string           `yaml:"other_units"`     // Frames for another TCP unit id: "ignore" (default) or "reject" (exception 0x0B)
+	RTU        *ModbusRTUConfig `yaml:"rtu,omitempty"`   // Optional: Modbus RTU slave on a serial port
+	Coils      []ModbusCoil     `yaml:"coils,omitempty"` // Coils 2, 3...: switch a light or group
+	Map        *ModbusMapConfig `yaml:"map,omitempty"`   // Optional: register and coil addresses
+	Scale      string           `yaml:"scale"`           // Channel registers: "raw" (0-255, default) or "percent" (0-1000)
+	Poll       []ModbusDevice   `yaml:"poll,omitempty"`  // Optional: external devices read as variables (master)
+}
+
+// ModbusDevice is an external Modbus TCP device polled for readings
+type ModbusDevice struct {
+	Address    string        `yaml:"address"`     // host:port
+	UnitID     uint8         `yaml:"unit_id"`     // default 1
+	IntervalMs int           `yaml:"interval_ms"` // default 5000
+	TimeoutMs  int           `yaml:"timeout_ms"`  // default 1000
+	Points     []ModbusPoint `yaml:"points"`
+}
+
+// ModbusPoint stores a device register as a variable
+type ModbusPoint struct {
+	Name     string  `yaml:"name"`     // Variable name
+	Register int     `yaml:"register"` // 0-65535
+	Type     string  `yaml:"type"`     // "holding" (default) or "input"
+	Format   string  `yaml:"format"`   // uint16 (default), int16, uint32, int32 or float32 (32-bit: high word first)
+	Scale    float64 `yaml:"scale"`    // Multiplier (default 1)
+}
+
+// ModbusPointFormats lists the register formats of a polled point
+var ModbusPointFormats = []string{"uint16", "int16", "uint32", "int32", "float32"}
+
+// ModbusMapConfig places the Modbus registers and coils (SCADA templates)
+type ModbusMapConfig struct {
+	Channels int            `yaml:"channels"`         // First of the 512 DMX channel registers (default 0)
+	Scene    *int           `yaml:"scene"`            // Scene recall register (default channels + 512)
+	Fade     *int           `yaml:"fade"`             // Scene fade register in ms (default scene + 1)
+	Coils    int            `yaml:"coils"`            // First coil (enable), blackout and coils follow (default 0)
+	Lights   map[string]int `yaml:"lights,omitempty"` // Light -> first register of a block, one per channel
+}
+
+// ModbusCoil switches a light or group between a preset (1) and off (0)
+type ModbusCoil struct {
+	Target string `yaml:"target"`  // "group" or "group/light"
+	Preset string `yaml:"preset"`  // Preset recalled on 1
+	FadeMs int    `yaml:"fade_ms"` // Optional: fade both ways
+}
+
+// ModbusRTUConfig defines the Modbus RTU serial slave
+type ModbusRTUConfig struct {
+	Device   string `yaml:"device"`    // e.g. "/dev/ttyS3"
+	Baud     int    `yaml:"baud"`      // default 19200
+	Parity   string `yaml:"parity"`    // "N", "E" or "O" (default "E")
+	StopBits int    `yaml:"stop_bits"` // 1 or 2 (default 1, 2 without parity)
+	UnitID   uint8  `yaml:"unit_id"`   // Slave address 1-247 (default 1)
+	RS485    bool   `yaml:"rs485"`     // Let the UART driver switch the RS-485 transceiver (RTS)
+}
+
+// RemoteprocConfig defines M-core firmware management via /sys/class/remoteproc
+// Presence of this section enables /api/firmware
+type RemoteprocConfig struct {
+	Device      string `yaml:"device"`       // defaults to "remoteproc0"
+	FirmwareDir string `yaml:"firmware_dir"` // defaults to "/lib/firmware"
 }
 
 // MQTTConfig defines MQTT client settings
@@ -41,11 +311,71 @@ type MQTTConfig struct {
 	Username    string `yaml:"username"`     // optional
 	Password    string `yaml:"password"`     // optional
 	TopicPrefix string `yaml:"topic_prefix"` // defaults to "dmx"
+	Targets     []string `yaml:"targets,omitempty"` // Groups/lights published on <prefix>/event (empty = all)
+
+	QoS    map[string]byte `yaml:"qos,omitempty"`    // Topic class -> QoS 0-2 (defaults: command 1, others 0)
+	Retain map[string]bool `yaml:"retain,omitempty"` // Topic class -> retained publishes (defaults: status, light)
+
+	EventIntervalMs int `yaml:"event_interval_ms,omitempty"` // Minimum interval between state publishes (0 = every update)
+
+	Brokers []MQTTConfig `yaml:"brokers,omitempty"` // Additional connections, same fields (no nested brokers)
+
+	Inputs []MQTTInput `yaml:"inputs,omitempty"` // External topics stored as named variables
 }
 
+// MQTTInput maps an external topic (sensor, occupancy...) to a named variable
+type MQTTInput struct {
+	Topic string `yaml:"topic"`           // May hold wildcards
+	Name  string `yaml:"name"`            // Variable name (letters, digits, _ . -)
+	Field string `yaml:"field,omitempty"` // JSON payload: dotted path of the value ("sensor.temp"), empty = whole payload
+}
+
+// Connections returns the broker connections: this one, then the additional brokers
+// Each publishes events to its broker and accepts commands from it.
+func (m *MQTTConfig) Connections() []MQTTConfig {
+	primary := *m
+	primary.Brokers = nil
+	return append([]MQTTConfig{primary}, m.Brokers...)
+}
+
+// MQTT topic classes: command = <prefix>/cmd and light set subscriptions, event =
+// <prefix>/event, status = <prefix>/status, light = light state topics, response =
+// <prefix>/response. Retain applies to the published ones except response.
+var (
+	MQTTQoSClasses    = []string{"command", "event", "status", "light", "response"}
+	MQTTRetainClasses = []string{"event", "status", "light"}
+)
+
 // ServerConfig defines server endpoints
 type ServerConfig struct {
-	HTTP string `yaml:"http"`
+	HTTP string     `yaml:"http"`
+	TLS  *TLSConfig `yaml:"tls,omitempty"` // Presence serves HTTPS (and WSS) on the http address
+
+	AllowedOrigins []string `yaml:"allowed_origins,omitempty"`  // Browser origins allowed besides the gateway itself
+	AllowAnyOrigin bool     `yaml:"allow_any_origin,omitempty"` // Development: accept any origin
+
+	RateLimit *RateLimitConfig `yaml:"rate_limit,omitempty"` // Presence limits API requests per client
+
+	WSPingSec int `yaml:"ws_ping_sec,omitempty"` // WebSocket keepalive ping interval (default 30, dropped after 2 unanswered)
+	WSIdleSec int `yaml:"ws_idle_sec,omitempty"` // Close WebSocket clients that send nothing this long (0 = never)
+
+	Compression string `yaml:"compression,omitempty"` // gzip (default) or off
+	Debug       bool   `yaml:"debug,omitempty"`       // Serve pprof and expvar under /debug (also the -debug flag)
+}
+
+// RateLimitConfig defines token bucket limits (requests per second, bursts up to burst)
+type RateLimitConfig struct {
+	RPS     float64 `yaml:"rps"`                // Per API key or IP address
+	Burst   int     `yaml:"burst,omitempty"`    // Default 2x rps
+	WSRPS   float64 `yaml:"ws_rps,omitempty"`   // Incoming messages per WebSocket connection (0 = unlimited)
+	WSBurst int     `yaml:"ws_burst,omitempty"` // Default 2x ws_rps
+}
+
+// TLSConfig defines the HTTPS certificate and optional client certificate check (mTLS)
+type TLSConfig struct {
+	Cert     string `yaml:"cert"`                // PEM certificate (chain)
+	Key      string `yaml:"key"`                 // PEM private key
+	ClientCA string `yaml:"client_ca,omitempty"` // PEM CA bundle: presence requires client certificates signed by it
 }
 
 // DMXConfig defines DMX backend settings
@@ -55,14 +385,62 @@ type DMXConfig struct {
 	ThrottleMs int    `yaml:"throttle_ms"`
 	TimeoutMs  int    `yaml:"timeout_ms"`
 	RefreshMs  int    `yaml:"refresh_ms"`  // Periodic state refresh (0 = disabled)
+	StatusPollMs int  `yaml:"status_poll_ms"` // Background status polling interval (default 1000, negative = disabled)
 	AutoEnable bool   `yaml:"auto_enable"` // Enable DMX output on startup
+	FPS        int    `yaml:"fps,omitempty"` // Firmware DMX refresh rate in Hz (1-44, 0 = firmware default)
+
+	WatchdogFailures int `yaml:"watchdog_failures"` // Consecutive backend errors before recovery (default 5)
+
+	Backend string        `yaml:"backend,omitempty"` // Active backend at startup: rpmsg (default), mock, artnet
+	ArtNet  *ArtNetConfig `yaml:"artnet,omitempty"`  // Presence makes the artnet backend available
+
+	Failover *FailoverConfig `yaml:"failover,omitempty"` // Presence enables primary -> secondary failover
+
+	PowerUp *PowerUpConfig `yaml:"power_up,omitempty"` // Presence staggers enable group by group
+}
+
+// PowerUpConfig defines the staggered power-up applied when output is enabled
+type PowerUpConfig struct {
+	GroupDelayMs int      `yaml:"group_delay_ms"`  // Delay between groups (default 500)
+	Order        []string `yaml:"order,omitempty"` // Groups first on, the others follow alphabetically
+}
+
+// FailoverConfig defines the secondary backend used when the primary keeps failing
+type FailoverConfig struct {
+	Backend  string `yaml:"backend"`   // Secondary backend (rpmsg, mock, artnet)
+	AfterSec int    `yaml:"after_sec"` // Seconds of continuous primary errors before failover (default 10)
+}
+
+// ArtNetConfig defines the Art-Net output backend
+type ArtNetConfig struct {
+	Address  string `yaml:"address"`  // Node IP or host:port (default port 6454)
+	Universe int    `yaml:"universe"` // Port-Address (net/subnet/universe, 0-32767)
 }
 
 // Channel defines a single DMX channel with color
 type Channel struct {
-	Ch    int    `yaml:"ch"`
-	Color string `yaml:"color"`
-	Name  string `yaml:"name,omitempty"` // Optional, defaults to color
+	Ch    int    `yaml:"ch" json:"ch"`
+	Color string `yaml:"color" json:"color"`
+	Name  string `yaml:"name,omitempty" json:"name,omitempty"` // Optional, defaults to color
+	FineCh int   `yaml:"fine_ch,omitempty" json:"fine_ch,omitempty"` // 16-bit pair: fine (LSB) slot, ch is the coarse (MSB) slot
+	Min   uint8  `yaml:"min,omitempty" json:"min,omitempty"`  // Lowest non-zero output (0 stays off)
+	Max   uint8  `yaml:"max,omitempty" json:"max,omitempty"`  // Highest output (0 = 255)
+	Curve string `yaml:"curve,omitempty" json:"curve,omitempty"` // Dimming curve: linear (default), gamma2.2, log, custom
+	CCT   int    `yaml:"cct,omitempty" json:"cct,omitempty"`   // Tunable white: this channel's color temperature (Kelvin)
+	Alias bool   `yaml:"alias,omitempty" json:"alias,omitempty"` // Virtual: reuses a channel owned by another light (inherits its settings)
+
+	CurveTable []uint8 `yaml:"curve_table,omitempty" json:"curve_table,omitempty"` // Points for curve: custom
+
+	Profile string `yaml:"profile,omitempty" json:"profile,omitempty"` // Sole entry of a light: patch this profile from start
+	Start   int    `yaml:"start,omitempty" json:"start,omitempty"`     // First channel of the profile
+}
+
+// LightMeta describes where a light or group is, for UIs ("Greenhouse 2, Row 4")
+// A light takes the location and notes of its group unless it has its own; tags are
+// given in the tags section.
+type LightMeta struct {
+	Location string `yaml:"location,omitempty" json:"location,omitempty"`
+	Notes    string `yaml:"notes,omitempty" json:"notes,omitempty"`
 }
 
 // ResolvedChannel is a channel with resolved color hex and name
@@ -71,6 +449,10 @@ type ResolvedChannel struct {
 	Color string `json:"color"` // Hex color
 	Name  string `json:"name"`
 	Value uint8  `json:"value"`
+	FineCh int   `json:"fine_ch,omitempty"`
+	Min   uint8  `json:"min,omitempty"`
+	Max   uint8  `json:"max,omitempty"`
+	CCT   int    `json:"cct,omitempty"`
 }
 
 // ResolvedLight is a light with all channels resolved
@@ -0,0 +1,320 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Runtime configuration updates
+// A new document (the whole config, or one top-level section) is derived from the
+// running config: same file, and secrets sent back as "[redacted]" (as GET /api/config
+// shows them) keep their current value. Unknown keys are rejected. Changed lists the
+// sections that differ and Save rewrites only those in the file, keeping the others
+// and their comments.
+
+// Derive parses data (YAML or JSON) as a replacement for c
+func (c *Config) Derive(data []byte) (*Config, error) {
+	var next Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&next); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	next.unredact(c)
+	next.applyDefaults()
+	next.strict = c.strict
+	if err := next.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config: %w", err)
+	}
+	next.path = c.path
+	return &next, nil
+}
+
+// DeriveSection returns c with one top-level section replaced by data (YAML or JSON)
+func (c *Config) DeriveSection(section string, data []byte) (*Config, error) {
+	if !slices.Contains(Sections(), section) {
+		return nil, fmt.Errorf("unknown section %q", section)
+	}
+	doc, err := c.document()
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", section, err)
+	}
+	if value == nil {
+		delete(doc, section)
+	} else {
+		doc[section] = value
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return c.Derive(out)
+}
+
+// DeriveSchedule returns c with the schedule events replaced (new events get an id)
+func (c *Config) DeriveSchedule(events []ScheduleEvent) (*Config, error) {
+	schedule := ScheduleConfig{}
+	if c.Schedule != nil {
+		schedule = *c.Schedule
+	}
+	schedule.Events = events
+	data, err := yaml.Marshal(schedule)
+	if err != nil {
+		return nil, err
+	}
+	return c.DeriveSection("schedule", data)
+}
+
+// Sections returns the top-level section names, in file order
+func Sections() []string {
+	t := reflect.TypeFor[Config]()
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Changed returns the sections that differ between c and next, in file order
+func (c *Config) Changed(next *Config) ([]string, error) {
+	before, err := c.document()
+	if err != nil {
+		return nil, err
+	}
+	after, err := next.document()
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, name := range Sections() {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			changed = append(changed, name)
+		}
+	}
+	return changed, nil
+}
+
+// Change is one difference between two configs
+type Change struct {
+	Path string `json:"path"` // Dotted keys, e.g. "lights.rack1.level2" or "mqtt.broker"
+	Op   string `json:"op"`   // added, removed or changed
+}
+
+// Diff returns the keys that differ between c and next, in file order for sections
+// Mappings are compared key by key; lists and values are reported as a whole.
+func (c *Config) Diff(next *Config) ([]Change, error) {
+	before, err := c.document()
+	if err != nil {
+		return nil, err
+	}
+	after, err := next.document()
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, name := range Sections() {
+		changes = diffValue(changes, name, before[name], after[name])
+	}
+	return changes, nil
+}
+
+// diffValue appends the changes from a to b under path
+func diffValue(changes []Change, path string, a, b any) []Change {
+	switch {
+	case reflect.DeepEqual(a, b):
+		return changes
+	case a == nil:
+		return append(changes, Change{Path: path, Op: "added"})
+	case b == nil:
+		return append(changes, Change{Path: path, Op: "removed"})
+	}
+	am, aok := mapping(a)
+	bm, bok := mapping(b)
+	if !aok || !bok {
+		return append(changes, Change{Path: path, Op: "changed"})
+	}
+	keys := slices.Collect(maps.Keys(am))
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		changes = diffValue(changes, path+"."+k, am[k], bm[k])
+	}
+	return changes
+}
+
+// mapping returns v as a string-keyed map if it is a YAML mapping (park has int keys)
+func mapping(v any) (map[string]any, bool) {
+	switch m := v.(type) {
+	case map[string]any:
+		return m, true
+	case map[any]any:
+		out := make(map[string]any, len(m))
+		for k, v := range m {
+			out[fmt.Sprint(k)] = v
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// document returns c as a generic YAML document
+func (c *Config) document() (map[string]any, error) {
+	data, err := yaml.Marshal(c.stored())
+	if err != nil {
+		return nil, err
+	}
+	doc := make(map[string]any)
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// unredact restores the secrets of current that next carries as redactedValue
+func (next *Config) unredact(current *Config) {
+	if a := next.Auth; a != nil && current.Auth != nil {
+		for i := range a.Keys {
+			if a.Keys[i].Key != redactedValue {
+				continue
+			}
+			for _, k := range current.Auth.Keys {
+				if k.Name == a.Keys[i].Name {
+					a.Keys[i].Key = k.Key
+				}
+			}
+		}
+		if a.JWT != nil && a.JWT.Secret == redactedValue && current.Auth.JWT != nil {
+			a.JWT.Secret = current.Auth.JWT.Secret
+		}
+	}
+	if m := next.MQTT; m != nil && current.MQTT != nil {
+		if m.Password == redactedValue {
+			m.Password = current.MQTT.Password
+		}
+		for i := range m.Brokers {
+			b := &m.Brokers[i]
+			if b.Password != redactedValue {
+				continue
+			}
+			for _, old := range current.MQTT.Brokers {
+				if old.Broker == b.Broker && old.ClientID == b.ClientID {
+					b.Password = old.Password
+				}
+			}
+		}
+	}
+	for i := range next.Webhooks {
+		wh := &next.Webhooks[i]
+		for k, v := range wh.Headers {
+			if v != redactedValue {
+				continue
+			}
+			for _, old := range current.Webhooks {
+				if old.URL == wh.URL {
+					wh.Headers[k] = old.Headers[k]
+				}
+			}
+		}
+	}
+}
+
+// Save rewrites sections of the config file with the values of c
+// Other sections and their comments are kept; comments inside rewritten sections are lost.
+func (c *Config) Save(sections []string) error {
+	if c.path == "" {
+		return fmt.Errorf("config not loaded from a file")
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("parse config: not a mapping")
+	}
+
+	var full yaml.Node
+	if err := full.Encode(c.stored()); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	values := make(map[string]*yaml.Node)
+	for i := 0; i+1 < len(full.Content); i += 2 {
+		values[full.Content[i].Value] = full.Content[i+1]
+	}
+
+	root := doc.Content[0]
+	for _, section := range sections {
+		value := values[section] // nil: section removed
+		if section == "lights" && value != nil {
+			// One line per channel, as in the shipped config
+			for _, group := range value.Content {
+				for _, light := range group.Content {
+					for _, ch := range light.Content {
+						ch.Style = yaml.FlowStyle
+					}
+				}
+			}
+		}
+
+		found := false
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value != section {
+				continue
+			}
+			found = true
+			if value == nil {
+				root.Content = slices.Delete(root.Content, i, i+2)
+			} else {
+				value.HeadComment = root.Content[i+1].HeadComment
+				root.Content[i+1] = value
+			}
+			break
+		}
+		if !found && value != nil {
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, value)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	enc.Close()
+
+	// Write to a temp file and rename so a crash never leaves a truncated config
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("write config file: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write config file: %w", err)
+	}
+	return nil
+}
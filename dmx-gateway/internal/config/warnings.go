@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Warnings
+// Validate also reports what loads but is likely a mistake: a schedule event setting
+// or recalling a preset on a target that does not exist (its run fails), a gap of
+// largeGap channels or more in the patch, and one group patched over more than
+// maxGroupShare of the universe. In strict mode (-strict) they are errors; configs
+// derived from a strict config or reloaded are strict too.
+
+const (
+	largeGap      = 32  // Unpatched channels reported as a gap
+	maxGroupShare = 0.6 // Of the 512 channels, patched to one group
+)
+
+// Warnings returns the warnings of the last validation
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// lint returns the warnings of a valid config
+func (c *Config) lint() []string {
+	var warnings []string
+	if c.Schedule != nil {
+		for _, e := range c.Schedule.Events {
+			var targets []string
+			for target := range e.Set {
+				targets = append(targets, target)
+			}
+			for target := range e.Preset {
+				targets = append(targets, target)
+			}
+			sort.Strings(targets)
+			for _, target := range slices.Compact(targets) {
+				if !c.HasTarget(target) {
+					warnings = append(warnings, fmt.Sprintf("schedule: event %s: unknown target %q", e.ID, target))
+				}
+			}
+		}
+	}
+
+	for _, gap := range c.Patch().Gaps {
+		if n := gap.Last - gap.First + 1; n >= largeGap {
+			warnings = append(warnings, fmt.Sprintf("lights: channels %d-%d unpatched (%d)", gap.First, gap.Last, n))
+		}
+	}
+
+	groups := c.GroupNames()
+	sort.Strings(groups)
+	for _, group := range groups {
+		slots := make(map[int]bool)
+		for _, channels := range c.Lights[group] {
+			for _, ch := range channels {
+				if ch.Alias {
+					continue
+				}
+				slots[ch.Ch] = true
+				if ch.FineCh != 0 {
+					slots[ch.FineCh] = true
+				}
+			}
+		}
+		if share := float64(len(slots)) / 512; share > maxGroupShare {
+			warnings = append(warnings, fmt.Sprintf("lights: group %q patched over %.0f%% of the universe (%d channels)", group, share*100, len(slots)))
+		}
+	}
+	return warnings
+}
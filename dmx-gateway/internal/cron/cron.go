@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron expressions
+// Standard 5-field syntax (minute hour day-of-month month day-of-week), or 6 fields
+// with seconds first. A field is *, a value, a range (1-5), a step (*/15, 0-30/10) or
+// a comma list of those; months and weekdays also take names (jan, mon), and weekday
+// 7 is Sunday. As in cron, an event restricted by both day-of-month and day-of-week
+// runs when either matches. A weekday can be numbered in its month (mon#1 = first
+// Monday), and @yearly, @monthly, @weekly, @daily and @hourly are accepted.
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	second, minute, hour, dom, month, dow uint64   // Bit per allowed value
+	nth                                   [7]uint8 // Weekday -> bit per week of the month (mon#1)
+
+	domAny, dowAny bool // Field left as * (or ?)
+}
+
+// field describes the range and names of a cron field
+type field struct {
+	name     string
+	min, max int
+	names    []string // Names of min, min+1...
+}
+
+var (
+	seconds  = field{"second", 0, 59, nil}
+	minutes  = field{"minute", 0, 59, nil}
+	hours    = field{"hour", 0, 23, nil}
+	doms     = field{"day of month", 1, 31, nil}
+	months   = field{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	weekdays = field{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// macros are the @ shorthands
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a 5 or 6-field cron expression
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.ToLower(strings.TrimSpace(expr))
+	if m, ok := macros[expr]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron %q: 5 or 6 fields expected", expr)
+	}
+
+	s := &Schedule{domAny: anyField(fields[3]), dowAny: anyField(fields[5])}
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		def  field
+	}{{&s.second, seconds}, {&s.minute, minutes}, {&s.hour, hours}, {&s.dom, doms}, {&s.month, months}} {
+		if *f.bits, err = parseField(fields[i], f.def); err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+	}
+	if err := s.parseWeekdays(fields[5]); err != nil {
+		return nil, fmt.Errorf("cron %q: %w", expr, err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 = Sunday
+	}
+	return s, nil
+}
+
+// anyField reports whether a day field is left unrestricted
+func anyField(f string) bool {
+	return f == "*" || f == "?"
+}
+
+// parseWeekdays parses the day-of-week field, numbered weekdays (mon#1) included
+func (s *Schedule) parseWeekdays(f string) error {
+	var plain []string
+	for _, part := range strings.Split(f, ",") {
+		day, n, ok := strings.Cut(part, "#")
+		if !ok {
+			plain = append(plain, part)
+			continue
+		}
+		d, err := value(day, weekdays)
+		if err != nil {
+			return err
+		}
+		week, err := strconv.Atoi(n)
+		if err != nil || week < 1 || week > 5 {
+			return fmt.Errorf("%s: week %q out of range (1-5)", weekdays.name, n)
+		}
+		s.nth[d%7] |= 1 << (week - 1)
+	}
+	if len(plain) == 0 {
+		return nil
+	}
+	var err error
+	s.dow, err = parseField(strings.Join(plain, ","), weekdays)
+	return err
+}
+
+// parseField returns the bits of a comma list of values, ranges and steps
+func parseField(f string, def field) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+		lo, hi := def.min, def.max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(a, def); err != nil {
+				return 0, err
+			}
+			if hi, err = value(b, def); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: range %q is reversed", def.name, rng)
+			}
+		default:
+			v, err := value(rng, def)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v // a/n runs from a to the end of the range
+			}
+		}
+		n := 1
+		if hasStep {
+			var err error
+			if n, err = strconv.Atoi(step); err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: invalid step %q", def.name, step)
+			}
+		}
+		for v := lo; v <= hi; v += n {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a number or name of def
+func value(s string, def field) (int, error) {
+	for i, name := range def.names {
+		if s == name {
+			return def.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < def.min || v > def.max {
+		return 0, fmt.Errorf("%s: %q out of range (%d-%d)", def.name, s, def.min, def.max)
+	}
+	return v, nil
+}
+
+// Match reports whether t (to the second) is a run of the schedule
+func (s *Schedule) Match(t time.Time) bool {
+	return s.second&(1<<t.Second()) != 0 && s.minute&(1<<t.Minute()) != 0 &&
+		s.hour&(1<<t.Hour()) != 0 && s.dayMatch(t)
+}
+
+// dayMatch reports whether the date of t is a run day
+func (s *Schedule) dayMatch(t time.Time) bool {
+	if s.month&(1<<t.Month()) == 0 {
+		return false
+	}
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0 || s.nth[t.Weekday()]&(1<<((t.Day()-1)/7)) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// maxDays bounds the search of Next (Feb 29 on a given weekday can be years away)
+const maxDays = 366 * 8
+
+// Next returns the first run after t, in the location of t (zero = none)
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Second).Add(time.Second)
+	loc := t.Location()
+	for day := 0; day < maxDays; day++ {
+		date := time.Date(t.Year(), t.Month(), t.Day()+day, 0, 0, 0, 0, loc)
+		if !s.dayMatch(date) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if s.hour&(1<<h) == 0 {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if s.minute&(1<<m) == 0 {
+					continue
+				}
+				for sec := 0; sec < 60; sec++ {
+					if s.second&(1<<sec) == 0 {
+						continue
+					}
+					next := time.Date(date.Year(), date.Month(), date.Day(), h, m, sec, 0, loc)
+					if !next.Before(t) && s.Match(next) { // Match skips times moved by DST
+						return next
+					}
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",       // 4 fields
+		"60 * * * *",    // Minute out of range
+		"* * * 13 *",    // Month out of range
+		"5-1 * * * *",   // Reversed range
+		"*/0 * * * *",   // Zero step
+		"* * * * mon#6", // Week out of range
+		"* * * * funday",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	// Wednesday 2025-01-01 10:07:30 UTC
+	from := time.Date(2025, 1, 1, 10, 7, 30, 0, time.UTC)
+	for _, tc := range []struct {
+		expr string
+		want string
+	}{
+		{"*/15 * * * *", "2025-01-01 10:15:00"},
+		{"0 8 * * mon-fri", "2025-01-02 08:00:00"},
+		{"0 8 * * 1#1", "2025-01-06 08:00:00"},          // First Monday
+		{"0 8 1 * 1", "2025-01-06 08:00:00"},            // 1st or Monday
+		{"30 */10 9-17 * * *", "2025-01-01 10:10:30"},   // 6 fields
+		{"0 0 29 feb *", "2028-02-29 00:00:00"},         // Leap day
+		{"@weekly", "2025-01-05 00:00:00"},              // Sunday
+		{"0 12 * jan-mar sun,7", "2025-01-05 12:00:00"}, // 7 = Sunday
+	} {
+		s, err := Parse(tc.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tc.expr, err)
+			continue
+		}
+		if got := s.Next(from).Format(time.DateTime); got != tc.want {
+			t.Errorf("%q: expected %s, got %s", tc.expr, tc.want, got)
+		}
+	}
+
+	s, _ := Parse("0 0 30 feb *")
+	if next := s.Next(from); !next.IsZero() {
+		t.Errorf("expected no run on Feb 30, got %s", next)
+	}
+	if s, _ := Parse("7 10 * * *"); !s.Match(from.Add(-30*time.Second)) || s.Match(from) {
+		t.Error("expected a match at 10:07:00 only")
+	}
+}
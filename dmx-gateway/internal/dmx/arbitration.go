@@ -0,0 +1,364 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"sort"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Source arbitration
+// Every write is tagged with its source. With an arbitration section, each channel
+// keeps the last value of every source that wrote it and outputs:
+//   - ltp: the most recent write among the highest-priority sources
+//   - htp: the highest value among the highest-priority sources
+// Sources keep their contribution until released (or a blackout clears them all).
+
+// Write sources
+const (
+	SourceLocal     = "local" // Internal callers (startup, tests)
+	SourceHTTP      = "http"
+	SourceWS        = "ws"
+	SourceMQTT      = "mqtt"
+	SourceModbus    = "modbus"
+	SourceScheduler = "scheduler"
+	SourceEffect    = "effect"
+	SourcePlayer    = "player"
+	SourceShow      = "show"
+)
+
+// contribution is the last value written by a source on a channel
+type contribution struct {
+	source string
+	value  uint8
+	seq    uint64
+}
+
+// arbiter merges per-source contributions (guarded by State.mu)
+type arbiter struct {
+	htp      [512]bool
+	priority map[string]int
+	contrib  [512][]contribution
+	seq      uint64
+}
+
+// ArbitrationInfo describes the merge configuration and current channel owners
+type ArbitrationInfo struct {
+	Policy     string         `json:"policy"`
+	Priorities map[string]int `json:"priorities,omitempty"`
+	HTP        []int          `json:"htp_channels,omitempty"`
+	Sources    map[string]int `json:"sources"` // source -> number of channels it contributes to
+}
+
+func newArbiter(cfg *config.Config) *arbiter {
+	a := &arbiter{priority: cfg.Arbitration.Priorities}
+	a.setPolicies(cfg)
+	return a
+}
+
+// setPolicies computes the per-channel merge policy (group overrides follow the lights config)
+func (a *arbiter) setPolicies(cfg *config.Config) {
+	for i := range a.htp {
+		a.htp[i] = cfg.Arbitration.Policy == "htp"
+	}
+	for group, policy := range cfg.Arbitration.Groups {
+		for _, channels := range cfg.Lights[group] {
+			for _, ch := range channels {
+				if ch.Alias {
+					continue // Follows the owning light's group
+				}
+				a.htp[ch.Ch-1] = policy == "htp"
+			}
+		}
+	}
+	for ch, policy := range cfg.Arbitration.Channels {
+		a.htp[ch-1] = policy == "htp"
+	}
+}
+
+// set records a source value on a channel and returns the merged output value
+func (a *arbiter) set(source string, channel int, value uint8) uint8 {
+	a.seq++
+	list := a.contrib[channel-1]
+	found := false
+	for i := range list {
+		if list[i].source == source {
+			list[i].value = value
+			list[i].seq = a.seq
+			found = true
+			break
+		}
+	}
+	if !found {
+		list = append(list, contribution{source: source, value: value, seq: a.seq})
+		a.contrib[channel-1] = list
+	}
+	return a.resolve(channel)
+}
+
+// resolve computes a channel output from its contributions (0 if none)
+func (a *arbiter) resolve(channel int) uint8 {
+	list := a.contrib[channel-1]
+	if len(list) == 0 {
+		return 0
+	}
+
+	best := list[0]
+	bestPrio := a.priority[best.source]
+	for _, c := range list[1:] {
+		prio := a.priority[c.source]
+		switch {
+		case prio > bestPrio:
+		case prio < bestPrio:
+			continue
+		case a.htp[channel-1] && c.value > best.value:
+		case !a.htp[channel-1] && c.seq > best.seq:
+		default:
+			continue
+		}
+		best, bestPrio = c, prio
+	}
+	return best.value
+}
+
+// release drops a source's contributions and returns the channels that changed owner
+func (a *arbiter) release(source string) []int {
+	var changed []int
+	for i, list := range a.contrib {
+		for j := range list {
+			if list[j].source == source {
+				a.contrib[i] = append(list[:j], list[j+1:]...)
+				changed = append(changed, i+1)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// reset drops every contribution
+func (a *arbiter) reset() {
+	for i := range a.contrib {
+		a.contrib[i] = nil
+	}
+}
+
+// applySourceLocked merges a source write through arbitration and applies the result
+// Returns the value actually output. Caller holds s.mu (write).
+func (s *State) applySourceLocked(source string, channel int, value uint8) uint8 {
+	if s.arb != nil {
+		value = s.arb.set(source, channel, value)
+	}
+	return s.applyChannelLocked(channel, value)
+}
+
+// ReleaseSource drops all contributions of a source so lower-priority sources take over
+// No-op without arbitration.
+func (s *State) ReleaseSource(source string) {
+	if s.arb == nil {
+		return
+	}
+
+	s.queueMu.Lock()
+	s.mu.Lock()
+	changed := s.arb.release(source)
+	for _, ch := range changed {
+		s.applyChannelLocked(ch, s.arb.resolve(ch))
+		s.dirty[ch-1] = true
+	}
+	s.mu.Unlock()
+	if len(changed) > 0 && s.throttle > 0 {
+		s.scheduleFlushLocked()
+	}
+	s.queueMu.Unlock()
+
+	if len(changed) == 0 {
+		return
+	}
+	s.logger.Info("Source released", "source", source, "channels", len(changed))
+	if s.throttle <= 0 {
+		s.Flush()
+	}
+	s.broadcastState()
+}
+
+// Arbitration returns the merge configuration and per-source channel counts (nil if disabled)
+func (s *State) Arbitration() *ArbitrationInfo {
+	if s.arb == nil {
+		return nil
+	}
+
+	info := &ArbitrationInfo{
+		Policy:     s.config().Arbitration.Policy,
+		Priorities: s.config().Arbitration.Priorities,
+		Sources:    make(map[string]int),
+	}
+	if info.Policy == "" {
+		info.Policy = "ltp"
+	}
+
+	s.mu.RLock()
+	for i, list := range s.arb.contrib {
+		if s.arb.htp[i] {
+			info.HTP = append(info.HTP, i+1)
+		}
+		for _, c := range list {
+			info.Sources[c.source]++
+		}
+	}
+	s.mu.RUnlock()
+	sort.Ints(info.HTP)
+	return info
+}
+
+// Source is a write handle tagging every change with its origin for arbitration
+type Source struct {
+	state     *State
+	name      string
+	requestID string // Traced request (see trace.go)
+}
+
+// Source returns a write handle for the named source
+func (s *State) Source(name string) *Source {
+	return &Source{state: s, name: name}
+}
+
+// Name returns the source name
+func (w *Source) Name() string { return w.name }
+
+// SetChannel sets a single DMX channel
+func (w *Source) SetChannel(channel int, value uint8) error {
+	return w.record(w.state.setChannel(w.name, channel, value), HistoryEntry{Action: "set", Channel: channel, Values: value})
+}
+
+// SetChannels sets consecutive DMX channels from start
+func (w *Source) SetChannels(start int, values []uint8) error {
+	return w.record(w.state.setChannelRange(w.name, start, values), channelsEntry(start, values))
+}
+
+// channelsEntry returns the history entry of a consecutive channel write
+func channelsEntry(start int, values []uint8) HistoryEntry {
+	recorded := make([]int, len(values)) // []uint8 would encode as base64
+	for i, v := range values {
+		recorded[i] = int(v)
+	}
+	return HistoryEntry{Action: "set", Channel: start, Values: recorded}
+}
+
+// SetLight sets a light's channel values
+func (w *Source) SetLight(group, name string, values map[string]uint8) error {
+	return w.record(w.state.setLight(w.name, group, name, values), HistoryEntry{Action: "set", Target: config.LightKey(group, name), Values: values})
+}
+
+// SetGroup sets all lights in a group
+func (w *Source) SetGroup(group string, values map[string]uint8) error {
+	return w.record(w.state.setGroup(w.name, group, values), HistoryEntry{Action: "set", Target: group, Values: values})
+}
+
+// FadeLight ramps a light's channels over duration
+func (w *Source) FadeLight(group, name string, values map[string]uint8, duration time.Duration) error {
+	return w.record(w.state.fadeLight(w.name, group, name, values, duration), HistoryEntry{Action: "fade", Target: config.LightKey(group, name), Values: values, FadeMs: duration.Milliseconds()})
+}
+
+// FadeLight16 ramps a light's channels to 16-bit values over duration
+func (w *Source) FadeLight16(group, name string, values map[string]uint16, duration time.Duration) error {
+	return w.record(w.state.fadeLight16(w.name, group, name, values, duration), HistoryEntry{Action: "fade", Target: config.LightKey(group, name), Values: values, FadeMs: duration.Milliseconds()})
+}
+
+// FadeGroup16 ramps all lights in a group to 16-bit values over duration
+func (w *Source) FadeGroup16(group string, values map[string]uint16, duration time.Duration) error {
+	return w.record(w.state.fadeGroup16(w.name, group, values, duration), HistoryEntry{Action: "fade", Target: group, Values: values, FadeMs: duration.Milliseconds()})
+}
+
+// FadeGroup ramps all lights in a group over duration
+func (w *Source) FadeGroup(group string, values map[string]uint8, duration time.Duration) error {
+	return w.record(w.state.fadeGroup(w.name, group, values, duration), HistoryEntry{Action: "fade", Target: group, Values: values, FadeMs: duration.Milliseconds()})
+}
+
+// RecallScene applies a named scene
+func (w *Source) RecallScene(name string, fade time.Duration) error {
+	return w.record(w.state.recallScene(w.name, name, fade), HistoryEntry{Action: "scene", Name: name, FadeMs: fade.Milliseconds()})
+}
+
+// RecallSceneIndex applies a scene by its 1-based index
+func (w *Source) RecallSceneIndex(index int, fade time.Duration) error {
+	scenes := w.state.Scenes()
+	if index < 1 || index > len(scenes) {
+		return ErrSceneNotFound
+	}
+	return w.RecallScene(scenes[index-1].Name, fade)
+}
+
+// RecallPreset applies a config preset to a target
+func (w *Source) RecallPreset(target, name string, fade time.Duration) error {
+	return w.record(w.state.recallPreset(w.name, target, name, fade), HistoryEntry{Action: "preset", Target: target, Name: name, FadeMs: fade.Milliseconds()})
+}
+
+// SetCCT sets color temperature and brightness of tunable-white lights in target
+func (w *Source) SetCCT(target string, cct, brightness int, fade time.Duration) error {
+	return w.record(w.state.setCCT(w.name, target, cct, brightness, fade), HistoryEntry{Action: "cct", Target: target, Values: map[string]int{"cct": cct, "brightness": brightness}, FadeMs: fade.Milliseconds()})
+}
+
+// CueGo runs the next cue of a list
+func (w *Source) CueGo(list string) (int, error) {
+	number, err := w.state.cueStep(w.name, list, 1)
+	return number, w.record(err, HistoryEntry{Action: "cue", Name: list, Values: number})
+}
+
+// CueBack runs the previous cue of a list
+func (w *Source) CueBack(list string) (int, error) {
+	number, err := w.state.cueStep(w.name, list, -1)
+	return number, w.record(err, HistoryEntry{Action: "cue", Name: list, Values: number})
+}
+
+// CueGoto runs cue number (1-based) of a list
+func (w *Source) CueGoto(list string, number int) error {
+	return w.record(w.state.cueGoto(w.name, list, number), HistoryEntry{Action: "cue", Name: list, Values: number})
+}
+
+// StartEffect starts (or replaces) an effect, its frames written as this source
+func (w *Source) StartEffect(params EffectParams) error {
+	_, err := w.state.startEffect(w.name, params)
+	return w.record(err, HistoryEntry{Action: "effect", Target: params.Target, Name: params.Type})
+}
+
+// Locate flashes a target then restores its values, written as this source
+func (w *Source) Locate(target string, duration time.Duration) error {
+	return w.record(w.state.locate(w.name, target, duration), HistoryEntry{Action: "locate", Target: target})
+}
+
+// Undo rolls back the last steps changes, the restored frame written as this source
+func (w *Source) Undo(steps int) (int, error) {
+	undone, err := w.state.undoSteps(w.name, steps)
+	return undone, w.record(err, HistoryEntry{Action: "undo", Values: undone})
+}
+
+// SaveScene captures the current values of targets (all lights when empty) as a scene
+func (w *Source) SaveScene(name string, targets []string) (*Scene, error) {
+	sc, err := w.state.SaveScene(name, targets)
+	return sc, w.record(err, HistoryEntry{Action: "scene_save", Name: name, Values: targets})
+}
+
+// Release drops this source's contributions
+func (w *Source) Release() {
+	w.state.ReleaseSource(w.name)
+	w.record(nil, HistoryEntry{Action: "release"})
+}
+
+// Enable enables DMX output
+func (w *Source) Enable() error {
+	return w.record(w.state.Enable(), HistoryEntry{Action: "enable"})
+}
+
+// Disable disables DMX output
+func (w *Source) Disable() error {
+	return w.record(w.state.Disable(), HistoryEntry{Action: "disable"})
+}
+
+// Blackout sets all channels to 0
+func (w *Source) Blackout() error {
+	return w.record(w.state.Blackout(), HistoryEntry{Action: "blackout"})
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+
+	"dmx-gateway/internal/config"
+)
+
+// Art-Net ArtDMX packet layout
+const (
+	artNetHeaderLen = 18
+	artNetOpDMX     = 0x5000
+	artNetProtVer   = 14
+	artNetPort      = "6454"
+)
+
+// ArtNetClient sends the full universe as Art-Net ArtDMX packets over UDP
+// Every write resends the whole 512-channel frame (nodes expect complete frames)
+type ArtNetClient struct {
+	addr     string
+	universe uint16
+
+	mu       sync.Mutex
+	conn     net.Conn
+	enabled  bool
+	channels [512]uint8
+	sequence uint8
+	frames   uint64
+	packet   [artNetHeaderLen + 512]byte // Pre-allocated packet buffer
+}
+
+// NewArtNetClient creates an Art-Net backend (connection is opened lazily)
+func NewArtNetClient(cfg config.ArtNetConfig) *ArtNetClient {
+	addr := cfg.Address
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, artNetPort)
+	}
+
+	a := &ArtNetClient{
+		addr:     addr,
+		universe: uint16(cfg.Universe),
+	}
+
+	// Static header: ID, OpCode (LE), ProtVer (BE), Physical, SubUni/Net, Length (BE)
+	copy(a.packet[0:8], "Art-Net\x00")
+	binary.LittleEndian.PutUint16(a.packet[8:10], artNetOpDMX)
+	binary.BigEndian.PutUint16(a.packet[10:12], artNetProtVer)
+	binary.LittleEndian.PutUint16(a.packet[14:16], a.universe)
+	binary.BigEndian.PutUint16(a.packet[16:18], 512)
+
+	return a
+}
+
+// send transmits the current frame (caller holds mu)
+func (a *ArtNetClient) send() error {
+	if !a.enabled {
+		return nil
+	}
+	if a.conn == nil {
+		conn, err := net.Dial("udp", a.addr)
+		if err != nil {
+			return fmt.Errorf("artnet dial %s: %w", a.addr, err)
+		}
+		a.conn = conn
+	}
+
+	a.sequence++
+	if a.sequence == 0 {
+		a.sequence = 1 // 0 disables sequencing on the receiver
+	}
+	a.packet[12] = a.sequence
+	copy(a.packet[artNetHeaderLen:], a.channels[:])
+
+	if _, err := a.conn.Write(a.packet[:]); err != nil {
+		return fmt.Errorf("artnet send: %w", err)
+	}
+	a.frames++
+	return nil
+}
+
+func (a *ArtNetClient) Enable() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enabled = true
+	return a.send()
+}
+
+func (a *ArtNetClient) Disable() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enabled = false
+	return nil
+}
+
+func (a *ArtNetClient) Blackout() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.channels = [512]uint8{}
+	return a.send()
+}
+
+func (a *ArtNetClient) SetChannel(channel int, value uint8) error {
+	if channel < 1 || channel > 512 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.channels[channel-1] = value
+	return a.send()
+}
+
+func (a *ArtNetClient) SetChannels(startChannel int, values []uint8) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, v := range values {
+		ch := startChannel + i
+		if ch >= 1 && ch <= 512 {
+			a.channels[ch-1] = v
+		}
+	}
+	return a.send()
+}
+
+func (a *ArtNetClient) Status() (*Status, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &Status{
+		Enabled:    a.enabled,
+		FrameCount: a.frames,
+	}, nil
+}
+
+// Reset closes the UDP socket so the next send redials
+func (a *ArtNetClient) Reset() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn != nil {
+		a.conn.Close()
+		a.conn = nil
+	}
+	return nil
+}
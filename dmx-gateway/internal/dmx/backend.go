@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"dmx-gateway/internal/config"
+)
+
+// Backend is a DMX output backend
+// Implemented by Client (dmx_client subprocess over RPMSG), ArtNetClient and MockClient
+type Backend interface {
+	Enable() error
+	Disable() error
+	Blackout() error
+	SetChannel(channel int, value uint8) error
+	SetChannels(startChannel int, values []uint8) error
+	Status() (*Status, error)
+	Reset() error // Prepare for a watchdog recovery attempt
+}
+
+// RateSetter is implemented by backends whose output frame rate is configurable
+type RateSetter interface {
+	SetRate(hz int) (int, error) // Returns the rate actually applied
+}
+
+// Backend names
+const (
+	BackendRPMSG  = "rpmsg"
+	BackendMock   = "mock"
+	BackendArtNet = "artnet"
+)
+
+// BackendInfo describes the active and available backends (GET /api/backend)
+type BackendInfo struct {
+	Active    string   `json:"active"`
+	Available []string `json:"available"`
+}
+
+// RegisterBackend makes a backend available for runtime switching
+func (s *State) RegisterBackend(name string, b Backend) {
+	s.backendMu.Lock()
+	s.backends[name] = b
+	s.backendMu.Unlock()
+}
+
+// backend returns the active backend
+func (s *State) backend() Backend {
+	s.backendMu.RLock()
+	defer s.backendMu.RUnlock()
+	return s.client
+}
+
+// Backends returns the active backend name and all registered backends
+func (s *State) Backends() BackendInfo {
+	s.backendMu.RLock()
+	defer s.backendMu.RUnlock()
+
+	info := BackendInfo{
+		Active:    s.backendName,
+		Available: make([]string, 0, len(s.backends)),
+	}
+	for name := range s.backends {
+		info.Available = append(info.Available, name)
+	}
+	sort.Strings(info.Available)
+	return info
+}
+
+// SwitchBackend hands output over to another registered backend without restarting
+// The new backend is brought up with the current frame before the old one is disabled,
+// so a failing target leaves the active backend untouched.
+func (s *State) SwitchBackend(name string) error {
+	s.backendMu.RLock()
+	next, ok := s.backends[name]
+	prev, prevName := s.client, s.backendName
+	s.backendMu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("unknown backend %q", name)
+	}
+	if name == prevName {
+		return nil
+	}
+
+	// Flush pending writes to the outgoing backend first
+	s.Flush()
+
+	if s.IsEnabled() {
+		if err := next.Enable(); err != nil {
+			return fmt.Errorf("enable %s: %w", name, err)
+		}
+	}
+	channels := s.outputChannels()
+	if err := next.SetChannels(1, channels[:]); err != nil {
+		return fmt.Errorf("replay frame on %s: %w", name, err)
+	}
+	s.applyRate(next)
+
+	s.backendMu.Lock()
+	s.client = next
+	s.backendName = name
+	s.backendMu.Unlock()
+
+	if err := prev.Disable(); err != nil {
+		s.logger.Warn("Failed to disable previous backend", "backend", prevName, "error", err)
+	}
+
+	// Failures of the old backend no longer apply
+	s.wdMu.Lock()
+	s.wd.health = BackendHealth{Healthy: true, Recoveries: s.wd.health.Recoveries}
+	s.wdMu.Unlock()
+
+	s.logger.Info("DMX backend switched", "from", prevName, "to", name)
+	s.broadcastState()
+	return nil
+}
+
+// NewBackends creates all backends available from the configuration
+// The dmx_client (RPMSG) and mock backends are always available.
+func NewBackends(cfg config.DMXConfig, client *Client, logger *slog.Logger) map[string]Backend {
+	backends := map[string]Backend{
+		BackendRPMSG: client,
+		BackendMock:  NewMockClient(),
+	}
+	if cfg.ArtNet != nil {
+		backends[BackendArtNet] = NewArtNetClient(*cfg.ArtNet)
+		logger.Info("Art-Net backend configured", "address", cfg.ArtNet.Address, "universe", cfg.ArtNet.Universe)
+	}
+	return backends
+}
+
+// ApplyRate sends the configured dmx.fps to the active backend (startup)
+func (s *State) ApplyRate() {
+	s.applyRate(s.backend())
+}
+
+// applyRate sets the configured frame rate on b if supported and records the result
+func (s *State) applyRate(b Backend) {
+	rs, ok := b.(RateSetter)
+	hz := s.config().DMX.FPS
+	if !ok || hz <= 0 {
+		s.mu.Lock()
+		s.refreshHz = 0
+		s.mu.Unlock()
+		return
+	}
+
+	applied, err := rs.SetRate(hz)
+	if err != nil {
+		s.logger.Warn("Failed to set DMX refresh rate", "fps", hz, "error", err)
+		return
+	}
+	if applied != hz {
+		s.logger.Warn("Firmware adjusted DMX refresh rate", "requested", hz, "applied", applied)
+	}
+
+	s.mu.Lock()
+	s.refreshHz = applied
+	s.mu.Unlock()
+	s.logger.Info("DMX refresh rate set", "fps", applied)
+}
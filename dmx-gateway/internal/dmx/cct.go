@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// Tunable white
+// Channels declaring a cct (Kelvin) make their light tunable white. A CCT request is
+// mixed between the two channels bracketing it (interpolated in mireds, which tracks
+// perceived color better than Kelvin) and the brightness is split between them.
+
+// ErrNoTunableWhite is returned when a target has no light with two cct channels
+var ErrNoTunableWhite = errors.New("no tunable white light (channels with cct)")
+
+// SetCCT sets color temperature (0 = keep) and brightness (< 0 = keep) of tunable-white lights in target
+func (s *State) SetCCT(target string, cct, brightness int, fade time.Duration) error {
+	return s.setCCT(SourceLocal, target, cct, brightness, fade)
+}
+
+func (s *State) setCCT(source, target string, cct, brightness int, fade time.Duration) error {
+	keys, err := s.resolveTargets([]string{target})
+	if err != nil {
+		return err
+	}
+
+	applied := 0
+	for _, key := range keys {
+		s.mu.RLock()
+		ls, ok := s.lights[key]
+		var values map[string]uint8
+		if ok {
+			values, ok = cctMix(ls.Channels, cct, brightness)
+		}
+		s.mu.RUnlock()
+		if !ok {
+			continue // Lights without tunable white channels in a group are left alone
+		}
+		if err := s.fadeLight(source, ls.Group, ls.Name, values, fade); err != nil {
+			return err
+		}
+		applied++
+	}
+	if applied == 0 {
+		return ErrNoTunableWhite
+	}
+	return nil
+}
+
+// cctMix computes white channel values for cct at brightness (caller holds s.mu)
+// cct 0 keeps the current temperature, brightness < 0 the current level.
+func cctMix(channels []ChannelState, cct, brightness int) (map[string]uint8, bool) {
+	var whites []*ChannelState
+	for i := range channels {
+		if channels[i].CCT > 0 {
+			whites = append(whites, &channels[i])
+		}
+	}
+	if len(whites) < 2 {
+		return nil, false
+	}
+	sort.Slice(whites, func(i, j int) bool { return whites[i].CCT < whites[j].CCT })
+
+	if cct <= 0 {
+		cct = currentCCT(whites)
+	}
+	if brightness < 0 {
+		sum := 0
+		for _, ch := range whites {
+			sum += int(ch.Value)
+		}
+		brightness = min(sum, 255)
+	}
+	cct = max(whites[0].CCT, min(cct, whites[len(whites)-1].CCT))
+
+	// Bracketing pair: whites[lo].CCT <= cct <= whites[lo+1].CCT
+	lo := 0
+	for lo < len(whites)-2 && whites[lo+1].CCT < cct {
+		lo++
+	}
+	warm, cool := whites[lo], whites[lo+1]
+	ratio := 0.0
+	if warm.CCT != cool.CCT {
+		ratio = (mired(warm.CCT) - mired(cct)) / (mired(warm.CCT) - mired(cool.CCT))
+	}
+
+	values := make(map[string]uint8, len(whites))
+	for _, ch := range whites {
+		values[ch.Name] = 0
+	}
+	values[warm.Name] = uint8(math.Round(float64(brightness) * (1 - ratio)))
+	values[cool.Name] = uint8(math.Round(float64(brightness) * ratio))
+	return values, true
+}
+
+// currentCCT derives the temperature from the two brightest white channels (mid-range when off)
+func currentCCT(whites []*ChannelState) int {
+	var a, b *ChannelState
+	for _, ch := range whites {
+		switch {
+		case a == nil || ch.Value > a.Value:
+			a, b = ch, a
+		case b == nil || ch.Value > b.Value:
+			b = ch
+		}
+	}
+	if a.Value == 0 {
+		mid := (mired(whites[0].CCT) + mired(whites[len(whites)-1].CCT)) / 2
+		return int(math.Round(1e6 / mid))
+	}
+	if b.Value == 0 {
+		return a.CCT
+	}
+	m := (mired(a.CCT)*float64(a.Value) + mired(b.CCT)*float64(b.Value)) / float64(int(a.Value)+int(b.Value))
+	return int(math.Round(1e6 / m))
+}
+
+// mired converts Kelvin to micro reciprocal degrees
+func mired(k int) float64 {
+	return 1e6 / float64(k)
+}
@@ -5,8 +5,10 @@ package dmx
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -21,6 +23,7 @@ type Client struct {
 	clientPath string
 	device     string // RPMSG device path (empty = use client default)
 	timeout    time.Duration
+	version    VersionInfo // Set by Negotiate
 	mu         sync.Mutex
 	logger     *slog.Logger
 }
@@ -74,6 +77,20 @@ func (c *Client) exec(args ...string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// Reset re-checks the client binary and RPMSG device before a recovery attempt
+// The subprocess backend holds no persistent handle, so there is nothing to reopen
+func (c *Client) Reset() error {
+	if _, err := exec.LookPath(c.clientPath); err != nil {
+		return fmt.Errorf("dmx_client not found: %w", err)
+	}
+	if c.device != "" {
+		if _, err := os.Stat(c.device); err != nil {
+			return fmt.Errorf("rpmsg device: %w", err)
+		}
+	}
+	return nil
+}
+
 // Enable starts DMX transmission
 func (c *Client) Enable() error {
 	c.logger.Debug("DMX enable")
@@ -121,42 +138,94 @@ func (c *Client) SetChannels(startChannel int, values []uint8) error {
 
 // Status returns the current DMX status
 func (c *Client) Status() (*Status, error) {
-	output, err := c.exec("--json", "status")
+	resp, err := c.execJSON("status")
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse JSON output
-	// Expected: {"enabled":true,"frame_count":1234,"fps":44.00}
-	status := &Status{}
+	return &Status{
+		Enabled:    resp.Enabled,
+		FPS:        resp.FPS,
+		FrameCount: resp.FrameCount,
+	}, nil
+}
 
-	// Simple parsing without json package for minimal deps
-	// This is a simplified parser - in production use encoding/json
-	if strings.Contains(output, `"enabled":true`) {
-		status.Enabled = true
+// SetRate sets the firmware DMX refresh rate and returns the rate reported back by the firmware
+func (c *Client) SetRate(hz int) (int, error) {
+	if _, err := c.execJSON("timing", strconv.Itoa(hz)); err != nil {
+		return 0, err
+	}
+	resp, err := c.execJSON("timing")
+	if err != nil {
+		return 0, err
 	}
+	return resp.RefreshHz, nil
+}
 
-	// Extract fps
-	if idx := strings.Index(output, `"fps":`); idx >= 0 {
-		rest := output[idx+6:]
-		if end := strings.IndexAny(rest, ",}"); end >= 0 {
-			if fps, err := strconv.ParseFloat(rest[:end], 64); err == nil {
-				status.FPS = fps
-			}
-		}
+// Negotiate queries the dmx_client version and checks JSON protocol compatibility
+// Clients predating the version command are assumed to speak protocol 1.
+func (c *Client) Negotiate() (*VersionInfo, error) {
+	resp, err := c.execJSON("version")
+	if err != nil {
+		c.logger.Warn("dmx_client version unknown, assuming protocol 1", "error", err)
+		c.version = VersionInfo{Protocol: 1}
+		return &c.version, nil
 	}
 
-	// Extract frame_count
-	if idx := strings.Index(output, `"frame_count":`); idx >= 0 {
-		rest := output[idx+14:]
-		if end := strings.IndexAny(rest, ",}"); end >= 0 {
-			if count, err := strconv.ParseUint(rest[:end], 10, 64); err == nil {
-				status.FrameCount = count
-			}
-		}
+	if resp.Protocol < 1 || resp.Protocol > ProtocolVersion {
+		return nil, fmt.Errorf("dmx_client %s speaks JSON protocol %d, gateway supports 1-%d",
+			resp.Version, resp.Protocol, ProtocolVersion)
 	}
 
-	return status, nil
+	c.version = VersionInfo{Version: resp.Version, Protocol: resp.Protocol}
+	c.logger.Info("dmx_client version negotiated", "version", resp.Version, "protocol", resp.Protocol)
+	return &c.version, nil
+}
+
+// Version returns the negotiated dmx_client version (zero until Negotiate)
+func (c *Client) Version() VersionInfo {
+	return c.version
+}
+
+// execJSON runs a dmx_client command with --json and decodes the response envelope
+func (c *Client) execJSON(args ...string) (*clientResponse, error) {
+	output, err := c.exec(append([]string{"--json"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp clientResponse
+	if err := json.Unmarshal([]byte(output), &resp); err != nil {
+		return nil, fmt.Errorf("dmx_client %v: invalid JSON output %q: %w", args, output, err)
+	}
+	if resp.Status == "error" {
+		return nil, fmt.Errorf("dmx_client %v: %s", args, resp.Error)
+	}
+	return &resp, nil
+}
+
+// ProtocolVersion is the highest dmx_client JSON protocol version understood by the gateway
+const ProtocolVersion = 1
+
+// VersionInfo describes the dmx_client binary
+type VersionInfo struct {
+	Version  string `json:"version,omitempty"`
+	Protocol int    `json:"protocol"`
+}
+
+// clientResponse is the dmx_client --json output envelope (union of all commands)
+// e.g. {"status":"ok","command":"get_status","enabled":true,"frame_count":1523,"fps":44.00,"latency_us":238}
+type clientResponse struct {
+	Status     string  `json:"status"` // "ok" or "error"
+	Error      string  `json:"error,omitempty"`
+	Command    string  `json:"command"`
+	LatencyUs  uint64  `json:"latency_us"`
+	Enabled    bool    `json:"enabled"`
+	FrameCount uint64  `json:"frame_count"`
+	FPS        float64 `json:"fps"`
+	Version    string  `json:"version"`
+	Protocol   int     `json:"protocol"`
+	RefreshHz  int     `json:"refresh_hz"`
 }
 
 // Status represents DMX status
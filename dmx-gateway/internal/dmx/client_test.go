@@ -6,6 +6,7 @@ package dmx
 import (
 	"log/slog"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"dmx-gateway/internal/config"
@@ -65,3 +66,80 @@ func TestStatus(t *testing.T) {
 		t.Error("expected error for nonexistent client")
 	}
 }
+
+// fakeClient writes a shell script standing in for dmx_client that prints output
+func fakeClient(t *testing.T, output string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dmx_client")
+	script := "#!/bin/sh\necho '" + output + "'\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake client: %v", err)
+	}
+	return path
+}
+
+func TestStatusJSON(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	path := fakeClient(t, `{"status":"ok","command":"get_status","enabled":true,"frame_count":1523,"fps":44.00,"latency_us":238}`)
+	client, _ := NewClient(config.DMXConfig{Client: path, TimeoutMs: 1000}, logger)
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !status.Enabled || status.FrameCount != 1523 || status.FPS != 44 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	// Error envelope is surfaced as an error
+	path = fakeClient(t, `{"status":"error","error":"Timeout waiting for response"}`)
+	client, _ = NewClient(config.DMXConfig{Client: path, TimeoutMs: 1000}, logger)
+	if _, err := client.Status(); err == nil {
+		t.Error("expected error for error envelope")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	path := fakeClient(t, `{"status":"ok","command":"version","version":"1.1.0","protocol":1}`)
+	client, _ := NewClient(config.DMXConfig{Client: path, TimeoutMs: 1000}, logger)
+
+	v, err := client.Negotiate()
+	if err != nil {
+		t.Fatalf("Negotiate failed: %v", err)
+	}
+	if v.Version != "1.1.0" || v.Protocol != 1 {
+		t.Errorf("unexpected version: %+v", v)
+	}
+
+	// Newer protocol is refused
+	path = fakeClient(t, `{"status":"ok","command":"version","version":"9.0.0","protocol":99}`)
+	client, _ = NewClient(config.DMXConfig{Client: path, TimeoutMs: 1000}, logger)
+	if _, err := client.Negotiate(); err == nil {
+		t.Error("expected error for incompatible protocol")
+	}
+
+	// Missing client falls back to protocol 1
+	client, _ = NewClient(config.DMXConfig{Client: "/nonexistent/dmx_client", TimeoutMs: 100}, logger)
+	v, err = client.Negotiate()
+	if err != nil || v.Protocol != 1 {
+		t.Errorf("expected protocol 1 fallback, got %+v (err %v)", v, err)
+	}
+}
+
+func TestSetRate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	path := fakeClient(t, `{"status":"ok","command":"get_timing","refresh_hz":30,"break_us":200,"mab_us":12}`)
+	client, _ := NewClient(config.DMXConfig{Client: path, TimeoutMs: 1000}, logger)
+
+	hz, err := client.SetRate(30)
+	if err != nil {
+		t.Fatalf("SetRate failed: %v", err)
+	}
+	if hz != 30 {
+		t.Errorf("expected 30 Hz, got %d", hz)
+	}
+}
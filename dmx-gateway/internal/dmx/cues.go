@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Cue lists
+// Ordered cue stacks from the config (cues: list -> [scene, fade_ms, follow_ms]).
+// Each list keeps its own position; go/back/goto recall the cue scene with the cue
+// fade time. A cue with follow_ms advances to the next one automatically, any manual
+// command or a blackout cancels the pending follow.
+
+var (
+	// ErrCueListNotFound is returned for a cue list missing from the config
+	ErrCueListNotFound = errors.New("cue list not found")
+	// ErrCueListEnd is returned when going past the first or last cue
+	ErrCueListEnd = errors.New("no more cues")
+)
+
+// CueListInfo describes a cue list and its playback position
+type CueListInfo struct {
+	Name    string       `json:"name"`
+	Current int          `json:"current"` // 1-based, 0 = not started
+	Cues    []config.Cue `json:"cues"`
+}
+
+// cuePlayer tracks the position of a cue list
+type cuePlayer struct {
+	current int
+	gen     int // Bumped on every cue so stale follow timers do nothing
+	follow  *time.Timer
+}
+
+// CueGo runs the next cue of a list and returns its number
+func (s *State) CueGo(list string) (int, error) {
+	return s.cueStep(SourceLocal, list, 1)
+}
+
+// CueBack runs the previous cue of a list and returns its number
+func (s *State) CueBack(list string) (int, error) {
+	return s.cueStep(SourceLocal, list, -1)
+}
+
+// CueGoto runs cue number (1-based) of a list
+func (s *State) CueGoto(list string, number int) error {
+	return s.cueGoto(SourceLocal, list, number)
+}
+
+func (s *State) cueStep(source, list string, delta int) (int, error) {
+	cues, ok := s.config().Cues[list]
+	if !ok {
+		return 0, ErrCueListNotFound
+	}
+
+	s.cuesMu.Lock()
+	defer s.cuesMu.Unlock()
+
+	number := s.cuePlayerLocked(list).current + delta
+	if number < 1 || number > len(cues) {
+		return 0, ErrCueListEnd
+	}
+	return number, s.runCueLocked(source, list, number)
+}
+
+func (s *State) cueGoto(source, list string, number int) error {
+	cues, ok := s.config().Cues[list]
+	if !ok {
+		return ErrCueListNotFound
+	}
+	if number < 1 || number > len(cues) {
+		return fmt.Errorf("cue %d out of range (1-%d)", number, len(cues))
+	}
+
+	s.cuesMu.Lock()
+	defer s.cuesMu.Unlock()
+	return s.runCueLocked(source, list, number)
+}
+
+// cuePlayerLocked returns the player of a list, creating it on first use (caller holds cuesMu)
+func (s *State) cuePlayerLocked(list string) *cuePlayer {
+	p, ok := s.cues[list]
+	if !ok {
+		p = &cuePlayer{}
+		s.cues[list] = p
+	}
+	return p
+}
+
+// runCueLocked recalls a cue and arms its follow (caller holds cuesMu)
+func (s *State) runCueLocked(source, list string, number int) error {
+	cues := s.config().Cues[list]
+	cue := cues[number-1]
+	p := s.cuePlayerLocked(list)
+
+	if p.follow != nil {
+		p.follow.Stop()
+		p.follow = nil
+	}
+	p.gen++
+
+	if err := s.recallScene(source, cue.Scene, time.Duration(cue.FadeMs)*time.Millisecond); err != nil {
+		return fmt.Errorf("cue %d: %s: %w", number, cue.Scene, err)
+	}
+	p.current = number
+	s.logger.Info("Cue", "list", list, "cue", number, "scene", cue.Scene)
+
+	if cue.FollowMs > 0 && number < len(cues) {
+		gen := p.gen
+		p.follow = time.AfterFunc(time.Duration(cue.FollowMs)*time.Millisecond, func() {
+			s.cuesMu.Lock()
+			defer s.cuesMu.Unlock()
+			if p.gen != gen {
+				return
+			}
+			if err := s.runCueLocked(source, list, number+1); err != nil {
+				s.logger.Warn("Cue follow failed", "list", list, "error", err)
+			}
+		})
+	}
+	return nil
+}
+
+// stopCueFollows cancels pending follows (positions are kept)
+func (s *State) stopCueFollows() {
+	s.cuesMu.Lock()
+	for _, p := range s.cues {
+		if p.follow != nil {
+			p.follow.Stop()
+			p.follow = nil
+		}
+		p.gen++
+	}
+	s.cuesMu.Unlock()
+}
+
+// CueLists returns configured cue lists sorted by name with their position
+func (s *State) CueLists() []CueListInfo {
+	s.cuesMu.Lock()
+	defer s.cuesMu.Unlock()
+
+	result := make([]CueListInfo, 0, len(s.config().Cues))
+	for name, cues := range s.config().Cues {
+		info := CueListInfo{Name: name, Cues: cues}
+		if p, ok := s.cues[name]; ok {
+			info.Current = p.current
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Effects engine
+// Generators driving a target's lights at the output frame rate, one effect per target
+// (starting another on the same target replaces it). A blackout stops every effect.
+//   strobe   all lights flash at rate_hz (50% duty)
+//   chase    one light at a time, stepping rate_hz lights per second
+//   rainbow  hue cycle at rate_hz, offset across lights (red/green/blue channels,
+//            otherwise the hue sweeps across the light's channels)
+//   sine     breathing between min and level at rate_hz
+
+// ErrEffectNotFound is returned when stopping an effect that is not running
+var ErrEffectNotFound = errors.New("effect not found")
+
+// EffectParams describes an effect to run
+type EffectParams struct {
+	Type     string   `json:"type"`               // strobe, chase, rainbow, sine
+	Target   string   `json:"target"`             // "group" or "group/light"
+	Channels []string `json:"channels,omitempty"` // Channel names driven (default: all)
+	RateHz   float64  `json:"rate_hz,omitempty"`  // Default 1
+	Level    uint8    `json:"level,omitempty"`    // Peak value (default 255)
+	Min      uint8    `json:"min,omitempty"`      // sine: floor value
+}
+
+// EffectInfo describes a running effect
+type EffectInfo struct {
+	EffectParams
+	Started time.Time `json:"started"`
+}
+
+// effect is a running generator
+type effect struct {
+	params EffectParams
+	source string // Frames are written as this source (arbitration)
+	lights []*LightState // Sorted by key (chase order)
+	values []map[string]uint8
+	start  time.Time
+	stop   chan struct{}
+}
+
+// StartEffect starts (or replaces) the effect on params.Target
+func (s *State) StartEffect(params EffectParams) error {
+	_, err := s.startEffect(SourceEffect, params)
+	return err
+}
+
+func (s *State) startEffect(source string, params EffectParams) (*effect, error) {
+	switch params.Type {
+	case "strobe", "chase", "rainbow", "sine":
+	default:
+		return nil, fmt.Errorf("unknown effect %q (strobe, chase, rainbow, sine)", params.Type)
+	}
+	if params.RateHz < 0 || params.RateHz > 50 {
+		return nil, fmt.Errorf("rate_hz %.2f out of range (0-50)", params.RateHz)
+	}
+	if params.RateHz == 0 {
+		params.RateHz = 1
+	}
+	if params.Level == 0 {
+		params.Level = 255
+	}
+
+	keys, err := s.resolveTargets([]string{params.Target})
+	if err != nil {
+		return nil, err
+	}
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+
+	e := &effect{
+		params: params,
+		source: source,
+		lights: make([]*LightState, 0, len(keys)),
+		values: make([]map[string]uint8, 0, len(keys)),
+		start:  time.Now(),
+		stop:   make(chan struct{}),
+	}
+	s.mu.RLock()
+	for _, key := range keys {
+		if ls, ok := s.lights[key]; ok {
+			e.lights = append(e.lights, ls)
+			e.values = append(e.values, make(map[string]uint8, len(ls.Channels)))
+		}
+	}
+	s.mu.RUnlock()
+	if len(e.lights) == 0 {
+		return nil, fmt.Errorf("no lights in %s", params.Target)
+	}
+
+	s.effectsMu.Lock()
+	if prev, ok := s.effects[params.Target]; ok {
+		close(prev.stop)
+	}
+	s.effects[params.Target] = e
+	s.effectsMu.Unlock()
+
+	go s.runEffect(e)
+	s.logger.Info("Effect started", "type", params.Type, "target", params.Target, "rate_hz", params.RateHz)
+	return e, nil
+}
+
+// StopEffect stops the effect on a target (lights keep their last values)
+func (s *State) StopEffect(target string) error {
+	s.effectsMu.Lock()
+	defer s.effectsMu.Unlock()
+
+	e, ok := s.effects[target]
+	if !ok {
+		return ErrEffectNotFound
+	}
+	close(e.stop)
+	delete(s.effects, target)
+	s.logger.Info("Effect stopped", "type", e.params.Type, "target", target)
+	return nil
+}
+
+// stopAllEffects stops every running effect
+func (s *State) stopAllEffects() {
+	s.effectsMu.Lock()
+	for target, e := range s.effects {
+		close(e.stop)
+		delete(s.effects, target)
+	}
+	s.effectsMu.Unlock()
+}
+
+// Effects returns running effects sorted by target
+func (s *State) Effects() []EffectInfo {
+	s.effectsMu.Lock()
+	defer s.effectsMu.Unlock()
+
+	result := make([]EffectInfo, 0, len(s.effects))
+	for _, e := range s.effects {
+		result = append(result, EffectInfo{EffectParams: e.params, Started: e.start})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Target < result[j].Target })
+	return result
+}
+
+// runEffect renders frames until stopped
+func (s *State) runEffect(e *effect) {
+	fps := s.config().DMX.FPS
+	if fps <= 0 {
+		fps = defaultFadeFPS
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case now := <-ticker.C:
+			e.render(now.Sub(e.start).Seconds())
+
+			// effectsMu is held while writing so a stop (e.g. blackout) never lands mid-frame
+			s.effectsMu.Lock()
+			select {
+			case <-e.stop:
+				s.effectsMu.Unlock()
+				return
+			default:
+			}
+			for i, ls := range e.lights {
+				values := e.values[i]
+				s.writeLight(e.source, ls.Group, ls.Name, func(ch *ChannelState) (uint8, uint8, bool) {
+					v, ok := values[ch.Name]
+					return v, v, ok
+				})
+			}
+			s.effectsMu.Unlock()
+		}
+	}
+}
+
+// render computes the values of every light at t seconds
+func (e *effect) render(t float64) {
+	p := e.params
+	phase := t * p.RateHz
+	n := len(e.lights)
+
+	for i, ls := range e.lights {
+		values := e.values[i]
+		switch p.Type {
+		case "strobe":
+			v := uint8(0)
+			if phase-math.Floor(phase) < 0.5 {
+				v = p.Level
+			}
+			e.fill(ls, values, func(int) uint8 { return v })
+		case "chase":
+			v := uint8(0)
+			if int(phase)%n == i {
+				v = p.Level
+			}
+			e.fill(ls, values, func(int) uint8 { return v })
+		case "sine":
+			level := float64(p.Min) + (float64(p.Level)-float64(p.Min))*(0.5-0.5*math.Cos(2*math.Pi*phase))
+			e.fill(ls, values, func(int) uint8 { return uint8(math.Round(level)) })
+		case "rainbow":
+			hue := phase + float64(i)/float64(n)
+			hue -= math.Floor(hue)
+			r, g, b := hueToRGB(hue)
+			hasRGB := false
+			for k := range ls.Channels {
+				hasRGB = hasRGB || ls.Channels[k].Name == "green"
+			}
+			count := len(ls.Channels)
+			e.fill(ls, values, func(k int) uint8 {
+				if hasRGB {
+					c := 0.0
+					switch ls.Channels[k].Name {
+					case "red":
+						c = r
+					case "green":
+						c = g
+					case "blue":
+						c = b
+					}
+					return uint8(math.Round(c * float64(p.Level)))
+				}
+				// Triangular window sweeping across the light's channels
+				d := math.Abs(hue - float64(k)/float64(count))
+				d = math.Min(d, 1-d) * float64(count)
+				return uint8(math.Round(math.Max(0, 1-d) * float64(p.Level)))
+			})
+		}
+	}
+}
+
+// fill sets the selected channels of a light using value(channel index)
+func (e *effect) fill(ls *LightState, values map[string]uint8, value func(k int) uint8) {
+	for k := range ls.Channels {
+		name := ls.Channels[k].Name // Only immutable fields are read outside s.mu
+		if len(e.params.Channels) > 0 && !slices.Contains(e.params.Channels, name) {
+			continue
+		}
+		values[name] = value(k)
+	}
+}
+
+// hueToRGB converts a hue (0-1, full saturation and value) to RGB components (0-1)
+func hueToRGB(h float64) (r, g, b float64) {
+	h6 := h * 6
+	x := 1 - math.Abs(math.Mod(h6, 2)-1)
+	switch int(h6) % 6 {
+	case 0:
+		return 1, x, 0
+	case 1:
+		return x, 1, 0
+	case 2:
+		return 0, 1, x
+	case 3:
+		return 0, x, 1
+	case 4:
+		return x, 0, 1
+	default:
+		return 1, 0, x
+	}
+}
@@ -0,0 +1,39 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import "time"
+
+// Notable events
+// Output enabled or disabled and blackouts (with the source that asked), scheduled
+// events run and backend health changes are passed to observers registered with
+// OnEvent, such as webhooks. Observers run on the goroutine that caused the event
+// and must not block.
+
+// Event is one notable event
+type Event struct {
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`            // enable, disable, blackout, schedule or backend
+	Source string    `json:"source,omitempty"` // Source that caused it (see Source*)
+	Data   any       `json:"data,omitempty"`   // schedule: the event run; backend: BackendEvent
+
+	RequestID string `json:"request_id,omitempty"` // Request that caused it (see trace.go)
+}
+
+// OnEvent registers fn to receive every event
+func (s *State) OnEvent(fn func(Event)) {
+	s.obsMu.Lock()
+	s.observers = append(s.observers, fn)
+	s.obsMu.Unlock()
+}
+
+// Emit passes e to the observers, stamped with the current time
+func (s *State) Emit(e Event) {
+	e.Time = time.Now()
+	s.obsMu.RLock()
+	defer s.obsMu.RUnlock()
+	for _, fn := range s.observers {
+		fn(e)
+	}
+}
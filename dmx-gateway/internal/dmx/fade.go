@@ -0,0 +1,346 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Fade engine
+// Fades are tracked per DMX channel and interpolated at the output frame rate by a
+// single goroutine that only runs while fades are in flight. A new fade or a direct
+// write on a channel replaces/cancels its in-flight fade. 16-bit pairs fade as one
+// value keyed by their coarse channel, so both slots move together.
+
+const defaultFadeFPS = 40 // Interpolation rate when dmx.fps is not set
+
+// channelFade is an in-flight linear fade on one channel (or 16-bit pair)
+type channelFade struct {
+	source   string
+	fine     int    // Fine slot of a 16-bit pair (0 = 8-bit channel)
+	from     uint16 // 0-255, or 0-65535 for a 16-bit pair
+	to       uint16
+	start    time.Time
+	duration time.Duration
+}
+
+// value returns the interpolated value at t and whether the fade is complete
+func (f *channelFade) value(t time.Time) (uint16, bool) {
+	p := float64(t.Sub(f.start)) / float64(f.duration)
+	if p >= 1 {
+		return f.to, true
+	}
+	if p < 0 {
+		p = 0
+	}
+	v := float64(f.from) + (float64(f.to)-float64(f.from))*p
+	return uint16(math.Round(v)), false
+}
+
+// wideValue scales an 8-bit value to a channel's fade resolution (255 -> 65535 on 16-bit pairs)
+func wideValue(ch *ChannelState, v uint8) uint16 {
+	if ch.FineCh > 0 {
+		return uint16(v) * 257
+	}
+	return uint16(v)
+}
+
+// FadeLight ramps a light's channels to values over duration (0 = immediate SetLight)
+func (s *State) FadeLight(group, name string, values map[string]uint8, duration time.Duration) error {
+	return s.fadeLight(SourceLocal, group, name, values, duration)
+}
+
+func (s *State) fadeLight(source, group, name string, values map[string]uint8, duration time.Duration) error {
+	if duration <= 0 {
+		return s.setLight(source, group, name, values)
+	}
+
+	// Channels read under the lock: a reconfigure rebuilds the lights
+	s.mu.RLock()
+	ls, ok := s.lights[config.LightKey(group, name)]
+	if !ok {
+		s.mu.RUnlock()
+		return fmt.Errorf("%w: %s", ErrLightNotFound, config.LightKey(group, name))
+	}
+	targets := make(map[int]uint16, len(values))
+	for i := range ls.Channels {
+		if val, exists := values[ls.Channels[i].Name]; exists {
+			targets[ls.Channels[i].Ch] = wideValue(&ls.Channels[i], val)
+		}
+	}
+	s.mu.RUnlock()
+
+	s.startFades(source, targets, duration)
+	return nil
+}
+
+// FadeLight16 ramps a light's channels to 16-bit values over duration (8-bit channels use the high byte)
+func (s *State) FadeLight16(group, name string, values map[string]uint16, duration time.Duration) error {
+	return s.fadeLight16(SourceLocal, group, name, values, duration)
+}
+
+func (s *State) fadeLight16(source, group, name string, values map[string]uint16, duration time.Duration) error {
+	if duration <= 0 {
+		return s.setLight16(source, group, name, values)
+	}
+
+	s.mu.RLock()
+	ls, ok := s.lights[config.LightKey(group, name)]
+	if !ok {
+		s.mu.RUnlock()
+		return fmt.Errorf("%w: %s", ErrLightNotFound, config.LightKey(group, name))
+	}
+	targets := make(map[int]uint16, len(values))
+	for _, ch := range ls.Channels {
+		if val, exists := values[ch.Name]; exists {
+			if ch.FineCh == 0 {
+				val >>= 8
+			}
+			targets[ch.Ch] = val
+		}
+	}
+	s.mu.RUnlock()
+
+	s.startFades(source, targets, duration)
+	return nil
+}
+
+// FadeGroup16 ramps all lights in a group to 16-bit values over duration
+func (s *State) FadeGroup16(groupName string, values map[string]uint16, duration time.Duration) error {
+	return s.fadeGroup16(SourceLocal, groupName, values, duration)
+}
+
+func (s *State) fadeGroup16(source, groupName string, values map[string]uint16, duration time.Duration) error {
+	lightNames := s.config().GetGroupLights(groupName)
+	if lightNames == nil {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
+	}
+	for _, name := range lightNames {
+		if err := s.fadeLight16(source, groupName, name, values, duration); err != nil {
+			s.logger.Warn("Failed to fade light in group", "light", name, "error", err)
+		}
+	}
+	return nil
+}
+
+// FadeGroup ramps all lights in a group over duration
+func (s *State) FadeGroup(groupName string, values map[string]uint8, duration time.Duration) error {
+	return s.fadeGroup(SourceLocal, groupName, values, duration)
+}
+
+func (s *State) fadeGroup(source, groupName string, values map[string]uint8, duration time.Duration) error {
+	lightNames := s.config().GetGroupLights(groupName)
+	if lightNames == nil {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
+	}
+
+	for _, name := range lightNames {
+		if err := s.fadeLight(source, groupName, name, values, duration); err != nil {
+			s.logger.Warn("Failed to fade light in group", "light", name, "error", err)
+		}
+	}
+	return nil
+}
+
+// crossfade tracks a scene recall fading as one batch
+type crossfade struct {
+	scene    string
+	channels []int
+	start    time.Time
+	duration time.Duration
+}
+
+// CrossfadeStatus reports progress of the last scene crossfade
+type CrossfadeStatus struct {
+	Active     bool    `json:"active"`
+	Scene      string  `json:"scene,omitempty"`
+	Progress   float64 `json:"progress"` // 0-1
+	ElapsedMs  int64   `json:"elapsed_ms"`
+	DurationMs int64   `json:"duration_ms"`
+}
+
+// startFades registers fades from current values to targets (DMX channel -> value)
+func (s *State) startFades(source string, targets map[int]uint16, duration time.Duration) {
+	s.fadeMu.Lock()
+	s.startFadesLocked(source, targets, duration, time.Now())
+	s.fadeMu.Unlock()
+}
+
+// startCrossfade starts a scene crossfade, replacing any previous one
+func (s *State) startCrossfade(source, scene string, targets map[int]uint16, duration time.Duration) {
+	now := time.Now()
+	xf := &crossfade{scene: scene, start: now, duration: duration}
+	for ch := range targets {
+		xf.channels = append(xf.channels, ch)
+	}
+
+	s.fadeMu.Lock()
+	s.xfade = xf
+	s.startFadesLocked(source, targets, duration, now)
+	s.fadeMu.Unlock()
+}
+
+// Crossfade returns the progress of the last scene crossfade
+func (s *State) Crossfade() CrossfadeStatus {
+	s.fadeMu.Lock()
+	defer s.fadeMu.Unlock()
+
+	xf := s.xfade
+	if xf == nil {
+		return CrossfadeStatus{}
+	}
+	elapsed := time.Since(xf.start)
+	if elapsed > xf.duration {
+		elapsed = xf.duration
+	}
+	return CrossfadeStatus{
+		Active:     s.crossfadeActiveLocked(xf),
+		Scene:      xf.scene,
+		Progress:   float64(elapsed) / float64(xf.duration),
+		ElapsedMs:  elapsed.Milliseconds(),
+		DurationMs: xf.duration.Milliseconds(),
+	}
+}
+
+// AbortCrossfade stops the running scene crossfade, leaving channels at their current values
+// Returns false if no crossfade is in flight.
+func (s *State) AbortCrossfade() bool {
+	s.fadeMu.Lock()
+	defer s.fadeMu.Unlock()
+
+	xf := s.xfade
+	if xf == nil || !s.crossfadeActiveLocked(xf) {
+		return false
+	}
+	for _, ch := range xf.channels {
+		if f, ok := s.fades[ch]; ok && f.start.Equal(xf.start) {
+			delete(s.fades, ch)
+		}
+	}
+	s.xfade = nil
+	s.logger.Info("Scene crossfade aborted", "scene", xf.scene)
+	return true
+}
+
+// crossfadeActiveLocked reports whether any channel still runs a fade from this crossfade
+// (a later fade or direct write on a channel takes it out of the crossfade). Caller holds fadeMu.
+func (s *State) crossfadeActiveLocked(xf *crossfade) bool {
+	for _, ch := range xf.channels {
+		if f, ok := s.fades[ch]; ok && f.start.Equal(xf.start) {
+			return true
+		}
+	}
+	return false
+}
+
+// startFadesLocked registers fades starting at now (caller holds fadeMu)
+func (s *State) startFadesLocked(source string, targets map[int]uint16, duration time.Duration, now time.Time) {
+	s.mu.RLock()
+	for ch, to := range targets {
+		fine := s.fineOf[ch-1]
+		from := uint16(s.channels[ch-1])
+		if fine > 0 {
+			from = from<<8 | uint16(s.channels[fine-1])
+		}
+		s.fades[ch] = &channelFade{
+			source:   source,
+			fine:     fine,
+			from:     from,
+			to:       to,
+			start:    now,
+			duration: duration,
+		}
+	}
+	s.mu.RUnlock()
+
+	if !s.fadeRunning && len(s.fades) > 0 {
+		s.fadeRunning = true
+		go s.fadeLoop()
+	}
+}
+
+// cancelFade stops an in-flight fade on a channel (value stays where it is)
+// With arbitration, only a write from the fading source cancels it.
+func (s *State) cancelFade(source string, channel int) {
+	s.fadeMu.Lock()
+	if f, ok := s.fades[channel]; ok && (s.arb == nil || f.source == source) {
+		delete(s.fades, channel)
+	}
+	s.fadeMu.Unlock()
+}
+
+// cancelAllFades stops every in-flight fade
+func (s *State) cancelAllFades() {
+	s.fadeMu.Lock()
+	clear(s.fades)
+	s.fadeMu.Unlock()
+}
+
+// FadesActive returns the number of channels currently fading
+func (s *State) FadesActive() int {
+	s.fadeMu.Lock()
+	defer s.fadeMu.Unlock()
+	return len(s.fades)
+}
+
+// fadeLoop interpolates fades at the frame rate until none remain
+func (s *State) fadeLoop() {
+	fps := s.config().DMX.FPS
+	if fps <= 0 {
+		fps = defaultFadeFPS
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if !s.fadeStep(now) {
+			return
+		}
+	}
+}
+
+// fadeStep applies one interpolation frame; returns false once all fades are done
+// fadeMu is held while applying so a concurrent direct write (which cancels the fade
+// first) always lands after this frame, never before it.
+func (s *State) fadeStep(now time.Time) bool {
+	s.fadeMu.Lock()
+	if len(s.fades) == 0 {
+		s.fadeRunning = false
+		s.fadeMu.Unlock()
+		return false
+	}
+
+	s.queueMu.Lock()
+	s.mu.Lock()
+	for ch, f := range s.fades {
+		v, done := f.value(now)
+		if done {
+			delete(s.fades, ch)
+		}
+		if f.fine > 0 {
+			s.applySourceLocked(f.source, ch, uint8(v>>8))
+			s.applySourceLocked(f.source, f.fine, uint8(v))
+			s.dirty[f.fine-1] = true
+		} else {
+			s.applySourceLocked(f.source, ch, uint8(v))
+		}
+		s.dirty[ch-1] = true
+	}
+	s.mu.Unlock()
+	if s.throttle > 0 {
+		s.scheduleFlushLocked()
+	}
+	s.queueMu.Unlock()
+	s.fadeMu.Unlock()
+
+	if s.throttle <= 0 {
+		s.Flush()
+	}
+	s.broadcastState()
+	return true
+}
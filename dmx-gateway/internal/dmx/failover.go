@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"sync"
+	"time"
+)
+
+// Backend failover chain
+// When the primary backend keeps failing for longer than `after`, output switches
+// to the secondary. The primary is then probed (Status) until it answers again,
+// at which point output fails back. Both transitions are broadcast as BackendEvent.
+
+// failover holds the primary/secondary pair and the failed-over flag
+type failover struct {
+	primary   string
+	secondary string
+	after     time.Duration
+
+	mu     sync.Mutex
+	active bool // Output currently on the secondary
+}
+
+// checkFailover switches to the secondary once the primary has failed long enough
+func (s *State) checkFailover(failingFor time.Duration, cause error) {
+	f := s.failover
+	if f == nil || failingFor < f.after {
+		return
+	}
+
+	f.mu.Lock()
+	if f.active || s.Backends().Active != f.primary {
+		f.mu.Unlock()
+		return
+	}
+	f.active = true
+	f.mu.Unlock()
+
+	go func() {
+		s.logger.Warn("DMX backend failing over",
+			"from", f.primary, "to", f.secondary, "failing_for", failingFor.Round(time.Second), "error", cause)
+
+		if err := s.SwitchBackend(f.secondary); err != nil {
+			s.logger.Error("DMX failover failed", "to", f.secondary, "error", err)
+			f.mu.Lock()
+			f.active = false
+			f.mu.Unlock()
+			return
+		}
+
+		e := BackendEvent{
+			Type:  "backend",
+			Event: "failover",
+			From:  f.primary,
+			To:    f.secondary,
+			Error: cause.Error(),
+		}
+		s.broadcastEvent(e)
+		s.Emit(Event{Event: "backend", Data: e})
+		s.probePrimary()
+	}()
+}
+
+// probePrimary polls the primary backend until it recovers, then fails back
+// Stops early if output was switched away from the secondary manually.
+func (s *State) probePrimary() {
+	f := s.failover
+
+	s.backendMu.RLock()
+	primary := s.backends[f.primary]
+	s.backendMu.RUnlock()
+
+	interval := f.after
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if s.Backends().Active != f.secondary {
+			break
+		}
+		if _, err := primary.Status(); err != nil {
+			s.logger.Debug("DMX primary backend still failing", "backend", f.primary, "error", err)
+			continue
+		}
+
+		if err := s.SwitchBackend(f.primary); err != nil {
+			s.logger.Warn("DMX failback failed", "to", f.primary, "error", err)
+			continue
+		}
+		s.logger.Info("DMX backend failed back", "to", f.primary)
+		e := BackendEvent{
+			Type:  "backend",
+			Event: "failback",
+			From:  f.secondary,
+			To:    f.primary,
+		}
+		s.broadcastEvent(e)
+		s.Emit(Event{Event: "backend", Data: e})
+		break
+	}
+
+	f.mu.Lock()
+	f.active = false
+	f.mu.Unlock()
+}
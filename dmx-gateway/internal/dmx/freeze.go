@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+// Freeze (hold output)
+// Freeze latches the current output frame: the backend keeps receiving it while writes
+// (including blackout) are still applied to the state as pending values, visible to
+// clients and the recorder. Unfreeze sends the pending frame. Enable/disable still act.
+
+// Freeze latches the current output frame (no-op if already frozen)
+func (s *State) Freeze() {
+	// Pending coalesced writes reach the output before it is latched
+	s.Flush()
+
+	s.mu.Lock()
+	if s.frozen == nil {
+		frame := s.channels
+		for i, v := range frame {
+			frame[i] = s.output(i+1, v)
+		}
+		s.frozen = &frame
+	}
+	s.mu.Unlock()
+
+	s.logger.Info("Output frozen")
+	s.broadcastState()
+}
+
+// Unfreeze resumes output and sends the pending frame
+func (s *State) Unfreeze() error {
+	s.mu.Lock()
+	wasFrozen := s.frozen != nil
+	s.frozen = nil
+	s.mu.Unlock()
+	if !wasFrozen {
+		return nil
+	}
+
+	channels := s.outputChannels()
+	if err := s.backendResult(s.backend().SetChannels(1, channels[:])); err != nil {
+		return err
+	}
+	s.logger.Info("Output unfrozen")
+	s.broadcastState()
+	return nil
+}
+
+// IsFrozen returns whether output is frozen
+func (s *State) IsFrozen() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.frozen != nil
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// State history
+// Every change made through a source handle (HTTP, WebSocket, MQTT, Modbus, scheduler)
+// is recorded with its time, source and target in a ring buffer of the last N changes,
+// to answer "what turned rack 3 off at 02:13, and from where". Effect frames, playback
+// and show steps are not recorded, their start commands are.
+
+const defaultHistorySize = 500
+
+// HistoryEntry is one recorded change
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Action  string    `json:"action"`           // set, fade, scene, preset, cct, cue, effect, locate, undo, scene_save, release, blackout, enable, disable
+	Target  string    `json:"target,omitempty"` // "group" or "group/light" (empty = not light-specific)
+	Name    string    `json:"name,omitempty"`   // Scene, preset, cue list or effect type
+	Channel int       `json:"ch,omitempty"`     // Raw channel writes
+	Values  any       `json:"values,omitempty"` // Values written (channel name -> value, or raw value)
+	FadeMs  int64     `json:"fade_ms,omitempty"`
+
+	RequestID string `json:"request_id,omitempty"` // Request that made the change (see trace.go)
+}
+
+// HistoryQuery selects history entries (zero fields match everything)
+type HistoryQuery struct {
+	Limit  int       // Most recent entries returned (0 = all)
+	Source string    // Only this source
+	Target string    // Entries touching this group or light, plus non light-specific ones
+	Since  time.Time // Only entries at or after this time
+}
+
+// history is a fixed-size ring of entries
+type history struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int  // Slot of the next entry
+	full    bool // Ring wrapped at least once
+}
+
+func newHistory(size int) *history {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	return &history{entries: make([]HistoryEntry, size)}
+}
+
+// add records an entry, overwriting the oldest once full
+func (h *history) add(e HistoryEntry) {
+	h.mu.Lock()
+	h.entries[h.next] = e
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+	h.mu.Unlock()
+}
+
+// recordHistory records a change made by source
+func (s *State) recordHistory(e HistoryEntry) HistoryEntry {
+	e.Time = time.Now()
+	s.hist.add(e)
+	return e
+}
+
+// History returns the entries matching q, most recent first
+func (s *State) History(q HistoryQuery) []HistoryEntry {
+	h := s.hist
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := h.next
+	if h.full {
+		n = len(h.entries)
+	}
+	result := make([]HistoryEntry, 0)
+	for i := 1; i <= n; i++ {
+		e := h.entries[(h.next-i+len(h.entries))%len(h.entries)]
+		if !q.Since.IsZero() && e.Time.Before(q.Since) {
+			break // Older entries are older still
+		}
+		if q.Source != "" && e.Source != q.Source {
+			continue
+		}
+		if q.Target != "" && e.Target != "" &&
+			!matches([]string{q.Target}, e.Target) && !matches([]string{e.Target}, q.Target) {
+			continue
+		}
+		result = append(result, e)
+		if q.Limit > 0 && len(result) == q.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// record adds a successful change to the history and passes err through
+func (w *Source) record(err error, e HistoryEntry) error {
+	if err != nil {
+		return err
+	}
+	e.Source = w.name
+	e.RequestID = w.requestID
+	if v, ok := e.Values.(map[string]uint8); ok {
+		e.Values = maps.Clone(v)
+	} else if v, ok := e.Values.(map[string]uint16); ok {
+		e.Values = maps.Clone(v)
+	}
+	e = w.state.recordHistory(e)
+	switch e.Action {
+	case "enable", "disable", "blackout":
+		w.state.Emit(Event{Event: e.Action, Source: w.name, RequestID: w.requestID})
+	}
+	if w.requestID != "" {
+		w.state.publishChange(e)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Locate (highlight)
+// Locate strobes a light or group for a few seconds, then restores the values it had,
+// so technicians can match physical fixtures with config names. It runs as a strobe
+// effect: a blackout or another effect on the same target cancels it without restoring.
+
+const (
+	defaultLocateDuration = 5 * time.Second
+	locateRateHz          = 2
+)
+
+// Locate flashes target for duration (0 = 5s) then restores its previous values
+func (s *State) Locate(target string, duration time.Duration) error {
+	return s.locate(SourceEffect, target, duration)
+}
+
+// locate runs a locate, flashing and restoring as source
+func (s *State) locate(source, target string, duration time.Duration) error {
+	if target == "" {
+		return fmt.Errorf("target required")
+	}
+	if duration <= 0 {
+		duration = defaultLocateDuration
+	}
+
+	keys, err := s.resolveTargets([]string{target})
+	if err != nil {
+		return err
+	}
+	saved := make(map[string]map[string]uint8, len(keys))
+	s.mu.RLock()
+	for _, key := range keys {
+		if ls, ok := s.lights[key]; ok {
+			values := make(map[string]uint8, len(ls.Values))
+			for k, v := range ls.Values {
+				values[k] = v
+			}
+			saved[key] = values
+		}
+	}
+	s.mu.RUnlock()
+
+	e, err := s.startEffect(source, EffectParams{Type: "strobe", Target: target, RateHz: locateRateHz})
+	if err != nil {
+		return err
+	}
+
+	time.AfterFunc(duration, func() {
+		// Restored under effectsMu so a concurrent blackout lands after, never before
+		s.effectsMu.Lock()
+		defer s.effectsMu.Unlock()
+		if s.effects[target] != e {
+			return // Stopped or replaced meanwhile
+		}
+		close(e.stop)
+		delete(s.effects, target)
+		for key, values := range saved {
+			group, name, _ := strings.Cut(key, "/")
+			if err := s.setLight(source, group, name, values); err != nil {
+				s.logger.Warn("Locate restore failed", "light", key, "error", err)
+			}
+		}
+		s.logger.Info("Locate done", "target", target)
+	})
+	s.logger.Info("Locating", "target", target, "duration", duration)
+	return nil
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"encoding/json"
+	"sort"
+
+	"dmx-gateway/internal/config"
+)
+
+// Out-of-service lights (maintenance mask)
+// A masked light still accepts writes (state, clients and the recorder follow them) but
+// its channels output 0 on the DMX bus, e.g. while the fixture is away for repair. The
+// mask works per DMX channel: a light sharing channels with a masked one is masked too.
+// Masks are runtime only and survive light provisioning.
+
+// Mask marks a light out of service (masked) or back in service
+func (s *State) Mask(group, name string, masked bool) error {
+	key := config.LightKey(group, name)
+
+	s.mu.Lock()
+	ls, ok := s.lights[key]
+	if !ok {
+		s.mu.Unlock()
+		return ErrLightNotFound
+	}
+	if masked {
+		s.masked[key] = true
+	} else {
+		delete(s.masked, key)
+	}
+	s.remaskLocked()
+	channels := make([]int, 0, len(ls.Channels))
+	for _, ch := range ls.Channels {
+		channels = append(channels, ch.Ch)
+		if ch.FineCh > 0 {
+			channels = append(channels, ch.FineCh)
+		}
+	}
+	// Clients rebuild their light list (masked flags) from a fresh init message
+	msg, _ := json.Marshal(WSInitMessage{Type: "init", Enabled: s.enabled, Groups: s.groupNames, Lights: s.lights})
+	s.mu.Unlock()
+
+	for _, ch := range channels {
+		s.sendChannel(ch)
+	}
+	s.publish(msg)
+	if masked {
+		s.logger.Info("Light out of service", "light", key)
+	} else {
+		s.logger.Info("Light back in service", "light", key)
+	}
+	return nil
+}
+
+// Masked returns the keys of masked lights, sorted
+func (s *State) Masked() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.masked))
+	for key := range s.masked {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// remaskLocked recomputes masked channels and light flags, dropping removed lights
+// Caller holds s.mu (write).
+func (s *State) remaskLocked() {
+	s.maskedCh = [512]bool{}
+	for key := range s.masked {
+		ls, ok := s.lights[key]
+		if !ok {
+			delete(s.masked, key)
+			continue
+		}
+		for _, ch := range ls.Channels {
+			s.maskedCh[ch.Ch-1] = true
+			if ch.FineCh > 0 {
+				s.maskedCh[ch.FineCh-1] = true
+			}
+		}
+	}
+	for key, ls := range s.lights {
+		ls.Masked = s.masked[key]
+	}
+}
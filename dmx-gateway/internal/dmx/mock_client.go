@@ -5,12 +5,15 @@ package dmx
 
 import (
 	"log/slog"
+	"sync"
 
 	"dmx-gateway/internal/config"
 )
 
 // MockClient is a mock DMX client for testing
+// Also available at runtime as the "mock" backend
 type MockClient struct {
+	mu        sync.Mutex
 	enabled   bool
 	channels  [512]uint8
 	calls     []string
@@ -23,6 +26,8 @@ func NewMockClient() *MockClient {
 }
 
 func (m *MockClient) Enable() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.calls = append(m.calls, "enable")
 	if m.failNext {
 		m.failNext = false
@@ -33,6 +38,8 @@ func (m *MockClient) Enable() error {
 }
 
 func (m *MockClient) Disable() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.calls = append(m.calls, "disable")
 	if m.failNext {
 		m.failNext = false
@@ -43,6 +50,8 @@ func (m *MockClient) Disable() error {
 }
 
 func (m *MockClient) Blackout() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.calls = append(m.calls, "blackout")
 	if m.failNext {
 		m.failNext = false
@@ -55,6 +64,8 @@ func (m *MockClient) Blackout() error {
 }
 
 func (m *MockClient) SetChannel(channel int, value uint8) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.calls = append(m.calls, "set_channel")
 	if m.failNext {
 		m.failNext = false
@@ -67,6 +78,8 @@ func (m *MockClient) SetChannel(channel int, value uint8) error {
 }
 
 func (m *MockClient) SetChannels(startChannel int, values []uint8) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.calls = append(m.calls, "set_channels")
 	if m.failNext {
 		m.failNext = false
@@ -82,6 +95,8 @@ func (m *MockClient) SetChannels(startChannel int, values []uint8) error {
 }
 
 func (m *MockClient) Status() (*Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.calls = append(m.calls, "status")
 	if m.failNext {
 		m.failNext = false
@@ -96,16 +111,22 @@ func (m *MockClient) Status() (*Status, error) {
 
 // FailNext makes the next call fail
 func (m *MockClient) FailNext() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.failNext = true
 }
 
 // Calls returns the list of method calls
 func (m *MockClient) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.calls
 }
 
 // GetChannel returns a channel value
 func (m *MockClient) GetChannel(ch int) uint8 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if ch >= 1 && ch <= 512 {
 		return m.channels[ch-1]
 	}
@@ -113,11 +134,14 @@ func (m *MockClient) GetChannel(ch int) uint8 {
 }
 
 // Reset clears the mock state
-func (m *MockClient) Reset() {
+func (m *MockClient) Reset() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.enabled = false
 	m.channels = [512]uint8{}
 	m.calls = nil
 	m.failNext = false
+	return nil
 }
 
 type MockError struct {
@@ -131,23 +155,5 @@ func (e *MockError) Error() string {
 // NewStateWithMock creates a State with a mock client for testing
 func NewStateWithMock(cfg *config.Config, logger *slog.Logger) (*State, *MockClient) {
 	mock := NewMockClient()
-
-	// Create a wrapper that implements the same interface
-	client := &Client{
-		clientPath: "mock",
-		logger:     logger,
-	}
-
-	state := &State{
-		cfg:      cfg,
-		client:   client,
-		logger:   logger,
-		throttle: 0, // No throttle in tests
-		subs:     make(map[chan []byte]struct{}),
-	}
-
-	// Replace client methods with mock
-	// Note: In real code, we'd use an interface. For simplicity, we test via State directly.
-
-	return state, mock
+	return NewState(cfg, mock, logger), mock
 }
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Multi-target set
+// A look often spans several groups and lights. SetMulti checks every target and
+// channel name first, then applies all values under one lock with one backend write
+// and one broadcast: either the whole look applies or nothing does, and WebSocket
+// clients never see a half-applied frame. Later targets win on shared channels.
+// SetBulk adds a range of raw channels to the same mutation (Modbus FC16 blocks),
+// targets winning over them.
+
+// TargetValues is one target of a multi-target set
+type TargetValues struct {
+	Target    string             `json:"target"`               // "group" or "group/light"
+	Values    map[string]uint8   `json:"values,omitempty"`     // Channel name -> 0-255
+	ValuesPct map[string]float64 `json:"values_pct,omitempty"` // Channel name -> 0-100% (explicit values win)
+}
+
+// SetMulti applies values to several targets at once
+func (s *State) SetMulti(items []TargetValues) error {
+	_, err := s.setMulti(SourceLocal, items)
+	return err
+}
+
+// SetMulti applies values to several targets at once
+func (w *Source) SetMulti(items []TargetValues) error {
+	applied, err := w.state.setMulti(w.name, items)
+	return w.record(err, HistoryEntry{Action: "set", Values: applied})
+}
+
+// SetBulk sets consecutive channels from start and target values at once
+// It records the same history entries as SetChannels and SetMulti.
+func (w *Source) SetBulk(start int, values []uint8, items []TargetValues) error {
+	applied, err := w.state.setBulk(w.name, start, values, items)
+	if err != nil {
+		return err
+	}
+	if len(values) > 0 {
+		w.record(nil, channelsEntry(start, values))
+	}
+	if len(items) > 0 {
+		w.record(nil, HistoryEntry{Action: "set", Values: applied})
+	}
+	return nil
+}
+
+// setMulti validates all items, then writes them as one mutation; returns target -> values applied
+func (s *State) setMulti(source string, items []TargetValues) (map[string]map[string]uint8, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no targets")
+	}
+	applied, channels, err := s.resolveMulti(items)
+	if err != nil {
+		return nil, err
+	}
+	return applied, s.setChannels(source, channels)
+}
+
+// setBulk validates the channel range and items, then writes them as one mutation
+func (s *State) setBulk(source string, start int, values []uint8, items []TargetValues) (map[string]map[string]uint8, error) {
+	if len(values) == 0 && len(items) == 0 {
+		return nil, fmt.Errorf("nothing to set")
+	}
+	if len(values) > 0 && (start < 1 || start+len(values)-1 > 512) {
+		return nil, fmt.Errorf("channels %d-%d out of range (1-512)", start, start+len(values)-1)
+	}
+	applied, channels, err := s.resolveMulti(items)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if _, ok := channels[start+i]; !ok {
+			channels[start+i] = v // Targets win
+		}
+	}
+	return applied, s.setChannels(source, channels)
+}
+
+// resolveMulti checks items and returns target -> values applied and the channel values
+func (s *State) resolveMulti(items []TargetValues) (map[string]map[string]uint8, map[int]uint8, error) {
+	applied := make(map[string]map[string]uint8, len(items))
+	channels := make(map[int]uint8)
+	for _, item := range items {
+		values, err := PercentValues(item.ValuesPct)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", item.Target, err)
+		}
+		maps.Copy(values, item.Values)
+
+		if item.Target == "" {
+			return nil, nil, fmt.Errorf("target required")
+		}
+		keys, err := s.resolveTargets([]string{item.Target})
+		if err != nil {
+			return nil, nil, err
+		}
+		used := make(map[string]bool, len(values))
+		s.mu.RLock()
+		for _, key := range keys {
+			ls := s.lights[key]
+			if ls == nil {
+				continue // Removed since the target was resolved
+			}
+			for i := range ls.Channels {
+				ch := &ls.Channels[i]
+				v, ok := values[ch.Name]
+				if !ok {
+					continue
+				}
+				used[ch.Name] = true
+				// 8-bit values on a 16-bit pair fill both slots, like SetLight
+				channels[ch.Ch] = v
+				if ch.FineCh > 0 {
+					channels[ch.FineCh] = v
+				}
+			}
+		}
+		s.mu.RUnlock()
+		for name := range values {
+			if !used[name] {
+				return nil, nil, fmt.Errorf("%s: unknown channel %s", item.Target, name)
+			}
+		}
+		if applied[item.Target] == nil {
+			applied[item.Target] = values
+		} else {
+			maps.Copy(applied[item.Target], values)
+		}
+	}
+
+	return applied, channels, nil
+}
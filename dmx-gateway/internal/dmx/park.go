@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Parked channels
+// A parked channel holds a fixed value whatever is written to it: sets, group writes,
+// scenes, fades, the scheduler and blackout are ignored (house lights, hazers). Writes
+// are still tracked underneath, so unparking returns the channel to the value it would
+// have had. Parks come from the config (park section) or the API.
+
+// parkedChannel is the park state of one channel (guarded by State.mu)
+type parkedChannel struct {
+	on    bool
+	value uint8 // Held value
+	under uint8 // Value written meanwhile, restored on unpark
+}
+
+// Park holds channels at fixed values until unparked (DMX channel -> value)
+func (s *State) Park(values map[int]uint8) error {
+	for ch := range values {
+		if ch < 1 || ch > 512 {
+			return fmt.Errorf("channel %d out of range (1-512)", ch)
+		}
+	}
+
+	s.mu.Lock()
+	for ch, v := range values {
+		p := &s.parked[ch-1]
+		if !p.on {
+			p.on = true
+			p.under = s.channels[ch-1]
+		}
+		p.value = s.limits[ch-1].clamp(v)
+		s.applyChannelLocked(ch, p.under)
+	}
+	s.mu.Unlock()
+
+	for ch := range values {
+		s.sendChannel(ch)
+	}
+	s.logger.Info("Channels parked", "channels", len(values))
+	s.broadcastState()
+	return nil
+}
+
+// Unpark releases parked channels (none = all), restoring the values written meanwhile
+func (s *State) Unpark(channels []int) {
+	var released []int
+	s.mu.Lock()
+	for ch := 1; ch <= 512; ch++ {
+		p := &s.parked[ch-1]
+		if !p.on || (len(channels) > 0 && !slices.Contains(channels, ch)) {
+			continue
+		}
+		p.on = false
+		s.applyChannelLocked(ch, p.under)
+		released = append(released, ch)
+	}
+	s.mu.Unlock()
+
+	if len(released) == 0 {
+		return
+	}
+	for _, ch := range released {
+		s.sendChannel(ch)
+	}
+	s.logger.Info("Channels unparked", "channels", len(released))
+	s.broadcastState()
+}
+
+// Parked returns the parked channels and their held values
+func (s *State) Parked() map[int]uint8 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[int]uint8)
+	for i, p := range s.parked {
+		if p.on {
+			result[i+1] = p.value
+		}
+	}
+	return result
+}
+
+// hasParkedLocked reports whether any channel is parked (caller holds s.mu)
+func (s *State) hasParkedLocked() bool {
+	for i := range s.parked {
+		if s.parked[i].on {
+			return true
+		}
+	}
+	return false
+}
+
+// reparkLocked re-applies parked values after channels were zeroed (caller holds s.mu)
+func (s *State) reparkLocked() {
+	for i := range s.parked {
+		if s.parked[i].on {
+			s.applyChannelLocked(i+1, 0)
+		}
+	}
+}
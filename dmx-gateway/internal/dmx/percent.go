@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"fmt"
+	"math"
+)
+
+// Percent values
+// Building automation works in percent: values_pct requests are converted to 0-255
+// (50% -> 128) and each light reports its intensity as a percentage, taken from its
+// brightest channel.
+
+// PercentValue converts 0-100% to a 0-255 channel value
+func PercentValue(pct float64) (uint8, error) {
+	if pct < 0 || pct > 100 || math.IsNaN(pct) {
+		return 0, fmt.Errorf("percent %v out of range (0-100)", pct)
+	}
+	return uint8(math.Round(pct * 255 / 100)), nil
+}
+
+// PercentValues converts channel name -> percent to channel name -> 0-255 value
+func PercentValues(pct map[string]float64) (map[string]uint8, error) {
+	values := make(map[string]uint8, len(pct))
+	for name, p := range pct {
+		v, err := PercentValue(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// updateIntensity refreshes the light's intensity from its brightest channel (caller holds s.mu)
+func (ls *LightState) updateIntensity() {
+	var highest uint8
+	for i := range ls.Channels {
+		highest = max(highest, ls.Channels[i].Value)
+	}
+	ls.IntensityPct = math.Round(float64(highest)*1000/255) / 10
+}
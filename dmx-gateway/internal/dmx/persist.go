@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Last-state persistence
+// The logical channel array and enabled flag are snapshotted to a file when they
+// change (checked every interval) and can be restored on boot, so a power blip
+// brings the room back where it was instead of dark.
+
+// Snapshot is the persisted output state
+type Snapshot struct {
+	Enabled  bool      `json:"enabled"`
+	Channels []uint8   `json:"channels"` // 512 logical values (index 0 = DMX ch 1)
+	Saved    time.Time `json:"saved"`
+}
+
+// snapshot returns the current output state
+func (s *State) snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Snapshot{
+		Enabled:  s.enabled,
+		Channels: append([]uint8(nil), s.channels[:]...),
+	}
+}
+
+// SaveSnapshot writes the current state to path if it changed since the last save
+func (s *State) SaveSnapshot(path string) error {
+	snap := s.snapshot()
+
+	s.persistMu.Lock()
+	defer s.persistMu.Unlock()
+	if s.lastSnapshot != nil && snap.Enabled == s.lastSnapshot.Enabled &&
+		string(snap.Channels) == string(s.lastSnapshot.Channels) {
+		return nil
+	}
+
+	snap.Saved = time.Now()
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("save state: %w", err)
+	}
+	s.lastSnapshot = &snap
+	return nil
+}
+
+// RestoreSnapshot loads a snapshot and applies it (channels, then enable/disable)
+// Returns false without error if no snapshot exists yet.
+func (s *State) RestoreSnapshot(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read state: %w", err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return false, fmt.Errorf("parse state: %w", err)
+	}
+	if len(snap.Channels) != 512 {
+		return false, fmt.Errorf("parse state: expected 512 channels, got %d", len(snap.Channels))
+	}
+
+	if err := s.applySnapshot(SourceLocal, snap); err != nil {
+		return false, err
+	}
+
+	s.persistMu.Lock()
+	s.lastSnapshot = &snap
+	s.persistMu.Unlock()
+
+	s.logger.Info("State restored", "path", path, "enabled", snap.Enabled, "saved", snap.Saved)
+	return true, nil
+}
+
+// applySnapshot replaces the full frame and enabled flag with a snapshot, written as source
+// In-flight fades and pending writes are superseded.
+func (s *State) applySnapshot(source string, snap Snapshot) error {
+	s.cancelAllFades()
+
+	s.mu.Lock()
+	for i, v := range snap.Channels {
+		s.applySourceLocked(source, i+1, v)
+	}
+	s.mu.Unlock()
+
+	channels := s.outputChannels()
+	if err := s.backendResult(s.backend().SetChannels(1, channels[:])); err != nil {
+		return err
+	}
+
+	var err error
+	if snap.Enabled != s.IsEnabled() {
+		if snap.Enabled {
+			err = s.Enable()
+		} else {
+			err = s.Disable()
+		}
+	}
+	s.broadcastState()
+	return err
+}
+
+// StartPersist snapshots state to path every interval (only when changed)
+func (s *State) StartPersist(path string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	s.persistMu.Lock()
+	s.stopPersist = make(chan struct{})
+	stop := s.stopPersist
+	s.persistMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		s.logger.Info("State persistence started", "path", path, "interval", interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.SaveSnapshot(path); err != nil {
+					s.logger.Warn("Failed to persist state", "error", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopPersist stops periodic snapshots and writes a final one
+func (s *State) StopPersist(path string) {
+	s.persistMu.Lock()
+	if s.stopPersist != nil {
+		close(s.stopPersist)
+		s.stopPersist = nil
+	}
+	s.persistMu.Unlock()
+
+	if err := s.SaveSnapshot(path); err != nil {
+		s.logger.Warn("Failed to persist state", "error", err)
+	}
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"slices"
+	"time"
+)
+
+// Staggered power-up
+// With dmx.power_up configured, enabling output brings groups on one at a time instead
+// of all fixtures at once (LED driver inrush can trip breakers). Every light channel is
+// held dark on output when enabling, then groups are released in order, group_delay_ms
+// apart. Writes are applied meanwhile, so startup values come up with their group.
+// Parked channels are never held. Disable cancels a power-up in progress.
+
+// holdForPowerUp holds all light channels dark before output is enabled
+// Returns the channels of each group in release order, nil when not staggering.
+func (s *State) holdForPowerUp() ([][]int, chan struct{}) {
+	cfg := s.config()
+	if cfg.DMX.PowerUp == nil {
+		return nil, nil
+	}
+
+	order := slices.Clone(cfg.DMX.PowerUp.Order)
+	var rest []string
+	for group := range cfg.Lights {
+		if !slices.Contains(order, group) {
+			rest = append(rest, group)
+		}
+	}
+	slices.Sort(rest)
+	order = append(order, rest...)
+
+	s.mu.Lock()
+	if s.enabled {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	s.stopPowerUpLocked()
+	groups := make([][]int, 0, len(order))
+	for _, group := range order {
+		var chans []int
+		for _, light := range cfg.Lights[group] {
+			for _, c := range light {
+				if !s.parked[c.Ch-1].on && !s.held[c.Ch-1] {
+					s.held[c.Ch-1] = true
+					chans = append(chans, c.Ch)
+				}
+			}
+		}
+		groups = append(groups, chans)
+	}
+	stop := make(chan struct{})
+	s.powerUpStop = stop
+	s.mu.Unlock()
+
+	// The backend keeps its frame while disabled: replace it with the held one
+	channels := s.outputChannels()
+	if err := s.backendResult(s.backend().SetChannels(1, channels[:])); err != nil {
+		s.logger.Warn("Power-up hold failed, enabling at once", "error", err)
+		s.mu.Lock()
+		s.stopPowerUpLocked()
+		s.mu.Unlock()
+		return nil, nil
+	}
+	return groups, stop
+}
+
+// powerUp releases held groups one by one, delay apart
+func (s *State) powerUp(groups [][]int, delay time.Duration, stop chan struct{}) {
+	for i, chans := range groups {
+		if i > 0 {
+			select {
+			case <-stop:
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		s.mu.Lock()
+		select {
+		case <-stop:
+			s.mu.Unlock()
+			return
+		default:
+		}
+		for _, ch := range chans {
+			s.held[ch-1] = false
+		}
+		if i == len(groups)-1 {
+			s.powerUpStop = nil
+		}
+		s.mu.Unlock()
+
+		channels := s.outputChannels()
+		if err := s.backendResult(s.backend().SetChannels(1, channels[:])); err != nil {
+			s.logger.Warn("Power-up step failed", "step", i+1, "error", err)
+		}
+	}
+	s.logger.Info("Power-up complete", "groups", len(groups))
+}
+
+// stopPowerUpLocked cancels a power-up in progress and releases held channels
+// Caller holds s.mu (write).
+func (s *State) stopPowerUpLocked() {
+	if s.powerUpStop != nil {
+		close(s.powerUpStop)
+		s.powerUpStop = nil
+	}
+	s.held = [512]bool{}
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Presets
+// Named channel values defined in config on a light or group (presets: section).
+// A light inherits its group presets; a light-level preset of the same name wins.
+
+// ErrPresetNotFound is returned when a target has no preset of that name
+var ErrPresetNotFound = errors.New("preset not found")
+
+// RecallPreset applies a named preset to a target ("group" or "group/light")
+func (s *State) RecallPreset(target, name string, fade time.Duration) error {
+	return s.recallPreset(SourceLocal, target, name, fade)
+}
+
+func (s *State) recallPreset(source, target, name string, fade time.Duration) error {
+	cfg := s.config()
+	values, ok := cfg.Preset(target, name)
+	if !ok {
+		return ErrPresetNotFound
+	}
+
+	group, light, _ := strings.Cut(target, "/")
+	if light != "" {
+		return s.fadeLight(source, group, light, values, fade)
+	}
+
+	// Group preset: lights with their own preset of that name keep it
+	for _, lightName := range cfg.GetGroupLights(group) {
+		lightValues, _ := cfg.Preset(group+"/"+lightName, name)
+		if err := s.fadeLight(source, group, lightName, lightValues, fade); err != nil {
+			s.logger.Warn("Failed to recall preset on light", "light", lightName, "preset", name, "error", err)
+		}
+	}
+	return nil
+}
+
+// Presets returns preset names available on each configured target
+func (s *State) Presets() map[string][]string {
+	result := make(map[string][]string, len(s.config().Presets))
+	for target, presets := range s.config().Presets {
+		names := make([]string, 0, len(presets))
+		for name := range presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		result[target] = names
+	}
+	return result
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"dmx-gateway/internal/config"
+)
+
+// Runtime provisioning
+// Lights and groups can be added or removed without a restart: the change is applied
+// to a copy of the config, validated like the file (channel conflicts, presets,
+// startup and circadian references), then swapped in and the lights cache rebuilt.
+// Channels no longer used by any light are zeroed. SaveLights writes the current
+// lights back to the config file.
+
+var (
+	// ErrLightExists is returned when adding a light that is already configured
+	ErrLightExists = errors.New("light already exists")
+	// ErrLightNotFound is returned when removing an unknown light
+	ErrLightNotFound = errors.New("light not found")
+	// ErrGroupExists is returned when adding a group that is already configured
+	ErrGroupExists = errors.New("group already exists")
+	// ErrGroupNotFound is returned when removing an unknown group
+	ErrGroupNotFound = errors.New("group not found")
+)
+
+// AddLight adds a light with its channels to a group (created if needed)
+func (s *State) AddLight(group, name string, channels []config.Channel) error {
+	if group == "" || name == "" {
+		return fmt.Errorf("group and light name required")
+	}
+	return s.reconfigure(func(cfg *config.Config) error {
+		if _, ok := cfg.Lights[group][name]; ok {
+			return ErrLightExists
+		}
+		if cfg.Lights[group] == nil {
+			cfg.Lights[group] = make(map[string][]config.Channel)
+		}
+		cfg.Lights[group][name] = channels
+		return nil
+	})
+}
+
+// AddGroup adds a group with its lights
+func (s *State) AddGroup(group string, lights map[string][]config.Channel) error {
+	if group == "" || len(lights) == 0 {
+		return fmt.Errorf("group name and lights required")
+	}
+	return s.reconfigure(func(cfg *config.Config) error {
+		if _, ok := cfg.Lights[group]; ok {
+			return ErrGroupExists
+		}
+		cfg.Lights[group] = lights
+		return nil
+	})
+}
+
+// RemoveLight removes a light (its group goes with its last light)
+func (s *State) RemoveLight(group, name string) error {
+	return s.reconfigure(func(cfg *config.Config) error {
+		if _, ok := cfg.Lights[group][name]; !ok {
+			return ErrLightNotFound
+		}
+		delete(cfg.Lights[group], name)
+		if len(cfg.Lights[group]) == 0 {
+			delete(cfg.Lights, group)
+		}
+		return nil
+	})
+}
+
+// RemoveGroup removes a group and all its lights
+func (s *State) RemoveGroup(group string) error {
+	return s.reconfigure(func(cfg *config.Config) error {
+		if _, ok := cfg.Lights[group]; !ok {
+			return ErrGroupNotFound
+		}
+		delete(cfg.Lights, group)
+		return nil
+	})
+}
+
+// ApplyConfig swaps in a whole new config (see config.Derive)
+// The lights cache, arbitration policies and parked channels follow; presets, cues,
+// shows and the other sections read by State apply from the next use. Lights that
+// still exist keep their values, even when repatched to other channels.
+func (s *State) ApplyConfig(next *config.Config) error {
+	prev := s.config()
+
+	// Channel values of each light, by channel name
+	type slot struct{ ch, fine int }
+	s.mu.RLock()
+	old := s.channels
+	slots := make(map[string]map[string]slot, len(s.lights))
+	for key, ls := range s.lights {
+		slots[key] = make(map[string]slot, len(ls.Channels))
+		for _, ch := range ls.Channels {
+			slots[key][ch.Name] = slot{ch.Ch, ch.FineCh}
+		}
+	}
+	s.mu.RUnlock()
+
+	if err := s.reconfigure(func(cfg *config.Config) error {
+		*cfg = *next
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	moved := make(map[int]uint8)
+	s.mu.RLock()
+	for key, ls := range s.lights {
+		for _, ch := range ls.Channels {
+			was, ok := slots[key][ch.Name]
+			if !ok || (was.ch == ch.Ch && was.fine == ch.FineCh) {
+				continue
+			}
+			moved[ch.Ch] = old[was.ch-1]
+			if ch.FineCh > 0 && was.fine > 0 {
+				moved[ch.FineCh] = old[was.fine-1]
+			}
+		}
+	}
+	s.mu.RUnlock()
+	if len(moved) > 0 {
+		if err := s.setChannels(SourceLocal, moved); err != nil {
+			return err
+		}
+	}
+
+	var unpark []int
+	for ch := range prev.Park {
+		if _, ok := next.Park[ch]; !ok {
+			unpark = append(unpark, ch)
+		}
+	}
+	if len(unpark) > 0 {
+		s.Unpark(unpark)
+	}
+	park := make(map[int]uint8)
+	for ch, v := range next.Park {
+		if old, ok := prev.Park[ch]; !ok || old != v {
+			park[ch] = v
+		}
+	}
+	if len(park) > 0 {
+		return s.Park(park)
+	}
+	return nil
+}
+
+// SaveLights writes the current lights to the config file
+func (s *State) SaveLights() error {
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+	return s.config().SaveLights()
+}
+
+// reconfigure applies change to a copy of the config and swaps it in once valid
+func (s *State) reconfigure(change func(cfg *config.Config) error) error {
+	s.provisionMu.Lock()
+	defer s.provisionMu.Unlock()
+
+	next := s.config().Clone()
+	if err := change(next); err != nil {
+		return err
+	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	// Channels mapped before but not after are released and zeroed
+	var freed []int
+	s.mu.RLock()
+	for i := range s.channelToLight {
+		if len(s.channelToLight[i]) > 0 {
+			freed = append(freed, i+1)
+		}
+	}
+	s.mu.RUnlock()
+	used := make(map[int]bool)
+	for _, lights := range next.Lights {
+		for _, channels := range lights {
+			for _, ch := range channels {
+				used[ch.Ch] = true
+				if ch.FineCh > 0 {
+					used[ch.FineCh] = true
+				}
+			}
+		}
+	}
+	n := 0
+	for _, ch := range freed {
+		if !used[ch] {
+			freed[n] = ch
+			n++
+		}
+	}
+	freed = freed[:n]
+
+	s.fadeMu.Lock()
+	for _, ch := range freed {
+		delete(s.fades, ch)
+	}
+	s.queueMu.Lock()
+	s.mu.Lock()
+	s.cfg.Store(next)
+	s.buildLightsCache(next)
+	if s.arb != nil {
+		s.arb.setPolicies(next)
+		for _, ch := range freed {
+			s.arb.contrib[ch-1] = nil
+		}
+	}
+	pending := len(freed)
+	for _, ch := range freed {
+		s.applyChannelLocked(ch, 0)
+		s.dirty[ch-1] = true
+	}
+	// New views pick up the current channel values (and limits of new lights)
+	for ch := 1; ch <= 512; ch++ {
+		if v := s.channels[ch-1]; len(s.channelToLight[ch-1]) > 0 && s.applyChannelLocked(ch, v) != v {
+			s.dirty[ch-1] = true
+			pending++
+		}
+	}
+	// Clients rebuild their light list from a fresh init message
+	msg, _ := json.Marshal(WSInitMessage{Type: "init", Enabled: s.enabled, Groups: s.groupNames, Lights: s.lights})
+	lights := len(s.lights)
+	s.mu.Unlock()
+	if pending > 0 && s.throttle > 0 {
+		s.scheduleFlushLocked()
+	}
+	s.queueMu.Unlock()
+	s.fadeMu.Unlock()
+
+	if pending > 0 && s.throttle <= 0 {
+		s.Flush()
+	}
+	s.publish(msg)
+	s.logger.Info("Lights reconfigured", "lights", lights, "groups", len(next.Lights), "released_channels", len(freed))
+	return nil
+}
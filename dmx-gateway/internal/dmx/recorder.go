@@ -0,0 +1,367 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Show recorder and player
+// The recorder samples the logical channel array every output frame and appends the
+// changed channels to <recorder.dir>/<name>.jsonl, one line per frame:
+//   {"t":0,"v":{"1":255,...}}   first frame holds all 512 channels
+//   {"t":40,"v":{"3":128}}      t = ms since start, v = changed channels
+//   {"t":9000}                  last frame marks the end (loop point)
+// The player replays frames in real time, optionally looped. A blackout stops playback.
+
+var (
+	// ErrRecorderDisabled is returned when the recorder section is missing from the config
+	ErrRecorderDisabled = errors.New("recorder not configured")
+	// ErrNotRecording is returned when stopping a recording that is not running
+	ErrNotRecording = errors.New("not recording")
+	// ErrNotPlaying is returned when stopping playback that is not running
+	ErrNotPlaying = errors.New("not playing")
+	// ErrRecordingNotFound is returned when playing a recording that does not exist
+	ErrRecordingNotFound = errors.New("recording not found")
+)
+
+// recordFrame is one line of a recording
+type recordFrame struct {
+	T int64         `json:"t"`           // ms since start
+	V map[int]uint8 `json:"v,omitempty"` // DMX channel -> value
+}
+
+// recording is an in-progress capture
+type recording struct {
+	name   string
+	file   *os.File
+	w      *bufio.Writer
+	start  time.Time
+	last   [512]uint8
+	frames int
+	err    error
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// playback is a running player
+type playback struct {
+	name string
+	loop bool
+	stop chan struct{}
+}
+
+// RecordingInfo describes a stored recording
+type RecordingInfo struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// RecorderStatus describes the recorder, the player and stored recordings
+type RecorderStatus struct {
+	Recording  string          `json:"recording,omitempty"` // Name being recorded
+	Playing    string          `json:"playing,omitempty"`   // Name being played
+	Loop       bool            `json:"loop,omitempty"`
+	Recordings []RecordingInfo `json:"recordings"`
+}
+
+// recordingPath validates a recording name and returns its file path
+func (s *State) recordingPath(name string) (string, error) {
+	if s.config().Recorder == nil {
+		return "", ErrRecorderDisabled
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid recording name %q", name)
+	}
+	return filepath.Join(s.config().Recorder.Dir, name+".jsonl"), nil
+}
+
+// StartRecording starts capturing channel changes to the named recording (overwritten)
+func (s *State) StartRecording(name string) error {
+	path, err := s.recordingPath(name)
+	if err != nil {
+		return err
+	}
+
+	s.recMu.Lock()
+	defer s.recMu.Unlock()
+	if s.rec != nil {
+		return fmt.Errorf("already recording %q", s.rec.name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create recorder dir: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create recording: %w", err)
+	}
+
+	r := &recording{
+		name:  name,
+		file:  f,
+		w:     bufio.NewWriter(f),
+		start: time.Now(),
+		last:  s.GetChannels(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	first := recordFrame{V: make(map[int]uint8, 512)}
+	for i, v := range r.last {
+		first.V[i+1] = v
+	}
+	r.write(first)
+
+	s.rec = r
+	go s.runRecording(r)
+	s.logger.Info("Recording started", "name", name, "path", path)
+	return nil
+}
+
+// StopRecording ends the current recording and returns the number of frames captured
+func (s *State) StopRecording() (int, error) {
+	s.recMu.Lock()
+	r := s.rec
+	s.rec = nil
+	s.recMu.Unlock()
+	if r == nil {
+		return 0, ErrNotRecording
+	}
+
+	close(r.stop)
+	<-r.done
+	if r.err != nil {
+		return r.frames, fmt.Errorf("write recording: %w", r.err)
+	}
+	s.logger.Info("Recording stopped", "name", r.name, "frames", r.frames)
+	return r.frames, nil
+}
+
+// runRecording samples channels every frame until stopped
+func (s *State) runRecording(r *recording) {
+	defer close(r.done)
+
+	fps := s.config().DMX.FPS
+	if fps <= 0 {
+		fps = defaultFadeFPS
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			// End marker keeps the hold time after the last change (loop point)
+			r.write(recordFrame{T: time.Since(r.start).Milliseconds()})
+			if err := r.w.Flush(); err != nil && r.err == nil {
+				r.err = err
+			}
+			if err := r.file.Close(); err != nil && r.err == nil {
+				r.err = err
+			}
+			return
+		case now := <-ticker.C:
+			channels := s.GetChannels()
+			var changed map[int]uint8
+			for i, v := range channels {
+				if v != r.last[i] {
+					if changed == nil {
+						changed = make(map[int]uint8)
+					}
+					changed[i+1] = v
+				}
+			}
+			if changed != nil {
+				r.last = channels
+				r.write(recordFrame{T: now.Sub(r.start).Milliseconds(), V: changed})
+			}
+		}
+	}
+}
+
+// write appends a frame (the first error is kept and reported on stop)
+func (r *recording) write(f recordFrame) {
+	if r.err != nil {
+		return
+	}
+	data, err := json.Marshal(f)
+	if err == nil {
+		data = append(data, '\n')
+		_, err = r.w.Write(data)
+	}
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.frames++
+}
+
+// Play replays a recording in real time (replacing any running playback)
+func (s *State) Play(name string, loop bool) error {
+	path, err := s.recordingPath(name)
+	if err != nil {
+		return err
+	}
+	frames, err := loadRecording(path)
+	if err != nil {
+		return err
+	}
+
+	s.stopPlayback()
+
+	if loop && frames[len(frames)-1].T <= 0 {
+		return fmt.Errorf("recording %q too short to loop", name)
+	}
+
+	p := &playback{name: name, loop: loop, stop: make(chan struct{})}
+	s.playMu.Lock()
+	s.player = p
+	s.playMu.Unlock()
+
+	go s.runPlayback(p, frames)
+	s.logger.Info("Playback started", "name", name, "loop", loop, "frames", len(frames))
+	return nil
+}
+
+// StopPlayback stops the running playback (channels keep their last values)
+func (s *State) StopPlayback() error {
+	if !s.stopPlayback() {
+		return ErrNotPlaying
+	}
+	return nil
+}
+
+// stopPlayback stops the running playback, returns false if none
+func (s *State) stopPlayback() bool {
+	s.playMu.Lock()
+	defer s.playMu.Unlock()
+	if s.player == nil {
+		return false
+	}
+	close(s.player.stop)
+	s.logger.Info("Playback stopped", "name", s.player.name)
+	s.player = nil
+	return true
+}
+
+// runPlayback applies frames at their recorded times until done or stopped
+func (s *State) runPlayback(p *playback, frames []recordFrame) {
+	for {
+		start := time.Now()
+		for _, f := range frames {
+			if d := time.Until(start.Add(time.Duration(f.T) * time.Millisecond)); d > 0 {
+				timer := time.NewTimer(d)
+				select {
+				case <-p.stop:
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+			if len(f.V) == 0 {
+				continue
+			}
+
+			// playMu is held while writing so a stop (e.g. blackout) never lands mid-frame
+			s.playMu.Lock()
+			select {
+			case <-p.stop:
+				s.playMu.Unlock()
+				return
+			default:
+			}
+			if err := s.setChannels(SourcePlayer, f.V); err != nil {
+				s.logger.Warn("Playback write failed", "name", p.name, "error", err)
+			}
+			s.playMu.Unlock()
+		}
+		if !p.loop {
+			break
+		}
+	}
+
+	s.playMu.Lock()
+	if s.player == p {
+		s.player = nil
+		s.logger.Info("Playback finished", "name", p.name)
+	}
+	s.playMu.Unlock()
+}
+
+// loadRecording reads all frames of a recording file
+func loadRecording(path string) ([]recordFrame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrRecordingNotFound, strings.TrimSuffix(filepath.Base(path), ".jsonl"))
+		}
+		return nil, fmt.Errorf("read recording: %w", err)
+	}
+	defer f.Close()
+
+	var frames []recordFrame
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var frame recordFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("parse recording line %d: %w", line, err)
+		}
+		frames = append(frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read recording: %w", err)
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("recording is empty")
+	}
+	return frames, nil
+}
+
+// Recorder returns recorder/player state and stored recordings sorted by name
+func (s *State) Recorder() (*RecorderStatus, error) {
+	if s.config().Recorder == nil {
+		return nil, ErrRecorderDisabled
+	}
+
+	status := &RecorderStatus{Recordings: []RecordingInfo{}}
+	s.recMu.Lock()
+	if s.rec != nil {
+		status.Recording = s.rec.name
+	}
+	s.recMu.Unlock()
+	s.playMu.Lock()
+	if s.player != nil {
+		status.Playing = s.player.name
+		status.Loop = s.player.loop
+	}
+	s.playMu.Unlock()
+
+	entries, err := os.ReadDir(s.config().Recorder.Dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("list recordings: %w", err)
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if !ok || e.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		status.Recordings = append(status.Recordings, RecordingInfo{Name: name, Size: info.Size(), Modified: info.ModTime()})
+	}
+	sort.Slice(status.Recordings, func(i, j int) bool { return status.Recordings[i].Name < status.Recordings[j].Name })
+	return status, nil
+}
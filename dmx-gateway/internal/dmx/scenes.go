@@ -0,0 +1,341 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Named scenes
+// A scene captures light values (all lights or a subset) under a name. Scenes are
+// persisted as JSON to scenes.file and recalled through State so every protocol
+// (HTTP, WebSocket, MQTT, Modbus, scheduler) shares the same store.
+
+// ErrSceneNotFound is returned when recalling or deleting an unknown scene
+var ErrSceneNotFound = errors.New("scene not found")
+
+// Scene is a named set of light values
+type Scene struct {
+	Name    string                      `json:"name"`
+	Lights  map[string]map[string]uint8 `json:"lights"` // light key -> channel name -> value
+	Created time.Time                   `json:"created"`
+}
+
+// SceneInfo is the scene summary returned by listings
+type SceneInfo struct {
+	Index  int    `json:"index"` // 1-based, stable for a given set of names (Modbus recall)
+	Name   string `json:"name"`
+	Lights int    `json:"lights"`
+}
+
+// LoadScenes reads persisted scenes and enables persistence to path
+// A missing file is not an error (starts with no scenes).
+func (s *State) LoadScenes(path string) error {
+	s.scenesMu.Lock()
+	defer s.scenesMu.Unlock()
+
+	// Persistence is only enabled once the file is known good (never overwrite a corrupt store)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.scenesPath = path
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read scenes: %w", err)
+	}
+
+	var scenes []*Scene
+	if err := json.Unmarshal(data, &scenes); err != nil {
+		return fmt.Errorf("parse scenes: %w", err)
+	}
+	for _, sc := range scenes {
+		s.scenes[sc.Name] = sc
+	}
+	s.scenesPath = path
+
+	s.logger.Info("Scenes loaded", "path", path, "scenes", len(s.scenes))
+	return nil
+}
+
+// SaveScene captures current values of targets ("group" or "group/light", empty = all lights)
+func (s *State) SaveScene(name string, targets []string) (*Scene, error) {
+	if name == "" {
+		return nil, fmt.Errorf("scene name required")
+	}
+
+	keys, err := s.resolveTargets(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &Scene{
+		Name:    name,
+		Lights:  make(map[string]map[string]uint8, len(keys)),
+		Created: time.Now(),
+	}
+
+	s.mu.RLock()
+	for _, key := range keys {
+		ls, ok := s.lights[key]
+		if !ok {
+			continue // Removed since targets were resolved
+		}
+		values := make(map[string]uint8, len(ls.Values))
+		for k, v := range ls.Values {
+			values[k] = v
+		}
+		sc.Lights[key] = values
+	}
+	s.mu.RUnlock()
+
+	s.scenesMu.Lock()
+	old := s.scenes[name]
+	s.scenes[name] = sc
+	if err = s.persistScenesLocked(); err != nil {
+		s.restoreSceneLocked(name, old)
+	}
+	s.scenesMu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Scene saved", "name", name, "lights", len(sc.Lights))
+	return sc, nil
+}
+
+// StoreScene saves explicit values (light key -> channel name -> value) as a scene
+// With merge, values are merged into the existing scene (ErrSceneNotFound if none).
+func (s *State) StoreScene(name string, lights map[string]map[string]uint8, merge bool) (*Scene, error) {
+	if name == "" {
+		return nil, fmt.Errorf("scene name required")
+	}
+
+	s.mu.RLock()
+	for key, values := range lights {
+		ls, ok := s.lights[key]
+		if !ok {
+			s.mu.RUnlock()
+			return nil, fmt.Errorf("%w: %s", ErrLightNotFound, key)
+		}
+		for ch := range values {
+			if _, ok := ls.Values[ch]; !ok {
+				s.mu.RUnlock()
+				return nil, fmt.Errorf("unknown channel %s on %s", ch, key)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	s.scenesMu.Lock()
+	defer s.scenesMu.Unlock()
+
+	sc := &Scene{Name: name, Lights: make(map[string]map[string]uint8, len(lights)), Created: time.Now()}
+	if merge {
+		old, ok := s.scenes[name]
+		if !ok {
+			return nil, ErrSceneNotFound
+		}
+		for key, values := range old.Lights {
+			sc.Lights[key] = maps.Clone(values)
+		}
+	}
+	for key, values := range lights {
+		if sc.Lights[key] == nil {
+			sc.Lights[key] = make(map[string]uint8, len(values))
+		}
+		maps.Copy(sc.Lights[key], values)
+	}
+	old := s.scenes[name]
+	s.scenes[name] = sc
+	if err := s.persistScenesLocked(); err != nil {
+		s.restoreSceneLocked(name, old)
+		return nil, err
+	}
+	s.logger.Info("Scene stored", "name", name, "lights", len(sc.Lights), "merge", merge)
+	return sc, nil
+}
+
+// RecallScene applies a scene, crossfading every affected channel over duration (0 = immediate)
+func (s *State) RecallScene(name string, fade time.Duration) error {
+	return s.recallScene(SourceLocal, name, fade)
+}
+
+func (s *State) recallScene(source, name string, fade time.Duration) error {
+	sc := s.GetScene(name)
+	if sc == nil {
+		return ErrSceneNotFound
+	}
+
+	if fade <= 0 {
+		for key, values := range sc.Lights {
+			group, light, _ := strings.Cut(key, "/")
+			if s.GetLight(group, light) == nil {
+				s.logger.Warn("Scene references unknown light", "scene", name, "light", key)
+				continue
+			}
+			if err := s.setLight(source, group, light, values); err != nil {
+				s.logger.Warn("Failed to recall scene light", "scene", name, "light", key, "error", err)
+			}
+		}
+		s.logger.Info("Scene recalled", "name", name)
+		return nil
+	}
+
+	// One batch so all channels share the same start time and progress
+	targets := make(map[int]uint16)
+	s.mu.RLock()
+	for key, values := range sc.Lights {
+		ls, ok := s.lights[key]
+		if !ok {
+			s.logger.Warn("Scene references unknown light", "scene", name, "light", key)
+			continue
+		}
+		for i := range ls.Channels {
+			if val, exists := values[ls.Channels[i].Name]; exists {
+				targets[ls.Channels[i].Ch] = wideValue(&ls.Channels[i], val)
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	s.startCrossfade(source, name, targets, fade)
+	s.logger.Info("Scene recalled", "name", name, "fade", fade)
+	return nil
+}
+
+// RecallSceneIndex recalls a scene by its 1-based index in the sorted scene list
+func (s *State) RecallSceneIndex(index int, fade time.Duration) error {
+	return s.recallSceneIndex(SourceLocal, index, fade)
+}
+
+func (s *State) recallSceneIndex(source string, index int, fade time.Duration) error {
+	scenes := s.Scenes()
+	if index < 1 || index > len(scenes) {
+		return ErrSceneNotFound
+	}
+	return s.recallScene(source, scenes[index-1].Name, fade)
+}
+
+// DeleteScene removes a scene
+func (s *State) DeleteScene(name string) error {
+	s.scenesMu.Lock()
+	defer s.scenesMu.Unlock()
+
+	old, ok := s.scenes[name]
+	if !ok {
+		return ErrSceneNotFound
+	}
+	delete(s.scenes, name)
+	if err := s.persistScenesLocked(); err != nil {
+		s.restoreSceneLocked(name, old)
+		return err
+	}
+	return nil
+}
+
+// restoreSceneLocked puts back a scene entry after a failed write (old nil = none)
+func (s *State) restoreSceneLocked(name string, old *Scene) {
+	if old == nil {
+		delete(s.scenes, name)
+	} else {
+		s.scenes[name] = old
+	}
+}
+
+// GetScene returns a scene by name (nil if not found)
+func (s *State) GetScene(name string) *Scene {
+	s.scenesMu.RLock()
+	defer s.scenesMu.RUnlock()
+	return s.scenes[name]
+}
+
+// Scenes returns all scenes sorted by name with their recall index
+func (s *State) Scenes() []SceneInfo {
+	s.scenesMu.RLock()
+	defer s.scenesMu.RUnlock()
+
+	result := make([]SceneInfo, 0, len(s.scenes))
+	for name, sc := range s.scenes {
+		result = append(result, SceneInfo{Name: name, Lights: len(sc.Lights)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	for i := range result {
+		result[i].Index = i + 1
+	}
+	return result
+}
+
+// resolveTargets expands "group" / "group/light" targets to light keys (empty = all lights)
+func (s *State) resolveTargets(targets []string) ([]string, error) {
+	if len(targets) == 0 {
+		return s.GetLightKeys(), nil
+	}
+
+	var keys []string
+	for _, target := range targets {
+		group, light, _ := strings.Cut(target, "/")
+		if light != "" {
+			if s.GetLight(group, light) == nil {
+				return nil, fmt.Errorf("%w: %s", ErrLightNotFound, target)
+			}
+			keys = append(keys, config.LightKey(group, light))
+			continue
+		}
+		names := s.config().GetGroupLights(group)
+		if names == nil {
+			return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, target)
+		}
+		for _, name := range names {
+			keys = append(keys, config.LightKey(group, name))
+		}
+	}
+	return keys, nil
+}
+
+// persistScenesLocked writes all scenes to disk atomically (caller holds scenesMu)
+func (s *State) persistScenesLocked() error {
+	if s.scenesPath == "" {
+		return nil
+	}
+
+	scenes := make([]*Scene, 0, len(s.scenes))
+	for _, sc := range s.scenes {
+		scenes = append(scenes, sc)
+	}
+	sort.Slice(scenes, func(i, j int) bool { return scenes[i].Name < scenes[j].Name })
+
+	data, err := json.MarshalIndent(scenes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.scenesPath, data)
+}
+
+// writeFileAtomic writes data to a temp file and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
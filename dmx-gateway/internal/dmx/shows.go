@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Timeline shows
+// A show is a list of steps (scene, presets, values, blackout) applied at an offset
+// from the show start, e.g. t=0 set X, t=5s fade group Y over 3s, t=20s blackout.
+// Shows come from the config or are loaded through the API; one runs at a time and
+// progress is broadcast to subscribers as ShowEvent. A blackout stops the show
+// (except a blackout step of the show itself).
+
+var (
+	// ErrShowNotFound is returned when starting an unknown show
+	ErrShowNotFound = errors.New("show not found")
+	// ErrShowNotRunning is returned when stopping while no show runs
+	ErrShowNotRunning = errors.New("no show running")
+)
+
+// ShowStatus describes the running show and available shows
+type ShowStatus struct {
+	Running   string   `json:"running,omitempty"`
+	Step      int      `json:"step,omitempty"` // Last applied step (1-based)
+	Steps     int      `json:"steps,omitempty"`
+	ElapsedMs int64    `json:"elapsed_ms,omitempty"` // Into the current pass (loops restart at 0)
+	LengthMs  int64    `json:"length_ms,omitempty"`
+	Loop      bool     `json:"loop,omitempty"`
+	Shows     []string `json:"shows"`
+}
+
+// showRun is a running show
+type showRun struct {
+	name   string
+	steps  []config.ShowStep // Sorted by at_ms
+	loop   bool
+	length time.Duration
+	start  time.Time // Start of the current pass
+	step   int
+	stop   chan struct{}
+}
+
+// LoadShow validates and stores a show under name (replaces a config show of that name)
+func (s *State) LoadShow(name string, show *config.Show) error {
+	if name == "" {
+		return fmt.Errorf("show name required")
+	}
+	if err := s.config().ValidateShow(show); err != nil {
+		return err
+	}
+
+	s.showsMu.Lock()
+	s.shows[name] = show
+	s.showsMu.Unlock()
+	s.logger.Info("Show loaded", "name", name, "steps", len(show.Steps))
+	return nil
+}
+
+// StartShow starts a show from the beginning (replacing the running one)
+func (s *State) StartShow(name string) error {
+	s.showsMu.Lock()
+	show, ok := s.shows[name]
+	if !ok {
+		show, ok = s.config().Shows[name]
+	}
+	s.showsMu.Unlock()
+	if !ok {
+		return ErrShowNotFound
+	}
+
+	run := &showRun{
+		name:  name,
+		steps: slices.Clone(show.Steps),
+		loop:  show.Loop,
+		stop:  make(chan struct{}),
+	}
+	sort.SliceStable(run.steps, func(i, j int) bool { return run.steps[i].AtMs < run.steps[j].AtMs })
+	for _, step := range run.steps {
+		run.length = max(run.length, time.Duration(step.AtMs+step.FadeMs)*time.Millisecond)
+	}
+
+	s.stopShow()
+	s.showsMu.Lock()
+	run.start = time.Now()
+	s.show = run
+	s.showsMu.Unlock()
+
+	s.broadcastEvent(run.event("start", 0))
+	go s.runShow(run)
+	s.logger.Info("Show started", "name", name, "steps", len(run.steps), "loop", run.loop)
+	return nil
+}
+
+// StopShow stops the running show (channels keep their values)
+func (s *State) StopShow() error {
+	if !s.stopShow() {
+		return ErrShowNotRunning
+	}
+	return nil
+}
+
+// stopShow stops the running show, returns false if none
+func (s *State) stopShow() bool {
+	s.showsMu.Lock()
+	run := s.show
+	if run == nil {
+		s.showsMu.Unlock()
+		return false
+	}
+	close(run.stop)
+	s.show = nil
+	event := run.event("stopped", run.step)
+	s.showsMu.Unlock()
+
+	s.broadcastEvent(event)
+	s.logger.Info("Show stopped", "name", run.name)
+	return true
+}
+
+// runShow applies steps at their offsets until the show ends or is stopped
+func (s *State) runShow(run *showRun) {
+	for {
+		for i, step := range run.steps {
+			if !run.wait(time.Duration(step.AtMs) * time.Millisecond) {
+				return
+			}
+
+			// showsMu is held while applying so a stop (e.g. blackout) never lands mid-step
+			s.showsMu.Lock()
+			select {
+			case <-run.stop:
+				s.showsMu.Unlock()
+				return
+			default:
+			}
+			s.applyShowStep(run.name, step)
+			run.step = i + 1
+			s.showsMu.Unlock()
+			s.broadcastEvent(run.event("step", i+1))
+		}
+
+		// Pass ends once the last fade completes
+		if !run.wait(run.length) {
+			return
+		}
+		if !run.loop {
+			break
+		}
+		s.showsMu.Lock()
+		run.start = time.Now()
+		s.showsMu.Unlock()
+	}
+
+	s.showsMu.Lock()
+	finished := s.show == run
+	if finished {
+		s.show = nil
+	}
+	s.showsMu.Unlock()
+	if finished {
+		s.broadcastEvent(run.event("finished", len(run.steps)))
+		s.logger.Info("Show finished", "name", run.name)
+	}
+}
+
+// applyShowStep applies one step: scene, presets, values, then blackout
+// Caller holds showsMu.
+func (s *State) applyShowStep(name string, step config.ShowStep) {
+	fade := time.Duration(step.FadeMs) * time.Millisecond
+
+	if step.Scene != "" {
+		if err := s.recallScene(SourceShow, step.Scene, fade); err != nil {
+			s.logger.Warn("Show scene failed", "show", name, "scene", step.Scene, "error", err)
+		}
+	}
+	for target, preset := range step.Preset {
+		if err := s.recallPreset(SourceShow, target, preset, fade); err != nil {
+			s.logger.Warn("Show preset failed", "show", name, "target", target, "preset", preset, "error", err)
+		}
+	}
+	for target, values := range step.Set {
+		group, light, _ := strings.Cut(target, "/")
+		var err error
+		if light == "" {
+			err = s.fadeGroup(SourceShow, group, values, fade)
+		} else {
+			err = s.fadeLight(SourceShow, group, light, values, fade)
+		}
+		if err != nil {
+			s.logger.Warn("Show values failed", "show", name, "target", target, "error", err)
+		}
+	}
+	if step.Blackout {
+		if err := s.blackout(false); err != nil {
+			s.logger.Warn("Show blackout failed", "show", name, "error", err)
+		}
+	}
+}
+
+// wait sleeps until offset into the current pass, returns false if stopped
+// start is only written by the show goroutine, so reading it here needs no lock.
+func (r *showRun) wait(offset time.Duration) bool {
+	d := time.Until(r.start.Add(offset))
+	if d <= 0 {
+		select {
+		case <-r.stop:
+			return false
+		default:
+			return true
+		}
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-r.stop:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// event builds a progress event (caller owns run or holds showsMu)
+func (r *showRun) event(event string, step int) ShowEvent {
+	return ShowEvent{
+		Type:      "show",
+		Event:     event,
+		Name:      r.name,
+		Step:      step,
+		Steps:     len(r.steps),
+		ElapsedMs: time.Since(r.start).Milliseconds(),
+		LengthMs:  r.length.Milliseconds(),
+	}
+}
+
+// Shows returns the running show progress and available show names
+func (s *State) Shows() ShowStatus {
+	s.showsMu.Lock()
+	defer s.showsMu.Unlock()
+
+	cfg := s.config()
+	status := ShowStatus{Shows: make([]string, 0, len(cfg.Shows)+len(s.shows))}
+	for name := range cfg.Shows {
+		status.Shows = append(status.Shows, name)
+	}
+	for name := range s.shows {
+		if _, ok := cfg.Shows[name]; !ok {
+			status.Shows = append(status.Shows, name)
+		}
+	}
+	sort.Strings(status.Shows)
+
+	if run := s.show; run != nil {
+		status.Running = run.name
+		status.Step = run.step
+		status.Steps = len(run.steps)
+		status.ElapsedMs = time.Since(run.start).Milliseconds()
+		status.LengthMs = run.length.Milliseconds()
+		status.Loop = run.loop
+	}
+	return status
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"strings"
+	"time"
+)
+
+// ApplyStartup applies the configured power-on values (startup section)
+// Failures are logged per step so one bad entry doesn't leave the rest dark.
+func (s *State) ApplyStartup() {
+	st := s.config().Startup
+	if st == nil {
+		return
+	}
+	fade := time.Duration(st.FadeMs) * time.Millisecond
+
+	if st.Scene != "" {
+		if err := s.RecallScene(st.Scene, fade); err != nil {
+			s.logger.Warn("Startup scene failed", "scene", st.Scene, "error", err)
+		}
+	}
+
+	for target, preset := range st.Preset {
+		if err := s.RecallPreset(target, preset, fade); err != nil {
+			s.logger.Warn("Startup preset failed", "target", target, "preset", preset, "error", err)
+		}
+	}
+
+	for target, values := range st.Set {
+		group, light, _ := strings.Cut(target, "/")
+		var err error
+		if light == "" {
+			err = s.FadeGroup(group, values, fade)
+		} else {
+			err = s.FadeLight(group, light, values, fade)
+		}
+		if err != nil {
+			s.logger.Warn("Startup values failed", "target", target, "error", err)
+		}
+	}
+
+	s.logger.Info("Startup values applied", "scene", st.Scene, "presets", len(st.Preset), "targets", len(st.Set))
+}
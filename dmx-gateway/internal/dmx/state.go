@@ -5,31 +5,64 @@ package dmx
 
 import (
 	"encoding/json"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/metrics"
 )
 
 // State manages DMX channel state and coordinates updates
 // Zero-allocation design: all data structures are pre-allocated at startup
 type State struct {
-	cfg      *config.Config
-	client   *Client
+	cfg      atomic.Pointer[config.Config] // Swapped by runtime provisioning (see provision.go)
 	logger   *slog.Logger
 
+	// Output backend (switchable at runtime, see backend.go)
+	backendMu   sync.RWMutex
+	client      Backend
+	backendName string
+	backends    map[string]Backend
+
 	mu       sync.RWMutex
 	channels [512]uint8 // Raw DMX channels (index 0 = DMX ch 1)
 	enabled  bool
 	throttle time.Duration
+	frozen   *[512]uint8 // Latched output frame while frozen (see freeze.go)
+	parked   [512]parkedChannel // Channels held at fixed values (see park.go)
+	held     [512]bool          // Channels kept dark during a staggered power-up (see powerup.go)
+	powerUpStop chan struct{}   // Closed to cancel a power-up in progress
+	masked   map[string]bool    // Out-of-service light keys (see mask.go)
+	maskedCh [512]bool          // Channels of masked lights, output 0
+
+	refreshHz int // Firmware refresh rate applied from dmx.fps (0 = firmware default)
 
-	// Pre-computed lights data (allocated ONCE at startup)
+	// Serializes runtime config changes (see provision.go)
+	provisionMu sync.Mutex
+
+	// Pre-computed lights data (allocated at startup, rebuilt only by provisioning)
 	// Key: "group/name", Value: pointer to pre-allocated LightState
+	// Guarded by mu; a rebuild replaces the maps and slices instead of mutating them.
 	lights      map[string]*LightState
 	lightKeys   []string // Ordered list of light keys for iteration
 	groupNames  []string // Pre-computed group names
 
+	// Per-channel output limits from config (zero value = unclamped)
+	limits [512]channelLimit
+
+	// Per-channel dimming curves, logical value -> output value (nil = linear)
+	// channels holds logical values; curves are applied only when sending to the backend.
+	curves [512]*[256]uint8
+
+	// 16-bit pairs: fineOf[coarse-1] = fine DMX channel (0 = 8-bit channel)
+	fineOf [512]int
+
 	// Channel to light mapping for fast updates
 	// channelToLight[dmxCh-1] = list of (lightKey, channelIndex) pairs
 	channelToLight [512][]channelMapping
@@ -37,19 +70,110 @@ type State struct {
 	// Subscribers for state changes (WebSocket clients)
 	// Channel sends pre-marshaled JSON []byte to avoid race conditions
 	subsMu sync.RWMutex
-	subs   map[chan []byte]struct{}
+	subs   map[chan []byte]*subscriber // Optional per-subscriber filter (see subscribers.go)
 
 	// Pre-allocated values map for broadcasts (avoids alloc per broadcast)
 	valuesCache map[string]map[string]uint8
 
 	// Refresh goroutine
 	stopRefresh chan struct{}
+
+	// Write coalescing queue (throttle_ms)
+	// Dirty channels are collected and flushed as batches once per throttle window
+	queueMu      sync.Mutex
+	flushMu      sync.Mutex // Serializes flushes so batches reach the backend in order
+	dirty        [512]bool
+	flushPending bool
+
+	// Backend watchdog
+	wdMu sync.Mutex
+	wd   watchdog
+
+	// Primary -> secondary failover (nil = disabled)
+	failover *failover
+
+	// Source arbitration (nil = plain last-write-wins, see arbitration.go)
+	arb *arbiter
+
+	// Fade engine (see fade.go)
+	fadeMu      sync.Mutex
+	fades       map[int]*channelFade // DMX channel -> in-flight fade
+	fadeRunning bool
+	xfade       *crossfade // Last scene crossfade (guarded by fadeMu)
+
+	// Running effects by target (see effects.go)
+	effectsMu sync.Mutex
+	effects   map[string]*effect
+
+	// Cue list playback positions (see cues.go)
+	cuesMu sync.Mutex
+	cues   map[string]*cuePlayer
+
+	// Named scenes (see scenes.go)
+	scenesMu   sync.RWMutex
+	scenes     map[string]*Scene
+	scenesPath string // Empty = in-memory only
+
+	// Last-state persistence (see persist.go)
+	persistMu    sync.Mutex
+	lastSnapshot *Snapshot
+	stopPersist  chan struct{}
+
+	// Change history (see history.go)
+	hist *history
+
+	// Event observers, e.g. webhooks (see events.go)
+	obsMu     sync.RWMutex
+	observers []func(Event)
+
+	// Undo history (see undo.go)
+	undoMu          sync.Mutex
+	undo            []Snapshot
+	lastUndoCapture time.Time
+
+	// Show recorder and player (see recorder.go)
+	recMu  sync.Mutex
+	rec    *recording
+	playMu sync.Mutex
+	player *playback
+
+	// Timeline shows (see shows.go)
+	showsMu sync.Mutex
+	shows   map[string]*config.Show // Loaded through the API (override config shows)
+	show    *showRun
+
+	// External variables, e.g. MQTT sensors (see variables.go)
+	varsMu sync.RWMutex
+	vars   map[string]Variable
+
+	// Background status poller cache
+	statusMu      sync.RWMutex
+	statusCache   Status
+	statusUpdated time.Time
+	stopStatus    chan struct{}
+}
+
+// channelLimit clamps a channel output: non-zero values below min are raised, values above max lowered
+type channelLimit struct {
+	min uint8
+	max uint8 // 0 = no upper limit
+}
+
+func (l channelLimit) clamp(value uint8) uint8 {
+	if l.max > 0 && value > l.max {
+		return l.max
+	}
+	if value > 0 && value < l.min {
+		return l.min
+	}
+	return value
 }
 
 // channelMapping maps a DMX channel to a light's channel index
 type channelMapping struct {
 	lightKey     string
 	channelIndex int
+	fine         bool // Slot is the fine (LSB) half of a 16-bit pair
 }
 
 // StateUpdate is the single event type sent to subscribers
@@ -61,26 +185,77 @@ type StateUpdate struct {
 }
 
 // NewState creates a new state manager with pre-allocated data structures
-func NewState(cfg *config.Config, client *Client, logger *slog.Logger) *State {
+// The initial backend is registered under cfg.DMX.Backend (default "rpmsg")
+func NewState(cfg *config.Config, client Backend, logger *slog.Logger) *State {
+	name := cfg.DMX.Backend
+	if name == "" {
+		name = BackendRPMSG
+	}
+
 	s := &State{
-		cfg:      cfg,
-		client:   client,
-		logger:   logger,
+		client:      client,
+		backendName: name,
+		backends:    map[string]Backend{name: client},
+		logger:      logger,
 		throttle: time.Duration(cfg.DMX.ThrottleMs) * time.Millisecond,
-		subs:     make(map[chan []byte]struct{}),
-		lights:   make(map[string]*LightState),
+		subs:     make(map[chan []byte]*subscriber),
+		fades:    make(map[int]*channelFade),
+		scenes:   make(map[string]*Scene),
+		effects:  make(map[string]*effect),
+		cues:     make(map[string]*cuePlayer),
+		shows:    make(map[string]*config.Show),
+		masked:   make(map[string]bool),
+	}
+	if cfg.History != nil {
+		s.hist = newHistory(cfg.History.Size)
+	} else {
+		s.hist = newHistory(0)
+	}
+
+	s.cfg.Store(cfg)
+
+	s.wd.threshold = cfg.DMX.WatchdogFailures
+	if s.wd.threshold <= 0 {
+		s.wd.threshold = defaultWatchdogFailures
+	}
+	s.wd.health.Healthy = true
+	metrics.SetBackendHealthy(true)
+
+	if f := cfg.DMX.Failover; f != nil {
+		s.failover = &failover{
+			primary:   name,
+			secondary: f.Backend,
+			after:     time.Duration(f.AfterSec) * time.Second,
+		}
 	}
 
 	// Pre-compute all light structures (ONCE at startup - zero runtime allocation)
-	s.buildLightsCache()
+	s.buildLightsCache(cfg)
+
+	if cfg.Arbitration != nil {
+		s.arb = newArbiter(cfg)
+	}
 
 	return s
 }
 
-// buildLightsCache pre-allocates all light structures at startup
-// This eliminates all allocations in GetLights/GetLight hot paths
-func (s *State) buildLightsCache() {
-	resolved := s.cfg.ResolveLights()
+// config returns the current configuration
+func (s *State) config() *config.Config {
+	return s.cfg.Load()
+}
+
+// buildLightsCache pre-allocates all light structures, limits and curves for cfg
+// This eliminates all allocations in GetLights/GetLight hot paths.
+// Called at startup and by provisioning (caller holds s.mu).
+func (s *State) buildLightsCache(cfg *config.Config) {
+	resolved := cfg.ResolveLights()
+
+	// Fresh structures: references handed out by GetLights stay consistent
+	s.lights = make(map[string]*LightState, len(resolved))
+	s.limits = [512]channelLimit{}
+	s.curves = [512]*[256]uint8{}
+	s.fineOf = [512]int{}
+	s.channelToLight = [512][]channelMapping{}
 
 	// Pre-allocate light keys slice
 	s.lightKeys = make([]string, 0, len(resolved))
@@ -94,12 +269,16 @@ func (s *State) buildLightsCache() {
 		groupSet[light.Group] = struct{}{}
 
 		// Pre-allocate LightState with all channels
+		meta := cfg.LightMeta(light.Group, light.Name)
 		ls := &LightState{
 			Key:      key,
 			Group:    light.Group,
 			Name:     light.Name,
 			Channels: make([]ChannelState, len(light.Channels)),
 			Values:   make(map[string]uint8, len(light.Channels)),
+			Tags:     cfg.LightTags(light.Group, light.Name),
+			Location: meta.Location,
+			Notes:    meta.Notes,
 		}
 
 		for i, ch := range light.Channels {
@@ -108,6 +287,13 @@ func (s *State) buildLightsCache() {
 				Color: ch.Color,
 				Name:  ch.Name,
 				Value: 0, // Will be updated in-place
+				FineCh: ch.FineCh,
+				Min:   ch.Min,
+				Max:   ch.Max,
+				CCT:   ch.CCT,
+			}
+			if ch.Min > 0 || ch.Max > 0 {
+				s.limits[ch.Ch-1] = channelLimit{min: ch.Min, max: ch.Max}
 			}
 			ls.Values[ch.Name] = 0
 
@@ -117,11 +303,26 @@ func (s *State) buildLightsCache() {
 				channelIndex: i,
 			}
 			s.channelToLight[ch.Ch-1] = append(s.channelToLight[ch.Ch-1], mapping)
+			if ch.FineCh > 0 {
+				mapping.fine = true
+				s.channelToLight[ch.FineCh-1] = append(s.channelToLight[ch.FineCh-1], mapping)
+				s.fineOf[ch.Ch-1] = ch.FineCh
+			}
 		}
 
 		s.lights[key] = ls
 	}
 
+	for _, lights := range cfg.Lights {
+		for _, channels := range lights {
+			for _, ch := range channels {
+				if table, err := ch.OutputCurve(); err == nil {
+					s.curves[ch.Ch-1] = table
+				}
+			}
+		}
+	}
+
 	// Pre-allocate values cache (points to same maps as lights - zero copy)
 	s.valuesCache = make(map[string]map[string]uint8, len(s.lights))
 	for key, ls := range s.lights {
@@ -134,6 +335,9 @@ func (s *State) buildLightsCache() {
 		s.groupNames = append(s.groupNames, g)
 	}
 
+	// Masks follow the rebuilt views
+	s.remaskLocked()
+
 	s.logger.Info("Lights cache built",
 		"lights", len(s.lights),
 		"groups", len(s.groupNames))
@@ -143,7 +347,7 @@ func (s *State) buildLightsCache() {
 func (s *State) Subscribe() chan []byte {
 	ch := make(chan []byte, 100)
 	s.subsMu.Lock()
-	s.subs[ch] = struct{}{}
+	s.subs[ch] = &subscriber{}
 	s.subsMu.Unlock()
 	return ch
 }
@@ -178,6 +382,38 @@ func (s *State) broadcastState() {
 	s.subsMu.RLock()
 	defer s.subsMu.RUnlock()
 
+	for ch, sub := range s.subs {
+		if sub.filtered() {
+			s.sendFiltered(ch, sub)
+			continue
+		}
+		select {
+		case ch <- data:
+		default:
+			// Channel full, skip
+		}
+	}
+}
+
+// broadcastEvent sends a one-off event message (not a state update) to all subscribers
+func (s *State) broadcastEvent(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.publish(data)
+}
+
+// Broadcast sends a one-off message (with a "type" field) to all subscribers
+func (s *State) Broadcast(v any) {
+	s.broadcastEvent(v)
+}
+
+// publish sends a pre-marshaled message to all subscribers
+func (s *State) publish(data []byte) {
+	s.subsMu.RLock()
+	defer s.subsMu.RUnlock()
+
 	for ch := range s.subs {
 		select {
 		case ch <- data:
@@ -188,38 +424,87 @@ func (s *State) broadcastState() {
 }
 
 // Enable enables DMX output
+// With a power_up section, groups are then brought on one at a time (see powerup.go).
 func (s *State) Enable() error {
-	if err := s.client.Enable(); err != nil {
+	groups, stop := s.holdForPowerUp()
+	if err := s.backendResult(s.backend().Enable()); err != nil {
+		if stop != nil {
+			s.mu.Lock()
+			s.stopPowerUpLocked()
+			s.mu.Unlock()
+		}
 		return err
 	}
 	s.mu.Lock()
 	s.enabled = true
 	s.mu.Unlock()
 
+	if stop != nil {
+		delay := time.Duration(s.config().DMX.PowerUp.GroupDelayMs) * time.Millisecond
+		s.logger.Info("Staggered power-up", "groups", len(groups), "delay", delay)
+		go s.powerUp(groups, delay, stop)
+	}
 	s.broadcastState()
 	return nil
 }
 
 // Disable disables DMX output
 func (s *State) Disable() error {
-	if err := s.client.Disable(); err != nil {
+	if err := s.backendResult(s.backend().Disable()); err != nil {
 		return err
 	}
 	s.mu.Lock()
 	s.enabled = false
+	staggering := s.powerUpStop != nil
+	s.stopPowerUpLocked()
 	s.mu.Unlock()
 
+	// Channels still held by a cancelled power-up get their values back on the backend
+	if staggering {
+		channels := s.outputChannels()
+		if err := s.backendResult(s.backend().SetChannels(1, channels[:])); err != nil {
+			return err
+		}
+	}
+
 	s.broadcastState()
 	return nil
 }
 
 // Blackout sets all channels to 0
 func (s *State) Blackout() error {
-	if err := s.client.Blackout(); err != nil {
-		return err
+	return s.blackout(true)
+}
+
+// blackout zeroes all channels, stopShow is false for a show's own blackout step
+func (s *State) blackout(stopShow bool) error {
+	// While frozen the blackout is only pending, like any other write
+	// With parked channels the zeroed frame is sent instead, so they never drop out.
+	s.mu.RLock()
+	frozen, parked := s.frozen != nil, s.hasParkedLocked()
+	s.mu.RUnlock()
+	if !frozen && !parked {
+		if err := s.backendResult(s.backend().Blackout()); err != nil {
+			return err
+		}
 	}
 
+	// Pending writes, fades, effects, cue follows, playback, shows and source contributions are superseded by the blackout
+	s.stopAllEffects()
+	s.stopCueFollows()
+	s.stopPlayback()
+	if stopShow {
+		s.stopShow()
+	}
+	s.cancelAllFades()
+	s.queueMu.Lock()
+	s.dirty = [512]bool{}
+	s.queueMu.Unlock()
+
 	s.mu.Lock()
+	if s.arb != nil {
+		s.arb.reset()
+	}
 	// Zero all channels
 	for i := range s.channels {
 		s.channels[i] = 0
@@ -232,33 +517,116 @@ func (s *State) Blackout() error {
 		for k := range ls.Values {
 			ls.Values[k] = 0
 		}
+		ls.IntensityPct = 0
 	}
+	s.reparkLocked()
 	s.mu.Unlock()
 
+	if !frozen && parked {
+		channels := s.outputChannels()
+		if err := s.backendResult(s.backend().SetChannels(1, channels[:])); err != nil {
+			return err
+		}
+	}
+
 	s.broadcastState()
 	return nil
 }
 
+// applyChannelLocked clamps and stores a channel value and updates every light view of it in-place
+// Returns the stored value. Caller holds s.mu (write)
+func (s *State) applyChannelLocked(channel int, value uint8) uint8 {
+	value = s.limits[channel-1].clamp(value)
+	if p := &s.parked[channel-1]; p.on {
+		p.under = value
+		value = p.value
+	}
+	s.channels[channel-1] = value
+	for _, mapping := range s.channelToLight[channel-1] {
+		if ls, ok := s.lights[mapping.lightKey]; ok {
+			cs := &ls.Channels[mapping.channelIndex]
+			if mapping.fine {
+				cs.Value16 = cs.Value16&0xff00 | uint16(value)
+				continue
+			}
+			cs.Value = value
+			if cs.FineCh > 0 {
+				cs.Value16 = uint16(value)<<8 | cs.Value16&0xff
+			}
+			ls.Values[cs.Name] = value
+			ls.updateIntensity()
+		}
+	}
+	return value
+}
+
 // SetChannel sets a single DMX channel (updates pre-allocated structures in-place)
 func (s *State) SetChannel(channel int, value uint8) error {
+	return s.setChannel(SourceLocal, channel, value)
+}
+
+func (s *State) setChannel(source string, channel int, value uint8) error {
 	if channel < 1 || channel > 512 {
 		return nil
 	}
 
+	s.cancelFade(source, channel)
+
 	s.mu.Lock()
-	s.channels[channel-1] = value
+	value = s.applySourceLocked(source, channel, value)
+	s.mu.Unlock()
 
-	// Update pre-allocated light structures in-place (zero allocation)
-	for _, mapping := range s.channelToLight[channel-1] {
-		if ls, ok := s.lights[mapping.lightKey]; ok {
-			ls.Channels[mapping.channelIndex].Value = value
-			ls.Values[ls.Channels[mapping.channelIndex].Name] = value
+	if s.throttle > 0 {
+		s.enqueue(channel)
+	} else if err := s.backendResult(s.backend().SetChannel(channel, s.channelOutput(channel))); err != nil {
+		return err
+	}
+
+	s.broadcastState()
+	return nil
+}
+
+// SetChannels sets consecutive DMX channels from start (1-based) with a single backend write and broadcast
+func (s *State) SetChannels(start int, values []uint8) error {
+	return s.setChannelRange(SourceLocal, start, values)
+}
+
+func (s *State) setChannelRange(source string, start int, values []uint8) error {
+	if start < 1 || start+len(values)-1 > 512 {
+		return fmt.Errorf("channels %d-%d out of range (1-512)", start, start+len(values)-1)
+	}
+	m := make(map[int]uint8, len(values))
+	for i, v := range values {
+		m[start+i] = v
+	}
+	return s.setChannels(source, m)
+}
+
+// setChannels sets several DMX channels at once with a single backend write and broadcast
+func (s *State) setChannels(source string, values map[int]uint8) error {
+	for ch := range values {
+		s.cancelFade(source, ch)
+	}
+
+	s.mu.Lock()
+	for ch, v := range values {
+		if ch >= 1 && ch <= 512 {
+			s.applySourceLocked(source, ch, v)
 		}
 	}
 	s.mu.Unlock()
 
-	if err := s.client.SetChannel(channel, value); err != nil {
-		return err
+	if s.throttle > 0 {
+		for ch := range values {
+			if ch >= 1 && ch <= 512 {
+				s.enqueue(ch)
+			}
+		}
+	} else {
+		channels := s.outputChannels()
+		if err := s.backendResult(s.backend().SetChannels(1, channels[:])); err != nil {
+			return err
+		}
 	}
 
 	s.broadcastState()
@@ -267,33 +635,76 @@ func (s *State) SetChannel(channel int, value uint8) error {
 
 // SetLight sets a light's channel values by group/name
 func (s *State) SetLight(group, name string, values map[string]uint8) error {
+	return s.setLight(SourceLocal, group, name, values)
+}
+
+func (s *State) setLight(source, group, name string, values map[string]uint8) error {
+	// 8-bit values on a 16-bit pair fill both slots (255 -> 65535)
+	return s.writeLight(source, group, name, func(ch *ChannelState) (uint8, uint8, bool) {
+		v, ok := values[ch.Name]
+		return v, v, ok
+	})
+}
+
+// SetLight16 sets a light's channel values at 16-bit resolution (8-bit channels get the high byte)
+func (s *State) SetLight16(group, name string, values map[string]uint16) error {
+	return s.setLight16(SourceLocal, group, name, values)
+}
+
+func (s *State) setLight16(source, group, name string, values map[string]uint16) error {
+	return s.writeLight(source, group, name, func(ch *ChannelState) (uint8, uint8, bool) {
+		v, ok := values[ch.Name]
+		return uint8(v >> 8), uint8(v), ok
+	})
+}
+
+// writeLight applies a light's channel values returned by lookup (coarse, fine, present)
+func (s *State) writeLight(source, group, name string, lookup func(ch *ChannelState) (uint8, uint8, bool)) error {
 	key := config.LightKey(group, name)
 
 	s.mu.Lock()
 	ls, ok := s.lights[key]
 	if !ok {
 		s.mu.Unlock()
-		return nil
+		return fmt.Errorf("%w: %s", ErrLightNotFound, key)
+	}
+
+	s.mu.Unlock()
+
+	// Direct writes override in-flight fades
+	for i := range ls.Channels {
+		if _, _, exists := lookup(&ls.Channels[i]); exists {
+			s.cancelFade(source, ls.Channels[i].Ch)
+		}
 	}
 
-	// Update channels array and pre-allocated light structures in-place
+	// Update channels array and pre-allocated light structures in-place, on the light
+	// as it is now (a reconfigure while fades were cancelled rebuilds the lights)
+	s.mu.Lock()
+	if ls, ok = s.lights[key]; !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrLightNotFound, key)
+	}
 	for i := range ls.Channels {
 		ch := &ls.Channels[i]
-		if val, exists := values[ch.Name]; exists {
-			s.channels[ch.Ch-1] = val
-			ch.Value = val
-			ls.Values[ch.Name] = val
+		if coarse, fine, exists := lookup(ch); exists {
+			s.applySourceLocked(source, ch.Ch, coarse)
+			if ch.FineCh > 0 {
+				s.applySourceLocked(source, ch.FineCh, fine)
+			}
 		}
 	}
 	s.mu.Unlock()
 
-	// Send to DMX client
-	channels := s.cfg.GetLight(group, name)
-	for _, ch := range channels {
-		if val, exists := values[ch.Name]; exists {
-			if err := s.client.SetChannel(ch.Ch, val); err != nil {
-				s.logger.Warn("Failed to set channel", "ch", ch.Ch, "error", err)
-			}
+	// Send to DMX client (coalesced when throttling is enabled)
+	for i := range ls.Channels {
+		ch := &ls.Channels[i]
+		if _, _, exists := lookup(ch); !exists {
+			continue
+		}
+		s.sendChannel(ch.Ch)
+		if ch.FineCh > 0 {
+			s.sendChannel(ch.FineCh)
 		}
 	}
 
@@ -301,32 +712,92 @@ func (s *State) SetLight(group, name string, values map[string]uint8) error {
 	return nil
 }
 
+// sendChannel queues (throttled) or immediately sends a channel's current output
+func (s *State) sendChannel(channel int) {
+	if s.throttle > 0 {
+		s.enqueue(channel)
+	} else if err := s.backendResult(s.backend().SetChannel(channel, s.channelOutput(channel))); err != nil {
+		s.logger.Warn("Failed to set channel", "ch", channel, "error", err)
+	}
+}
+
 // SetGroup sets all lights in a group
 func (s *State) SetGroup(groupName string, values map[string]uint8) error {
-	lightNames := s.cfg.GetGroupLights(groupName)
+	return s.setGroup(SourceLocal, groupName, values)
+}
+
+func (s *State) setGroup(source, groupName string, values map[string]uint8) error {
+	lightNames := s.config().GetGroupLights(groupName)
 	if lightNames == nil {
-		return nil
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
 	}
 
 	for _, name := range lightNames {
-		if err := s.SetLight(groupName, name, values); err != nil {
+		if err := s.setLight(source, groupName, name, values); err != nil {
 			s.logger.Warn("Failed to set light in group", "light", name, "error", err)
 		}
 	}
 	return nil
 }
 
+// channelOutput returns the current output value of a channel (curve applied)
+func (s *State) channelOutput(channel int) uint8 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.output(channel, s.channels[channel-1])
+}
+
+// output maps a logical channel value through its dimming curve (caller holds s.mu)
+// While frozen the latched frame is output instead, channels held by a power-up or masked output 0.
+func (s *State) output(channel int, value uint8) uint8 {
+	if s.frozen != nil {
+		return s.frozen[channel-1]
+	}
+	if s.held[channel-1] || s.maskedCh[channel-1] {
+		return 0
+	}
+	if curve := s.curves[channel-1]; curve != nil {
+		return curve[value]
+	}
+	return value
+}
+
+// outputChannels returns the full output frame (curves applied)
+func (s *State) outputChannels() [512]uint8 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	channels := s.channels
+	for i, v := range channels {
+		channels[i] = s.output(i+1, v)
+	}
+	return channels
+}
+
 // GetStatus returns current DMX status (typed struct, minimal allocation)
 func (s *State) GetStatus() StatusResponse {
 	s.mu.RLock()
 	enabled := s.enabled
+	refreshHz := s.refreshHz
 	s.mu.RUnlock()
 
-	resp := StatusResponse{Enabled: enabled}
+	resp := StatusResponse{Enabled: enabled, RefreshHz: refreshHz, Frozen: s.IsFrozen()}
+
+	// Serve the poller cache when running (no subprocess on the request path)
+	if cached, updated, ok := s.cachedStatus(); ok {
+		resp.FPS = cached.FPS
+		resp.FrameCount = cached.FrameCount
+		resp.Errors = cached.Errors
+		resp.UpdatedAt = updated.UnixMilli()
+		resp.AgeMs = time.Since(updated).Milliseconds()
+		return resp
+	}
 
-	if status, err := s.client.Status(); err == nil && status != nil {
+	status, err := s.backend().Status()
+	if s.backendResult(err) == nil && status != nil {
 		resp.FPS = status.FPS
 		resp.FrameCount = status.FrameCount
+		resp.Errors = status.Errors
 	}
 
 	return resp
@@ -342,6 +813,44 @@ func (s *State) GetLights() map[string]*LightState {
 	return s.lights
 }
 
+// LightQuery selects lights (zero fields match everything)
+type LightQuery struct {
+	Group    string // Only lights of this group
+	Tag      string // Only lights carrying this tag
+	Location string // Only lights whose location contains this (case-insensitive)
+	Offset   int    // Matching lights skipped, in key order
+	Limit    int    // Lights returned (0 = all)
+}
+
+// Lights returns the lights matching q and the number of matches before offset/limit
+// The zero query returns the pre-allocated map like GetLights.
+func (s *State) Lights(q LightQuery) (map[string]*LightState, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if q == (LightQuery{}) {
+		return s.lights, len(s.lights)
+	}
+	keys := make([]string, 0, len(s.lights))
+	for key, ls := range s.lights {
+		if (q.Group == "" || ls.Group == q.Group) && (q.Tag == "" || slices.Contains(ls.Tags, q.Tag)) &&
+			(q.Location == "" || strings.Contains(strings.ToLower(ls.Location), strings.ToLower(q.Location))) {
+			keys = append(keys, key)
+		}
+	}
+	total := len(keys)
+	slices.Sort(keys)
+	keys = keys[min(q.Offset, total):]
+	if q.Limit > 0 && q.Limit < len(keys) {
+		keys = keys[:q.Limit]
+	}
+	result := make(map[string]*LightState, len(keys))
+	for _, key := range keys {
+		result[key] = s.lights[key]
+	}
+	return result, total
+}
+
 // GetLight returns a single light state (returns reference - ZERO allocation)
 func (s *State) GetLight(group, name string) *LightState {
 	key := config.LightKey(group, name)
@@ -354,6 +863,8 @@ func (s *State) GetLight(group, name string) *LightState {
 
 // GetLightKeys returns ordered list of light keys (pre-allocated)
 func (s *State) GetLightKeys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.lightKeys
 }
 
@@ -364,9 +875,44 @@ func (s *State) GetChannels() [512]uint8 {
 	return s.channels
 }
 
+// GetValues returns a copy of all light values (light key -> channel name -> value)
+func (s *State) GetValues() map[string]map[string]uint8 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make(map[string]map[string]uint8, len(s.valuesCache))
+	for key, v := range s.valuesCache {
+		values[key] = maps.Clone(v)
+	}
+	return values
+}
+
+// ChannelMap returns the address map: patch, value and output of all 512 channels
+func (s *State) ChannelMap() []ChannelInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]ChannelInfo, 512)
+	for i := range result {
+		info := ChannelInfo{
+			Ch:     i + 1,
+			Value:  s.channels[i],
+			Output: s.output(i+1, s.channels[i]),
+			Parked: s.parked[i].on,
+		}
+		for _, m := range s.channelToLight[i] {
+			ch := s.lights[m.lightKey].Channels[m.channelIndex]
+			info.Lights = append(info.Lights, ChannelPatch{Light: m.lightKey, Name: ch.Name, Color: ch.Color, Fine: m.fine})
+		}
+		info.Patched = len(info.Lights) > 0
+		result[i] = info
+	}
+	return result
+}
+
 // GetConfig returns the configuration
 func (s *State) GetConfig() *config.Config {
-	return s.cfg
+	return s.config()
 }
 
 // IsEnabled returns whether DMX is enabled
@@ -378,6 +924,8 @@ func (s *State) IsEnabled() bool {
 
 // GetGroups returns all group names (pre-allocated slice)
 func (s *State) GetGroups() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.groupNames
 }
 
@@ -445,7 +993,7 @@ func (s *State) refresh() {
 	s.mu.RLock()
 	for _, ls := range s.lights {
 		for _, ch := range ls.Channels {
-			if err := s.client.SetChannel(ch.Ch, ch.Value); err != nil {
+			if err := s.backendResult(s.backend().SetChannel(ch.Ch, s.output(ch.Ch, ch.Value))); err != nil {
 				s.logger.Warn("Refresh failed", "ch", ch.Ch, "error", err)
 			}
 		}
@@ -454,3 +1002,65 @@ func (s *State) refresh() {
 
 	s.logger.Debug("DMX state refreshed")
 }
+
+// enqueue marks a channel dirty and schedules a flush at the end of the throttle window
+// Repeated writes to the same channel within the window collapse into a single backend write
+func (s *State) enqueue(channel int) {
+	s.queueMu.Lock()
+	s.dirty[channel-1] = true
+	s.scheduleFlushLocked()
+	s.queueMu.Unlock()
+}
+
+// scheduleFlushLocked arms the flush timer if not already pending (caller holds queueMu)
+func (s *State) scheduleFlushLocked() {
+	if !s.flushPending {
+		s.flushPending = true
+		time.AfterFunc(s.throttle, s.Flush)
+	}
+}
+
+// Flush sends all pending channel writes to the DMX client
+// Contiguous dirty channels are sent as one batch (dmx_client set <start> <v1,v2,...>)
+func (s *State) Flush() {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.queueMu.Lock()
+	dirty := s.dirty
+	s.dirty = [512]bool{}
+	s.flushPending = false
+	s.queueMu.Unlock()
+
+	// Latest values win: read current channels rather than queued values
+	channels := s.outputChannels()
+
+	for start := 0; start < len(dirty); {
+		if !dirty[start] {
+			start++
+			continue
+		}
+		end := start
+		for end < len(dirty) && dirty[end] {
+			end++
+		}
+		if err := s.backendResult(s.backend().SetChannels(start+1, channels[start:end])); err != nil {
+			s.logger.Warn("Failed to flush channels", "start", start+1, "count", end-start, "error", err)
+		}
+		start = end
+	}
+}
+
+// pendingWrites returns the number of channels waiting in the write queue
+func (s *State) pendingWrites() int {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	n := 0
+	for _, d := range s.dirty {
+		if d {
+			n++
+		}
+	}
+	return n
+}
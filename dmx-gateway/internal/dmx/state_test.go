@@ -4,8 +4,12 @@
 package dmx
 
 import (
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -215,3 +219,1279 @@ func TestStateGetGroups(t *testing.T) {
 		t.Errorf("expected 1 group, got %d", len(groups))
 	}
 }
+
+func TestStateWriteQueueCoalesces(t *testing.T) {
+	cfg := testConfig()
+	cfg.DMX.ThrottleMs = 50
+	logger := testLogger()
+
+	client, _ := NewClient(config.DMXConfig{Client: "mock", TimeoutMs: 100}, logger)
+	state := NewState(cfg, client, logger)
+
+	// Repeated writes to the same channel within the window collapse into one entry
+	_ = state.SetChannel(1, 10)
+	_ = state.SetChannel(1, 20)
+	_ = state.SetChannel(1, 30)
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"red": 40})
+
+	if n := state.pendingWrites(); n != 2 {
+		t.Errorf("expected 2 pending channels, got %d", n)
+	}
+
+	channels := state.GetChannels()
+	if channels[0] != 30 || channels[1] != 40 {
+		t.Errorf("expected state to reflect latest values, got %d/%d", channels[0], channels[1])
+	}
+
+	state.Flush()
+	if n := state.pendingWrites(); n != 0 {
+		t.Errorf("expected empty queue after flush, got %d", n)
+	}
+}
+
+func TestStateWatchdogMarksBackendUnhealthy(t *testing.T) {
+	cfg := testConfig()
+	cfg.DMX.WatchdogFailures = 3
+	logger := testLogger()
+
+	// Nonexistent client: every backend call fails
+	client, _ := NewClient(config.DMXConfig{Client: "/nonexistent/dmx_client", TimeoutMs: 100}, logger)
+	state := NewState(cfg, client, logger)
+
+	if !state.BackendHealth().Healthy {
+		t.Fatal("backend should start healthy")
+	}
+
+	for i := 0; i < 3; i++ {
+		_ = state.Enable()
+	}
+
+	health := state.BackendHealth()
+	if health.Healthy {
+		t.Error("backend should be unhealthy after reaching failure threshold")
+	}
+	if health.ConsecutiveFailures < 3 {
+		t.Errorf("expected at least 3 consecutive failures, got %d", health.ConsecutiveFailures)
+	}
+	if health.LastError == "" {
+		t.Error("expected last error to be recorded")
+	}
+}
+
+func TestStateFailoverToSecondary(t *testing.T) {
+	cfg := testConfig()
+	cfg.DMX.Failover = &config.FailoverConfig{Backend: BackendMock, AfterSec: 0}
+	logger := testLogger()
+
+	// Failing primary, healthy mock secondary
+	client, _ := NewClient(config.DMXConfig{Client: "/nonexistent/dmx_client", TimeoutMs: 100}, logger)
+	state := NewState(cfg, client, logger)
+	state.RegisterBackend(BackendMock, NewMockClient())
+
+	ch := state.Subscribe()
+	defer state.Unsubscribe(ch)
+
+	_ = state.SetChannel(1, 42)
+
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case data := <-ch:
+			if strings.Contains(string(data), `"event":"failover"`) {
+				if active := state.Backends().Active; active != BackendMock {
+					t.Errorf("expected active backend 'mock', got %q", active)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatal("timeout waiting for failover event")
+		}
+	}
+}
+
+func TestStateStatusPollerCache(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	state.StartStatusPoller(time.Hour)
+	defer state.StopStatusPoller()
+
+	status := state.GetStatus()
+	if status.FPS != 44 {
+		t.Errorf("expected cached fps 44, got %v", status.FPS)
+	}
+	if status.UpdatedAt == 0 {
+		t.Error("expected freshness timestamp on cached status")
+	}
+
+	state.StopStatusPoller()
+	if status := state.GetStatus(); status.UpdatedAt != 0 {
+		t.Error("expected direct query once poller is stopped")
+	}
+}
+
+func TestStateFadeLight(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, mock := NewStateWithMock(cfg, logger)
+
+	_ = state.FadeLight("rack1", "level1", map[string]uint8{"blue": 200}, 100*time.Millisecond)
+	if state.FadesActive() != 1 {
+		t.Fatalf("expected 1 active fade, got %d", state.FadesActive())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for state.FadesActive() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if v := state.GetChannels()[0]; v != 200 {
+		t.Errorf("expected channel 1 to reach 200, got %d", v)
+	}
+	if v := mock.GetChannel(1); v != 200 {
+		t.Errorf("expected backend channel 1 to reach 200, got %d", v)
+	}
+}
+
+func TestStateFadeOverriddenByDirectWrite(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+
+	_ = state.FadeLight("rack1", "level1", map[string]uint8{"blue": 255}, time.Hour)
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 10})
+
+	if state.FadesActive() != 0 {
+		t.Error("direct write should cancel in-flight fade")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if v := state.GetChannels()[0]; v != 10 {
+		t.Errorf("expected channel 1 to stay at 10, got %d", v)
+	}
+}
+
+func TestStateScenesSaveRecallDelete(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	path := filepath.Join(t.TempDir(), "scenes.json")
+
+	state, _ := NewStateWithMock(cfg, logger)
+	if err := state.LoadScenes(path); err != nil {
+		t.Fatalf("LoadScenes on missing file: %v", err)
+	}
+
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 100, "red": 50})
+	if _, err := state.SaveScene("evening", []string{"rack1/level1"}); err != nil {
+		t.Fatalf("SaveScene: %v", err)
+	}
+
+	_ = state.Blackout()
+	if err := state.RecallScene("evening", 0); err != nil {
+		t.Fatalf("RecallScene: %v", err)
+	}
+	if ch := state.GetChannels(); ch[0] != 100 || ch[1] != 50 {
+		t.Errorf("expected channels 1-2 = 100/50 after recall, got %d/%d", ch[0], ch[1])
+	}
+
+	// Persisted scenes survive a restart
+	reloaded, _ := NewStateWithMock(cfg, logger)
+	if err := reloaded.LoadScenes(path); err != nil {
+		t.Fatalf("LoadScenes: %v", err)
+	}
+	if scenes := reloaded.Scenes(); len(scenes) != 1 || scenes[0].Name != "evening" || scenes[0].Index != 1 {
+		t.Errorf("unexpected reloaded scenes: %+v", scenes)
+	}
+
+	if err := state.DeleteScene("evening"); err != nil {
+		t.Fatalf("DeleteScene: %v", err)
+	}
+	if err := state.RecallScene("evening", 0); err != ErrSceneNotFound {
+		t.Errorf("expected ErrSceneNotFound after delete, got %v", err)
+	}
+}
+
+func TestStateScenesWriteFailure(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	dir := filepath.Join(t.TempDir(), "scenes")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	state, _ := NewStateWithMock(cfg, logger)
+	if err := state.LoadScenes(filepath.Join(dir, "scenes.json")); err != nil {
+		t.Fatalf("LoadScenes: %v", err)
+	}
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 100})
+	saved, err := state.SaveScene("evening", nil)
+	if err != nil {
+		t.Fatalf("SaveScene: %v", err)
+	}
+
+	// Failed writes leave the scenes as they were
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.SaveScene("evening", nil); err == nil {
+		t.Error("expected SaveScene to fail")
+	}
+	if _, err := state.SaveScene("night", nil); err == nil {
+		t.Error("expected SaveScene to fail")
+	}
+	if _, err := state.StoreScene("evening", map[string]map[string]uint8{"rack1/level1": {"blue": 1}}, true); err == nil {
+		t.Error("expected StoreScene to fail")
+	}
+	if err := state.DeleteScene("evening"); err == nil {
+		t.Error("expected DeleteScene to fail")
+	}
+	if sc := state.GetScene("evening"); sc != saved {
+		t.Errorf("expected the saved scene kept, got %+v", sc)
+	}
+	if scenes := state.Scenes(); len(scenes) != 1 {
+		t.Errorf("expected one scene, got %+v", scenes)
+	}
+}
+
+func TestStateSceneCrossfadeAbort(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 200})
+	if _, err := state.SaveScene("bright", nil); err != nil {
+		t.Fatalf("SaveScene: %v", err)
+	}
+	_ = state.Blackout()
+
+	if err := state.RecallScene("bright", time.Hour); err != nil {
+		t.Fatalf("RecallScene: %v", err)
+	}
+	xf := state.Crossfade()
+	if !xf.Active || xf.Scene != "bright" || xf.DurationMs != time.Hour.Milliseconds() {
+		t.Errorf("unexpected crossfade status: %+v", xf)
+	}
+
+	if !state.AbortCrossfade() {
+		t.Fatal("expected abort to succeed")
+	}
+	if state.FadesActive() != 0 {
+		t.Errorf("expected no fades after abort, got %d", state.FadesActive())
+	}
+	if state.Crossfade().Active || state.AbortCrossfade() {
+		t.Error("crossfade should be inactive after abort")
+	}
+}
+
+func TestStateRecallPreset(t *testing.T) {
+	cfg := testConfig()
+	cfg.Presets = map[string]map[string]map[string]uint8{
+		"rack1":        {"veg": {"blue": 200, "white": 90}},
+		"rack1/level1": {"veg": {"blue": 100}},
+	}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	if err := state.RecallPreset("rack1", "veg", 0); err != nil {
+		t.Fatalf("RecallPreset: %v", err)
+	}
+	if ch := state.GetChannels(); ch[0] != 100 || ch[2] != 90 {
+		t.Errorf("expected ch1=100 (light preset) ch3=90 (group preset), got %d/%d", ch[0], ch[2])
+	}
+	if err := state.RecallPreset("rack1/level2", "bloom", 0); err != ErrPresetNotFound {
+		t.Errorf("expected ErrPresetNotFound, got %v", err)
+	}
+}
+
+func TestStateArbitrationPriorityAndHTP(t *testing.T) {
+	cfg := testConfig()
+	cfg.Arbitration = &config.ArbitrationConfig{
+		Priorities: map[string]int{"modbus": 10},
+		Channels:   map[int]string{3: "htp"},
+	}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	plc := state.Source(SourceModbus)
+	sched := state.Source(SourceScheduler)
+	web := state.Source(SourceHTTP)
+
+	// LTP among equal priorities, higher priority source wins regardless of order
+	_ = sched.SetChannel(1, 50)
+	_ = web.SetChannel(1, 80)
+	if v := state.GetChannels()[0]; v != 80 {
+		t.Errorf("expected latest write 80, got %d", v)
+	}
+	_ = plc.SetChannel(1, 20)
+	_ = web.SetChannel(1, 90)
+	if v := state.GetChannels()[0]; v != 20 {
+		t.Errorf("expected priority source value 20, got %d", v)
+	}
+
+	// Releasing the priority source hands back to the latest remaining write
+	plc.Release()
+	if v := state.GetChannels()[0]; v != 90 {
+		t.Errorf("expected 90 after release, got %d", v)
+	}
+
+	// HTP channel keeps the highest value
+	_ = web.SetChannel(3, 200)
+	_ = sched.SetChannel(3, 100)
+	if v := state.GetChannels()[2]; v != 200 {
+		t.Errorf("expected HTP value 200, got %d", v)
+	}
+
+	if info := state.Arbitration(); info == nil || info.Sources["http"] != 2 {
+		t.Errorf("unexpected arbitration info: %+v", info)
+	}
+}
+
+func TestStateChannelClamps(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["rack1"]["level1"][0].Min = 20
+	cfg.Lights["rack1"]["level1"][0].Max = 180
+	logger := testLogger()
+
+	state, mock := NewStateWithMock(cfg, logger)
+
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 255})
+	if v := state.GetChannels()[0]; v != 180 {
+		t.Errorf("expected max clamp 180, got %d", v)
+	}
+	if v := mock.GetChannel(1); v != 180 {
+		t.Errorf("expected backend to receive 180, got %d", v)
+	}
+
+	_ = state.SetChannel(1, 5)
+	if v := state.GetChannels()[0]; v != 20 {
+		t.Errorf("expected min clamp 20, got %d", v)
+	}
+
+	_ = state.SetChannel(1, 0)
+	if v := state.GetChannels()[0]; v != 0 {
+		t.Errorf("expected 0 to stay off, got %d", v)
+	}
+}
+
+func TestStateDimmingCurveOnOutputOnly(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["rack1"]["level1"][0].Curve = "gamma2.2"
+	logger := testLogger()
+
+	state, mock := NewStateWithMock(cfg, logger)
+
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 128})
+	if v := state.GetLight("rack1", "level1").Values["blue"]; v != 128 {
+		t.Errorf("expected logical value 128, got %d", v)
+	}
+	if v := mock.GetChannel(1); v != 56 {
+		t.Errorf("expected curve-corrected output 56, got %d", v)
+	}
+}
+
+func TestState16BitPair(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["rack1"]["level2"][0].FineCh = 4
+	logger := testLogger()
+
+	state, mock := NewStateWithMock(cfg, logger)
+
+	_ = state.SetLight16("rack1", "level2", map[string]uint16{"white": 0x1234})
+	if mock.GetChannel(3) != 0x12 || mock.GetChannel(4) != 0x34 {
+		t.Errorf("expected coarse/fine 0x12/0x34, got 0x%02x/0x%02x", mock.GetChannel(3), mock.GetChannel(4))
+	}
+	if v := state.GetLight("rack1", "level2").Channels[0].Value16; v != 0x1234 {
+		t.Errorf("expected value16 0x1234, got 0x%04x", v)
+	}
+
+	// 8-bit writes fill both slots
+	_ = state.SetLight("rack1", "level2", map[string]uint8{"white": 255})
+	if v := state.GetLight("rack1", "level2").Channels[0].Value16; v != 0xffff {
+		t.Errorf("expected value16 0xffff, got 0x%04x", v)
+	}
+
+	_ = state.FadeLight16("rack1", "level2", map[string]uint16{"white": 0x0100}, 50*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for state.FadesActive() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := state.GetLight("rack1", "level2").Channels[0].Value16; v != 0x0100 {
+		t.Errorf("expected faded value16 0x0100, got 0x%04x", v)
+	}
+}
+
+func TestStateApplyStartup(t *testing.T) {
+	cfg := testConfig()
+	cfg.Presets = map[string]map[string]map[string]uint8{"rack1": {"veg": {"blue": 200}}}
+	cfg.Startup = &config.StartupConfig{
+		Preset: map[string]string{"rack1": "veg"},
+		Set:    map[string]map[string]uint8{"rack1/level2": {"white": 40}},
+	}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	state.ApplyStartup()
+
+	if ch := state.GetChannels(); ch[0] != 200 || ch[2] != 40 {
+		t.Errorf("expected ch1=200 ch3=40 after startup, got %d/%d", ch[0], ch[2])
+	}
+}
+
+func TestStateSnapshotRestore(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, _ := NewStateWithMock(cfg, logger)
+	_ = state.Enable()
+	_ = state.SetChannel(2, 77)
+	if err := state.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+
+	restored, mock := NewStateWithMock(cfg, logger)
+	ok, err := restored.RestoreSnapshot(path)
+	if err != nil || !ok {
+		t.Fatalf("RestoreSnapshot: ok=%v err=%v", ok, err)
+	}
+	if !restored.IsEnabled() {
+		t.Error("expected output enabled after restore")
+	}
+	if v := restored.GetChannels()[1]; v != 77 {
+		t.Errorf("expected channel 2 = 77, got %d", v)
+	}
+	if v := mock.GetChannel(2); v != 77 {
+		t.Errorf("expected backend channel 2 = 77, got %d", v)
+	}
+
+	if ok, err := restored.RestoreSnapshot(filepath.Join(t.TempDir(), "missing.json")); ok || err != nil {
+		t.Errorf("expected missing snapshot to be skipped, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestStateUndo(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	_ = state.SetChannel(1, 10)
+	state.Snapshot()
+	_ = state.SetChannel(1, 20)
+	state.Snapshot()
+	_ = state.SetChannel(1, 30)
+
+	if n, err := state.Undo(1); err != nil || n != 1 {
+		t.Fatalf("Undo(1): n=%d err=%v", n, err)
+	}
+	if v := state.GetChannels()[0]; v != 20 {
+		t.Errorf("expected 20 after one undo, got %d", v)
+	}
+	if n, _ := state.Undo(5); n != 1 {
+		t.Errorf("expected undo to be capped at history depth, got %d", n)
+	}
+	if v := state.GetChannels()[0]; v != 10 {
+		t.Errorf("expected 10 after second undo, got %d", v)
+	}
+	if _, err := state.Undo(1); err != ErrNothingToUndo {
+		t.Errorf("expected ErrNothingToUndo, got %v", err)
+	}
+
+	// Auto captures in quick succession collapse into one step
+	state.CaptureUndo()
+	state.CaptureUndo()
+	if d := state.UndoDepth(); d != 1 {
+		t.Errorf("expected 1 auto capture, got %d", d)
+	}
+}
+
+func TestSourceUndo(t *testing.T) {
+	cfg := testConfig()
+	cfg.Arbitration = &config.ArbitrationConfig{}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	_ = state.SetChannel(1, 10)
+	state.Snapshot()
+	_ = state.SetChannel(1, 20)
+
+	ws := state.Source(SourceWS).WithRequest("job-1")
+	if _, err := ws.SaveScene("warm", nil); err != nil {
+		t.Fatalf("SaveScene: %v", err)
+	}
+	if n, err := ws.Undo(1); err != nil || n != 1 {
+		t.Fatalf("Undo(1): n=%d err=%v", n, err)
+	}
+	if v := state.GetChannels()[0]; v != 10 {
+		t.Errorf("expected 10 after undo, got %d", v)
+	}
+	if info := state.Arbitration(); info.Sources[SourceWS] == 0 {
+		t.Errorf("expected the restored frame written as ws, got %v", info.Sources)
+	}
+	got := state.History(HistoryQuery{Source: SourceWS})
+	if len(got) != 2 || got[0].Action != "undo" || got[0].RequestID != "job-1" || got[1].Action != "scene_save" || got[1].Name != "warm" {
+		t.Errorf("expected the scene save and undo recorded, got %+v", got)
+	}
+}
+
+func TestSourceEffect(t *testing.T) {
+	cfg := testConfig()
+	cfg.Arbitration = &config.ArbitrationConfig{}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	defer state.Blackout()
+	if err := state.Source(SourceMQTT).WithRequest("job-1").StartEffect(EffectParams{Type: "strobe", Target: "rack1", RateHz: 5}); err != nil {
+		t.Fatalf("StartEffect: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Frames written as the source that started the effect
+	if info := state.Arbitration(); info.Sources[SourceMQTT] == 0 || info.Sources[SourceEffect] != 0 {
+		t.Errorf("expected mqtt contributions only, got %v", info.Sources)
+	}
+	got := state.History(HistoryQuery{Source: SourceMQTT})
+	if len(got) != 1 || got[0].Action != "effect" || got[0].Name != "strobe" || got[0].RequestID != "job-1" {
+		t.Errorf("expected the effect recorded, got %+v", got)
+	}
+}
+
+func TestStateEffectStopsOnBlackout(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	if err := state.StartEffect(EffectParams{Type: "bogus", Target: "rack1"}); err == nil {
+		t.Error("expected error for unknown effect type")
+	}
+	if err := state.StartEffect(EffectParams{Type: "strobe", Target: "rack1", RateHz: 5}); err != nil {
+		t.Fatalf("StartEffect: %v", err)
+	}
+	// Replacing the effect on the same target keeps a single entry
+	if err := state.StartEffect(EffectParams{Type: "sine", Target: "rack1", RateHz: 5}); err != nil {
+		t.Fatalf("StartEffect: %v", err)
+	}
+	if effects := state.Effects(); len(effects) != 1 || effects[0].Type != "sine" {
+		t.Fatalf("expected one sine effect, got %+v", effects)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	state.Blackout()
+	if len(state.Effects()) != 0 {
+		t.Error("expected blackout to stop effects")
+	}
+	time.Sleep(100 * time.Millisecond)
+	channels := state.GetChannels()
+	for i, v := range channels[:3] {
+		if v != 0 {
+			t.Errorf("channel %d: expected 0 after blackout, got %d", i+1, v)
+		}
+	}
+	if err := state.StopEffect("rack1"); err != ErrEffectNotFound {
+		t.Errorf("expected ErrEffectNotFound, got %v", err)
+	}
+}
+
+func TestStateCueList(t *testing.T) {
+	cfg := testConfig()
+	cfg.Cues = map[string][]config.Cue{
+		"show": {
+			{Scene: "one"},
+			{Scene: "two", FollowMs: 50},
+			{Scene: "three"},
+		},
+	}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	for name, v := range map[string]uint8{"one": 10, "two": 20, "three": 30} {
+		_ = state.SetChannel(1, v)
+		if _, err := state.SaveScene(name, nil); err != nil {
+			t.Fatalf("SaveScene: %v", err)
+		}
+	}
+
+	if n, err := state.CueGo("show"); err != nil || n != 1 {
+		t.Fatalf("CueGo: n=%d err=%v", n, err)
+	}
+	if v := state.GetChannels()[0]; v != 10 {
+		t.Errorf("expected cue 1 value 10, got %d", v)
+	}
+	if _, err := state.CueBack("show"); err != ErrCueListEnd {
+		t.Errorf("expected ErrCueListEnd before first cue, got %v", err)
+	}
+
+	// Cue 2 follows into cue 3
+	if n, _ := state.CueGo("show"); n != 2 {
+		t.Fatalf("expected cue 2, got %d", n)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if lists := state.CueLists(); lists[0].Current != 3 {
+		t.Errorf("expected follow to cue 3, got %d", lists[0].Current)
+	}
+	if v := state.GetChannels()[0]; v != 30 {
+		t.Errorf("expected cue 3 value 30, got %d", v)
+	}
+
+	if err := state.CueGoto("show", 1); err != nil {
+		t.Fatalf("CueGoto: %v", err)
+	}
+	if v := state.GetChannels()[0]; v != 10 {
+		t.Errorf("expected 10 after goto 1, got %d", v)
+	}
+	if err := state.CueGoto("show", 4); err == nil {
+		t.Error("expected error for out of range cue")
+	}
+	if _, err := state.CueGo("missing"); err != ErrCueListNotFound {
+		t.Errorf("expected ErrCueListNotFound, got %v", err)
+	}
+}
+
+func TestStateRecordAndPlay(t *testing.T) {
+	cfg := testConfig()
+	cfg.Recorder = &config.RecorderConfig{Dir: t.TempDir()}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	if err := state.StartRecording("../escape"); err == nil {
+		t.Error("expected error for invalid recording name")
+	}
+	if err := state.StartRecording("demo"); err != nil {
+		t.Fatalf("StartRecording: %v", err)
+	}
+	_ = state.SetChannel(1, 100)
+	time.Sleep(60 * time.Millisecond)
+	_ = state.SetChannel(2, 50)
+	time.Sleep(60 * time.Millisecond)
+	frames, err := state.StopRecording()
+	if err != nil {
+		t.Fatalf("StopRecording: %v", err)
+	}
+	if frames < 4 {
+		t.Errorf("expected initial, 2 change and end frames, got %d", frames)
+	}
+
+	_ = state.Blackout()
+	if err := state.Play("demo", false); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	channels := state.GetChannels()
+	if channels[0] != 100 || channels[1] != 50 {
+		t.Errorf("expected replayed values 100/50, got %d/%d", channels[0], channels[1])
+	}
+	if status, _ := state.Recorder(); status.Playing != "" || len(status.Recordings) != 1 {
+		t.Errorf("unexpected recorder status: %+v", status)
+	}
+
+	// Blackout stops a looping playback
+	if err := state.Play("demo", true); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	_ = state.Blackout()
+	if err := state.StopPlayback(); err != ErrNotPlaying {
+		t.Errorf("expected ErrNotPlaying after blackout, got %v", err)
+	}
+}
+
+func TestStateTimelineShow(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	if err := state.StartShow("missing"); err != ErrShowNotFound {
+		t.Errorf("expected ErrShowNotFound, got %v", err)
+	}
+	if err := state.LoadShow("bad", &config.Show{Steps: []config.ShowStep{{Set: map[string]map[string]uint8{"rack9": {"blue": 1}}}}}); err == nil {
+		t.Error("expected error for unknown target")
+	}
+
+	show := &config.Show{Steps: []config.ShowStep{
+		{AtMs: 0, Set: map[string]map[string]uint8{"rack1/level1": {"blue": 100}}},
+		{AtMs: 50, Blackout: true},
+		{AtMs: 100, Set: map[string]map[string]uint8{"rack1/level2": {"white": 30}}},
+	}}
+	if err := state.LoadShow("demo", show); err != nil {
+		t.Fatalf("LoadShow: %v", err)
+	}
+
+	events := state.Subscribe()
+	defer state.Unsubscribe(events)
+	if err := state.StartShow("demo"); err != nil {
+		t.Fatalf("StartShow: %v", err)
+	}
+	time.Sleep(25 * time.Millisecond)
+	if v := state.GetChannels()[0]; v != 100 {
+		t.Errorf("expected step 1 value 100, got %d", v)
+	}
+
+	// The show's own blackout step does not stop it
+	time.Sleep(150 * time.Millisecond)
+	channels := state.GetChannels()
+	if channels[0] != 0 || channels[2] != 30 {
+		t.Errorf("expected 0/30 after blackout and step 3, got %d/%d", channels[0], channels[2])
+	}
+	if status := state.Shows(); status.Running != "" || len(status.Shows) != 1 {
+		t.Errorf("expected finished show, got %+v", status)
+	}
+
+	finished := false
+	for len(events) > 0 {
+		if strings.Contains(string(<-events), `"event":"finished"`) {
+			finished = true
+		}
+	}
+	if !finished {
+		t.Error("expected a finished show event")
+	}
+}
+
+func TestStateSetCCT(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["office"] = map[string][]config.Channel{
+		"desk": {
+			{Ch: 10, Color: "orange", Name: "warm", CCT: 2700},
+			{Ch: 11, Color: "white", Name: "cool", CCT: 6500},
+		},
+	}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	if err := state.SetCCT("rack1", 4000, 255, 0); err != ErrNoTunableWhite {
+		t.Errorf("expected ErrNoTunableWhite, got %v", err)
+	}
+
+	if err := state.SetCCT("office/desk", 6500, 200, 0); err != nil {
+		t.Fatalf("SetCCT: %v", err)
+	}
+	channels := state.GetChannels()
+	if channels[9] != 0 || channels[10] != 200 {
+		t.Errorf("expected all cool at 6500K, got warm=%d cool=%d", channels[9], channels[10])
+	}
+
+	// 4000K is 56% of the way from 2700K to 6500K in mireds, brightness kept
+	if err := state.SetCCT("office", 4000, -1, 0); err != nil {
+		t.Fatalf("SetCCT: %v", err)
+	}
+	channels = state.GetChannels()
+	if channels[9] != 89 || channels[10] != 111 {
+		t.Errorf("expected warm=89 cool=111 at 4000K, got warm=%d cool=%d", channels[9], channels[10])
+	}
+}
+
+func TestStateVirtualLight(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["all"] = map[string][]config.Channel{
+		"blue": {
+			{Ch: 1, Color: "blue", Alias: true},
+			{Ch: 3, Color: "blue", Alias: true},
+		},
+	}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	if err := state.SetLight("all", "blue", map[string]uint8{"blue": 90}); err != nil {
+		t.Fatalf("SetLight: %v", err)
+	}
+	channels := state.GetChannels()
+	if channels[0] != 90 || channels[2] != 90 {
+		t.Errorf("expected both aliased channels at 90, got %d/%d", channels[0], channels[2])
+	}
+	if v := state.GetLight("rack1", "level1").Values["blue"]; v != 90 {
+		t.Errorf("expected owner view to follow the alias, got %d", v)
+	}
+
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 40})
+	if v := state.GetLight("all", "blue").Channels[0].Value; v != 40 {
+		t.Errorf("expected alias view to follow the owner, got %d", v)
+	}
+}
+
+func TestStateProvisioning(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	_ = state.SetChannel(5, 70)
+
+	if err := state.AddLight("rack2", "level1", []config.Channel{{Ch: 5, Color: "red"}, {Ch: 6, Color: "blue"}}); err != nil {
+		t.Fatalf("AddLight: %v", err)
+	}
+	ls := state.GetLight("rack2", "level1")
+	if ls == nil || ls.Values["red"] != 70 {
+		t.Fatalf("expected new light to pick up channel 5 at 70, got %+v", ls)
+	}
+	if len(state.GetGroups()) != 2 || len(state.GetLightKeys()) != 3 {
+		t.Errorf("expected 2 groups and 3 lights, got %v / %v", state.GetGroups(), state.GetLightKeys())
+	}
+
+	if err := state.AddLight("rack2", "level2", []config.Channel{{Ch: 2, Color: "red"}}); err == nil {
+		t.Error("expected error for channel already used by rack1/level1")
+	}
+	if err := state.AddLight("rack2", "level1", []config.Channel{{Ch: 7, Color: "red"}}); err != ErrLightExists {
+		t.Errorf("expected ErrLightExists, got %v", err)
+	}
+
+	_ = state.SetLight("rack1", "level2", map[string]uint8{"white": 200})
+	if err := state.RemoveLight("rack1", "level2"); err != nil {
+		t.Fatalf("RemoveLight: %v", err)
+	}
+	if state.GetLight("rack1", "level2") != nil {
+		t.Error("expected light removed")
+	}
+	if ch := state.GetChannels(); ch[2] != 0 {
+		t.Errorf("expected released channel 3 zeroed, got %d", ch[2])
+	}
+
+	if err := state.RemoveGroup("rack2"); err != nil {
+		t.Fatalf("RemoveGroup: %v", err)
+	}
+	if err := state.RemoveGroup("rack2"); err != ErrGroupNotFound {
+		t.Errorf("expected ErrGroupNotFound, got %v", err)
+	}
+	if state.GetConfig().Lights["rack2"] != nil || cfg.Lights["rack1"]["level2"] == nil {
+		t.Error("expected changes on a copy of the config, not the original")
+	}
+}
+
+func TestStateApplyConfig(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 50, "red": 100})
+	_ = state.SetLight("rack1", "level2", map[string]uint8{"white": 200})
+
+	// level1 repatched to 10-11, level2 removed, channel 40 parked
+	next := testConfig()
+	next.Lights["rack1"] = map[string][]config.Channel{
+		"level1": {{Ch: 10, Color: "blue"}, {Ch: 11, Color: "red"}},
+	}
+	next.Park = map[int]uint8{40: 255}
+	if err := state.ApplyConfig(next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+
+	ls := state.GetLight("rack1", "level1")
+	if ls == nil || ls.Values["blue"] != 50 || ls.Values["red"] != 100 {
+		t.Fatalf("expected repatched light to keep its values, got %+v", ls)
+	}
+	ch := state.GetChannels()
+	if ch[0] != 0 || ch[1] != 0 || ch[2] != 0 {
+		t.Errorf("expected released channels 1-3 zeroed, got %v", ch[:3])
+	}
+	if state.GetLight("rack1", "level2") != nil {
+		t.Error("expected removed light gone")
+	}
+	if state.Parked()[40] != 255 {
+		t.Errorf("expected channel 40 parked, got %v", state.Parked())
+	}
+
+	next = testConfig()
+	if err := state.ApplyConfig(next); err != nil {
+		t.Fatalf("ApplyConfig: %v", err)
+	}
+	if len(state.Parked()) != 0 {
+		t.Errorf("expected channel 40 unparked, got %v", state.Parked())
+	}
+}
+
+func TestStateSubscriptionFilter(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["rack2"] = map[string][]config.Channel{"level1": {{Ch: 10, Color: "red"}}}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	ch := state.Subscribe()
+	defer state.Unsubscribe(ch)
+
+	if err := state.SetFilter(ch, []string{"rack9"}); err == nil {
+		t.Error("expected error for unknown target")
+	}
+	if err := state.SetFilter(ch, []string{"rack1/level2"}); err != nil {
+		t.Fatalf("SetFilter: %v", err)
+	}
+	var update StateUpdate
+	select {
+	case data := <-ch:
+		if err := json.Unmarshal(data, &update); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatal("expected current state right after subscribing")
+	}
+	if len(update.Values) != 1 || update.Values["rack1/level2"] == nil {
+		t.Errorf("expected only rack1/level2, got %v", update.Values)
+	}
+
+	// Unrelated changes are not sent
+	_ = state.SetLight("rack2", "level1", map[string]uint8{"red": 50})
+	select {
+	case data := <-ch:
+		t.Errorf("unexpected update for an unrelated light: %s", data)
+	default:
+	}
+
+	_ = state.SetLight("rack1", "level2", map[string]uint8{"white": 50})
+	select {
+	case data := <-ch:
+		_ = json.Unmarshal(data, &update)
+		if update.Values["rack1/level2"]["white"] != 50 {
+			t.Errorf("expected white=50, got %v", update.Values)
+		}
+	default:
+		t.Error("expected update for a subscribed light")
+	}
+}
+
+func TestStateFreeze(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, mock := NewStateWithMock(cfg, logger)
+	_ = state.SetChannel(1, 100)
+	state.Freeze()
+
+	_ = state.SetChannel(1, 200)
+	_ = state.SetLight("rack1", "level2", map[string]uint8{"white": 50})
+	_ = state.Blackout()
+	if mock.GetChannel(1) != 100 || mock.GetChannel(3) != 0 {
+		t.Errorf("expected output held at the frozen frame, got ch1=%d ch3=%d", mock.GetChannel(1), mock.GetChannel(3))
+	}
+	_ = state.SetChannel(3, 60)
+	if ch := state.GetChannels(); ch[0] != 0 || ch[2] != 60 {
+		t.Errorf("expected pending state ch1=0 ch3=60, got %d/%d", ch[0], ch[2])
+	}
+	if !state.GetStatus().Frozen {
+		t.Error("expected frozen in status")
+	}
+
+	if err := state.Unfreeze(); err != nil {
+		t.Fatalf("Unfreeze: %v", err)
+	}
+	if mock.GetChannel(1) != 0 || mock.GetChannel(3) != 60 {
+		t.Errorf("expected pending frame sent on unfreeze, got ch1=%d ch3=%d", mock.GetChannel(1), mock.GetChannel(3))
+	}
+}
+
+func TestStatePark(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, mock := NewStateWithMock(cfg, logger)
+	_ = state.SetChannel(1, 30)
+	if err := state.Park(map[int]uint8{1: 200, 40: 128}); err != nil {
+		t.Fatalf("Park: %v", err)
+	}
+	if err := state.Park(map[int]uint8{600: 1}); err == nil {
+		t.Error("expected error for channel out of range")
+	}
+
+	_ = state.SetGroup("rack1", map[string]uint8{"blue": 90, "red": 80})
+	_ = state.Blackout()
+	if ch := state.GetChannels(); ch[0] != 200 || ch[39] != 128 || ch[1] != 0 {
+		t.Errorf("expected parked 200/128 and ch2 blacked out, got %d/%d/%d", ch[0], ch[39], ch[1])
+	}
+	if mock.GetChannel(1) != 200 || mock.GetChannel(40) != 128 {
+		t.Errorf("expected parked values on output after blackout, got %d/%d", mock.GetChannel(1), mock.GetChannel(40))
+	}
+	if v := state.GetLight("rack1", "level1").Values["blue"]; v != 200 {
+		t.Errorf("expected light view to show the parked value, got %d", v)
+	}
+
+	_ = state.SetChannel(1, 70)
+	state.Unpark([]int{1})
+	if ch := state.GetChannels(); ch[0] != 70 || ch[39] != 128 {
+		t.Errorf("expected ch1 back to its written value 70 and ch40 still parked, got %d/%d", ch[0], ch[39])
+	}
+	if parked := state.Parked(); len(parked) != 1 || parked[40] != 128 {
+		t.Errorf("expected only ch40 parked, got %v", parked)
+	}
+}
+
+func TestStateLocate(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 40, "red": 10})
+	if err := state.Locate("rack1/level1", 150*time.Millisecond); err != nil {
+		t.Fatalf("Locate: %v", err)
+	}
+	if len(state.Effects()) != 1 {
+		t.Fatal("expected locate to run as an effect")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if len(state.Effects()) != 0 {
+		t.Error("expected locate to end")
+	}
+	if ch := state.GetChannels(); ch[0] != 40 || ch[1] != 10 {
+		t.Errorf("expected previous values restored, got %d/%d", ch[0], ch[1])
+	}
+	if err := state.Locate("rack9", 0); err == nil {
+		t.Error("expected error for unknown target")
+	}
+
+	// Through a source handle: recorded, traced
+	if err := state.Source(SourceWS).WithRequest("job-2").Locate("rack1", 50*time.Millisecond); err != nil {
+		t.Fatalf("Locate: %v", err)
+	}
+	got := state.History(HistoryQuery{Source: SourceWS})
+	if len(got) != 1 || got[0].Action != "locate" || got[0].Target != "rack1" || got[0].RequestID != "job-2" {
+		t.Errorf("expected the locate recorded, got %+v", got)
+	}
+	time.Sleep(150 * time.Millisecond)
+}
+
+func TestStatePowerUp(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["rack2"] = map[string][]config.Channel{"level1": {{Ch: 10, Color: "white"}}}
+	cfg.DMX.PowerUp = &config.PowerUpConfig{GroupDelayMs: 50, Order: []string{"rack2"}}
+	logger := testLogger()
+
+	state, mock := NewStateWithMock(cfg, logger)
+	_ = state.SetChannel(1, 100)
+	_ = state.SetChannel(10, 80)
+	if err := state.Enable(); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+
+	// rack2 is listed first and comes up at once, rack1 is held dark for a step
+	time.Sleep(10 * time.Millisecond)
+	if mock.GetChannel(10) != 80 || mock.GetChannel(1) != 0 {
+		t.Errorf("expected rack2 on and rack1 held, got ch10=%d ch1=%d", mock.GetChannel(10), mock.GetChannel(1))
+	}
+	_ = state.SetChannel(1, 120)
+	if ch := state.GetChannels(); ch[0] != 120 {
+		t.Errorf("expected writes applied while held, got %d", ch[0])
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if mock.GetChannel(1) != 120 {
+		t.Errorf("expected rack1 released with its pending value, got %d", mock.GetChannel(1))
+	}
+
+	// Disabling mid power-up cancels it and restores held values on the backend
+	_ = state.Disable()
+	if err := state.Enable(); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if err := state.Disable(); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if mock.GetChannel(1) != 120 || mock.GetChannel(10) != 80 {
+		t.Errorf("expected frame restored after cancelled power-up, got ch1=%d ch10=%d", mock.GetChannel(1), mock.GetChannel(10))
+	}
+}
+
+func TestStateMask(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+
+	state, mock := NewStateWithMock(cfg, logger)
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 100, "red": 50})
+	if err := state.Mask("rack1", "level1", true); err != nil {
+		t.Fatalf("Mask: %v", err)
+	}
+	if err := state.Mask("rack1", "nope", true); !errors.Is(err, ErrLightNotFound) {
+		t.Errorf("expected ErrLightNotFound, got %v", err)
+	}
+	if mock.GetChannel(1) != 0 || mock.GetChannel(2) != 0 {
+		t.Errorf("expected masked channels at 0 on output, got %d/%d", mock.GetChannel(1), mock.GetChannel(2))
+	}
+
+	// Writes are accepted but not sent
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 200})
+	_ = state.SetLight("rack1", "level2", map[string]uint8{"white": 70})
+	if light := state.GetLight("rack1", "level1"); !light.Masked || light.Values["blue"] != 200 {
+		t.Errorf("expected masked light tracking blue=200, got %+v", light)
+	}
+	if mock.GetChannel(1) != 0 || mock.GetChannel(3) != 70 {
+		t.Errorf("expected ch1 masked and ch3=70, got %d/%d", mock.GetChannel(1), mock.GetChannel(3))
+	}
+	if masked := state.Masked(); len(masked) != 1 || masked[0] != "rack1/level1" {
+		t.Errorf("expected [rack1/level1], got %v", masked)
+	}
+
+	// Masks survive provisioning
+	if err := state.AddLight("rack1", "level3", []config.Channel{{Ch: 5, Color: "red"}}); err != nil {
+		t.Fatalf("AddLight: %v", err)
+	}
+	if !state.GetLight("rack1", "level1").Masked {
+		t.Error("expected mask kept after provisioning")
+	}
+
+	if err := state.Mask("rack1", "level1", false); err != nil {
+		t.Fatalf("Unmask: %v", err)
+	}
+	if state.GetLight("rack1", "level1").Masked || mock.GetChannel(1) != 200 || mock.GetChannel(2) != 50 {
+		t.Errorf("expected values sent back in service, got %d/%d", mock.GetChannel(1), mock.GetChannel(2))
+	}
+}
+
+func TestStateHistory(t *testing.T) {
+	cfg := testConfig()
+	cfg.History = &config.HistoryConfig{Size: 3}
+	logger := testLogger()
+
+	state, _ := NewStateWithMock(cfg, logger)
+	start := time.Now()
+	_ = state.Source(SourceHTTP).SetLight("rack1", "level1", map[string]uint8{"blue": 10})
+	_ = state.Source(SourceMQTT).SetGroup("rack1", map[string]uint8{"white": 20})
+	_ = state.Source(SourceModbus).SetChannel(40, 30)
+	_ = state.Source(SourceScheduler).Blackout()
+	_ = state.Source(SourceHTTP).RecallScene("nope", 0) // Failed, not recorded
+	_ = state.SetChannel(5, 1)                          // Internal, not recorded
+
+	all := state.History(HistoryQuery{})
+	if len(all) != 3 {
+		t.Fatalf("expected ring capped at 3, got %d", len(all))
+	}
+	if all[0].Action != "blackout" || all[0].Source != SourceScheduler || all[2].Target != "rack1" {
+		t.Errorf("expected most recent first, got %+v", all)
+	}
+	if all[1].Channel != 40 || all[1].Values != uint8(30) || all[0].Time.Before(start) {
+		t.Errorf("expected channel write with value and time, got %+v", all[1])
+	}
+
+	if got := state.History(HistoryQuery{Source: SourceMQTT}); len(got) != 1 || got[0].Target != "rack1" {
+		t.Errorf("expected the mqtt group write, got %+v", got)
+	}
+	// A light matches writes to its group and global actions
+	if got := state.History(HistoryQuery{Target: "rack1/level2", Limit: 5}); len(got) != 3 {
+		t.Errorf("expected group write, channel write and blackout, got %+v", got)
+	}
+	if got := state.History(HistoryQuery{Limit: 1}); len(got) != 1 {
+		t.Errorf("expected limit applied, got %d", len(got))
+	}
+	if got := state.History(HistoryQuery{Since: time.Now().Add(time.Hour)}); len(got) != 0 {
+		t.Errorf("expected nothing in the future, got %d", len(got))
+	}
+}
+
+func TestStatePercent(t *testing.T) {
+	if v, err := PercentValue(50); err != nil || v != 128 {
+		t.Errorf("expected 50%% = 128, got %d (%v)", v, err)
+	}
+	if _, err := PercentValues(map[string]float64{"blue": 101}); err == nil {
+		t.Error("expected error for percent out of range")
+	}
+
+	state, _ := NewStateWithMock(testConfig(), testLogger())
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 51, "red": 255})
+	if pct := state.GetLight("rack1", "level1").IntensityPct; pct != 100 {
+		t.Errorf("expected intensity from brightest channel 100, got %v", pct)
+	}
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"red": 0})
+	if pct := state.GetLight("rack1", "level1").IntensityPct; pct != 20 {
+		t.Errorf("expected intensity 20, got %v", pct)
+	}
+	_ = state.Blackout()
+	if pct := state.GetLight("rack1", "level1").IntensityPct; pct != 0 {
+		t.Errorf("expected intensity 0 after blackout, got %v", pct)
+	}
+}
+
+func TestStateSetMulti(t *testing.T) {
+	state, _ := NewStateWithMock(testConfig(), testLogger())
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 1, "red": 2})
+	src := state.Source(SourceHTTP)
+
+	// One invalid item: nothing applies
+	for _, items := range [][]TargetValues{
+		{{Target: "rack1/level1", Values: map[string]uint8{"blue": 100}}, {Target: "rack1/level2", Values: map[string]uint8{"uv": 5}}},
+		{{Target: "rack1/level1", Values: map[string]uint8{"blue": 100}}, {Target: "rack9", Values: map[string]uint8{"white": 5}}},
+		{{Target: "rack1/level1", ValuesPct: map[string]float64{"blue": 150}}},
+		nil,
+	} {
+		if err := src.SetMulti(items); err == nil {
+			t.Errorf("expected error for %+v", items)
+		}
+	}
+	if ch := state.GetChannels(); ch[0] != 1 || ch[1] != 2 || ch[2] != 0 {
+		t.Errorf("expected no change after rejected sets, got %v", ch[:3])
+	}
+
+	// Valid look: one broadcast, later targets win on shared channels
+	updates := state.Subscribe()
+	defer state.Unsubscribe(updates)
+	err := src.SetMulti([]TargetValues{
+		{Target: "rack1", Values: map[string]uint8{"white": 10, "blue": 20}},
+		{Target: "rack1/level1", Values: map[string]uint8{"blue": 30}, ValuesPct: map[string]float64{"red": 100}},
+	})
+	if err != nil {
+		t.Fatalf("SetMulti: %v", err)
+	}
+	if ch := state.GetChannels(); ch[0] != 30 || ch[1] != 255 || ch[2] != 10 {
+		t.Errorf("expected channels 30/255/10, got %v", ch[:3])
+	}
+	if n := len(updates); n != 1 {
+		t.Errorf("expected a single broadcast, got %d", n)
+	}
+	if h := state.History(HistoryQuery{Limit: 1}); len(h) != 1 || h[0].Source != SourceHTTP || h[0].Action != "set" {
+		t.Errorf("expected the set recorded once, got %+v", h)
+	}
+}
+
+func TestStateSetBulk(t *testing.T) {
+	state, mock := NewStateWithMock(testConfig(), testLogger())
+	src := state.Source(SourceModbus)
+
+	if err := src.SetBulk(511, []uint8{1, 2, 3}, nil); err == nil {
+		t.Error("expected error for channels past 512")
+	}
+
+	// Raw channels and a light at once: one backend write, one broadcast, the light wins
+	updates := state.Subscribe()
+	defer state.Unsubscribe(updates)
+	before := len(mock.Calls())
+	err := src.SetBulk(1, []uint8{5, 6, 7, 8}, []TargetValues{{Target: "rack1/level1", Values: map[string]uint8{"blue": 40}}})
+	if err != nil {
+		t.Fatalf("SetBulk: %v", err)
+	}
+	if ch := state.GetChannels(); ch[0] != 40 || ch[1] != 6 || ch[3] != 8 {
+		t.Errorf("expected channels 40/6/_/8, got %v", ch[:4])
+	}
+	if calls := mock.Calls()[before:]; len(calls) != 1 || calls[0] != "set_channels" {
+		t.Errorf("expected a single backend write, got %v", calls)
+	}
+	if n := len(updates); n != 1 {
+		t.Errorf("expected a single broadcast, got %d", n)
+	}
+	if h := state.History(HistoryQuery{Limit: 2}); len(h) != 2 || h[1].Channel != 1 {
+		t.Errorf("expected the channel and light sets recorded, got %+v", h)
+	}
+}
+
+func TestStateVariables(t *testing.T) {
+	state, _ := NewStateWithMock(testConfig(), testLogger())
+	updates := state.Subscribe()
+	defer state.Unsubscribe(updates)
+
+	state.SetVariable("temp", " 21.5 ", "sensors/temp")
+	state.SetVariable("occupied", "ON", "sensors/pir")
+	state.SetVariable("mode", "auto", "sensors/mode")
+
+	if v, ok := state.Variable("temp"); !ok || !v.Numeric || v.Value != 21.5 || v.Source != "sensors/temp" {
+		t.Errorf("unexpected temp variable: %+v", v)
+	}
+	if v, _ := state.Variable("occupied"); !v.Numeric || v.Value != 1 {
+		t.Errorf("expected ON = 1, got %+v", v)
+	}
+	if v, _ := state.Variable("mode"); v.Numeric || v.Raw != "auto" {
+		t.Errorf("expected text variable kept raw, got %+v", v)
+	}
+	if _, ok := state.Variable("missing"); ok {
+		t.Error("expected unknown variable not found")
+	}
+	if vars := state.Variables(); len(vars) != 3 {
+		t.Errorf("expected 3 variables, got %v", vars)
+	}
+	var msg VariableMessage
+	if err := json.Unmarshal(<-updates, &msg); err != nil || msg.Type != "variable" || msg.Name != "temp" {
+		t.Errorf("expected variable message, got %+v (%v)", msg, err)
+	}
+}
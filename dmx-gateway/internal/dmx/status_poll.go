@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"time"
+
+	"dmx-gateway/internal/metrics"
+)
+
+// StartStatusPoller polls backend status in the background so GetStatus
+// (HTTP, WebSocket, MQTT) serves cached values instead of invoking dmx_client
+func (s *State) StartStatusPoller(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	s.pollStatus()
+
+	s.statusMu.Lock()
+	s.stopStatus = make(chan struct{})
+	stop := s.stopStatus
+	s.statusMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		s.logger.Info("DMX status poller started", "interval", interval)
+
+		for {
+			select {
+			case <-ticker.C:
+				s.pollStatus()
+			case <-stop:
+				s.logger.Info("DMX status poller stopped")
+				return
+			}
+		}
+	}()
+}
+
+// StopStatusPoller stops the background poller (GetStatus falls back to direct queries)
+func (s *State) StopStatusPoller() {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	if s.stopStatus != nil {
+		close(s.stopStatus)
+		s.stopStatus = nil
+		s.statusUpdated = time.Time{}
+	}
+}
+
+// pollStatus queries the backend and refreshes the cache (stale values are kept on error)
+func (s *State) pollStatus() {
+	status, err := s.backend().Status()
+	if s.backendResult(err) != nil || status == nil {
+		return
+	}
+
+	s.statusMu.Lock()
+	s.statusCache = *status
+	s.statusUpdated = time.Now()
+	s.statusMu.Unlock()
+
+	if status.FPS > 0 {
+		metrics.FPS.Set(status.FPS)
+	}
+}
+
+// cachedStatus returns the cached status if the poller is running and has data
+func (s *State) cachedStatus() (Status, time.Time, bool) {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+
+	if s.stopStatus == nil || s.statusUpdated.IsZero() {
+		return Status{}, time.Time{}, false
+	}
+	return s.statusCache, s.statusUpdated, true
+}
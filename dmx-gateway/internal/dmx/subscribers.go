@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Subscription filters
+// A subscriber can restrict state updates to some groups or lights: it then receives
+// only their values, and only when one of them changed since its last update (a panel
+// showing one rack stays quiet while other racks change). Events (init, show, ...)
+// are not filtered.
+
+// subscriber holds a subscriber's filter
+type subscriber struct {
+	mu      sync.Mutex
+	targets []string // "group" or "group/light" (empty = all lights)
+	last    []byte   // Last filtered update sent
+}
+
+// filtered reports whether the subscriber restricts its updates
+func (sub *subscriber) filtered() bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return len(sub.targets) > 0
+}
+
+// matches reports whether a light key is selected by targets
+func matches(targets []string, key string) bool {
+	for _, t := range targets {
+		if key == t || strings.HasPrefix(key, t+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFilter restricts a subscriber's state updates to targets (empty = all lights)
+// The subscriber receives the current state of its targets right away.
+func (s *State) SetFilter(ch chan []byte, targets []string) error {
+	cfg := s.config()
+	for _, t := range targets {
+		if !cfg.HasTarget(t) {
+			return fmt.Errorf("unknown target %q", t)
+		}
+	}
+
+	s.subsMu.RLock()
+	sub, ok := s.subs[ch]
+	s.subsMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("not subscribed")
+	}
+
+	sub.mu.Lock()
+	sub.targets = targets
+	sub.last = nil
+	sub.mu.Unlock()
+
+	s.broadcastState()
+	return nil
+}
+
+// sendFiltered sends the subscriber's lights if they changed since its last update
+// Caller holds subsMu (read).
+func (s *State) sendFiltered(ch chan []byte, sub *subscriber) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	s.mu.RLock()
+	values := make(map[string]map[string]uint8)
+	for key, v := range s.valuesCache {
+		if matches(sub.targets, key) {
+			values[key] = v
+		}
+	}
+	data, _ := json.Marshal(StateUpdate{
+		Type:    "state",
+		Enabled: s.enabled,
+		Values:  values,
+	})
+	s.mu.RUnlock()
+
+	if bytes.Equal(data, sub.last) {
+		return
+	}
+	select {
+	case ch <- data:
+		sub.last = data
+	default:
+		// Channel full, skip (resent on the next change)
+	}
+}
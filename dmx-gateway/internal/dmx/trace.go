@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Request tracing
+// A write handle can carry the id of the request that caused its writes
+// (Source.WithRequest). The history entries and events it records keep the id, and
+// each traced change is published to subscribers as {"type":"change", ...} so
+// WebSocket and MQTT clients see which request moved the output. Protocols accept
+// an id from the client or generate one (NewRequestID), and log it.
+
+// ChangeMessage is published for each change made by a traced request
+type ChangeMessage struct {
+	Type string `json:"type"` // "change"
+	HistoryEntry
+}
+
+// NewRequestID returns a random request id (16 hex digits)
+func NewRequestID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithRequest returns a copy of the handle whose writes are traced with id
+func (w *Source) WithRequest(id string) *Source {
+	traced := *w
+	traced.requestID = id
+	return &traced
+}
+
+// RequestID returns the id of the request traced by the handle (empty = untraced)
+func (w *Source) RequestID() string { return w.requestID }
+
+// publishChange sends a traced change to subscribers and logs it
+func (s *State) publishChange(e HistoryEntry) {
+	s.logger.Debug("Change applied", "source", e.Source, "action", e.Action, "target", e.Target,
+		"name", e.Name, "request_id", e.RequestID)
+	s.broadcastEvent(ChangeMessage{Type: "change", HistoryEntry: e})
+}
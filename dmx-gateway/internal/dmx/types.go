@@ -3,6 +3,8 @@
 
 package dmx
 
+import "dmx-gateway/internal/remoteproc"
+
 // Zero-allocation response types for DMX Gateway
 // These typed structs replace map[string]interface{} to eliminate heap allocations
 
@@ -11,6 +13,11 @@ type StatusResponse struct {
 	Enabled    bool    `json:"enabled"`
 	FPS        float64 `json:"fps,omitempty"`
 	FrameCount uint64  `json:"frame_count,omitempty"`
+	Errors     uint64  `json:"errors,omitempty"`     // Backend error count
+	RefreshHz  int     `json:"refresh_hz,omitempty"` // Negotiated firmware rate (dmx.fps)
+	Frozen     bool    `json:"frozen,omitempty"`     // Output latched (see Freeze)
+	UpdatedAt  int64   `json:"updated_at,omitempty"` // Unix ms of the cached backend status
+	AgeMs      int64   `json:"age_ms,omitempty"`     // Cached status age at response time
 }
 
 // ChannelState represents a single channel's current state (pre-allocated)
@@ -19,6 +26,29 @@ type ChannelState struct {
 	Color string `json:"color"`
 	Name  string `json:"name"`
 	Value uint8  `json:"value"`
+	FineCh  int    `json:"fine_ch,omitempty"` // 16-bit pair: fine slot
+	Value16 uint16 `json:"value16,omitempty"` // 16-bit pair: coarse<<8 | fine
+	Min   uint8  `json:"min,omitempty"`
+	Max   uint8  `json:"max,omitempty"`
+	CCT   int    `json:"cct,omitempty"` // Tunable white channel color temperature (Kelvin)
+}
+
+// ChannelInfo describes one DMX channel of the address map (GET /api/channels/map)
+type ChannelInfo struct {
+	Ch      int            `json:"ch"`
+	Patched bool           `json:"patched"`
+	Lights  []ChannelPatch `json:"lights,omitempty"` // Lights using the channel (aliases share it)
+	Value   uint8          `json:"value"`            // Logical value
+	Output  uint8          `json:"output"`           // Value sent to the bus (curve, freeze, mask applied)
+	Parked  bool           `json:"parked,omitempty"`
+}
+
+// ChannelPatch is a light channel patched on a DMX channel
+type ChannelPatch struct {
+	Light string `json:"light"` // "group/light"
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Fine  bool   `json:"fine,omitempty"` // Fine (LSB) slot of a 16-bit pair
 }
 
 // LightState represents a light's full state (pre-allocated at startup)
@@ -28,6 +58,11 @@ type LightState struct {
 	Name     string            `json:"name"`
 	Channels []ChannelState    `json:"channels"` // Pre-allocated slice
 	Values   map[string]uint8  `json:"values"`   // Pre-allocated map
+	Masked   bool              `json:"masked,omitempty"` // Out of service: writes accepted, output 0 (see mask.go)
+	IntensityPct float64       `json:"intensity_pct"`    // Brightest channel in percent (see percent.go)
+	Tags     []string          `json:"tags,omitempty"`   // Given to the light or its group (config tags)
+	Location string            `json:"location,omitempty"` // Config metadata, the group's when the light has none
+	Notes    string            `json:"notes,omitempty"`
 }
 
 // LightUpdate is sent when a light changes (minimal allocation)
@@ -59,6 +94,26 @@ type WSStateMessage struct {
 	Values  map[string]map[string]uint8 `json:"values"` // light key -> channel name -> value
 }
 
+// BackendEvent is broadcast to subscribers when output fails over between backends
+type BackendEvent struct {
+	Type  string `json:"type"`  // "backend"
+	Event string `json:"event"` // "failover" or "failback" (events also "unhealthy" and "recovered")
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Error string `json:"error,omitempty"` // Primary error that triggered failover
+}
+
+// ShowEvent is broadcast to subscribers as a timeline show progresses
+type ShowEvent struct {
+	Type      string `json:"type"`  // "show"
+	Event     string `json:"event"` // "start", "step", "finished" or "stopped"
+	Name      string `json:"name"`
+	Step      int    `json:"step,omitempty"` // 1-based step just applied
+	Steps     int    `json:"steps"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+	LengthMs  int64  `json:"length_ms"`
+}
+
 // HealthResponse for /api/health endpoint (typed to avoid map allocation)
 type HealthResponse struct {
 	UptimeSec   int     `json:"uptime_sec"`
@@ -73,6 +128,9 @@ type HealthResponse struct {
 	GCRuns      uint32  `json:"gc_runs"`
 	GoVersion   string  `json:"go_version"`
 	NumCPU      int     `json:"num_cpu"`
+
+	Backend  BackendHealth    `json:"backend"`
+	Firmware *remoteproc.Info `json:"firmware,omitempty"` // M-core firmware (remoteproc configured)
 }
 
 // Pre-serialized responses (computed once at startup)
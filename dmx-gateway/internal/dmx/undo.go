@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"errors"
+	"time"
+)
+
+// Undo history
+// A small ring of full states (frame + enabled flag). Protocol handlers capture one
+// before each change; rapid changes (slider drags) collapse into a single undo step.
+
+const (
+	undoDepth      = 16          // States kept
+	undoAutoWindow = time.Second // Auto captures closer than this are merged
+)
+
+// ErrNothingToUndo is returned when the undo history is empty
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Snapshot pushes the current state onto the undo history and returns the history depth
+func (s *State) Snapshot() int {
+	snap := s.snapshot()
+	snap.Saved = time.Now()
+
+	s.undoMu.Lock()
+	defer s.undoMu.Unlock()
+	s.pushUndoLocked(snap)
+	return len(s.undo)
+}
+
+// CaptureUndo records an undo point before a change, unless one was taken within undoAutoWindow
+func (s *State) CaptureUndo() {
+	snap := s.snapshot()
+	now := time.Now()
+
+	s.undoMu.Lock()
+	defer s.undoMu.Unlock()
+	if now.Sub(s.lastUndoCapture) < undoAutoWindow {
+		s.lastUndoCapture = now
+		return
+	}
+	s.lastUndoCapture = now
+	snap.Saved = now
+	s.pushUndoLocked(snap)
+}
+
+func (s *State) pushUndoLocked(snap Snapshot) {
+	if len(s.undo) == undoDepth {
+		copy(s.undo, s.undo[1:])
+		s.undo = s.undo[:undoDepth-1]
+	}
+	s.undo = append(s.undo, snap)
+}
+
+// Undo rolls back the last steps changes (at least 1) and returns how many were undone
+func (s *State) Undo(steps int) (int, error) {
+	return s.undoSteps(SourceLocal, steps)
+}
+
+func (s *State) undoSteps(source string, steps int) (int, error) {
+	if steps < 1 {
+		steps = 1
+	}
+
+	s.undoMu.Lock()
+	if len(s.undo) == 0 {
+		s.undoMu.Unlock()
+		return 0, ErrNothingToUndo
+	}
+	if steps > len(s.undo) {
+		steps = len(s.undo)
+	}
+	snap := s.undo[len(s.undo)-steps]
+	s.undo = s.undo[:len(s.undo)-steps]
+	s.lastUndoCapture = time.Time{}
+	s.undoMu.Unlock()
+
+	if err := s.applySnapshot(source, snap); err != nil {
+		return 0, err
+	}
+	s.logger.Info("Undo", "steps", steps, "to", snap.Saved)
+	return steps, nil
+}
+
+// UndoDepth returns the number of states in the undo history
+func (s *State) UndoDepth() int {
+	s.undoMu.Lock()
+	defer s.undoMu.Unlock()
+	return len(s.undo)
+}
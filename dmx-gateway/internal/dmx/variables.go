@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"errors"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"dmx-gateway/internal/metrics"
+)
+
+// Variables
+// Named values fed from outside (MQTT sensor topics: temperature, PAR, occupancy...),
+// kept for conditional schedule rules and exposed on the API. A value is numeric:
+// numbers as they are, true/on/yes/occupied = 1 and false/off/no = 0; other text is kept
+// in Raw only (Numeric false). Each update is pushed to subscribers as
+// {"type":"variable", ...}; numeric values are exported as dmx_variable_value{name}.
+
+// ErrVariableNotFound is returned for a variable never received
+var ErrVariableNotFound = errors.New("variable not found")
+
+// Variable is the last value received for a name
+type Variable struct {
+	Name    string    `json:"name"`
+	Value   float64   `json:"value"`
+	Numeric bool      `json:"numeric"` // Value parsed from Raw
+	Raw     string    `json:"raw"`
+	Source  string    `json:"source,omitempty"` // Where it came from (MQTT topic)
+	Updated time.Time `json:"updated"`
+}
+
+// VariableMessage is pushed to subscribers on each update
+type VariableMessage struct {
+	Type string `json:"type"` // "variable"
+	Variable
+}
+
+// ParseVariable returns the numeric value of a raw payload
+func ParseVariable(raw string) (float64, bool) {
+	raw = strings.TrimSpace(raw)
+	if v, err := strconv.ParseFloat(raw, 64); err == nil {
+		return v, true
+	}
+	switch strings.ToLower(raw) {
+	case "true", "on", "yes", "occupied":
+		return 1, true
+	case "false", "off", "no", "unoccupied":
+		return 0, true
+	}
+	return 0, false
+}
+
+// SetVariable records a value received from source
+func (s *State) SetVariable(name, raw, source string) Variable {
+	v := Variable{Name: name, Raw: raw, Source: source, Updated: time.Now()}
+	v.Value, v.Numeric = ParseVariable(raw)
+
+	s.varsMu.Lock()
+	if s.vars == nil {
+		s.vars = make(map[string]Variable)
+	}
+	s.vars[name] = v
+	s.varsMu.Unlock()
+
+	if v.Numeric {
+		metrics.VariableValue.WithLabelValues(name).Set(v.Value)
+	}
+
+	s.broadcastEvent(VariableMessage{Type: "variable", Variable: v})
+	return v
+}
+
+// Variable returns the last value of name
+func (s *State) Variable(name string) (Variable, bool) {
+	s.varsMu.RLock()
+	defer s.varsMu.RUnlock()
+	v, ok := s.vars[name]
+	return v, ok
+}
+
+// Variables returns all variables by name
+func (s *State) Variables() map[string]Variable {
+	s.varsMu.RLock()
+	defer s.varsMu.RUnlock()
+	result := make(map[string]Variable, len(s.vars))
+	maps.Copy(result, s.vars)
+	return result
+}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package dmx
+
+import (
+	"time"
+
+	"dmx-gateway/internal/metrics"
+)
+
+// Backend watchdog
+// Every backend call reports its result here. After N consecutive failures the
+// watchdog resets the client, re-enables output and replays the current frame.
+
+const (
+	defaultWatchdogFailures = 5
+	watchdogBackoff         = 5 * time.Second // Min delay between recovery attempts
+)
+
+// BackendHealth describes the DMX backend health (exposed in /api/health)
+type BackendHealth struct {
+	Healthy             bool   `json:"healthy"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Recoveries          uint64 `json:"recoveries"`
+	LastError           string `json:"last_error,omitempty"`
+}
+
+// watchdog holds backend failure tracking (guarded by State.wdMu)
+type watchdog struct {
+	threshold    int
+	health       BackendHealth
+	recovering   bool
+	lastAttempt  time.Time
+	failingSince time.Time // Start of the current failure streak
+}
+
+// backendResult records the outcome of a backend call and returns err as a *BackendError
+func (s *State) backendResult(err error) error {
+	s.wdMu.Lock()
+	if err == nil {
+		recovered := !s.wd.health.Healthy
+		if recovered {
+			s.logger.Info("DMX backend recovered")
+			metrics.SetBackendHealthy(true)
+		}
+		s.wd.health.Healthy = true
+		s.wd.health.ConsecutiveFailures = 0
+		s.wdMu.Unlock()
+		if recovered {
+			s.Emit(Event{Event: "backend", Data: BackendEvent{Type: "backend", Event: "recovered", To: s.Backends().Active}})
+		}
+		return nil
+	}
+
+	if s.wd.health.ConsecutiveFailures == 0 {
+		s.wd.failingSince = time.Now()
+	}
+	s.wd.health.ConsecutiveFailures++
+	s.wd.health.LastError = err.Error()
+	failingFor := time.Since(s.wd.failingSince)
+
+	trigger := s.wd.health.ConsecutiveFailures >= s.wd.threshold &&
+		!s.wd.recovering &&
+		time.Since(s.wd.lastAttempt) >= watchdogBackoff
+	unhealthy := s.wd.health.ConsecutiveFailures >= s.wd.threshold && s.wd.health.Healthy
+	if unhealthy {
+		s.wd.health.Healthy = false
+		metrics.SetBackendHealthy(false)
+		s.logger.Error("DMX backend unhealthy",
+			"failures", s.wd.health.ConsecutiveFailures,
+			"error", err)
+	}
+	if trigger {
+		s.wd.recovering = true
+		s.wd.lastAttempt = time.Now()
+	}
+	s.wdMu.Unlock()
+
+	if unhealthy {
+		s.Emit(Event{Event: "backend", Data: BackendEvent{Type: "backend", Event: "unhealthy", From: s.Backends().Active, Error: err.Error()}})
+	}
+
+	if trigger {
+		go s.recoverBackend()
+	}
+	s.checkFailover(failingFor, err)
+	return &BackendError{Err: err}
+}
+
+// BackendError is a failure of the DMX output backend (dmx_client, RPMSG, Art-Net)
+type BackendError struct {
+	Err error
+}
+
+func (e *BackendError) Error() string { return e.Err.Error() }
+
+func (e *BackendError) Unwrap() error { return e.Err }
+
+// recoverBackend resets the client, re-enables output if needed and replays the current frame
+func (s *State) recoverBackend() {
+	s.logger.Warn("DMX backend watchdog: attempting recovery")
+
+	err := s.backend().Reset()
+	if err == nil {
+		err = s.Replay()
+	}
+
+	s.wdMu.Lock()
+	s.wd.recovering = false
+	if err != nil {
+		s.wd.health.LastError = err.Error()
+		s.wdMu.Unlock()
+		metrics.BackendRecoveries.WithLabelValues("failed").Inc()
+		s.logger.Error("DMX backend recovery failed", "error", err)
+		return
+	}
+	s.wd.health.Healthy = true
+	s.wd.health.ConsecutiveFailures = 0
+	s.wd.health.Recoveries++
+	s.wdMu.Unlock()
+
+	metrics.SetBackendHealthy(true)
+	metrics.BackendRecoveries.WithLabelValues("success").Inc()
+	s.logger.Info("DMX backend recovery succeeded")
+}
+
+// Replay re-enables output if needed and resends the full frame to the active backend
+// Used after recovery or a firmware restart, when the backend has lost its state.
+func (s *State) Replay() error {
+	b := s.backend()
+	if s.IsEnabled() {
+		if err := b.Enable(); err != nil {
+			return err
+		}
+	}
+	channels := s.outputChannels()
+	return b.SetChannels(1, channels[:])
+}
+
+// BackendHealth returns a snapshot of the backend watchdog state
+func (s *State) BackendHealth() BackendHealth {
+	s.wdMu.Lock()
+	defer s.wdMu.Unlock()
+	return s.wd.health
+}
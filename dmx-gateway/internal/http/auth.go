@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"dmx-gateway/internal/api"
+	"dmx-gateway/internal/config"
+)
+
+// Authentication and role-based access
+// With an auth section, /api, /api/* and /ws require credentials: an API key (X-API-Key
+// header or api_key query parameter) or a JWT bearer token (Authorization header or
+// access_token query parameter; browsers cannot set headers on a WebSocket). Each
+// credential maps to a role:
+//   - viewer (key scope read): GET routes, queries and WebSocket subscriptions
+//   - operator (key scope write): also sets values, scenes, effects, shows, ...
+//   - admin: also enable/disable, schedule, lights provisioning, backend and firmware
+// The web UI page, /metrics and /api/health stay open. A WebSocket may also upgrade
+// without credentials and authenticate with its first message (see wsauth.go).
+
+// scope is what a request may do, ordered: a scope includes the ones below
+type scope int
+
+const (
+	scopeRead  scope = iota + 1 // viewer
+	scopeWrite                  // operator
+	scopeAdmin                  // admin
+)
+
+// scopes maps key scopes and JWT roles to access levels
+var scopes = map[string]scope{
+	"read": scopeRead, "write": scopeWrite, "admin": scopeAdmin,
+	"viewer": scopeRead, "operator": scopeWrite,
+}
+
+// scopeNames names levels in error messages
+var scopeNames = map[scope]string{scopeRead: "viewer", scopeWrite: "operator", scopeAdmin: "admin"}
+
+type scopeKey struct{}
+
+// authenticate wraps next with credential and role checks (no-op without an auth section)
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.cfg.Auth == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !protected(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sc, err := s.credentials(r)
+		if errors.Is(err, errNoCredentials) && r.URL.Path == "/ws" {
+			// First-message handshake: the connection gets nothing until it authenticates
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopeKey{}, scope(0))))
+			return
+		}
+		if err != nil {
+			s.logger.Warn("Unauthorized request", "remote", r.RemoteAddr, "path", r.URL.Path, "error", err)
+			httpError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// The unified endpoint is checked per command (see handleAPI)
+		if need := routeScope(r); sc < need {
+			writeError(w, forbidden(need))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scopeKey{}, sc)))
+	})
+}
+
+// protected reports whether path requires credentials
+func protected(path string) bool {
+	return (apiPath(path) && path != "/api/health") || debugPath(path)
+}
+
+// credentials returns the access level of the key or token presented by r
+func (s *Server) credentials(r *http.Request) (scope, error) {
+	return s.authorize(bearerToken(r), presentedKey(r))
+}
+
+// authorize returns the access level of a JWT or else an API key
+func (s *Server) authorize(token, key string) (scope, error) {
+	if token != "" {
+		if s.cfg.Auth.JWT == nil {
+			return 0, errJWTDisabled
+		}
+		role, err := verifyJWT(s.cfg.Auth.JWT, token)
+		if err != nil {
+			return 0, err
+		}
+		return scopes[role], nil
+	}
+	if k := s.lookupKey(key); k != nil {
+		return scopes[k.Scope], nil
+	}
+	if key != "" {
+		return 0, errUnknownKey
+	}
+	return 0, errNoCredentials
+}
+
+// bearerToken returns the JWT presented by r, if any
+func bearerToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// presentedKey returns the API key presented by r, if any
+func presentedKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	return r.URL.Query().Get("api_key")
+}
+
+// lookupKey returns the configured key matching presented, nil if none matches
+func (s *Server) lookupKey(presented string) *config.APIKey {
+	if presented == "" {
+		return nil
+	}
+	for i := range s.cfg.Auth.Keys {
+		k := &s.cfg.Auth.Keys[i]
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(k.Key)) == 1 {
+			return k
+		}
+	}
+	return nil
+}
+
+// routeScope returns the level a REST request needs
+func routeScope(r *http.Request) scope {
+	path := r.URL.Path
+	switch {
+	case debugPath(path):
+		return scopeAdmin
+	case r.Method == http.MethodGet, path == "/api", path == "/ws":
+		return scopeRead
+	case strings.HasPrefix(path, "/api/schedule/events/") && strings.HasSuffix(path, "/run"):
+		return scopeWrite // Like recalling a scene
+	case path == "/api/enable", path == "/api/disable",
+		path == "/api/schedule", strings.HasPrefix(path, "/api/schedule/"),
+		path == "/api/backend", path == "/api/firmware", strings.HasPrefix(path, "/api/firmware/"),
+		path == "/api/config", strings.HasPrefix(path, "/api/config/"), path == "/api/reload":
+		return scopeAdmin
+	case (strings.HasPrefix(path, "/api/lights/") && !strings.HasSuffix(path, "/mask")) || strings.HasPrefix(path, "/api/groups/"):
+		// Provisioning changes the lights config, setting values does not
+		if r.Method == http.MethodPost || r.Method == http.MethodDelete {
+			return scopeAdmin
+		}
+	}
+	return scopeWrite
+}
+
+// requestScope returns what r may do (everything without an auth section)
+func requestScope(r *http.Request) scope {
+	if sc, ok := r.Context().Value(scopeKey{}).(scope); ok {
+		return sc
+	}
+	return scopeAdmin
+}
+
+// messageScope returns the level a unified API message needs
+// Unparsable messages need none, the handler reports them.
+func messageScope(message []byte) scope {
+	var req api.Request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return scopeRead
+	}
+	switch {
+	case req.ReadOnly():
+		return scopeRead
+	case req.Admin():
+		return scopeAdmin
+	}
+	return scopeWrite
+}
+
+// forbidden is the failure of a request beyond the caller's role
+func forbidden(need scope) *api.Error {
+	e := api.NewError(api.CodeForbidden, "", "forbidden: requires "+scopeNames[need]+" role")
+	e.Details = map[string]string{"role": scopeNames[need]}
+	return e
+}
+
+// forbiddenResponse is the unified API answer to a command beyond the caller's role
+func forbiddenResponse(need scope) []byte {
+	data, _ := json.Marshal(api.Response{Type: "error", Error: forbidden(need)})
+	return data
+}
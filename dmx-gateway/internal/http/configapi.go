@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"dmx-gateway/internal/api"
+	"dmx-gateway/internal/config"
+)
+
+// Configuration API
+// GET /api/config returns the running config with secrets redacted; PUT replaces it
+// (YAML or JSON, secrets left as "[redacted]" keep their value). /api/config/{section}
+// does the same for one top-level section, and PUT null removes an optional one;
+// /api/config/schema is the JSON Schema of the file (see config.Schema).
+// A new config is validated, applied live (lights, park, presets, cues, integrations
+// through OnConfigChange), then the changed sections are written back to config.yaml.
+// POST /api/reload (or SIGHUP) applies config.yaml as edited on disk instead.
+// Sections the running server only reads at startup are reported as restart_required,
+// and the diff lists every key added, removed or changed.
+
+// restartSections are only read at startup
+var restartSections = []string{"server", "dmx", "remoteproc", "history", "persist", "scenes", "auth"}
+
+// ConfigUpdate is the answer to a config PUT or reload
+type ConfigUpdate struct {
+	Status          string          `json:"status"`
+	Changed         []string        `json:"changed"`            // Sections that differ from the running config
+	RestartRequired []string        `json:"restart_required"`   // Changed sections applied at the next start
+	Diff            []config.Change `json:"diff"`               // Keys added, removed or changed
+	Warnings        []string        `json:"warnings,omitempty"` // Of the new config (see config.Warnings)
+}
+
+// handleConfigSchema serves the JSON Schema of the config file
+func (s *Server) handleConfigSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.jsonResponse(w, config.Schema())
+}
+
+// OnConfigChange registers fn to apply changed sections outside the DMX state
+// (integrations, scheduler); an error rejects the update before it is saved, and the
+// running config is applied again (fn called back with it).
+func (s *Server) OnConfigChange(fn func(cfg *config.Config, changed []string) error) {
+	s.onConfig = fn
+}
+
+// handleConfig reads or replaces the whole configuration
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := s.state.GetConfig().Redacted()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.jsonResponse(w, cfg)
+	case http.MethodPut:
+		s.updateConfig(w, r, "")
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleConfigSection reads or replaces one top-level section
+func (s *Server) handleConfigSection(w http.ResponseWriter, r *http.Request) {
+	section := strings.TrimPrefix(r.URL.Path, "/api/config/")
+	if !slices.Contains(config.Sections(), section) {
+		httpError(w, "Unknown section: "+section, http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := s.state.GetConfig().Redacted()
+		if err != nil {
+			httpError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.jsonResponse(w, cfg[section])
+	case http.MethodPut:
+		s.updateConfig(w, r, section)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateConfig validates, applies and saves a new config (section "" = whole document)
+func (s *Server) updateConfig(w http.ResponseWriter, r *http.Request, section string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		httpError(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	// One update at a time: each is derived from the config the previous one applied
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	cur := s.state.GetConfig()
+	var next *config.Config
+	if section == "" {
+		next, err = cur.Derive(body)
+	} else {
+		next, err = cur.DeriveSection(section, body)
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	update, err := s.commitConfig(cur, next)
+	if err != nil {
+		failed(w, err, section)
+		return
+	}
+	s.jsonResponse(w, update)
+}
+
+// commitConfig applies next, then writes its changed sections to the config file;
+// configMu must be held
+func (s *Server) commitConfig(cur, next *config.Config) (*ConfigUpdate, error) {
+	update, err := s.applyConfig(cur, next)
+	if err != nil {
+		return nil, err
+	}
+	if len(update.Changed) == 0 {
+		return update, nil
+	}
+	if err := next.Save(update.Changed); err != nil {
+		s.logger.Error("Failed to save config", "error", err)
+		return nil, api.NewError(api.CodeInternal, "", "Applied but not saved: "+err.Error())
+	}
+	return update, nil
+}
+
+// handleReload re-reads the config file (POST /api/reload)
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	update, err := s.Reload()
+	if err != nil {
+		httpError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.jsonResponse(w, update)
+}
+
+// Reload re-reads the config file and applies it like a PUT, without writing it back
+// (POST /api/reload and SIGHUP). On error the running config is left unchanged.
+func (s *Server) Reload() (*ConfigUpdate, error) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	cur := s.state.GetConfig()
+	if cur.Path() == "" {
+		return nil, errors.New("config not loaded from a file")
+	}
+	next, err := cur.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return s.applyConfig(cur, next)
+}
+
+// applyConfig applies next in place of cur; configMu must be held
+func (s *Server) applyConfig(cur, next *config.Config) (*ConfigUpdate, error) {
+	changed, err := cur.Changed(next)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := cur.Diff(next)
+	if err != nil {
+		return nil, err
+	}
+	update := &ConfigUpdate{Status: "ok", Changed: []string{}, RestartRequired: []string{}, Diff: []config.Change{}, Warnings: next.Warnings()}
+	if len(changed) == 0 {
+		return update, nil
+	}
+
+	if err := s.state.ApplyConfig(next); err != nil {
+		return nil, err
+	}
+	if s.onConfig != nil {
+		if err := s.onConfig(next, changed); err != nil {
+			// Back to cur, integrations included, so a retry sees the same changes
+			if rerr := s.state.ApplyConfig(cur); rerr != nil {
+				s.logger.Error("Failed to restore config", "error", rerr)
+			} else if rerr := s.onConfig(cur, changed); rerr != nil {
+				s.logger.Error("Failed to restart integrations", "sections", changed, "error", rerr)
+			}
+			return nil, err
+		}
+	}
+
+	s.logger.Info("Configuration updated", "changed", changed)
+	update.Changed = changed
+	update.Diff = diff
+	for _, name := range changed {
+		if slices.Contains(restartSections, name) {
+			update.RestartRequired = append(update.RestartRequired, name)
+		}
+	}
+	return update, nil
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// CORS and origin checks
+// Browsers send an Origin header: on /api, /api/* and /ws it must be the gateway itself
+// or one of server.allowed_origins, otherwise the request (or WebSocket upgrade) is
+// refused. Allowed origins get CORS headers and preflight answers. Clients without an
+// Origin header (curl, BMS, scripts) are not affected. server.allow_any_origin lifts
+// the check for development.
+
+// apiPath reports whether path belongs to the API (REST, unified endpoint, WebSocket)
+func apiPath(path string) bool {
+	return path == "/ws" || path == "/api" || strings.HasPrefix(path, "/api/")
+}
+
+// originAllowed reports whether r comes from an allowed origin (also the WebSocket CheckOrigin)
+func (s *Server) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || s.cfg.Server.AllowAnyOrigin {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true // Same origin (web UI)
+	}
+	return slices.Contains(s.cfg.Server.AllowedOrigins, origin)
+}
+
+// cors wraps next with origin checks, CORS headers and preflight answers on API paths
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !apiPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.originAllowed(r) {
+			s.logger.Warn("Request from disallowed origin", "origin", origin, "path", r.URL.Path)
+			httpError(w, "Forbidden origin", http.StatusForbidden)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Expose-Headers", "X-Total-Count, X-Request-ID")
+		h.Add("Vary", "Origin")
+		// Preflights carry no credentials: answered before authentication
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID")
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// Debug endpoints
+// With server.debug (or the -debug flag), /debug/pprof/ serves the Go profiler and
+// /debug/vars the expvar counters (memstats, cmdline), so the gateway can be profiled
+// on the target without a rebuild:
+//   go tool pprof http://gateway:8080/debug/pprof/profile?seconds=30
+// Off by default (404). With an auth section, /debug requires the admin role.
+
+// EnableDebug serves /debug regardless of the config (-debug flag)
+func (s *Server) EnableDebug() {
+	s.debug.Store(true)
+}
+
+// debugPath reports whether path is a debug endpoint
+func debugPath(path string) bool {
+	return strings.HasPrefix(path, "/debug/")
+}
+
+// handleDebug routes /debug/pprof/* and /debug/vars
+func (s *Server) handleDebug(w http.ResponseWriter, r *http.Request) {
+	if !s.debug.Load() {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.URL.Path {
+	case "/debug/vars":
+		expvar.Handler().ServeHTTP(w, r)
+	case "/debug/pprof/cmdline":
+		pprof.Cmdline(w, r)
+	case "/debug/pprof/profile":
+		pprof.Profile(w, r)
+	case "/debug/pprof/symbol":
+		pprof.Symbol(w, r)
+	case "/debug/pprof/trace":
+		pprof.Trace(w, r)
+	default:
+		if strings.HasPrefix(r.URL.Path, "/debug/pprof/") {
+			pprof.Index(w, r) // Index page and named profiles (heap, goroutine, allocs...)
+			return
+		}
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"dmx-gateway/internal/api"
+)
+
+// Error responses
+// Every REST failure answers {"error": {"code", "message", "target", "details"}} with
+// the status of its code (see api.Error). Errors coming from the DMX state are
+// classified, so an unknown light is a 404, an existing one a 409 and a failed write
+// to the output a 502, whichever route reports them.
+
+// errorBody is the JSON body of a failure
+type errorBody struct {
+	Error *api.Error `json:"error"`
+}
+
+// writeError answers e with the status of its code
+func writeError(w http.ResponseWriter, e *api.Error) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(api.Status(e.Code))
+	json.NewEncoder(w).Encode(errorBody{Error: e})
+}
+
+// httpError answers message with status (JSON replacement for http.Error)
+func httpError(w http.ResponseWriter, message string, status int) {
+	writeError(w, api.NewError(api.StatusCode(status), "", message))
+}
+
+// failed answers err, classified by api.Code; target names the light, group, scene...
+func failed(w http.ResponseWriter, err error, target string) {
+	writeError(w, api.ErrorOf(err, target))
+}
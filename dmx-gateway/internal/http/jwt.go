@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// JWT bearer tokens
+// Tokens are signed with a shared HS256 secret by the site's identity provider. The
+// role claim (default "role") holds viewer, operator or admin, as a string or a list
+// (the highest role wins). exp and nbf are enforced when present, iss when configured.
+
+var (
+	errNoCredentials = errors.New("no credentials")
+	errJWTDisabled   = errors.New("bearer token presented but jwt not configured")
+	errUnknownKey    = errors.New("unknown api key")
+)
+
+// verifyJWT checks a token's signature and claims and returns its role
+func verifyJWT(cfg *config.JWTConfig, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", fmt.Errorf("header: %w", err)
+	}
+	if header.Alg != "HS256" {
+		return "", fmt.Errorf("unsupported alg %q", header.Alg)
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.Secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", errors.New("invalid signature")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("claims: %w", err)
+	}
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return "", errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return "", errors.New("token not yet valid")
+	}
+	if cfg.Issuer != "" && claims["iss"] != cfg.Issuer {
+		return "", fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+
+	role := ""
+	switch v := claims[cfg.RoleClaim].(type) {
+	case string:
+		role = v
+	case []any:
+		for _, r := range v {
+			if name, ok := r.(string); ok && scopes[name] > scopes[role] {
+				role = name
+			}
+		}
+	}
+	if role != "viewer" && role != "operator" && role != "admin" {
+		return "", fmt.Errorf("no known role in claim %q", cfg.RoleClaim)
+	}
+	return role, nil
+}
+
+// decodeSegment decodes a base64url JSON token segment
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
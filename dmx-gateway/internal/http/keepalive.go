@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket keepalive
+// Tablets that suspend leave half-open TCP connections behind: nothing fails until a
+// write fills the socket buffer, so their subscriber and goroutines would stay forever.
+// The server pings every ws_ping_sec and drops clients that answer neither with a pong
+// nor a message within two intervals. With ws_idle_sec, clients that send no message
+// for that long are closed too (checked at each ping). Writes time out after wsWriteWait.
+
+const (
+	defaultWSPing = 30 * time.Second
+	wsWriteWait   = 10 * time.Second
+)
+
+// keepalive tracks one connection's liveness
+type keepalive struct {
+	ping     time.Duration
+	idleFor  time.Duration // 0 = never idle
+	lastRead atomic.Int64  // Unix nano of the last client message
+}
+
+// wsKeepalive returns the keepalive settings for a new connection
+func (s *Server) wsKeepalive() *keepalive {
+	ka := &keepalive{
+		ping:    time.Duration(s.cfg.Server.WSPingSec) * time.Second,
+		idleFor: time.Duration(s.cfg.Server.WSIdleSec) * time.Second,
+	}
+	if ka.ping <= 0 {
+		ka.ping = defaultWSPing
+	}
+	ka.lastRead.Store(time.Now().UnixNano())
+	return ka
+}
+
+// watch arms the read deadline, extended by every pong and message
+func (ka *keepalive) watch(conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(2 * ka.ping))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * ka.ping))
+	})
+}
+
+// received records a client message
+func (ka *keepalive) received(conn *websocket.Conn) {
+	ka.lastRead.Store(time.Now().UnixNano())
+	conn.SetReadDeadline(time.Now().Add(2 * ka.ping))
+}
+
+// idle reports whether the client has been silent past the idle timeout
+func (ka *keepalive) idle() bool {
+	return ka.idleFor > 0 && time.Since(time.Unix(0, ka.lastRead.Load())) >= ka.idleFor
+}
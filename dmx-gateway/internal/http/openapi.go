@@ -0,0 +1,364 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"dmx-gateway/internal/api"
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+	"dmx-gateway/internal/modbus"
+	"dmx-gateway/internal/remoteproc"
+	"dmx-gateway/internal/scheduler"
+)
+
+// OpenAPI document
+// GET /api/openapi.json describes the unified API and the REST routes for client
+// generators and API explorers. Schemas are reflected from the Go types the handlers
+// encode (json tags, omitempty = optional), so they follow the code; the route table
+// below is maintained by hand alongside the mux in NewServer.
+
+// route documents one method of a REST path
+type route struct {
+	path, method, summary string
+	query                 []string // Query parameters
+	body                  any      // Request body: reflect.Type or inline schema
+	response              any      // 200 body: reflect.Type, inline schema or nil (status ok)
+	v2                    bool     // Response wrapped in the v2 envelope
+}
+
+func typeOf[T any]() reflect.Type { return reflect.TypeFor[T]() }
+
+// valuesBody is the PUT body of lights and groups: channel name -> value, plus values_pct
+var valuesBody = map[string]any{
+	"type":                 "object",
+	"additionalProperties": map[string]any{"type": "integer", "minimum": 0, "maximum": 255},
+	"properties": map[string]any{
+		"values_pct": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "number", "minimum": 0, "maximum": 100}},
+	},
+}
+
+var routes = []route{
+	{path: "/api/status", method: "get", summary: "DMX status", response: typeOf[dmx.StatusResponse]()},
+	{path: "/api/enable", method: "post", summary: "Enable output"},
+	{path: "/api/disable", method: "post", summary: "Disable output"},
+	{path: "/api/blackout", method: "post", summary: "All channels to 0"},
+	{path: "/api/freeze", method: "post", summary: "Hold the current output frame"},
+	{path: "/api/unfreeze", method: "post", summary: "Resume output with the pending frame"},
+	{path: "/api/park", method: "get", summary: "Parked channels", response: typeOf[map[int]uint8]()},
+	{path: "/api/park", method: "post", summary: "Park channels", body: typeOf[map[int]uint8]()},
+	{path: "/api/park", method: "delete", summary: "Unpark channels (none = all)", query: []string{"ch"}},
+	{path: "/api/history", method: "get", summary: "Recent changes, most recent first", query: []string{"limit", "source", "target", "since"}, response: typeOf[[]dmx.HistoryEntry]()},
+	{path: "/api/variables", method: "get", summary: "External variables (MQTT inputs, Modbus points) by name", response: typeOf[map[string]dmx.Variable]()},
+	{path: "/api/variables/{name}", method: "get", summary: "One external variable", response: typeOf[dmx.Variable]()},
+	{path: "/api/channels", method: "get", summary: "Raw channel values", query: []string{"start", "count"}, response: typeOf[channelRange]()},
+	{path: "/api/channels", method: "put", summary: "Write consecutive raw channels", body: typeOf[channelRange]()},
+	{path: "/api/channels/map", method: "get", summary: "Address map of the 512 channels", response: typeOf[[]dmx.ChannelInfo]()},
+	{path: "/api/channels/patch", method: "get", summary: "Patch report: channel span of each light, gaps and overlaps", response: typeOf[config.PatchReport]()},
+	{path: "/api/lights", method: "get", summary: "Lights state, filtered and paged in key order (X-Total-Count header)", query: []string{"group", "tag", "location", "offset", "limit"}, response: typeOf[map[string]*dmx.LightState]()},
+	{path: "/api/lights/{group}/{name}", method: "get", summary: "Single light", response: typeOf[dmx.LightState]()},
+	{path: "/api/lights/{group}/{name}", method: "put", summary: "Set light values", body: valuesBody},
+	{path: "/api/lights/{group}/{name}", method: "post", summary: "Add a light", query: []string{"persist"}, body: typeOf[struct {
+		Channels []config.Channel `json:"channels"`
+	}]()},
+	{path: "/api/lights/{group}/{name}", method: "delete", summary: "Remove a light", query: []string{"persist"}},
+	{path: "/api/lights/{group}/{name}/mask", method: "post", summary: "Take a light out of service"},
+	{path: "/api/lights/{group}/{name}/mask", method: "delete", summary: "Put a light back in service"},
+	{path: "/api/groups", method: "get", summary: "List groups", response: typeOf[[]string]()},
+	{path: "/api/groups/{name}", method: "get", summary: "Group lights", response: typeOf[struct {
+		Name   string   `json:"name"`
+		Lights []string `json:"lights"`
+	}]()},
+	{path: "/api/groups/{name}", method: "put", summary: "Set group values", body: valuesBody},
+	{path: "/api/groups/{name}", method: "post", summary: "Add a group", query: []string{"persist"}, body: typeOf[struct {
+		Lights map[string][]config.Channel `json:"lights"`
+	}]()},
+	{path: "/api/groups/{name}", method: "delete", summary: "Remove a group", query: []string{"persist"}},
+	{path: "/api/schedule", method: "get", summary: "Scheduled events", response: typeOf[struct {
+		Events []scheduler.EventInfo `json:"events"`
+	}]()},
+	{path: "/api/schedule/next", method: "get", summary: "Next scheduled event", response: typeOf[*scheduler.NextEventInfo]()},
+	{path: "/api/schedule/preview", method: "get", summary: "Timeline of a date (runs, holiday, sun times, photoperiod phases) without running it", query: []string{"date"}, response: typeOf[scheduler.Preview]()},
+	{path: "/api/schedule/upcoming", method: "get", summary: "Next runs of the events with their timestamps (count default 10, max 100)", query: []string{"count"}, response: typeOf[[]scheduler.NextEventInfo]()},
+	{path: "/api/schedule/circadian", method: "get", summary: "Circadian CCT/level", response: typeOf[scheduler.CircadianStatus]()},
+	{path: "/api/schedule/circadian", method: "post", summary: "Enable or disable circadian", body: typeOf[struct {
+		Enabled bool `json:"enabled"`
+	}]()},
+	{path: "/api/schedule/photoperiod", method: "get", summary: "Photoperiod phase/level", response: typeOf[scheduler.PhotoperiodStatus]()},
+	{path: "/api/schedule/photoperiod", method: "post", summary: "Enable or disable photoperiod", body: typeOf[struct {
+		Enabled bool `json:"enabled"`
+	}]()},
+	{path: "/api/schedule/pause", method: "get", summary: "Scheduler pause status", response: typeOf[scheduler.PauseStatus]()},
+	{path: "/api/schedule/pause", method: "post", summary: "Pause the scheduler (events skipped, circadian held), until resumed or for timeout_ms", body: typeOf[struct {
+		TimeoutMs int `json:"timeout_ms,omitempty"`
+	}](), response: typeOf[scheduler.PauseStatus]()},
+	{path: "/api/schedule/resume", method: "post", summary: "Resume the scheduler", response: typeOf[scheduler.PauseStatus]()},
+	{path: "/api/schedule/events", method: "get", summary: "Configured schedule events (with id and disabled flag)", response: typeOf[[]config.ScheduleEvent]()},
+	{path: "/api/schedule/events", method: "post", summary: "Add a schedule event (id assigned when missing), applied and saved", body: typeOf[config.ScheduleEvent](), response: typeOf[config.ScheduleEvent]()},
+	{path: "/api/schedule/events/{id}/run", method: "post", summary: "Run a schedule event now (disabled or not)", response: typeOf[scheduler.Execution]()},
+	{path: "/api/schedule/events/{id}", method: "get", summary: "One schedule event", response: typeOf[config.ScheduleEvent]()},
+	{path: "/api/schedule/events/{id}", method: "put", summary: "Replace a schedule event, applied and saved", body: typeOf[config.ScheduleEvent](), response: typeOf[config.ScheduleEvent]()},
+	{path: "/api/schedule/events/{id}", method: "patch", summary: "Change the fields given (e.g. {\"disabled\":true}), applied and saved", body: typeOf[config.ScheduleEvent](), response: typeOf[config.ScheduleEvent]()},
+	{path: "/api/schedule/events/{id}", method: "delete", summary: "Remove a schedule event, applied and saved"},
+	{path: "/api/modbus/map", method: "get", summary: "Modbus register and coil map (CSV with ?format=csv or Accept: text/csv)", query: []string{"format"}, response: typeOf[[]modbus.MapPoint]()},
+	{path: "/api/config", method: "get", summary: "Active configuration (config file keys, secrets redacted)", response: map[string]any{"type": "object"}},
+	{path: "/api/config", method: "put", summary: "Replace, apply and save the configuration (YAML or JSON)", body: map[string]any{"type": "object"}, response: typeOf[ConfigUpdate]()},
+	{path: "/api/config/schema", method: "get", summary: "JSON Schema of the configuration file", response: map[string]any{"type": "object"}},
+	{path: "/api/config/{section}", method: "get", summary: "One configuration section (secrets redacted)", response: map[string]any{}},
+	{path: "/api/config/{section}", method: "put", summary: "Replace, apply and save one configuration section", body: map[string]any{}, response: typeOf[ConfigUpdate]()},
+	{path: "/api/reload", method: "post", summary: "Reload the configuration file", response: typeOf[ConfigUpdate]()},
+	{path: "/api/health", method: "get", summary: "System health", response: typeOf[dmx.HealthResponse]()},
+	{path: "/api/backend", method: "get", summary: "Output backends", response: typeOf[dmx.BackendInfo]()},
+	{path: "/api/backend", method: "post", summary: "Switch output backend", body: typeOf[struct {
+		Backend string `json:"backend"`
+	}](), response: typeOf[dmx.BackendInfo]()},
+	{path: "/api/firmware", method: "get", summary: "M-core firmware state", response: typeOf[remoteproc.Info]()},
+	{path: "/api/firmware/{action}", method: "post", summary: "Start, stop or reload M-core firmware", body: typeOf[struct {
+		Firmware string `json:"firmware,omitempty"`
+	}](), response: typeOf[remoteproc.Info]()},
+	{path: "/api/scenes", method: "get", summary: "List scenes", response: typeOf[[]dmx.SceneInfo]()},
+	{path: "/api/scenes/{name}", method: "get", summary: "Get a scene", response: typeOf[dmx.Scene]()},
+	{path: "/api/scenes/{name}", method: "post", summary: "Save current values as a scene", body: typeOf[struct {
+		Targets []string `json:"targets,omitempty"`
+	}](), response: typeOf[dmx.Scene]()},
+	{path: "/api/scenes/{name}", method: "delete", summary: "Delete a scene"},
+	{path: "/api/scenes/{name}/recall", method: "post", summary: "Recall a scene", body: typeOf[struct {
+		FadeMs int `json:"fade_ms,omitempty"`
+	}]()},
+	{path: "/api/crossfade", method: "get", summary: "Scene crossfade progress", response: typeOf[dmx.CrossfadeStatus]()},
+	{path: "/api/crossfade", method: "delete", summary: "Abort the scene crossfade"},
+	{path: "/api/presets", method: "get", summary: "Preset names per target", response: typeOf[map[string][]string]()},
+	{path: "/api/cues", method: "get", summary: "Cue lists and current cue", response: typeOf[[]dmx.CueListInfo]()},
+	{path: "/api/recordings", method: "get", summary: "Recorder/player state and recordings", response: typeOf[dmx.RecorderStatus]()},
+	{path: "/api/shows", method: "get", summary: "Timeline show progress", response: typeOf[dmx.ShowStatus]()},
+	{path: "/api/arbitration", method: "get", summary: "Merge policy and channel owners", response: typeOf[dmx.ArbitrationInfo]()},
+	{path: "/api/arbitration", method: "post", summary: "Release a source", body: typeOf[struct {
+		Release string `json:"release"`
+	}]()},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+)
+
+// handleOpenAPI serves the OpenAPI 3 document (built on first request)
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	openAPIOnce.Do(func() {
+		openAPIDoc, _ = json.Marshal(buildOpenAPI())
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDoc)
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// buildOpenAPI assembles the document from the route table and the unified API types
+func buildOpenAPI() map[string]any {
+	g := &schemaGen{schemas: map[string]any{}, names: map[string]reflect.Type{}}
+	okResponse := map[string]any{"type": "object", "properties": map[string]any{"status": map[string]any{"type": "string"}}}
+
+	request := g.schema(typeOf[api.Request]())
+	req := g.schemas["Request"].(map[string]any)
+	req["properties"].(map[string]any)["cmd"] = map[string]any{"type": "string", "enum": api.Commands}
+
+	failure := map[string]any{"type": "object", "properties": map[string]any{"error": g.schema(typeOf[api.Error]())}}
+	paths := map[string]any{
+		"/api": map[string]any{
+			"post": operation("Unified JSON API (same messages as the WebSocket)", nil,
+				request, g.schema(typeOf[api.Response]()), failure),
+		},
+	}
+	for _, rt := range append(routes, v2Routes()...) {
+		item, ok := paths[rt.path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[rt.path] = item
+		}
+		var params []any
+		for _, m := range pathParam.FindAllStringSubmatch(rt.path, -1) {
+			params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+		for _, q := range rt.query {
+			params = append(params, map[string]any{"name": q, "in": "query", "schema": map[string]any{"type": "string"}})
+		}
+		response := any(okResponse)
+		if rt.response != nil {
+			response = g.resolve(rt.response)
+		}
+		if rt.v2 {
+			response = map[string]any{"type": "object", "properties": map[string]any{
+				"data": response, "error": g.schema(typeOf[api.Error]()),
+			}}
+		}
+		var body any
+		if rt.body != nil {
+			body = g.resolve(rt.body)
+		}
+		item[rt.method] = operation(rt.summary, params, body, response, failure)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "DMX Gateway API",
+			"version": "1.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []any{map[string]any{"apiKey": []any{}}, map[string]any{"bearer": []any{}}},
+	}
+}
+
+// operation builds an OpenAPI operation object
+func operation(summary string, params []any, body, response, failure any) map[string]any {
+	op := map[string]any{
+		"summary": summary,
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "OK",
+				"content":     map[string]any{"application/json": map[string]any{"schema": response}},
+			},
+			"default": map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": failure}},
+			},
+		},
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if body != nil {
+		op["requestBody"] = map[string]any{
+			"content": map[string]any{"application/json": map[string]any{"schema": body}},
+		}
+	}
+	return op
+}
+
+// schemaGen reflects Go types to JSON schemas, collecting named structs as components
+type schemaGen struct {
+	schemas map[string]any
+	names   map[string]reflect.Type
+}
+
+// resolve returns the schema of a route table entry
+func (g *schemaGen) resolve(v any) any {
+	if t, ok := v.(reflect.Type); ok {
+		return g.schema(t)
+	}
+	return v
+}
+
+// schema returns the schema of t as encoding/json writes it
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Uint8:
+		return map[string]any{"type": "integer", "minimum": 0, "maximum": 255}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := g.name(t)
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = map[string]any{} // Placeholder for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{} // interface{}: any value
+}
+
+// name returns the component name of a named struct, package-qualified on clashes
+func (g *schemaGen) name(t reflect.Type) string {
+	name := t.Name()
+	if other, ok := g.names[name]; ok && other != t {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	g.names[name] = t
+	return name
+}
+
+// object returns the object schema of a struct, inlining embedded structs
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	g.fields(t, props, &required)
+	obj := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Rate limiting
+// With server.rate_limit, API requests are limited per client by a token bucket: rps
+// tokens per second up to burst. A client is its API key when it presents a valid one,
+// its IP address otherwise. WebSocket connections have their own bucket for incoming
+// messages. Excess requests get 429, excess messages an error reply; neither reaches
+// the DMX backend.
+
+// bucketIdle is how long an unused client bucket is kept
+const bucketIdle = time.Minute
+
+// bucket is a token bucket (not safe for concurrent use, see limiter)
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and takes one token if available
+func (b *bucket) take(now time.Time, rps, burst float64) bool {
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*rps)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// limiter holds one bucket per client
+type limiter struct {
+	rps, burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newLimiter(rps float64, burst int) *limiter {
+	return &limiter{rps: rps, burst: float64(burst), buckets: make(map[string]*bucket)}
+}
+
+// allow reports whether client may make a request now
+func (l *limiter) allow(client string) bool {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > bucketIdle {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > bucketIdle {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	return b.take(now, l.rps, l.burst)
+}
+
+// rateLimit wraps next with per-client limits on API paths (no-op without rate_limit)
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	rl := s.cfg.Server.RateLimit
+	if rl == nil || rl.RPS <= 0 {
+		return next
+	}
+	l := newLimiter(rl.RPS, rl.Burst)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !apiPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := s.clientID(r)
+		if !l.allow(client) {
+			s.logger.Debug("Rate limit exceeded", "client", client, "path", r.URL.Path)
+			w.Header().Set("Retry-After", "1")
+			httpError(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientID identifies a client for rate limiting: API key name or IP address
+func (s *Server) clientID(r *http.Request) string {
+	if s.cfg.Auth != nil {
+		if key := s.lookupKey(presentedKey(r)); key != nil {
+			return "key:" + key.Name
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// wsLimiter returns the message limiter of a new WebSocket connection, nil if unlimited
+func (s *Server) wsLimiter() *limiter {
+	rl := s.cfg.Server.RateLimit
+	if rl == nil || rl.WSRPS <= 0 {
+		return nil
+	}
+	return newLimiter(rl.WSRPS, rl.WSBurst)
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"dmx-gateway/internal/api"
+	"dmx-gateway/internal/config"
+)
+
+// Schedule management
+// /api/schedule/events lists the events of the config with their id and disabled
+// flag, and POST adds one; /api/schedule/events/{id} reads (GET), replaces (PUT),
+// changes the fields given (PATCH, e.g. {"disabled":true}) or removes (DELETE) one.
+// A PATCH giving a cron expression drops the time and days of the event, and one
+// giving a time drops its cron expression, so an event switches between the two.
+// Each change goes through the config update path: validated, the scheduler restarted
+// with the new events (OnConfigChange), then the schedule section written back to
+// config.yaml. /api/schedule keeps listing what the running scheduler executes.
+// POST /api/schedule/events/{id}/run runs an event at once, disabled or not, and
+// answers its result (operator scope, for "evening now" buttons).
+
+// handleScheduleEvents lists (GET) or adds (POST) schedule events
+func (s *Server) handleScheduleEvents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.jsonResponse(w, scheduleEvents(s.state.GetConfig()))
+	case http.MethodPost:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+		var e config.ScheduleEvent
+		if err := decodeEvent(data, &e); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.updateSchedule(w, e.ID, func(events []config.ScheduleEvent) ([]config.ScheduleEvent, int, error) {
+			if e.ID != "" && indexEvent(events, e.ID) >= 0 {
+				return nil, 0, api.NewError(api.CodeConflict, e.ID, "schedule event exists: "+e.ID)
+			}
+			return append(events, e), len(events), nil
+		})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScheduleEvent reads, replaces, changes or removes one schedule event
+func (s *Server) handleScheduleEvent(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/schedule/events/")
+	if id, ok := strings.CutSuffix(id, "/run"); ok {
+		s.runScheduleEvent(w, r, id)
+		return
+	}
+	notFound := api.NewError(api.CodeNotFound, id, "schedule event not found: "+id)
+
+	switch r.Method {
+	case http.MethodGet:
+		events := scheduleEvents(s.state.GetConfig())
+		i := indexEvent(events, id)
+		if i < 0 {
+			writeError(w, notFound)
+			return
+		}
+		s.jsonResponse(w, events[i])
+	case http.MethodPut, http.MethodPatch:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			httpError(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+		s.updateSchedule(w, id, func(events []config.ScheduleEvent) ([]config.ScheduleEvent, int, error) {
+			i := indexEvent(events, id)
+			if i < 0 {
+				return nil, 0, notFound
+			}
+			var e config.ScheduleEvent
+			if r.Method == http.MethodPatch {
+				// Fields not given keep their value (maps are shared with the running config)
+				e = events[i]
+				e.Set = maps.Clone(e.Set)
+				e.Preset = maps.Clone(e.Preset)
+				e.Days = slices.Clone(e.Days)
+				var given map[string]json.RawMessage
+				if err := json.Unmarshal(data, &given); err != nil {
+					return nil, 0, api.NewError(api.CodeBadRequest, id, "invalid JSON: "+err.Error())
+				}
+				if _, ok := given["cron"]; ok {
+					e.Time, e.Days = "", nil
+				}
+				if _, ok := given["time"]; ok {
+					e.Cron = ""
+				}
+				// Maps given replace the current ones (json merges into a map)
+				if _, ok := given["set"]; ok {
+					e.Set = nil
+				}
+				if _, ok := given["preset"]; ok {
+					e.Preset = nil
+				}
+			}
+			if err := decodeEvent(data, &e); err != nil {
+				return nil, 0, err
+			}
+			if e.ID != "" && e.ID != id {
+				return nil, 0, api.NewError(api.CodeBadRequest, id, "id cannot be changed")
+			}
+			e.ID = id
+			events[i] = e
+			return events, i, nil
+		})
+	case http.MethodDelete:
+		s.updateSchedule(w, id, func(events []config.ScheduleEvent) ([]config.ScheduleEvent, int, error) {
+			i := indexEvent(events, id)
+			if i < 0 {
+				return nil, 0, notFound
+			}
+			return slices.Delete(events, i, i+1), -1, nil
+		})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// runScheduleEvent runs a configured event now (POST)
+func (s *Server) runScheduleEvent(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := s.state.GetConfig()
+	events := scheduleEvents(cfg)
+	i := indexEvent(events, id)
+	if i < 0 {
+		writeError(w, api.NewError(api.CodeNotFound, id, "schedule event not found: "+id))
+		return
+	}
+	sched := s.scheduler.Load()
+	if sched == nil {
+		httpError(w, "scheduler not running", http.StatusNotFound)
+		return
+	}
+	x, err := sched.RunEvent(cfg.Schedule, events[i])
+	if err != nil {
+		failed(w, err, id) // Invalid event: 400
+		return
+	}
+	s.jsonResponse(w, x)
+}
+
+// updateSchedule applies change to a copy of the configured events, commits the
+// result and answers the event at the index change returns (-1 = status only)
+func (s *Server) updateSchedule(w http.ResponseWriter, target string, change func(events []config.ScheduleEvent) ([]config.ScheduleEvent, int, error)) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	cur := s.state.GetConfig()
+	events, i, err := change(scheduleEvents(cur))
+	if err != nil {
+		failed(w, err, target)
+		return
+	}
+	next, err := cur.DeriveSchedule(events)
+	if err != nil {
+		failed(w, err, target)
+		return
+	}
+	if _, err := s.commitConfig(cur, next); err != nil {
+		failed(w, err, target)
+		return
+	}
+	if i < 0 {
+		s.jsonResponse(w, map[string]string{"status": "ok"})
+		return
+	}
+	s.jsonResponse(w, next.Schedule.Events[i]) // Order is kept, new events carry their id
+}
+
+// scheduleEvents returns a copy of the configured events
+func scheduleEvents(cfg *config.Config) []config.ScheduleEvent {
+	if cfg.Schedule == nil {
+		return []config.ScheduleEvent{}
+	}
+	return append([]config.ScheduleEvent{}, cfg.Schedule.Events...)
+}
+
+// indexEvent returns the position of event id, or -1
+func indexEvent(events []config.ScheduleEvent, id string) int {
+	return slices.IndexFunc(events, func(e config.ScheduleEvent) bool { return e.ID == id })
+}
+
+// decodeEvent decodes a JSON event into e, rejecting unknown fields
+func decodeEvent(data []byte, e *config.ScheduleEvent) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(e)
+}
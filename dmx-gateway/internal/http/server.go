@@ -14,7 +14,11 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -23,6 +27,9 @@ import (
 	"dmx-gateway/internal/api"
 	"dmx-gateway/internal/config"
 	"dmx-gateway/internal/dmx"
+	"dmx-gateway/internal/metrics"
+	"dmx-gateway/internal/modbus"
+	"dmx-gateway/internal/remoteproc"
 	"dmx-gateway/internal/scheduler"
 )
 
@@ -35,11 +42,18 @@ var staticFiles embed.FS
 type Server struct {
 	cfg       *config.Config
 	state     *dmx.State
-	api       *api.Handler
-	scheduler *scheduler.Scheduler
+	api       *api.Handler // HTTP POST /api (source "http")
+	wsAPI     *api.Handler // WebSocket (source "ws")
+	scheduler atomic.Pointer[scheduler.Scheduler]
+	modbus    atomic.Pointer[modbus.Server]
+	firmware  *remoteproc.Manager
 	logger    *slog.Logger
 	server    *http.Server
 	upgrader  websocket.Upgrader
+	configMu  sync.Mutex                                       // Serializes config updates
+	onConfig  func(cfg *config.Config, changed []string) error // See OnConfigChange
+	debug     atomic.Bool                                      // Serve /debug (see debug.go)
+	wsClients atomic.Int64                                     // Connected WebSocket clients
 }
 
 // NewServer creates a new HTTP server
@@ -47,12 +61,11 @@ func NewServer(cfg *config.Config, state *dmx.State, logger *slog.Logger) *Serve
 	s := &Server{
 		cfg:    cfg,
 		state:  state,
-		api:    api.NewHandler(state),
+		api:    api.NewHandler(state, dmx.SourceHTTP),
+		wsAPI:  api.NewHandler(state, dmx.SourceWS),
 		logger: logger,
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
-		},
 	}
+	s.upgrader.CheckOrigin = s.originAllowed
 
 	mux := http.NewServeMux()
 
@@ -67,31 +80,87 @@ func NewServer(cfg *config.Config, state *dmx.State, logger *slog.Logger) *Serve
 	mux.HandleFunc("/api/enable", s.handleEnable)
 	mux.HandleFunc("/api/disable", s.handleDisable)
 	mux.HandleFunc("/api/blackout", s.handleBlackout)
+	mux.HandleFunc("/api/freeze", s.handleFreeze)
+	mux.HandleFunc("/api/unfreeze", s.handleUnfreeze)
+	mux.HandleFunc("/api/park", s.handlePark)
+	mux.HandleFunc("/api/history", s.handleHistory)
+	mux.HandleFunc("/api/variables", s.handleVariables)
+	mux.HandleFunc("/api/variables/", s.handleVariables)
+	mux.HandleFunc("/api/channels", s.handleChannels)
+	mux.HandleFunc("/api/channels/map", s.handleChannelMap)
+	mux.HandleFunc("/api/channels/patch", s.handleChannelPatch)
 	mux.HandleFunc("/api/lights", s.handleLights)
 	mux.HandleFunc("/api/lights/", s.handleLight)
 	mux.HandleFunc("/api/groups", s.handleGroups)
 	mux.HandleFunc("/api/groups/", s.handleGroup)
 	mux.HandleFunc("/api/schedule", s.handleSchedule)
 	mux.HandleFunc("/api/schedule/next", s.handleScheduleNext)
+	mux.HandleFunc("/api/schedule/upcoming", s.handleScheduleUpcoming)
+	mux.HandleFunc("/api/schedule/preview", s.handleSchedulePreview)
+	mux.HandleFunc("/api/schedule/circadian", s.handleCircadian)
+	mux.HandleFunc("/api/schedule/photoperiod", s.handlePhotoperiod)
+	mux.HandleFunc("/api/schedule/pause", s.handleSchedulePause)
+	mux.HandleFunc("/api/schedule/resume", s.handleScheduleResume)
+	mux.HandleFunc("/api/schedule/events", s.handleScheduleEvents)
+	mux.HandleFunc("/api/schedule/events/", s.handleScheduleEvent)
+	mux.HandleFunc("/api/modbus/map", s.handleModbusMap)
 	mux.HandleFunc("/api/health", s.handleHealth)
+	mux.HandleFunc("/api/config", s.handleConfig)
+	mux.HandleFunc("/api/config/", s.handleConfigSection)
+	mux.HandleFunc("/api/config/schema", s.handleConfigSchema)
+	mux.HandleFunc("/api/reload", s.handleReload)
+	mux.HandleFunc("/api/backend", s.handleBackend)
+	mux.HandleFunc("/api/firmware", s.handleFirmware)
+	mux.HandleFunc("/api/firmware/", s.handleFirmwareAction)
+	mux.HandleFunc("/api/scenes", s.handleScenes)
+	mux.HandleFunc("/api/scenes/", s.handleScene)
+	mux.HandleFunc("/api/crossfade", s.handleCrossfade)
+	mux.HandleFunc("/api/presets", s.handlePresets)
+	mux.HandleFunc("/api/cues", s.handleCues)
+	mux.HandleFunc("/api/recordings", s.handleRecordings)
+	mux.HandleFunc("/api/shows", s.handleShows)
+	mux.HandleFunc("/api/arbitration", s.handleArbitration)
+	mux.HandleFunc("/api/openapi.json", s.handleOpenAPI)
+
+	// Resource API (see v2.go)
+	mux.HandleFunc("/api/v2/", s.handleV2)
 
 	// Prometheus metrics
 	mux.Handle("/metrics", promhttp.Handler())
 
+	// Profiling (see debug.go)
+	s.debug.Store(cfg.Server.Debug)
+	mux.HandleFunc("/debug/", s.handleDebug)
+
 	// Static files
 	staticFS, _ := fs.Sub(staticFiles, "static")
 	mux.Handle("/", http.FileServer(http.FS(staticFS)))
 
 	s.server = &http.Server{
 		Addr:    cfg.Server.HTTP,
-		Handler: mux,
+		Handler: s.trace(s.compress(s.cors(s.rateLimit(s.authenticate(mux))))),
 	}
 
 	return s
 }
 
-// Start starts the HTTP server
+// Start starts the HTTP server (HTTPS with a server.tls section)
 func (s *Server) Start() error {
+	if t := s.cfg.Server.TLS; t != nil {
+		tlsConfig, err := s.tlsConfig()
+		if err != nil {
+			return err
+		}
+		s.server.TLSConfig = tlsConfig
+		s.logger.Info("Starting HTTPS server", "addr", s.cfg.Server.HTTP, "client_certs", t.ClientCA != "")
+		go func() {
+			if err := s.server.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+				s.logger.Error("HTTPS server error", "error", err)
+			}
+		}()
+		return nil
+	}
+
 	s.logger.Info("Starting HTTP server", "addr", s.cfg.Server.HTTP)
 	go func() {
 		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
@@ -106,6 +175,11 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
 
+// WSClients returns the number of connected WebSocket clients
+func (s *Server) WSClients() int {
+	return int(s.wsClients.Load())
+}
+
 // handleWebSocket handles WebSocket connections
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := s.upgrader.Upgrade(w, r, nil)
@@ -116,6 +190,21 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	defer conn.Close()
 
 	s.logger.Debug("WebSocket client connected", "remote", r.RemoteAddr)
+	metrics.WSClients.Inc()
+	defer metrics.WSClients.Dec()
+	s.wsClients.Add(1)
+	defer s.wsClients.Add(-1)
+
+	// Viewers may query and subscribe, not write
+	sc := requestScope(r)
+	if sc == 0 {
+		var ok bool
+		if sc, ok = s.wsHandshake(conn, r); !ok {
+			return
+		}
+	}
+	limit := s.wsLimiter()
+	ka := s.wsKeepalive()
 
 	// Subscribe to state updates
 	updates := s.state.Subscribe()
@@ -129,6 +218,7 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	s.sendInitialStateAsync(outgoing)
 
 	// Read from client
+	ka.watch(conn)
 	go func() {
 		defer close(done)
 		for {
@@ -139,16 +229,48 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 				}
 				return
 			}
-			s.handleWSMessageAsync(message, outgoing)
+			ka.received(conn)
+			if limit != nil && !limit.allow("") {
+				data, _ := json.Marshal(api.Response{Type: "error", Error: api.NewError(api.CodeRateLimited, "", "rate limit exceeded")})
+				outgoing <- data
+				continue
+			}
+			s.handleWSMessageAsync(message, outgoing, updates, sc)
 		}
 	}()
 
-	// Write loop - all writes go through here
+	s.wsWriteLoop(conn, ka, outgoing, updates, done)
+
+	// Unblock the reader (it may wait on a full outgoing) until the closed connection stops it
+	conn.Close()
+	for {
+		select {
+		case <-outgoing:
+		case <-done:
+			s.logger.Debug("WebSocket client disconnected", "remote", r.RemoteAddr)
+			return
+		}
+	}
+}
+
+// wsWriteLoop sends responses, state updates and keepalive pings until the connection ends
+// All writes go through here.
+func (s *Server) wsWriteLoop(conn *websocket.Conn, ka *keepalive, outgoing, updates <-chan []byte, done <-chan struct{}) {
+	ticker := time.NewTicker(ka.ping)
+	defer ticker.Stop()
+
+	write := func(data []byte) bool {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			s.logger.Debug("WebSocket write error", "error", err)
+			return false
+		}
+		return true
+	}
 	for {
 		select {
 		case data := <-outgoing:
-			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-				s.logger.Debug("WebSocket write error", "error", err)
+			if !write(data) {
 				return
 			}
 		case data, ok := <-updates:
@@ -156,8 +278,18 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			// data is pre-marshaled JSON from broadcastState
-			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-				s.logger.Debug("WebSocket write error", "error", err)
+			if !write(data) {
+				return
+			}
+		case <-ticker.C:
+			if ka.idle() {
+				s.logger.Debug("WebSocket client idle, closing")
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "idle timeout"), time.Now().Add(wsWriteWait))
+				return
+			}
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				s.logger.Debug("WebSocket ping error", "error", err)
 				return
 			}
 		case <-done:
@@ -184,17 +316,44 @@ func (s *Server) sendInitialStateAsync(outgoing chan<- []byte) {
 }
 
 // handleWSMessageAsync handles incoming WebSocket message and sends response via outgoing channel
-func (s *Server) handleWSMessageAsync(message []byte, outgoing chan<- []byte) {
+// updates is the connection's subscription (filtered with {"cmd":"subscribe","targets":[...]}),
+// sc what the connection's credentials allow.
+func (s *Server) handleWSMessageAsync(message []byte, outgoing chan<- []byte, updates chan []byte, sc scope) {
 	// Try unified API format first (has "cmd" field)
 	var unified struct {
-		Cmd string `json:"cmd"`
+		Cmd     string   `json:"cmd"`
+		Targets []string `json:"targets"`
 	}
-	if err := json.Unmarshal(message, &unified); err == nil && unified.Cmd != "" {
+	err := json.Unmarshal(message, &unified)
+	if err == nil && unified.Cmd == "subscribe" {
+		resp := api.Response{Type: "ok"}
+		if err := s.state.SetFilter(updates, unified.Targets); err != nil {
+			resp = api.Response{Type: "error", Error: api.ErrorOf(err, "")}
+		}
+		data, _ := json.Marshal(resp)
+		outgoing <- data
+		return
+	}
+	if err == nil && unified.Cmd == "auth" {
+		// Already authenticated at upgrade (or auth disabled)
+		data, _ := json.Marshal(api.Response{Type: "ok", Data: map[string]string{"role": scopeNames[sc]}})
+		outgoing <- data
+		return
+	}
+	if err == nil && unified.Cmd != "" {
+		if need := messageScope(message); sc < need {
+			outgoing <- forbiddenResponse(need)
+			return
+		}
 		// Use unified API handler
-		resp := s.api.HandleJSON(message)
+		resp := s.wsAPI.HandleJSON(message)
 		outgoing <- resp
 		return
 	}
+	if sc < scopeWrite {
+		s.logger.Debug("WebSocket write ignored, viewer role")
+		return
+	}
 
 	// Legacy format - no response needed (state changes broadcast via updates channel)
 	var msg struct {
@@ -212,42 +371,56 @@ func (s *Server) handleWSMessageAsync(message []byte, outgoing chan<- []byte) {
 	}
 
 	switch msg.Type {
-	case "enable":
-		s.state.Enable()
-	case "disable":
-		s.state.Disable()
+	case "enable", "disable":
+		if sc < scopeAdmin {
+			s.logger.Debug("WebSocket enable/disable ignored, requires admin role")
+			return
+		}
+		if msg.Type == "enable" {
+			s.state.Source(dmx.SourceWS).Enable()
+		} else {
+			s.state.Source(dmx.SourceWS).Disable()
+		}
 	case "blackout":
-		s.state.Blackout()
+		s.state.Source(dmx.SourceWS).Blackout()
 	case "set_channel":
-		s.state.SetChannel(msg.Channel, msg.Value)
+		s.state.Source(dmx.SourceWS).SetChannel(msg.Channel, msg.Value)
 	case "set_light":
 		group, name := parseKey(msg.Key)
 		if group != "" && name != "" {
 			values := parseValues(msg.Values)
-			s.state.SetLight(group, name, values)
+			s.state.Source(dmx.SourceWS).SetLight(group, name, values)
 		}
 	case "set_group":
 		values := parseValues(msg.Values)
-		s.state.SetGroup(msg.Group, values)
+		s.state.Source(dmx.SourceWS).SetGroup(msg.Group, values)
 	}
 }
 
 // handleAPI handles the unified JSON API endpoint
 func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		httpError(w, "Failed to read body", http.StatusBadRequest)
 		return
 	}
 
-	resp := s.api.HandleJSON(body)
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	if need := messageScope(body); requestScope(r) < need {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write(forbiddenResponse(need))
+		return
+	}
+	resp := s.api.HandleData(body, requestID(r))
+	if resp.Error != nil {
+		w.WriteHeader(api.Status(resp.Error.Code))
+	}
+	json.NewEncoder(w).Encode(resp)
 }
 
 // handleWSMessage handles an incoming WebSocket message
@@ -259,7 +432,7 @@ func (s *Server) handleWSMessage(conn *websocket.Conn, message []byte) {
 	}
 	if err := json.Unmarshal(message, &unified); err == nil && unified.Cmd != "" {
 		// Use unified API handler
-		resp := s.api.HandleJSON(message)
+		resp := s.wsAPI.HandleJSON(message)
 		conn.WriteMessage(websocket.TextMessage, resp)
 		return
 	}
@@ -281,27 +454,27 @@ func (s *Server) handleWSMessage(conn *websocket.Conn, message []byte) {
 
 	switch msg.Type {
 	case "enable":
-		s.state.Enable()
+		s.state.Source(dmx.SourceWS).Enable()
 
 	case "disable":
-		s.state.Disable()
+		s.state.Source(dmx.SourceWS).Disable()
 
 	case "blackout":
-		s.state.Blackout()
+		s.state.Source(dmx.SourceWS).Blackout()
 
 	case "set_channel":
-		s.state.SetChannel(msg.Channel, msg.Value)
+		s.state.Source(dmx.SourceWS).SetChannel(msg.Channel, msg.Value)
 
 	case "set_light":
 		group, name := parseKey(msg.Key)
 		if group != "" && name != "" {
 			values := parseValues(msg.Values)
-			s.state.SetLight(group, name, values)
+			s.state.Source(dmx.SourceWS).SetLight(group, name, values)
 		}
 
 	case "set_group":
 		values := parseValues(msg.Values)
-		s.state.SetGroup(msg.Group, values)
+		s.state.Source(dmx.SourceWS).SetGroup(msg.Group, values)
 	}
 }
 
@@ -327,6 +500,29 @@ func parseValues(raw map[string]interface{}) map[string]uint8 {
 	return values
 }
 
+// parseValuesPct parses a REST body with optional "values_pct": {"blue": 50} (explicit values win)
+func parseValuesPct(raw map[string]interface{}) (map[string]uint8, error) {
+	values := parseValues(raw)
+	pct, ok := raw["values_pct"].(map[string]interface{})
+	if !ok {
+		return values, nil
+	}
+	for k, v := range pct {
+		p, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s: percent must be a number", k)
+		}
+		value, err := dmx.PercentValue(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		if _, explicit := values[k]; !explicit {
+			values[k] = value
+		}
+	}
+	return values, nil
+}
+
 // REST API Handlers
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
@@ -335,11 +531,11 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleEnable(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if err := s.state.Enable(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.source(r).Enable(); err != nil {
+		failed(w, err, "")
 		return
 	}
 	s.jsonResponse(w, map[string]string{"status": "ok"})
@@ -347,11 +543,11 @@ func (s *Server) handleEnable(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleDisable(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if err := s.state.Disable(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.source(r).Disable(); err != nil {
+		failed(w, err, "")
 		return
 	}
 	s.jsonResponse(w, map[string]string{"status": "ok"})
@@ -359,51 +555,280 @@ func (s *Server) handleDisable(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleBlackout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.source(r).Blackout(); err != nil {
+		failed(w, err, "")
+		return
+	}
+	s.jsonResponse(w, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleFreeze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if err := s.state.Blackout(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	s.state.Freeze()
+	s.jsonResponse(w, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleUnfreeze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.state.Unfreeze(); err != nil {
+		failed(w, err, "")
 		return
 	}
 	s.jsonResponse(w, map[string]string{"status": "ok"})
 }
 
+func (s *Server) handlePark(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.jsonResponse(w, s.state.Parked())
+	case http.MethodPost:
+		var body map[int]uint8
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.state.Park(body); err != nil {
+			failed(w, err, "")
+			return
+		}
+		s.jsonResponse(w, map[string]string{"status": "ok"})
+	case http.MethodDelete:
+		// ?ch=1,40 (none = all)
+		var channels []int
+		for _, v := range strings.Split(r.URL.Query().Get("ch"), ",") {
+			if v == "" {
+				continue
+			}
+			ch, err := strconv.Atoi(v)
+			if err != nil {
+				httpError(w, "Invalid channel: "+v, http.StatusBadRequest)
+				return
+			}
+			channels = append(channels, ch)
+		}
+		s.state.Unpark(channels)
+		s.jsonResponse(w, map[string]string{"status": "ok"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// channelRange is a block of consecutive raw channel values
+type channelRange struct {
+	Start  int   `json:"start"`  // First channel (1-based)
+	Values []int `json:"values"` // 0-255 each
+}
+
+// handleChannels reads (GET ?start=1&count=64) or writes (PUT {"start":1,"values":[...]}) raw channels
+func (s *Server) handleChannels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		start, count := 1, 512
+		q := r.URL.Query()
+		if v := q.Get("start"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 512 {
+				httpError(w, "Invalid start (1-512): "+v, http.StatusBadRequest)
+				return
+			}
+			start, count = n, 512-n+1
+		}
+		if v := q.Get("count"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || start+n-1 > 512 {
+				httpError(w, "Invalid count: "+v, http.StatusBadRequest)
+				return
+			}
+			count = n
+		}
+		channels := s.state.GetChannels()
+		values := make([]int, count)
+		for i := range values {
+			values[i] = int(channels[start-1+i])
+		}
+		s.jsonResponse(w, channelRange{Start: start, Values: values})
+	case http.MethodPut:
+		var body channelRange
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Start == 0 {
+			body.Start = 1
+		}
+		if len(body.Values) == 0 || body.Start < 1 || body.Start+len(body.Values)-1 > 512 {
+			httpError(w, "Values required within channels 1-512", http.StatusBadRequest)
+			return
+		}
+		values := make([]uint8, len(body.Values))
+		for i, v := range body.Values {
+			if v < 0 || v > 255 {
+				httpError(w, fmt.Sprintf("Value %d out of range (0-255) at channel %d", v, body.Start+i), http.StatusBadRequest)
+				return
+			}
+			values[i] = uint8(v)
+		}
+		if err := s.source(r).SetChannels(body.Start, values); err != nil {
+			failed(w, err, "")
+			return
+		}
+		s.jsonResponse(w, map[string]string{"status": "ok"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleChannelMap(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, s.state.ChannelMap())
+}
+
+// handleChannelPatch returns the patch report of the lights (spans, gaps, overlaps)
+func (s *Server) handleChannelPatch(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, s.state.GetConfig().Patch())
+}
+
+// handleVariables lists the external variables, or one with /api/variables/{name}
+func (s *Server) handleVariables(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/variables"), "/")
+	if name == "" {
+		s.jsonResponse(w, s.state.Variables())
+		return
+	}
+	v, ok := s.state.Variable(name)
+	if !ok {
+		failed(w, dmx.ErrVariableNotFound, name)
+		return
+	}
+	s.jsonResponse(w, v)
+}
+
+// handleHistory lists recent changes, most recent first
+// Query: ?limit=50&source=mqtt&target=rack3&since=2025-01-01T02:00:00Z
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := dmx.HistoryQuery{Source: q.Get("source"), Target: q.Get("target")}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			httpError(w, "Invalid limit: "+v, http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			httpError(w, "Invalid since (RFC 3339): "+v, http.StatusBadRequest)
+			return
+		}
+		query.Since = since
+	}
+	s.jsonResponse(w, s.state.History(query))
+}
+
+// handleLights lists lights, filtered and paged in key order
+// Query: ?group=rack1&tag=veg&offset=0&limit=50 (X-Total-Count = matches before paging)
 func (s *Server) handleLights(w http.ResponseWriter, r *http.Request) {
-	s.jsonResponse(w, s.state.GetLights())
+	q := r.URL.Query()
+	query := dmx.LightQuery{Group: q.Get("group"), Tag: q.Get("tag"), Location: q.Get("location")}
+	for name, field := range map[string]*int{"offset": &query.Offset, "limit": &query.Limit} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				httpError(w, "Invalid "+name+": "+v, http.StatusBadRequest)
+				return
+			}
+			*field = n
+		}
+	}
+	lights, total := s.state.Lights(query)
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	s.jsonResponse(w, lights)
 }
 
 func (s *Server) handleLight(w http.ResponseWriter, r *http.Request) {
 	// Path: /api/lights/group/name
 	path := strings.TrimPrefix(r.URL.Path, "/api/lights/")
+	if key, ok := strings.CutSuffix(path, "/mask"); ok {
+		s.handleLightMask(w, r, key)
+		return
+	}
 	group, name := parseKey(path)
 	if group == "" || name == "" {
-		http.Error(w, "Invalid path, use /api/lights/group/name", http.StatusBadRequest)
+		httpError(w, "Invalid path, use /api/lights/group/name", http.StatusBadRequest)
 		return
 	}
 
-	if r.Method == http.MethodPut {
+	switch r.Method {
+	case http.MethodPut:
 		var body map[string]interface{}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			httpError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		values := parseValues(body)
-		if err := s.state.SetLight(group, name, values); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		values, err := parseValuesPct(body)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.source(r).SetLight(group, name, values); err != nil {
+			failed(w, err, group+"/"+name)
 			return
 		}
 		s.jsonResponse(w, map[string]string{"status": "ok"})
-	} else {
+	case http.MethodPost:
+		var body struct {
+			Channels []config.Channel `json:"channels"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.provisioned(w, r, s.state.AddLight(group, name, body.Channels))
+	case http.MethodDelete:
+		s.provisioned(w, r, s.state.RemoveLight(group, name))
+	default:
 		light := s.state.GetLight(group, name)
 		if light == nil {
-			http.Error(w, "Light not found", http.StatusNotFound)
+			failed(w, dmx.ErrLightNotFound, group+"/"+name)
 			return
 		}
 		s.jsonResponse(w, light)
 	}
 }
 
+// handleLightMask takes a light out of service (POST) or back in service (DELETE)
+// Path: /api/lights/group/name/mask
+func (s *Server) handleLightMask(w http.ResponseWriter, r *http.Request, key string) {
+	group, name := parseKey(key)
+	if group == "" || name == "" {
+		httpError(w, "Invalid path, use /api/lights/group/name/mask", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.state.Mask(group, name, r.Method == http.MethodPost); err != nil {
+		failed(w, err, key)
+		return
+	}
+	s.jsonResponse(w, map[string]string{"status": "ok"})
+}
+
 func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
 	s.jsonResponse(w, s.state.GetGroups())
 }
@@ -411,26 +836,42 @@ func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGroup(w http.ResponseWriter, r *http.Request) {
 	name := strings.TrimPrefix(r.URL.Path, "/api/groups/")
 	if name == "" {
-		http.Error(w, "Missing group name", http.StatusBadRequest)
+		httpError(w, "Missing group name", http.StatusBadRequest)
 		return
 	}
 
-	if r.Method == http.MethodPut {
+	switch r.Method {
+	case http.MethodPut:
 		var body map[string]interface{}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		values, err := parseValuesPct(body)
+		if err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		values := parseValues(body)
-		if err := s.state.SetGroup(name, values); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := s.source(r).SetGroup(name, values); err != nil {
+			failed(w, err, name)
 			return
 		}
 		s.jsonResponse(w, map[string]string{"status": "ok"})
-	} else {
-		lights := s.cfg.GetGroupLights(name)
+	case http.MethodPost:
+		var body struct {
+			Lights map[string][]config.Channel `json:"lights"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.provisioned(w, r, s.state.AddGroup(name, body.Lights))
+	case http.MethodDelete:
+		s.provisioned(w, r, s.state.RemoveGroup(name))
+	default:
+		lights := s.state.GetConfig().GetGroupLights(name)
 		if lights == nil {
-			http.Error(w, "Group not found", http.StatusNotFound)
+			failed(w, dmx.ErrGroupNotFound, name)
 			return
 		}
 		s.jsonResponse(w, map[string]interface{}{
@@ -455,29 +896,275 @@ func (s *Server) Addr() string {
 	return s.cfg.Server.HTTP
 }
 
-// SetScheduler sets the scheduler for API endpoints
+// SetScheduler sets the scheduler for API endpoints (nil when none, swapped on config changes)
 func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
-	s.scheduler = sched
+	s.scheduler.Store(sched)
+}
+
+// SetModbus sets the Modbus server for /api/modbus/map (nil when none, swapped on config changes)
+func (s *Server) SetModbus(srv *modbus.Server) {
+	s.modbus.Store(srv)
+}
+
+// handleModbusMap returns the Modbus register map as JSON, or CSV with ?format=csv or
+// Accept: text/csv
+func (s *Server) handleModbusMap(w http.ResponseWriter, r *http.Request) {
+	srv := s.modbus.Load()
+	if srv == nil {
+		httpError(w, "modbus not configured", http.StatusNotFound)
+		return
+	}
+	points := srv.Points()
+	if r.URL.Query().Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="modbus-map.csv"`)
+		modbus.WriteCSV(w, points)
+		return
+	}
+	s.jsonResponse(w, points)
 }
 
 func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
-	if s.scheduler == nil {
+	sched := s.scheduler.Load()
+	if sched == nil {
 		s.jsonResponse(w, map[string]interface{}{"events": []interface{}{}})
 		return
 	}
-	s.jsonResponse(w, map[string]interface{}{"events": s.scheduler.Events()})
+	resp := map[string]interface{}{"events": sched.Events()}
+	if name, ok := sched.Holiday(time.Now()); ok {
+		resp["holiday"] = map[string]string{"name": name} // Today runs the holiday program
+	}
+	s.jsonResponse(w, resp)
 }
 
 func (s *Server) handleScheduleNext(w http.ResponseWriter, r *http.Request) {
-	if s.scheduler == nil {
+	sched := s.scheduler.Load()
+	if sched == nil {
 		s.jsonResponse(w, nil)
 		return
 	}
-	next := s.scheduler.NextEvent()
-	if next != nil {
-		next.InStr = next.In.String()
+	s.jsonResponse(w, sched.NextEvent())
+}
+
+// handleScheduleUpcoming returns the next runs of the events (?count=N, default 10, max 100)
+func (s *Server) handleScheduleUpcoming(w http.ResponseWriter, r *http.Request) {
+	count := 10
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			httpError(w, "Invalid count: "+v, http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+	sched := s.scheduler.Load()
+	if sched == nil {
+		s.jsonResponse(w, []scheduler.NextEventInfo{})
+		return
+	}
+	s.jsonResponse(w, sched.Upcoming(count))
+}
+
+// handleSchedulePreview returns the timeline of a date without running it (?date=YYYY-MM-DD,
+// default today)
+func (s *Server) handleSchedulePreview(w http.ResponseWriter, r *http.Request) {
+	sched := s.scheduler.Load()
+	if sched == nil {
+		httpError(w, "scheduler not running", http.StatusNotFound)
+		return
+	}
+	date := r.URL.Query().Get("date")
+	preview, err := sched.Preview(date)
+	if err != nil {
+		httpError(w, "Invalid date (YYYY-MM-DD): "+date, http.StatusBadRequest)
+		return
+	}
+	s.jsonResponse(w, preview)
+}
+
+// handleCircadian returns circadian mode status (GET) or enables/disables it (POST {"enabled":false})
+func (s *Server) handleCircadian(w http.ResponseWriter, r *http.Request) {
+	sched := s.scheduler.Load()
+	if sched == nil || sched.Circadian() == nil {
+		httpError(w, "circadian mode not configured", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var body struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sched.SetCircadian(body.Enabled)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.jsonResponse(w, sched.Circadian())
+}
+
+// handlePhotoperiod returns photoperiod mode status (GET) or enables/disables it (POST {"enabled":false})
+func (s *Server) handlePhotoperiod(w http.ResponseWriter, r *http.Request) {
+	sched := s.scheduler.Load()
+	if sched == nil || sched.Photoperiod() == nil {
+		httpError(w, "photoperiod mode not configured", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var body struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sched.SetPhotoperiod(body.Enabled)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.jsonResponse(w, sched.Photoperiod())
+}
+
+// handleSchedulePause returns the pause status (GET) or pauses the scheduler (POST,
+// optional {"timeout_ms": 3600000} for an automatic resume)
+func (s *Server) handleSchedulePause(w http.ResponseWriter, r *http.Request) {
+	sched := s.scheduler.Load()
+	if sched == nil {
+		httpError(w, "scheduler not running", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var body struct {
+			TimeoutMs int `json:"timeout_ms"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF { // Body optional
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.TimeoutMs < 0 {
+			httpError(w, "timeout_ms must be positive", http.StatusBadRequest)
+			return
+		}
+		var until time.Time
+		if body.TimeoutMs > 0 {
+			until = time.Now().Add(time.Duration(body.TimeoutMs) * time.Millisecond)
+		}
+		sched.Pause(until)
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.jsonResponse(w, sched.PauseStatus())
+}
+
+// handleScheduleResume ends a scheduler pause (POST)
+func (s *Server) handleScheduleResume(w http.ResponseWriter, r *http.Request) {
+	sched := s.scheduler.Load()
+	if sched == nil {
+		httpError(w, "scheduler not running", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sched.Resume()
+	s.jsonResponse(w, sched.PauseStatus())
+}
+
+// SetFirmware enables the /api/firmware endpoints, to call before Start
+func (s *Server) SetFirmware(m *remoteproc.Manager) {
+	s.firmware = m
+}
+
+// handleFirmware returns the M-core remoteproc state and firmware identity
+func (s *Server) handleFirmware(w http.ResponseWriter, r *http.Request) {
+	if s.firmware == nil {
+		httpError(w, "remoteproc not configured", http.StatusNotFound)
+		return
+	}
+	s.jsonResponse(w, s.firmware.Info())
+}
+
+// handleFirmwareAction handles POST /api/firmware/{start,stop,reload}
+// Reload accepts an optional {"firmware":"name.elf"} body to switch images.
+func (s *Server) handleFirmwareAction(w http.ResponseWriter, r *http.Request) {
+	if s.firmware == nil {
+		httpError(w, "remoteproc not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	switch action := strings.TrimPrefix(r.URL.Path, "/api/firmware/"); action {
+	case "start":
+		err = s.firmware.Start()
+	case "stop":
+		err = s.firmware.Stop()
+	case "reload":
+		var body struct {
+			Firmware string `json:"firmware"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF { // Body optional
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = s.firmware.Reload(body.Firmware)
+	default:
+		httpError(w, "Unknown action: "+action, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httpError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Freshly booted firmware starts dark: restore output
+	if s.firmware.Info().State == "running" {
+		if err := s.state.Replay(); err != nil {
+			s.logger.Warn("Failed to replay frame after firmware start", "error", err)
+		}
+	}
+	s.jsonResponse(w, s.firmware.Info())
+}
+
+// handleBackend lists backends (GET) or switches the active backend (POST {"backend":"mock"})
+func (s *Server) handleBackend(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.jsonResponse(w, s.state.Backends())
+	case http.MethodPost:
+		var body struct {
+			Backend string `json:"backend"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(s.state.Backends().Available, body.Backend) {
+			httpError(w, "Unknown backend: "+body.Backend, http.StatusBadRequest)
+			return
+		}
+		if err := s.state.SwitchBackend(body.Backend); err != nil {
+			httpError(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		s.jsonResponse(w, s.state.Backends())
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
-	s.jsonResponse(w, next)
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -504,7 +1191,163 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		GCRuns:      m.NumGC,
 		GoVersion:   runtime.Version(),
 		NumCPU:      runtime.NumCPU(),
+		Backend:     s.state.BackendHealth(),
+	}
+	if s.firmware != nil {
+		info := s.firmware.Info()
+		health.Firmware = &info
 	}
 
 	s.jsonResponse(w, health)
 }
+
+func (s *Server) handleScenes(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, s.state.Scenes())
+}
+
+// handleScene handles /api/scenes/{name} (GET, POST save, DELETE) and POST /api/scenes/{name}/recall
+func (s *Server) handleScene(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/scenes/")
+	name, action, _ := strings.Cut(path, "/")
+	if name == "" {
+		httpError(w, "Missing scene name", http.StatusBadRequest)
+		return
+	}
+
+	if action == "recall" {
+		if r.Method != http.MethodPost {
+			httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var body struct {
+			FadeMs int `json:"fade_ms"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF { // Body optional
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.source(r).RecallScene(name, time.Duration(body.FadeMs)*time.Millisecond); err != nil {
+			failed(w, err, name)
+			return
+		}
+		s.jsonResponse(w, map[string]string{"status": "ok"})
+		return
+	}
+	if action != "" {
+		httpError(w, "Unknown action: "+action, http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sc := s.state.GetScene(name)
+		if sc == nil {
+			failed(w, dmx.ErrSceneNotFound, name)
+			return
+		}
+		s.jsonResponse(w, sc)
+	case http.MethodPost, http.MethodPut:
+		var body struct {
+			Targets []string `json:"targets"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF { // Body optional
+			httpError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sc, err := s.state.SaveScene(name, body.Targets)
+		if err != nil {
+			failed(w, err, name)
+			return
+		}
+		s.jsonResponse(w, sc)
+	case http.MethodDelete:
+		if err := s.state.DeleteScene(name); err != nil {
+			failed(w, err, name)
+			return
+		}
+		s.jsonResponse(w, map[string]string{"status": "ok"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handlePresets(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, s.state.Presets())
+}
+
+// handleCues returns cue lists with their playback position
+func (s *Server) handleCues(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, s.state.CueLists())
+}
+
+// handleShows returns the running show progress and available shows
+func (s *Server) handleShows(w http.ResponseWriter, r *http.Request) {
+	s.jsonResponse(w, s.state.Shows())
+}
+
+// handleRecordings returns recorder/player state and stored recordings
+func (s *Server) handleRecordings(w http.ResponseWriter, r *http.Request) {
+	status, err := s.state.Recorder()
+	if err != nil {
+		failed(w, err, "")
+		return
+	}
+	s.jsonResponse(w, status)
+}
+
+// handleArbitration returns merge policy and channel owners (GET) or releases a source (POST {"release":"mqtt"})
+func (s *Server) handleArbitration(w http.ResponseWriter, r *http.Request) {
+	info := s.state.Arbitration()
+	if info == nil {
+		httpError(w, "arbitration not configured", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.jsonResponse(w, info)
+	case http.MethodPost:
+		var body struct {
+			Release string `json:"release"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Release == "" {
+			httpError(w, "Expected {\"release\":\"<source>\"}", http.StatusBadRequest)
+			return
+		}
+		s.state.ReleaseSource(body.Release)
+		s.jsonResponse(w, s.state.Arbitration())
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCrossfade returns scene crossfade progress (GET) or aborts it (DELETE)
+func (s *Server) handleCrossfade(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.jsonResponse(w, s.state.Crossfade())
+	case http.MethodDelete:
+		if !s.state.AbortCrossfade() {
+			httpError(w, "No crossfade in progress", http.StatusConflict)
+			return
+		}
+		s.jsonResponse(w, map[string]string{"status": "ok"})
+	default:
+		httpError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// provisioned reports the result of a light/group change, saving lights to the config file with ?persist=true
+func (s *Server) provisioned(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		failed(w, err, "")
+		return
+	}
+
+	if r.URL.Query().Get("persist") == "true" {
+		if err := s.state.SaveLights(); err != nil {
+			httpError(w, "Applied but not persisted: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	s.jsonResponse(w, map[string]string{"status": "ok"})
+}
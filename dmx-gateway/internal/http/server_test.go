@@ -4,16 +4,41 @@
 package http
 
 import (
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
 	"log/slog"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+
+	"dmx-gateway/internal/api"
 	"dmx-gateway/internal/config"
 	"dmx-gateway/internal/dmx"
+	"dmx-gateway/internal/modbus"
+	"dmx-gateway/internal/scheduler"
 )
 
 func testConfig() *config.Config {
@@ -108,6 +133,66 @@ func TestHandleLights(t *testing.T) {
 	}
 }
 
+func TestHandleLightsQuery(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["rack2"] = map[string][]config.Channel{
+		"level1": {{Ch: 10, Color: "red"}},
+		"level2": {{Ch: 11, Color: "red"}},
+	}
+	cfg.Tags = map[string][]string{"veg": {"rack2", "rack1/level2"}}
+	cfg.Metadata = map[string]config.LightMeta{
+		"rack2":        {Location: "Greenhouse 2"},
+		"rack2/level2": {Location: "Greenhouse 2, Row 4", Notes: "New driver"},
+	}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	get := func(query string) (int, []string, string) {
+		req := httptest.NewRequest("GET", "/api/lights"+query, nil)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		var result map[string]dmx.LightState
+		json.Unmarshal(w.Body.Bytes(), &result)
+		keys := make([]string, 0, len(result))
+		for key := range result {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		return w.Code, keys, w.Header().Get("X-Total-Count")
+	}
+
+	for _, tc := range []struct {
+		query string
+		keys  []string
+		total string
+	}{
+		{"", []string{"rack1/level1", "rack1/level2", "rack2/level1", "rack2/level2"}, "4"},
+		{"?group=rack2", []string{"rack2/level1", "rack2/level2"}, "2"},
+		{"?tag=veg", []string{"rack1/level2", "rack2/level1", "rack2/level2"}, "3"},
+		{"?tag=veg&group=rack1", []string{"rack1/level2"}, "1"},
+		{"?offset=1&limit=2", []string{"rack1/level2", "rack2/level1"}, "4"},
+		{"?tag=veg&offset=2", []string{"rack2/level2"}, "3"},
+		{"?offset=9", []string{}, "4"},
+		{"?location=greenhouse%202", []string{"rack2/level1", "rack2/level2"}, "2"},
+		{"?location=row%204", []string{"rack2/level2"}, "1"},
+	} {
+		code, keys, total := get(tc.query)
+		if code != http.StatusOK || !slices.Equal(keys, tc.keys) || total != tc.total {
+			t.Errorf("%q: expected %v (total %s), got %d %v (total %s)", tc.query, tc.keys, tc.total, code, keys, total)
+		}
+	}
+	if code, _, _ := get("?limit=-1"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a negative limit, got %d", code)
+	}
+	if light := state.GetLight("rack2", "level1"); !slices.Equal(light.Tags, []string{"veg"}) {
+		t.Errorf("expected tags in light state, got %v", light.Tags)
+	}
+	if light := state.GetLight("rack2", "level2"); light.Location != "Greenhouse 2, Row 4" || light.Notes != "New driver" || !slices.Equal(light.Tags, []string{"veg"}) {
+		t.Errorf("expected metadata in light state, got %q %q %v", light.Location, light.Notes, light.Tags)
+	}
+}
+
 func TestHandleLightGet(t *testing.T) {
 	server := setupServer(t)
 
@@ -281,3 +366,1337 @@ func TestParseKey(t *testing.T) {
 		t.Error("expected empty for invalid key")
 	}
 }
+
+func TestHandleBackendSwitch(t *testing.T) {
+	server := setupServer(t)
+	server.state.RegisterBackend(dmx.BackendMock, dmx.NewMockClient())
+
+	req := httptest.NewRequest("POST", "/api/backend", strings.NewReader(`{"backend":"mock"}`))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var info dmx.BackendInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if info.Active != dmx.BackendMock {
+		t.Errorf("expected active backend 'mock', got %q", info.Active)
+	}
+
+	// Unknown backend
+	req = httptest.NewRequest("POST", "/api/backend", strings.NewReader(`{"backend":"dmx-over-carrier-pigeon"}`))
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestAPISetColor(t *testing.T) {
+	cfg := testConfig()
+	cfg.Lights["strip"] = map[string][]config.Channel{
+		"rgb": {
+			{Ch: 10, Color: "red"},
+			{Ch: 11, Color: "green"},
+			{Ch: 12, Color: "blue"},
+		},
+	}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	post := func(body string) map[string]interface{} {
+		req := httptest.NewRequest("POST", "/api", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		var resp map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := post(`{"cmd":"set","target":"strip/rgb","color":"#FF8800"}`); resp["type"] != "ok" {
+		t.Fatalf("hex color: %v", resp)
+	}
+	if ch := state.GetChannels(); ch[9] != 255 || ch[10] != 136 || ch[11] != 0 {
+		t.Errorf("expected 255/136/0, got %d/%d/%d", ch[9], ch[10], ch[11])
+	}
+
+	if resp := post(`{"cmd":"set","target":"strip","h":240,"s":100,"v":50}`); resp["type"] != "ok" {
+		t.Fatalf("hsv color: %v", resp)
+	}
+	if ch := state.GetChannels(); ch[9] != 0 || ch[10] != 0 || ch[11] != 128 {
+		t.Errorf("expected 0/0/128, got %d/%d/%d", ch[9], ch[10], ch[11])
+	}
+
+	// rack1 lights have no green channel
+	if resp := post(`{"cmd":"set","target":"rack1","color":"#FFFFFF"}`); resp["type"] != "error" {
+		t.Errorf("expected error for non-RGB target, got %v", resp)
+	}
+}
+
+func TestHandleLightProvisioning(t *testing.T) {
+	server := setupServer(t)
+
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("POST", "/api/lights/rack2/level1", `{"channels":[{"ch":20,"color":"red"},{"ch":21,"color":"blue","fine_ch":22}]}`); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if light := server.state.GetLight("rack2", "level1"); light == nil || light.Channels[1].FineCh != 22 {
+		t.Fatalf("expected light added with fine channel, got %+v", light)
+	}
+	if code := do("POST", "/api/lights/rack2/level1", `{"channels":[{"ch":30,"color":"red"}]}`); code != http.StatusConflict {
+		t.Errorf("expected status 409 for existing light, got %d", code)
+	}
+	if code := do("POST", "/api/lights/rack2/level2", `{"channels":[{"ch":22,"color":"red"}]}`); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for channel conflict, got %d", code)
+	}
+	if code := do("DELETE", "/api/groups/rack2", ""); code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", code)
+	}
+	if code := do("DELETE", "/api/lights/rack2/level1", ""); code != http.StatusNotFound {
+		t.Errorf("expected status 404 after group removal, got %d", code)
+	}
+	if code := do("POST", "/api/groups/rack3", `{"lights":{"a":[{"ch":40,"color":"white"}]}}`); code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", code)
+	}
+	// Config not loaded from a file: applied but cannot persist
+	if code := do("DELETE", "/api/lights/rack3/a?persist=true", ""); code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 without a config file, got %d", code)
+	}
+}
+
+func TestHandleLightMask(t *testing.T) {
+	server := setupServer(t)
+
+	req := httptest.NewRequest("POST", "/api/lights/rack1/level1/mask", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !server.state.GetLight("rack1", "level1").Masked {
+		t.Error("expected light masked")
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/lights/rack1/nope/mask", nil)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown light, got %d", w.Code)
+	}
+}
+
+func TestHandleHistory(t *testing.T) {
+	server := setupServer(t)
+
+	req := httptest.NewRequest("PUT", "/api/lights/rack1/level1", strings.NewReader(`{"blue":100}`))
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("GET", "/api/history?source=http&limit=10", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var entries []dmx.HistoryEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Target != "rack1/level1" || entries[0].Action != "set" {
+		t.Errorf("expected the light write, got %+v", entries)
+	}
+
+	req = httptest.NewRequest("GET", "/api/history?since=yesterday", nil)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid since, got %d", w.Code)
+	}
+}
+
+func TestHandleChannelMap(t *testing.T) {
+	server := setupServer(t)
+	_ = server.state.SetChannel(2, 90)
+
+	req := httptest.NewRequest("GET", "/api/channels/map", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var channels []dmx.ChannelInfo
+	if err := json.NewDecoder(w.Body).Decode(&channels); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(channels) != 512 {
+		t.Fatalf("expected 512 channels, got %d", len(channels))
+	}
+	ch2 := channels[1]
+	if !ch2.Patched || len(ch2.Lights) != 1 || ch2.Lights[0].Light != "rack1/level1" || ch2.Value != 90 || ch2.Output != 90 {
+		t.Errorf("expected ch2 patched to rack1/level1 at 90, got %+v", ch2)
+	}
+	if channels[99].Patched {
+		t.Errorf("expected ch100 unpatched, got %+v", channels[99])
+	}
+}
+
+func TestHandleLightPercent(t *testing.T) {
+	server := setupServer(t)
+
+	req := httptest.NewRequest("PUT", "/api/lights/rack1/level1", strings.NewReader(`{"red":10,"values_pct":{"blue":50,"red":100}}`))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if light := server.state.GetLight("rack1", "level1"); light.Values["blue"] != 128 || light.Values["red"] != 10 {
+		t.Errorf("expected blue=128 and explicit red=10, got %v", light.Values)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/groups/rack1", strings.NewReader(`{"values_pct":{"blue":150}}`))
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for percent out of range, got %d", w.Code)
+	}
+}
+
+func TestAuthAPIKeys(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth = &config.AuthConfig{Keys: []config.APIKey{
+		{Name: "bms", Key: "r-key", Scope: "read"},
+		{Name: "console", Key: "w-key", Scope: "write"},
+	}}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	do := func(method, path, key, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, tc := range []struct {
+		method, path, key, body string
+		want                    int
+	}{
+		{"GET", "/api/status", "", "", http.StatusUnauthorized},
+		{"GET", "/api/status", "wrong", "", http.StatusUnauthorized},
+		{"GET", "/api/health", "", "", http.StatusOK},
+		{"GET", "/api/status", "r-key", "", http.StatusOK},
+		{"GET", "/api/status?api_key=r-key", "", "", http.StatusOK},
+		{"POST", "/api/blackout", "r-key", "", http.StatusForbidden},
+		{"POST", "/api", "r-key", `{"cmd":"status"}`, http.StatusOK},
+		{"POST", "/api", "r-key", `{"cmd":"blackout"}`, http.StatusForbidden},
+		{"POST", "/api/blackout", "w-key", "", http.StatusOK},
+		{"POST", "/api", "w-key", `{"cmd":"blackout"}`, http.StatusOK},
+		{"POST", "/api/schedule/pause", "w-key", "", http.StatusForbidden},
+		{"POST", "/api/schedule/events/1/run", "w-key", "", http.StatusNotFound}, // Allowed, no such event
+		{"POST", "/api/schedule/events/1/run", "r-key", "", http.StatusForbidden},
+	} {
+		if code := do(tc.method, tc.path, tc.key, tc.body); code != tc.want {
+			t.Errorf("%s %s key=%q %s: expected %d, got %d", tc.method, tc.path, tc.key, tc.body, tc.want, code)
+		}
+	}
+}
+
+func TestAuthJWTRoles(t *testing.T) {
+	const secret = "0123456789abcdef"
+	cfg := testConfig()
+	cfg.Auth = &config.AuthConfig{JWT: &config.JWTConfig{Secret: secret, Issuer: "idp", RoleClaim: "role"}}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	sign := func(alg string, claims map[string]any, key string) string {
+		enc := func(v any) string {
+			data, _ := json.Marshal(v)
+			return base64.RawURLEncoding.EncodeToString(data)
+		}
+		unsigned := enc(map[string]string{"alg": alg, "typ": "JWT"}) + "." + enc(claims)
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(unsigned))
+		return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	token := func(role any) string {
+		return sign("HS256", map[string]any{"iss": "idp", "role": role, "exp": time.Now().Add(time.Hour).Unix()}, secret)
+	}
+	do := func(method, path, token, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	expired := sign("HS256", map[string]any{"iss": "idp", "role": "admin", "exp": time.Now().Add(-time.Minute).Unix()}, secret)
+	for _, tc := range []struct {
+		method, path, token, body string
+		want                      int
+	}{
+		{"GET", "/api/status", "", "", http.StatusUnauthorized},
+		{"GET", "/api/status", expired, "", http.StatusUnauthorized},
+		{"GET", "/api/status", sign("HS256", map[string]any{"iss": "idp", "role": "admin"}, "wrong-secret-wrong"), "", http.StatusUnauthorized},
+		{"GET", "/api/status", sign("HS256", map[string]any{"iss": "other", "role": "admin"}, secret), "", http.StatusUnauthorized},
+		{"GET", "/api/status", sign("none", map[string]any{"iss": "idp", "role": "admin"}, secret), "", http.StatusUnauthorized},
+		{"GET", "/api/status", token("guest"), "", http.StatusUnauthorized},
+		{"GET", "/api/lights", token("viewer"), "", http.StatusOK},
+		{"PUT", "/api/lights/rack1/level1", token("viewer"), `{"blue":10}`, http.StatusForbidden},
+		{"PUT", "/api/lights/rack1/level1", token("operator"), `{"blue":10}`, http.StatusOK},
+		{"POST", "/api", token("operator"), `{"cmd":"set","target":"rack1","values":{"blue":5}}`, http.StatusOK},
+		{"POST", "/api", token("operator"), `{"cmd":"disable"}`, http.StatusForbidden},
+		{"POST", "/api/enable", token("operator"), "", http.StatusForbidden},
+		{"DELETE", "/api/lights/rack1/level2", token("operator"), "", http.StatusForbidden},
+		{"POST", "/api/enable", token([]any{"viewer", "admin"}), "", http.StatusOK},
+		{"POST", "/api", token("admin"), `{"cmd":"disable"}`, http.StatusOK},
+	} {
+		if code := do(tc.method, tc.path, tc.token, tc.body); code != tc.want {
+			t.Errorf("%s %s %s: expected %d, got %d", tc.method, tc.path, tc.body, tc.want, code)
+		}
+	}
+}
+
+// writeCert creates a key pair signed by parent (self-signed when nil) and writes it as PEM files
+func writeCert(t *testing.T, dir, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certPath, keyPath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return cert, key, certPath, keyPath
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPath, _ := writeCert(t, dir, "ca", true, nil, nil)
+	_, _, certPath, keyPath := writeCert(t, dir, "server", false, ca, caKey)
+	_, _, clientCert, clientKey := writeCert(t, dir, "bms", false, ca, caKey)
+	_, _, rogueCert, rogueKey := writeCert(t, dir, "rogue", false, nil, nil)
+
+	cfg := testConfig()
+	cfg.Server.TLS = &config.TLSConfig{Cert: certPath, Key: keyPath, ClientCA: caPath}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	tlsConfig, err := server.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(server.server.Handler)
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(certFile, keyFile string) error {
+		tc := &tls.Config{RootCAs: roots}
+		if certFile != "" {
+			pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.Certificates = []tls.Certificate{pair}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tc}}
+		resp, err := client.Get(ts.URL + "/api/status")
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(clientCert, clientKey); err != nil {
+		t.Errorf("expected client with a CA-signed certificate accepted, got %v", err)
+	}
+	if err := get("", ""); err == nil {
+		t.Error("expected client without certificate rejected")
+	}
+	if err := get(rogueCert, rogueKey); err == nil {
+		t.Error("expected client with an unknown certificate rejected")
+	}
+
+	cfg.Server.TLS.ClientCA = filepath.Join(dir, "missing.pem")
+	if _, err := server.tlsConfig(); err == nil {
+		t.Error("expected error for missing client CA")
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.AllowedOrigins = []string{"https://bms.example"}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	do := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/api/status", "https://evil.example"); w.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for disallowed origin, got %d", w.Code)
+	}
+	if w := do("GET", "/api/status", "http://example.com"); w.Code != http.StatusOK {
+		t.Errorf("expected same origin allowed, got %d", w.Code) // httptest requests target example.com
+	}
+	if w := do("GET", "/api/status", ""); w.Code != http.StatusOK {
+		t.Errorf("expected requests without origin allowed, got %d", w.Code)
+	}
+	w := do("GET", "/api/status", "https://bms.example")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://bms.example" {
+		t.Errorf("expected CORS header for allowed origin, got %d %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	w = do("OPTIONS", "/api/lights/rack1/level1", "https://bms.example")
+	if w.Code != http.StatusNoContent || !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), "PUT") {
+		t.Errorf("expected preflight answered, got %d %q", w.Code, w.Header().Get("Access-Control-Allow-Methods"))
+	}
+	if w := do("GET", "/ws", "https://evil.example"); w.Code != http.StatusForbidden {
+		t.Errorf("expected WebSocket upgrade refused for disallowed origin, got %d", w.Code)
+	}
+
+	cfg.Server.AllowAnyOrigin = true
+	if w := do("GET", "/api/status", "https://evil.example"); w.Code != http.StatusOK {
+		t.Errorf("expected any origin allowed in dev mode, got %d", w.Code)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.RateLimit = &config.RateLimitConfig{RPS: 1, Burst: 2}
+	cfg.Auth = &config.AuthConfig{Keys: []config.APIKey{{Name: "bms", Key: "k", Scope: "read"}}}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	do := func(remote, key string) int {
+		req := httptest.NewRequest("GET", "/api/status", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The key is one client whatever its address
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := do(fmt.Sprintf("10.0.0.%d:1234", i+1), "k"); code != want {
+			t.Errorf("request %d: expected %d, got %d", i+1, want, code)
+		}
+	}
+	// Without a valid key clients are told apart by address (and still rejected by auth)
+	if code := do("10.0.0.9:1234", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("expected another client unaffected, got %d", code)
+	}
+	// Static files are not limited
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code == http.StatusTooManyRequests {
+		t.Error("expected web UI not rate limited")
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Enum []string `json:"enum"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	raw := w.Body.String()
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("expected OpenAPI 3, got %q", doc.OpenAPI)
+	}
+	for path, method := range map[string]string{"/api": "post", "/api/lights/{group}/{name}": "put", "/api/history": "get", "/api/scenes/{name}/recall": "post"} {
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("missing %s %s", method, path)
+		}
+	}
+
+	// Every reference resolves
+	for _, m := range regexp.MustCompile(`"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(raw, -1) {
+		if _, ok := doc.Components.Schemas[m[1]]; !ok {
+			t.Errorf("dangling reference to %s", m[1])
+		}
+	}
+
+	// The cmd enum only lists commands the handler knows
+	cmds := doc.Components.Schemas["Request"].Properties["cmd"].Enum
+	if len(cmds) == 0 {
+		t.Fatal("expected a cmd enum on the Request schema")
+	}
+	handler := api.NewHandler(state, dmx.SourceHTTP)
+	for _, cmd := range cmds {
+		if resp := handler.Handle(&api.Request{Cmd: cmd}); resp.Error != nil && strings.HasPrefix(resp.Error.Message, "unknown command") {
+			t.Errorf("enum lists unknown command %q", cmd)
+		}
+	}
+	if _, ok := doc.Components.Schemas["LightState"]; !ok {
+		t.Error("expected a LightState schema")
+	}
+}
+
+func TestModbusMap(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/modbus/map", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("without modbus: expected status 404, got %d", w.Code)
+	}
+
+	server.SetModbus(modbus.NewServer(&modbus.Config{}, state, logger))
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/modbus/map", nil))
+	var points []modbus.MapPoint
+	if err := json.Unmarshal(w.Body.Bytes(), &points); err != nil || len(points) == 0 {
+		t.Fatalf("JSON map: %v (%d points)", err, len(points))
+	}
+
+	req := httptest.NewRequest("GET", "/api/modbus/map", nil)
+	req.Header.Set("Accept", "text/csv")
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("expected CSV, got %q", ct)
+	}
+	if lines := strings.Count(w.Body.String(), "\n"); lines != len(points)+1 {
+		t.Errorf("expected %d CSV lines, got %d", len(points)+1, lines)
+	}
+}
+
+func TestRESTv2(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	do := func(method, path, body string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		var env map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+			t.Fatalf("%s %s: not an envelope: %s", method, path, w.Body.String())
+		}
+		return w.Code, env
+	}
+
+	// PATCH changes the channels given, PUT zeroes the others
+	if code, _ := do("PATCH", "/api/v2/lights/rack1/level1", `{"values":{"blue":100,"red":50}}`); code != http.StatusOK {
+		t.Fatalf("PATCH light: expected 200, got %d", code)
+	}
+	code, env := do("PUT", "/api/v2/lights/rack1/level1", `{"values_pct":{"red":100}}`)
+	if code != http.StatusOK {
+		t.Fatalf("PUT light: expected 200, got %d", code)
+	}
+	var light dmx.LightState
+	json.Unmarshal(env["data"], &light)
+	if light.Values["red"] != 255 || light.Values["blue"] != 0 {
+		t.Errorf("expected red 255 and blue 0 after PUT, got %v", light.Values)
+	}
+
+	// Validation and lookup failures use the error envelope
+	for _, tc := range []struct {
+		method, path, body, code string
+		status                   int
+	}{
+		{"GET", "/api/v2/lights/rack1/nope", "", "not_found", http.StatusNotFound},
+		{"PATCH", "/api/v2/lights/rack1/level1", `{"values":{"red":300}}`, "bad_request", http.StatusBadRequest},
+		{"PATCH", "/api/v2/groups/rack1", `{"colour":"red"}`, "bad_request", http.StatusBadRequest},
+		{"DELETE", "/api/v2/channels/3", "", "method_not_allowed", http.StatusMethodNotAllowed},
+		{"PUT", "/api/v2/channels/513", `{"value":1}`, "not_found", http.StatusNotFound},
+		{"PATCH", "/api/v2/scenes/missing", `{"lights":{"rack1/level2":{"white":1}}}`, "not_found", http.StatusNotFound},
+	} {
+		code, env := do(tc.method, tc.path, tc.body)
+		var e api.Error
+		json.Unmarshal(env["error"], &e)
+		if code != tc.status || e.Code != tc.code {
+			t.Errorf("%s %s: expected %d %s, got %d %+v", tc.method, tc.path, tc.status, tc.code, code, e)
+		}
+	}
+
+	// Channels
+	code, env = do("PUT", "/api/v2/channels/3", `{"value":77}`)
+	var ch dmx.ChannelInfo
+	json.Unmarshal(env["data"], &ch)
+	if code != http.StatusOK || ch.Ch != 3 || ch.Value != 77 {
+		t.Errorf("expected channel 3 at 77, got %d %+v", code, ch)
+	}
+
+	// Scenes: PUT stores explicit values, PATCH merges, recall applies
+	if code, _ := do("PUT", "/api/v2/scenes/look", `{"lights":{"rack1/level1":{"red":10}}}`); code != http.StatusOK {
+		t.Fatalf("PUT scene: expected 200, got %d", code)
+	}
+	code, env = do("PATCH", "/api/v2/scenes/look", `{"lights":{"rack1/level2":{"white":20}}}`)
+	var sc dmx.Scene
+	json.Unmarshal(env["data"], &sc)
+	if code != http.StatusOK || sc.Lights["rack1/level1"]["red"] != 10 || sc.Lights["rack1/level2"]["white"] != 20 {
+		t.Errorf("expected merged scene, got %d %+v", code, sc)
+	}
+	if code, _ := do("PUT", "/api/v2/scenes/bad", `{"lights":{"rack1/level2":{"uv":1}}}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown channel, got %d", code)
+	}
+	if code, _ := do("POST", "/api/v2/scenes/look/recall", ""); code != http.StatusOK {
+		t.Fatalf("recall: expected 200, got %d", code)
+	}
+	if v := state.GetLight("rack1", "level2").Values["white"]; v != 20 {
+		t.Errorf("expected white 20 after recall, got %d", v)
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.WSPingSec = 1
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	ts := httptest.NewServer(NewServer(cfg, state, logger).server.Handler)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	// A client that answers pings (gorilla does while reading) stays connected
+	live, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer live.Close()
+	go func() {
+		for {
+			if _, _, err := live.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// A suspended client (never reads, so never pongs) is dropped after two intervals
+	dead, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer dead.Close()
+	time.Sleep(2500 * time.Millisecond)
+
+	dead.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		_, _, err := dead.ReadMessage()
+		if err == nil {
+			continue
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			t.Fatal("expected the silent client to be disconnected")
+		}
+		break
+	}
+	if err := live.WriteMessage(websocket.TextMessage, []byte(`{"cmd":"status"}`)); err != nil {
+		t.Errorf("expected the answering client to stay connected: %v", err)
+	}
+}
+
+func TestWebSocketAuth(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth = &config.AuthConfig{Keys: []config.APIKey{{Name: "tablet", Key: "r-key", Scope: "read"}}, WSGraceSec: 1}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	ts := httptest.NewServer(NewServer(cfg, state, logger).server.Handler)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	read := func(conn *websocket.Conn) (map[string]any, error) {
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		var msg map[string]any
+		err := conn.ReadJSON(&msg)
+		return msg, err
+	}
+
+	// Key at upgrade: init right away; wrong key: upgrade refused
+	header := http.Header{"X-API-Key": {"r-key"}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if msg, err := read(conn); err != nil || msg["type"] != "init" {
+		t.Errorf("expected init, got %v %v", msg, err)
+	}
+	conn.Close()
+	if _, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"X-API-Key": {"nope"}}); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong key at upgrade, got %v", err)
+	}
+
+	// First-message handshake: ok with the role, then init
+	conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn.WriteJSON(map[string]string{"cmd": "auth", "api_key": "r-key"})
+	if msg, err := read(conn); err != nil || msg["type"] != "ok" {
+		t.Errorf("expected ok, got %v %v", msg, err)
+	}
+	if msg, err := read(conn); err != nil || msg["type"] != "init" {
+		t.Errorf("expected init after auth, got %v %v", msg, err)
+	}
+	conn.Close()
+
+	// Wrong credentials, another command first, or silence: an error, then closed
+	for name, first := range map[string]any{
+		"wrong key": map[string]string{"cmd": "auth", "api_key": "nope"},
+		"no auth":   map[string]string{"cmd": "status"},
+		"silent":    nil,
+	} {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		if first != nil {
+			conn.WriteJSON(first)
+		}
+		if msg, err := read(conn); err != nil || msg["type"] != "error" {
+			t.Errorf("%s: expected an error, got %v %v", name, msg, err)
+		}
+		if _, err := read(conn); !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Errorf("%s: expected the socket closed, got %v", name, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestHandleChannels(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("PUT", "/api/channels", `{"start":2,"values":[10,20,30]}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	if v := server.state.GetLight("rack1", "level1").Values["red"]; v != 10 {
+		t.Errorf("expected light views updated (red 10), got %d", v)
+	}
+
+	w := do("GET", "/api/channels?start=1&count=4", "")
+	var got channelRange
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Start != 1 || fmt.Sprint(got.Values) != "[0 10 20 30]" {
+		t.Errorf("expected [0 10 20 30] from 1, got %+v", got)
+	}
+	w = do("GET", "/api/channels?start=500", "")
+	if json.NewDecoder(w.Body).Decode(&got); len(got.Values) != 13 {
+		t.Errorf("expected channels 500-512, got %d values", len(got.Values))
+	}
+
+	for _, tc := range []struct{ method, path, body string }{
+		{"GET", "/api/channels?start=0", ""},
+		{"GET", "/api/channels?start=510&count=4", ""},
+		{"PUT", "/api/channels", `{"start":511,"values":[1,2,3]}`},
+		{"PUT", "/api/channels", `{"start":1,"values":[256]}`},
+		{"PUT", "/api/channels", `{"start":1,"values":[]}`},
+	} {
+		if w := do(tc.method, tc.path, tc.body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s %s: expected 400, got %d", tc.method, tc.path, tc.body, w.Code)
+		}
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `dmx:
+  client: mock # Keep me
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+	var applied []string
+	server.OnConfigChange(func(_ *config.Config, changed []string) error {
+		applied = changed
+		return nil
+	})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("PUT", "/api/config/lights", `{"rack1": {"level1": [{"ch": 1, "color": "blue"}], "level2": [{"ch": 5, "color": "red"}]}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT section: expected 200, got %d %s", w.Code, w.Body.String())
+	}
+	var update ConfigUpdate
+	json.NewDecoder(w.Body).Decode(&update)
+	if fmt.Sprint(update.Changed) != "[lights]" || len(update.RestartRequired) != 0 || fmt.Sprint(applied) != "[lights]" {
+		t.Errorf("unexpected update %+v (hook saw %v)", update, applied)
+	}
+	if state.GetLight("rack1", "level2") == nil {
+		t.Error("expected new light applied live")
+	}
+	saved, _ := os.ReadFile(path)
+	if !strings.Contains(string(saved), "level2") || !strings.Contains(string(saved), "# Keep me") {
+		t.Errorf("expected lights saved and other comments kept, got:\n%s", saved)
+	}
+
+	w = do("PUT", "/api/config", "dmx:\n  client: mock\n  fps: 30\nlights:\n  rack1:\n    level1:\n      - { ch: 1, color: blue }\n")
+	json.NewDecoder(w.Body).Decode(&update)
+	if w.Code != http.StatusOK || fmt.Sprint(update.Changed) != "[dmx lights]" || fmt.Sprint(update.RestartRequired) != "[dmx]" {
+		t.Errorf("PUT config: unexpected %d %+v", w.Code, update)
+	}
+	if state.GetLight("rack1", "level2") != nil {
+		t.Error("expected removed light gone")
+	}
+
+	if w := do("GET", "/api/config/lights", ""); !strings.Contains(w.Body.String(), `"level1"`) {
+		t.Errorf("GET section: unexpected %s", w.Body.String())
+	}
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{"PUT", "/api/config/lights", `{}`, http.StatusBadRequest},
+		{"PUT", "/api/config", `{"bogus": 1}`, http.StatusBadRequest},
+		{"GET", "/api/config/bogus", "", http.StatusNotFound},
+		{"DELETE", "/api/config", "", http.StatusMethodNotAllowed},
+	} {
+		if w := do(tc.method, tc.path, tc.body); w.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.path, tc.code, w.Code)
+		}
+	}
+	if state.GetLight("rack1", "level1") == nil {
+		t.Error("expected rejected updates to leave the config unchanged")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("dmx: { client: mock }\nlights:\n  rack1:\n    level1: [{ ch: 1, color: blue }]\n")
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+	_ = state.SetLight("rack1", "level1", map[string]uint8{"blue": 120})
+
+	reload := func() (*httptest.ResponseRecorder, ConfigUpdate) {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("POST", "/api/reload", nil))
+		var update ConfigUpdate
+		json.NewDecoder(w.Body).Decode(&update)
+		return w, update
+	}
+
+	write("dmx: { client: mock }\nlights:\n  rack1:\n    level1: [{ ch: 1, color: blue }]\n    level2: [{ ch: 2, color: red }]\n")
+	w, update := reload()
+	if w.Code != http.StatusOK || fmt.Sprint(update.Diff) != "[{lights.rack1.level2 added}]" {
+		t.Fatalf("reload: unexpected %d %+v", w.Code, update)
+	}
+	if state.GetLight("rack1", "level2") == nil || state.GetLight("rack1", "level1").Values["blue"] != 120 {
+		t.Error("expected new light added and existing light values kept")
+	}
+
+	write("lights: {}\n")
+	if w, _ := reload(); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid file: expected 400, got %d", w.Code)
+	}
+	if state.GetLight("rack1", "level2") == nil {
+		t.Error("expected a failed reload to keep the running config")
+	}
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth = &config.AuthConfig{Keys: []config.APIKey{
+		{Name: "viewer", Key: "r", Scope: "read"},
+		{Name: "admin", Key: "a", Scope: "admin"},
+	}}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	get := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("/debug/vars", "a"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 while disabled, got %d", w.Code)
+	}
+	server.EnableDebug()
+	if w := get("/debug/vars", "a"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "memstats") {
+		t.Errorf("expected expvar memstats, got %d", w.Code)
+	}
+	if w := get("/debug/pprof/", "a"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("expected pprof index, got %d", w.Code)
+	}
+	if w := get("/debug/pprof/heap", "a"); w.Code != http.StatusOK {
+		t.Errorf("expected heap profile, got %d", w.Code)
+	}
+	if w := get("/debug/vars", "r"); w.Code != http.StatusForbidden {
+		t.Errorf("expected viewer rejected, got %d", w.Code)
+	}
+	if w := get("/debug/vars", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected anonymous rejected, got %d", w.Code)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	cfg := testConfig()
+	big := make(map[string][]config.Channel)
+	for i := 0; i < 50; i++ {
+		big[fmt.Sprintf("level%d", i)] = []config.Channel{{Ch: 10 + i, Color: "red"}}
+	}
+	cfg.Lights["rack2"] = big
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	get := func(path, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	plain := get("/api/lights", "")
+	if plain.Header().Get("Content-Encoding") != "" || plain.Body.Len() < gzipMinSize {
+		t.Fatalf("expected a large plain response, got %q (%d bytes)", plain.Header().Get("Content-Encoding"), plain.Body.Len())
+	}
+	w := get("/api/lights", "br, gzip;q=0.8")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("expected gzipped JSON, got %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if string(body) != plain.Body.String() {
+		t.Error("expected the same body once decompressed")
+	}
+
+	if w := get("/api/status", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		t.Error("expected small responses sent as is")
+	}
+	if w := get("/api/lights", "gzip;q=0"); w.Header().Get("Content-Encoding") != "" {
+		t.Error("expected gzip;q=0 honoured")
+	}
+
+	cfg.Server.Compression = "off"
+	server = NewServer(cfg, state, logger)
+	if w := get("/api/lights", "gzip"); w.Header().Get("Content-Encoding") != "" {
+		t.Error("expected no compression with compression: off")
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	do := func(method, path, body string) (int, api.Error) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s %s: expected JSON, got %q", method, path, ct)
+		}
+		var resp struct {
+			Error api.Error `json:"error"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Error
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		code, target       string
+	}{
+		{"GET", "/api/enable", "", http.StatusMethodNotAllowed, api.CodeMethodNotAllowed, ""},
+		{"PUT", "/api/lights/rack1/nope", `{"red":1}`, http.StatusNotFound, api.CodeNotFound, "rack1/nope"},
+		{"PUT", "/api/groups/nope", `{"red":1}`, http.StatusNotFound, api.CodeNotFound, "nope"},
+		{"PUT", "/api/lights/rack1/level1", `{"red":`, http.StatusBadRequest, api.CodeBadRequest, ""},
+		{"POST", "/api/lights/rack1/level1", `{"channels":[{"ch":30,"color":"red"}]}`, http.StatusConflict, api.CodeConflict, ""},
+		{"POST", "/api/scenes/missing/recall", "", http.StatusNotFound, api.CodeNotFound, "missing"},
+		{"GET", "/api/recordings", "", http.StatusServiceUnavailable, api.CodeUnavailable, ""},
+		// Unified API: the status follows the code
+		{"POST", "/api", `{"cmd":"set","target":"rack1/nope","values":{"red":1}}`, http.StatusNotFound, api.CodeNotFound, "rack1/nope"},
+		{"POST", "/api", `{"cmd":"show","action":"stop"}`, http.StatusConflict, api.CodeConflict, ""},
+		{"POST", "/api", `{"cmd":"nope"}`, http.StatusBadRequest, api.CodeBadRequest, ""},
+		{"POST", "/api", `{`, http.StatusBadRequest, api.CodeBadRequest, ""},
+	} {
+		status, e := do(tc.method, tc.path, tc.body)
+		if status != tc.status || e.Code != tc.code || e.Target != tc.target || e.Message == "" {
+			t.Errorf("%s %s %s: expected %d %s %q, got %d %+v", tc.method, tc.path, tc.body, tc.status, tc.code, tc.target, status, e)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+	updates := state.Subscribe()
+	defer state.Unsubscribe(updates)
+
+	post := func(path, header, body string) (*httptest.ResponseRecorder, api.Response) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if header != "" {
+			req.Header.Set("X-Request-ID", header)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		var resp api.Response
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	// Header kept, echoed, and traced through history and the change event
+	w, resp := post("/api", "plc-42", `{"cmd":"set","target":"rack1/level1","values":{"red":10}}`)
+	if w.Header().Get("X-Request-ID") != "plc-42" || resp.RequestID != "plc-42" {
+		t.Errorf("expected request id plc-42, got header %q body %q", w.Header().Get("X-Request-ID"), resp.RequestID)
+	}
+	if h := state.History(dmx.HistoryQuery{Limit: 1}); len(h) != 1 || h[0].RequestID != "plc-42" {
+		t.Errorf("expected history traced with plc-42, got %+v", h)
+	}
+	found := false
+	for len(updates) > 0 && !found {
+		var msg dmx.ChangeMessage
+		if json.Unmarshal(<-updates, &msg) == nil && msg.Type == "change" {
+			found = msg.RequestID == "plc-42" && msg.Target == "rack1/level1"
+		}
+	}
+	if !found {
+		t.Error("expected a change event with the request id")
+	}
+
+	// Body field wins over the header
+	if _, resp := post("/api", "plc-42", `{"cmd":"status","request_id":"mine"}`); resp.RequestID != "mine" {
+		t.Errorf("expected body request id, got %q", resp.RequestID)
+	}
+
+	// Invalid header replaced by a generated id, also on REST routes
+	w, _ = post("/api/blackout", "bad id!", "")
+	if id := w.Header().Get("X-Request-ID"); id == "" || id == "bad id!" {
+		t.Errorf("expected generated request id, got %q", id)
+	}
+	if h := state.History(dmx.HistoryQuery{Limit: 1}); len(h) != 1 || h[0].RequestID != w.Header().Get("X-Request-ID") {
+		t.Errorf("expected blackout traced with %q, got %+v", w.Header().Get("X-Request-ID"), h)
+	}
+}
+
+func TestSchedulePause(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	pause := func(method, path, body string) scheduler.PauseStatus {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected status 200, got %d: %s", method, path, w.Code, w.Body)
+		}
+		var status scheduler.PauseStatus
+		json.Unmarshal(w.Body.Bytes(), &status)
+		return status
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/api/schedule/pause", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("without scheduler: expected status 404, got %d", w.Code)
+	}
+
+	sched, err := scheduler.New(&config.ScheduleConfig{Events: []config.ScheduleEvent{{Time: "08:00", Blackout: true}}}, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetScheduler(sched)
+
+	if status := pause("POST", "/api/schedule/pause", `{"timeout_ms":60000}`); !status.Paused || status.Until == nil {
+		t.Errorf("expected a timed pause, got %+v", status)
+	}
+	if status := pause("POST", "/api/schedule/pause", ""); !status.Paused || status.Until != nil {
+		t.Errorf("expected a pause until resumed, got %+v", status)
+	}
+	if status := pause("POST", "/api/schedule/resume", ""); status.Paused {
+		t.Errorf("expected resumed, got %+v", status)
+	}
+	if status := pause("GET", "/api/schedule/pause", ""); status.Paused {
+		t.Errorf("expected not paused, got %+v", status)
+	}
+}
+
+func TestScheduleUpcoming(t *testing.T) {
+	cfg := testConfig()
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+
+	upcoming := func(query string) (int, []scheduler.NextEventInfo) {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", "/api/schedule/upcoming"+query, nil))
+		var runs []scheduler.NextEventInfo
+		json.Unmarshal(w.Body.Bytes(), &runs)
+		return w.Code, runs
+	}
+
+	if code, runs := upcoming(""); code != http.StatusOK || runs == nil || len(runs) != 0 {
+		t.Errorf("without scheduler: expected an empty list, got %d %v", code, runs)
+	}
+
+	sched, err := scheduler.New(&config.ScheduleConfig{Events: []config.ScheduleEvent{
+		{ID: "morning", Time: "08:00", Scene: "day"},
+		{ID: "evening", Time: "20:00", Blackout: true},
+		{ID: "check", Cron: "0 */6 * * *", Scene: "day"},
+	}}, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetScheduler(sched)
+
+	code, runs := upcoming("?count=12")
+	if code != http.StatusOK || len(runs) != 12 {
+		t.Fatalf("expected 12 runs, got %d %d", code, len(runs))
+	}
+	ids := map[string]int{}
+	for i, run := range runs {
+		ids[run.ID]++
+		if i > 0 && run.At.Before(runs[i-1].At) {
+			t.Errorf("run %d at %s before run %d at %s", i, run.At, i-1, runs[i-1].At)
+		}
+		if run.InStr == "" {
+			t.Errorf("run %d: expected in_str", i)
+		}
+	}
+	// 6 runs a day: 12 runs cover two days
+	if ids["morning"] != 2 || ids["evening"] != 2 || ids["check"] != 8 {
+		t.Errorf("expected runs of every event, got %v", ids)
+	}
+	if code, _ := upcoming("?count=abc"); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid count, got %d", code)
+	}
+}
+
+func TestScheduleEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `dmx:
+  client: mock
+schedule:
+  events:
+    - { time: "08:00", set: { rack1: { blue: 200 } } }
+    - { id: night, time: "22:00", blackout: true }
+lights:
+  rack1:
+    level1:
+      - { ch: 1, color: blue }
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	logger := testLogger()
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := NewServer(cfg, state, logger)
+	var applied []string
+	server.OnConfigChange(func(_ *config.Config, changed []string) error {
+		applied = changed
+		return nil
+	})
+
+	do := func(method, path, body string) (int, config.ScheduleEvent) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		var e config.ScheduleEvent
+		json.Unmarshal(w.Body.Bytes(), &e)
+		return w.Code, e
+	}
+
+	// Events without an id are numbered
+	if code, e := do("GET", "/api/schedule/events/1", ""); code != http.StatusOK || e.Time != "08:00" {
+		t.Fatalf("expected event 1, got %d %+v", code, e)
+	}
+
+	code, e := do("POST", "/api/schedule/events", `{"time":"19:00","scene":"evening"}`)
+	if code != http.StatusOK || e.ID != "2" || e.Scene != "evening" || fmt.Sprint(applied) != "[schedule]" {
+		t.Fatalf("POST: expected event 2, got %d %+v (hook saw %v)", code, e, applied)
+	}
+	if code, _ := do("POST", "/api/schedule/events", `{"id":"night","time":"23:00","blackout":true}`); code != http.StatusConflict {
+		t.Errorf("expected 409 for an existing id, got %d", code)
+	}
+	if code, _ := do("POST", "/api/schedule/events", `{"time":"25:00","blackout":true}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid time, got %d", code)
+	}
+
+	if code, e := do("PATCH", "/api/schedule/events/1", `{"disabled":true}`); code != http.StatusOK || !e.Disabled || e.Set["rack1"]["blue"] != 200 {
+		t.Errorf("PATCH: expected event 1 disabled with its values, got %d %+v", code, e)
+	}
+	if code, e := do("PATCH", "/api/schedule/events/1", `{"set":{"rack1/level1":{"blue":50}}}`); code != http.StatusOK || len(e.Set) != 1 || e.Set["rack1/level1"]["blue"] != 50 {
+		t.Errorf("PATCH: expected the set targets replaced, got %d %+v", code, e)
+	}
+	if code, e := do("PATCH", "/api/schedule/events/night", `{"cron":"0 22 * * fri,sat"}`); code != http.StatusOK || e.Time != "" || e.Cron == "" {
+		t.Errorf("PATCH: expected night switched to cron, got %d %+v", code, e)
+	}
+	for _, bad := range []string{`{"time":`, `"21:00"`} {
+		if code, _ := do("PATCH", "/api/schedule/events/night", bad); code != http.StatusBadRequest {
+			t.Errorf("PATCH %s: expected 400, got %d", bad, code)
+		}
+	}
+	if code, e := do("PUT", "/api/schedule/events/night", `{"time":"21:30","blackout":true}`); code != http.StatusOK || e.ID != "night" || e.Time != "21:30" {
+		t.Errorf("PUT: expected night moved to 21:30, got %d %+v", code, e)
+	}
+	if code, _ := do("DELETE", "/api/schedule/events/2", ""); code != http.StatusOK {
+		t.Errorf("DELETE: expected 200, got %d", code)
+	}
+	if code, _ := do("DELETE", "/api/schedule/events/2", ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a removed event, got %d", code)
+	}
+
+	// Running config and file follow
+	events := state.GetConfig().Schedule.Events
+	if len(events) != 2 || !events[0].Disabled || events[1].Time != "21:30" {
+		t.Errorf("unexpected running events %+v", events)
+	}
+	saved, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if !reflect.DeepEqual(saved.Schedule.Events, events) {
+		t.Errorf("expected saved events %+v, got %+v", events, saved.Schedule.Events)
+	}
+
+	// Manual run, disabled event included
+	sched, err := scheduler.New(state.GetConfig().Schedule, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetScheduler(sched)
+	state.Enable()
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/api/schedule/events/1/run", nil))
+	var x scheduler.Execution
+	json.Unmarshal(w.Body.Bytes(), &x)
+	if w.Code != http.StatusOK || x.Result != "ok" || x.ID != "1" {
+		t.Errorf("run: expected event 1 ok, got %d %s", w.Code, w.Body)
+	}
+	if ch := state.GetChannels(); ch[0] != 50 {
+		t.Errorf("run: expected blue 50, got %d", ch[0])
+	}
+	if code, _ := do("POST", "/api/schedule/events/2/run", ""); code != http.StatusNotFound {
+		t.Errorf("run: expected 404 for a removed event, got %d", code)
+	}
+}
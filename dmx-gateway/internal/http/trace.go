@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"dmx-gateway/internal/dmx"
+)
+
+// Request ids
+// Each request gets an id: the client's X-Request-ID header when it is a short token
+// (up to 64 letters, digits or ._:-), a generated one otherwise. It is echoed in the
+// X-Request-ID response header, logged, and traced through the writes the request
+// makes (history, {"type":"change"} events on the WebSocket and MQTT). On POST /api a
+// request_id field in the body wins over the header.
+
+const maxRequestIDLen = 64
+
+type requestIDKey struct{}
+
+// trace wraps next with request id assignment
+func (s *Server) trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = dmx.NewRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		if apiPath(r.URL.Path) {
+			s.logger.Debug("HTTP request", "method", r.Method, "path", r.URL.Path, "request_id", id)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID reports whether a client supplied id can be kept
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '.', c == '_', c == ':', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the id assigned to r (empty outside the trace middleware)
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// source returns the HTTP write handle traced with the id of r
+func (s *Server) source(r *http.Request) *dmx.Source {
+	return s.state.Source(dmx.SourceHTTP).WithRequest(requestID(r))
+}
@@ -0,0 +1,371 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dmx-gateway/internal/api"
+	"dmx-gateway/internal/dmx"
+)
+
+// REST v2
+// /api/v2 exposes lights, groups, channels and scenes as resources: GET reads one,
+// PATCH changes the fields given, PUT replaces it (channels not listed go to 0,
+// a scene is overwritten). Every answer uses the same envelope, {"data": ...} with the
+// updated resource, or the {"error": {"code", "message", "target"}} of every REST failure.
+// The legacy routes and POST /api stay as they are.
+
+// v2Envelope wraps every v2 response
+type v2Envelope struct {
+	Data  any        `json:"data,omitempty"`
+	Error *api.Error `json:"error,omitempty"`
+}
+
+// v2Values is the PUT/PATCH body of lights and groups
+type v2Values struct {
+	Values    map[string]uint8   `json:"values,omitempty"`     // Channel name -> 0-255
+	ValuesPct map[string]float64 `json:"values_pct,omitempty"` // Channel name -> 0-100% (explicit values win)
+	FadeMs    int                `json:"fade_ms,omitempty"`    // Ramp duration (0 = immediate)
+}
+
+// v2Group is a group resource
+type v2Group struct {
+	Name   string            `json:"name"`
+	Lights []*dmx.LightState `json:"lights"`
+}
+
+// v2Channel is the PUT/PATCH body of a channel
+type v2Channel struct {
+	Value *uint8 `json:"value"`
+}
+
+// v2Scene is the PUT/PATCH body of a scene: explicit values, or targets to capture
+type v2Scene struct {
+	Lights  map[string]map[string]uint8 `json:"lights,omitempty"`  // Light key -> channel name -> value
+	Targets []string                    `json:"targets,omitempty"` // PUT without lights: capture these (empty = all)
+}
+
+func (s *Server) v2Data(w http.ResponseWriter, v any) {
+	s.jsonResponse(w, v2Envelope{Data: v})
+}
+
+func (s *Server) v2NotFound(w http.ResponseWriter, message string) {
+	httpError(w, message, http.StatusNotFound)
+}
+
+func (s *Server) v2BadRequest(w http.ResponseWriter, err error) {
+	httpError(w, err.Error(), http.StatusBadRequest)
+}
+
+// v2Methods rejects methods a resource does not support
+func (s *Server) v2Methods(w http.ResponseWriter, r *http.Request, allowed ...string) bool {
+	for _, m := range allowed {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	httpError(w, "method "+r.Method+" not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// v2Decode decodes a JSON body, rejecting unknown fields
+func v2Decode(r *http.Request, v any) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// handleV2 routes /api/v2/{lights,groups,channels,scenes}
+func (s *Server) handleV2(w http.ResponseWriter, r *http.Request) {
+	resource, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v2/"), "/")
+	switch resource {
+	case "lights":
+		s.handleV2Lights(w, r, rest)
+	case "groups":
+		s.handleV2Groups(w, r, rest)
+	case "channels":
+		s.handleV2Channels(w, r, rest)
+	case "scenes":
+		s.handleV2Scenes(w, r, rest)
+	default:
+		s.v2NotFound(w, "unknown resource: "+resource)
+	}
+}
+
+func (s *Server) handleV2Lights(w http.ResponseWriter, r *http.Request, key string) {
+	if key == "" {
+		if !s.v2Methods(w, r, http.MethodGet) {
+			return
+		}
+		lights := s.state.GetLights()
+		list := make([]*dmx.LightState, 0, len(lights))
+		for _, k := range s.state.GetLightKeys() {
+			list = append(list, lights[k])
+		}
+		s.v2Data(w, list)
+		return
+	}
+
+	group, name := parseKey(key)
+	light := s.state.GetLight(group, name)
+	if light == nil {
+		s.v2NotFound(w, "light not found: "+key)
+		return
+	}
+	if !s.v2Methods(w, r, http.MethodGet, http.MethodPut, http.MethodPatch) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		values, fade, err := v2ReadValues(r, [][]dmx.ChannelState{light.Channels})
+		if err != nil {
+			s.v2BadRequest(w, err)
+			return
+		}
+		src := s.source(r)
+		if fade > 0 {
+			err = src.FadeLight(group, name, values, fade)
+		} else {
+			err = src.SetLight(group, name, values)
+		}
+		if err != nil {
+			failed(w, err, key)
+			return
+		}
+	}
+	s.v2Data(w, light)
+}
+
+func (s *Server) handleV2Groups(w http.ResponseWriter, r *http.Request, name string) {
+	if name == "" {
+		if !s.v2Methods(w, r, http.MethodGet) {
+			return
+		}
+		groups := make([]v2Group, 0)
+		for _, g := range s.state.GetGroups() {
+			groups = append(groups, s.v2Group(g))
+		}
+		s.v2Data(w, groups)
+		return
+	}
+
+	if s.state.GetConfig().GetGroupLights(name) == nil {
+		s.v2NotFound(w, "group not found: "+name)
+		return
+	}
+	if !s.v2Methods(w, r, http.MethodGet, http.MethodPut, http.MethodPatch) {
+		return
+	}
+	group := s.v2Group(name)
+	if r.Method != http.MethodGet {
+		channels := make([][]dmx.ChannelState, 0, len(group.Lights))
+		for _, light := range group.Lights {
+			channels = append(channels, light.Channels)
+		}
+		values, fade, err := v2ReadValues(r, channels)
+		if err != nil {
+			s.v2BadRequest(w, err)
+			return
+		}
+		src := s.source(r)
+		if fade > 0 {
+			err = src.FadeGroup(name, values, fade)
+		} else {
+			err = src.SetGroup(name, values)
+		}
+		if err != nil {
+			failed(w, err, name)
+			return
+		}
+	}
+	s.v2Data(w, group)
+}
+
+// v2Group returns a group resource
+func (s *Server) v2Group(name string) v2Group {
+	g := v2Group{Name: name, Lights: make([]*dmx.LightState, 0)}
+	for _, light := range s.state.GetConfig().GetGroupLights(name) {
+		if ls := s.state.GetLight(name, light); ls != nil {
+			g.Lights = append(g.Lights, ls)
+		}
+	}
+	return g
+}
+
+// v2ReadValues decodes a lights/groups body; PUT adds 0 for every channel not listed
+func v2ReadValues(r *http.Request, lights [][]dmx.ChannelState) (map[string]uint8, time.Duration, error) {
+	var body v2Values
+	if err := v2Decode(r, &body); err != nil {
+		return nil, 0, err
+	}
+	pct, err := dmx.PercentValues(body.ValuesPct)
+	if err != nil {
+		return nil, 0, err
+	}
+	values := make(map[string]uint8, len(body.Values)+len(pct))
+	for k, v := range pct {
+		values[k] = v
+	}
+	for k, v := range body.Values {
+		values[k] = v
+	}
+	if r.Method == http.MethodPut {
+		for _, channels := range lights {
+			for i := range channels {
+				if _, ok := values[channels[i].Name]; !ok {
+					values[channels[i].Name] = 0
+				}
+			}
+		}
+	}
+	return values, time.Duration(body.FadeMs) * time.Millisecond, nil
+}
+
+func (s *Server) handleV2Channels(w http.ResponseWriter, r *http.Request, n string) {
+	if n == "" {
+		if s.v2Methods(w, r, http.MethodGet) {
+			s.v2Data(w, s.state.ChannelMap())
+		}
+		return
+	}
+
+	ch, err := strconv.Atoi(n)
+	if err != nil || ch < 1 || ch > 512 {
+		s.v2NotFound(w, "channel not found: "+n)
+		return
+	}
+	if !s.v2Methods(w, r, http.MethodGet, http.MethodPut, http.MethodPatch) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		var body v2Channel
+		if err := v2Decode(r, &body); err != nil {
+			s.v2BadRequest(w, err)
+			return
+		}
+		if body.Value == nil {
+			s.v2BadRequest(w, errors.New("value required"))
+			return
+		}
+		if err := s.source(r).SetChannel(ch, *body.Value); err != nil {
+			failed(w, err, n)
+			return
+		}
+	}
+	s.v2Data(w, s.state.ChannelMap()[ch-1])
+}
+
+func (s *Server) handleV2Scenes(w http.ResponseWriter, r *http.Request, path string) {
+	if path == "" {
+		if s.v2Methods(w, r, http.MethodGet) {
+			s.v2Data(w, s.state.Scenes())
+		}
+		return
+	}
+
+	name, action, _ := strings.Cut(path, "/")
+	switch action {
+	case "":
+	case "recall":
+		if !s.v2Methods(w, r, http.MethodPost) {
+			return
+		}
+		var body struct {
+			FadeMs int `json:"fade_ms"`
+		}
+		if err := v2Decode(r, &body); err != nil {
+			s.v2BadRequest(w, err)
+			return
+		}
+		if err := s.source(r).RecallScene(name, time.Duration(body.FadeMs)*time.Millisecond); err != nil {
+			failed(w, err, name)
+			return
+		}
+		s.v2Data(w, s.state.GetScene(name))
+		return
+	default:
+		s.v2NotFound(w, "unknown action: "+action)
+		return
+	}
+
+	if !s.v2Methods(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete) {
+		return
+	}
+	var body v2Scene
+	if r.Method == http.MethodPut || r.Method == http.MethodPatch {
+		if err := v2Decode(r, &body); err != nil {
+			s.v2BadRequest(w, err)
+			return
+		}
+	}
+
+	var (
+		sc  *dmx.Scene
+		err error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		if sc = s.state.GetScene(name); sc == nil {
+			err = dmx.ErrSceneNotFound
+		}
+	case http.MethodPut:
+		if body.Lights != nil {
+			sc, err = s.state.StoreScene(name, body.Lights, false)
+		} else {
+			sc, err = s.state.SaveScene(name, body.Targets)
+		}
+	case http.MethodPatch:
+		sc, err = s.state.StoreScene(name, body.Lights, true)
+	case http.MethodDelete:
+		sc = s.state.GetScene(name)
+		err = s.state.DeleteScene(name)
+	}
+	if err != nil {
+		failed(w, err, name)
+		return
+	}
+	s.v2Data(w, sc)
+}
+
+// v2Routes documents /api/v2 in the OpenAPI document
+func v2Routes() []route {
+	values := typeOf[v2Values]()
+	routes := []route{
+		{path: "/api/v2/lights", method: "get", summary: "Lights, in config order", response: typeOf[[]dmx.LightState]()},
+		{path: "/api/v2/groups", method: "get", summary: "Groups and their lights", response: typeOf[[]v2Group]()},
+		{path: "/api/v2/channels", method: "get", summary: "The 512 channels", response: typeOf[[]dmx.ChannelInfo]()},
+		{path: "/api/v2/scenes", method: "get", summary: "Scenes", response: typeOf[[]dmx.SceneInfo]()},
+		{path: "/api/v2/scenes/{name}", method: "delete", summary: "Delete a scene", response: typeOf[dmx.Scene]()},
+		{path: "/api/v2/scenes/{name}/recall", method: "post", summary: "Recall a scene", body: typeOf[struct {
+			FadeMs int `json:"fade_ms,omitempty"`
+		}](), response: typeOf[dmx.Scene]()},
+	}
+	for _, res := range []struct {
+		path, name string
+		body, data any
+	}{
+		{"/api/v2/lights/{group}/{name}", "light", values, typeOf[dmx.LightState]()},
+		{"/api/v2/groups/{name}", "group", values, typeOf[v2Group]()},
+		{"/api/v2/channels/{n}", "channel", typeOf[v2Channel](), typeOf[dmx.ChannelInfo]()},
+		{"/api/v2/scenes/{name}", "scene", typeOf[v2Scene](), typeOf[dmx.Scene]()},
+	} {
+		routes = append(routes,
+			route{path: res.path, method: "get", summary: "Get a " + res.name, response: res.data},
+			route{path: res.path, method: "put", summary: "Replace a " + res.name, body: res.body, response: res.data},
+			route{path: res.path, method: "patch", summary: "Update a " + res.name, body: res.body, response: res.data})
+	}
+	for i := range routes {
+		routes[i].v2 = true
+	}
+	return routes
+}
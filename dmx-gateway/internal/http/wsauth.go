@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"dmx-gateway/internal/api"
+)
+
+// WebSocket authentication
+// Credentials can come with the upgrade (X-API-Key or Authorization header, api_key or
+// access_token query parameter) or, since browsers cannot set headers and query strings
+// end up in proxy logs, in a first message:
+//   {"cmd":"auth","api_key":"..."} or {"cmd":"auth","token":"<jwt>"}
+// Until then the socket gets no init message and no updates. Anything else, wrong
+// credentials or no message within auth.ws_grace_sec closes it.
+
+const defaultWSGrace = 5 * time.Second
+
+// wsHandshake authenticates a socket upgraded without credentials and returns its level
+func (s *Server) wsHandshake(conn *websocket.Conn, r *http.Request) (scope, bool) {
+	grace := time.Duration(s.cfg.Auth.WSGraceSec) * time.Second
+	if grace <= 0 {
+		grace = defaultWSGrace
+	}
+	conn.SetReadDeadline(time.Now().Add(grace))
+
+	reject := func(reason string) (scope, bool) {
+		s.logger.Warn("WebSocket authentication failed", "remote", r.RemoteAddr, "error", reason)
+		deadline := time.Now().Add(wsWriteWait)
+		data, _ := json.Marshal(api.Response{Type: "error", Error: api.NewError(api.CodeUnauthorized, "", reason)})
+		conn.SetWriteDeadline(deadline)
+		conn.WriteMessage(websocket.TextMessage, data)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason), deadline)
+		return 0, false
+	}
+
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return reject("authentication timeout")
+	}
+	var msg struct {
+		Cmd    string `json:"cmd"`
+		APIKey string `json:"api_key"`
+		Token  string `json:"token"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Cmd != "auth" {
+		return reject("authentication required")
+	}
+	sc, err := s.authorize(msg.Token, msg.APIKey)
+	if err != nil {
+		return reject("unauthorized")
+	}
+
+	data, _ := json.Marshal(api.Response{Type: "ok", Data: map[string]string{"role": scopeNames[sc]}})
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return 0, false
+	}
+	return sc, true
+}
@@ -59,6 +59,56 @@ var (
 		},
 		[]string{"type"},
 	)
+
+	// BackendHealthy indicates if the DMX backend is responding
+	BackendHealthy = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "dmx_backend_healthy",
+			Help: "DMX backend healthy (1) or failing (0)",
+		},
+	)
+
+	// WSClients is the number of connected WebSocket clients
+	WSClients = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "dmx_ws_clients",
+			Help: "Connected WebSocket clients",
+		},
+	)
+
+	// VariableValue is the last numeric value of each variable
+	VariableValue = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dmx_variable_value",
+			Help: "Last numeric value of each variable (MQTT inputs, polled Modbus points)",
+		},
+		[]string{"name"},
+	)
+
+	// DLITarget is the daily light integral targeted by the photoperiod
+	DLITarget = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "dmx_dli_target",
+			Help: "Daily light integral target (mol/m²/day)",
+		},
+	)
+
+	// DLIAchieved is the light integral since lights on
+	DLIAchieved = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "dmx_dli_achieved",
+			Help: "Daily light integral achieved since lights on (mol/m²)",
+		},
+	)
+
+	// BackendRecoveries counts watchdog recovery attempts by result
+	BackendRecoveries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dmx_backend_recoveries_total",
+			Help: "Total backend recovery attempts by result",
+		},
+		[]string{"result"},
+	)
 )
 
 // SetEnabled updates the enabled metric
@@ -70,6 +120,15 @@ func SetEnabled(enabled bool) {
 	}
 }
 
+// SetBackendHealthy updates the backend health metric
+func SetBackendHealthy(healthy bool) {
+	if healthy {
+		BackendHealthy.Set(1)
+	} else {
+		BackendHealthy.Set(0)
+	}
+}
+
 // SetChannelValue updates a channel value metric
 func SetChannelValue(channel int, group, light, color string, value uint8) {
 	ChannelValue.WithLabelValues(
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"encoding/binary"
+	"strings"
+	"time"
+
+	"github.com/tbrandon/mbserver"
+
+	"dmx-gateway/internal/dmx"
+)
+
+// Light and group coils
+// Coils after enable (0) and blackout (1) are configured in modbus.coils, in order from
+// coil 2 (numbers relative to the first coil of the map, see regmap.go). Writing 1 recalls the coil's preset on its light or group, 0 turns every
+// channel of the target off, both with the coil's fade. A coil reads 1 while any
+// channel of its target is above 0, whichever source set it, so ladder logic sees
+// the actual output. FC15 writes several coils in one frame.
+
+// Coil switches a light or group between a preset and off
+type Coil struct {
+	Target string // "group" or "group/light"
+	Preset string
+	Fade   time.Duration
+}
+
+const coilFirst = 2 // First configurable coil
+
+// coilCount returns the number of coils (enable, blackout and the configured ones)
+func (s *Server) coilCount() int {
+	return coilFirst + len(s.cfg.Coils)
+}
+
+// inTarget reports whether light key belongs to target
+func inTarget(key, target string) bool {
+	group, _, _ := strings.Cut(key, "/")
+	return key == target || group == target
+}
+
+// coilOn reports whether any channel of the coil's target is on
+func (s *Server) coilOn(values map[string]map[string]uint8, addr uint16) bool {
+	coil := s.cfg.Coils[addr-coilFirst]
+	for key, v := range values {
+		if !inTarget(key, coil.Target) {
+			continue
+		}
+		for _, value := range v {
+			if value > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// switchCoil recalls the preset of a coil (on) or turns its target off
+func (s *Server) switchCoil(src *dmx.Source, addr uint16, on bool) error {
+	coil := s.cfg.Coils[addr-coilFirst]
+	if on {
+		return src.RecallPreset(coil.Target, coil.Preset, coil.Fade)
+	}
+	for key, v := range s.state.GetValues() {
+		if !inTarget(key, coil.Target) {
+			continue
+		}
+		for name := range v {
+			v[name] = 0
+		}
+		group, light, _ := strings.Cut(key, "/")
+		if err := src.FadeLight(group, light, v, coil.Fade); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FC15: Write Multiple Coils
+func (s *Server) handleWriteMultipleCoils(_ *mbserver.Server, frame mbserver.Framer) ([]byte, *mbserver.Exception) {
+	data := frame.GetData()
+	if len(data) < 5 {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
+
+	startAddr := binary.BigEndian.Uint16(data[0:2])
+	quantity := binary.BigEndian.Uint16(data[2:4])
+	byteCount := data[4]
+	if quantity == 0 || quantity > maxWriteBits {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
+
+	first, ok := s.coilRange(startAddr, quantity)
+	if !ok {
+		return []byte{}, &mbserver.IllegalDataAddress
+	}
+	if int(byteCount) != (int(quantity)+7)/8 || len(data) < 5+int(byteCount) {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
+
+	src, log := s.request(frame)
+	result := &mbserver.Success
+	for i := uint16(0); i < quantity; i++ {
+		on := data[5+i/8]&(1<<(i%8)) != 0
+		if exception := s.writeCoil(src, log, first+i, on); exception != &mbserver.Success {
+			result = exception // The other coils are still written
+		}
+	}
+	if result != &mbserver.Success {
+		return []byte{}, result
+	}
+
+	// Response: start addr + quantity
+	resp := make([]byte, 4)
+	binary.BigEndian.PutUint16(resp[0:2], startAddr)
+	binary.BigEndian.PutUint16(resp[2:4], quantity)
+	return resp, &mbserver.Success
+}
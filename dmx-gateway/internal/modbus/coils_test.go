@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tbrandon/mbserver"
+)
+
+func TestCoils(t *testing.T) {
+	s := newTestServer(t)
+	s.state.Enable()
+
+	// Coil 2 on: preset "full" on rack1
+	req := rtuFrame(7, 5, 0, 2, 0xFF, 0)
+	if resp := s.handleRTU(req); !bytes.Equal(resp, req) {
+		t.Fatalf("expected echo %x, got %x", req, resp)
+	}
+	if ch := s.state.GetChannels(); ch[0] != 255 || ch[1] != 255 {
+		t.Errorf("expected channels 1-2 at 255, got %d %d", ch[0], ch[1])
+	}
+	if resp := s.handleRTU(rtuFrame(7, 1, 0, 0, 0, 3)); !bytes.Equal(resp, rtuFrame(7, 1, 1, 0b101)) {
+		t.Errorf("expected coils enabled and rack1 on, got %x", resp)
+	}
+
+	// FC15 coils 1-2 = 0: rack1 off, blackout not triggered
+	if resp := s.handleRTU(rtuFrame(7, 15, 0, 1, 0, 2, 1, 0)); !bytes.Equal(resp, rtuFrame(7, 15, 0, 1, 0, 2)) {
+		t.Fatalf("unexpected FC15 answer %x", resp)
+	}
+	if ch := s.state.GetChannels(); ch[0] != 0 || ch[1] != 0 {
+		t.Errorf("expected rack1 off, got %d %d", ch[0], ch[1])
+	}
+
+	// Past the configured coils
+	if resp := s.handleRTU(rtuFrame(7, 5, 0, 3, 0xFF, 0)); !bytes.Equal(resp, rtuFrame(7, 5|0x80, byte(mbserver.IllegalDataAddress))) {
+		t.Errorf("expected illegal address, got %x", resp)
+	}
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Register map export
+// Points lists every address the server answers, with its access, type and meaning,
+// for GET /api/modbus/map: integrators import it (JSON or CSV) into their SCADA point
+// database instead of copying the README tables. Addresses are those of the running
+// server, after modbus.map.
+
+// MapPoint is one coil or register of the map
+type MapPoint struct {
+	Table       string `json:"table"` // "coil", "discrete" (FC02), "input" (FC04) or "holding"
+	Address     uint16 `json:"address"`
+	Access      string `json:"access"` // "R", "W" or "RW"
+	Type        string `json:"type"`   // "bool", "uint16" or "uint32" (two registers, high word first)
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// tableOrder sorts the points by table, then address
+var tableOrder = map[string]int{"coil": 0, "discrete": 1, "input": 2, "holding": 3}
+
+// Points returns the map of the server
+func (s *Server) Points() []MapPoint {
+	m := s.regMap
+	points := []MapPoint{
+		{"coil", m.Coils, "RW", "bool", "enable", "DMX output enabled"},
+		{"coil", m.Coils + 1, "W", "bool", "blackout", "Blackout on 1"},
+	}
+	for i, c := range s.cfg.Coils {
+		points = append(points, MapPoint{"coil", m.Coils + coilFirst + uint16(i), "RW", "bool", "coil_" + c.Target,
+			fmt.Sprintf("%s: preset %s on 1, off on 0", c.Target, c.Preset)})
+	}
+
+	points = append(points,
+		MapPoint{"discrete", diBackend, "R", "bool", "backend_ok", "DMX backend healthy (watchdog)"},
+		MapPoint{"discrete", diCommand, "R", "bool", "last_command_ok", "Last backend write succeeded"},
+		MapPoint{"discrete", diMQTT, "R", "bool", "mqtt_connected", "Every MQTT connection up (0 without MQTT)"},
+		MapPoint{"discrete", diScheduler, "R", "bool", "scheduler_running", "Scheduler running (0 without a schedule or while paused)"},
+		MapPoint{"input", inEnabled, "R", "uint16", "enabled", "Output enabled (0/1)"},
+		MapPoint{"input", inFPS, "R", "uint16", "fps_x100", "Frames per second x100"},
+		MapPoint{"input", inFrames, "R", "uint32", "frame_count", "Frame count (low 32 bits)"},
+		MapPoint{"input", inErrors, "R", "uint32", "error_count", "Backend error count (low 32 bits)"},
+		MapPoint{"input", inUptime, "R", "uint32", "uptime_sec", "Uptime in seconds"},
+		MapPoint{"input", inWSClients, "R", "uint16", "ws_clients", "Connected WebSocket clients"},
+	)
+
+	scale := "0-255"
+	if s.cfg.Scale == ScalePercent {
+		scale = "0-1000, tenths of a percent"
+	}
+	for i, info := range s.state.ChannelMap() {
+		desc := "DMX channel " + strconv.Itoa(info.Ch) + " (" + scale + ")"
+		var patches []string
+		for _, p := range info.Lights {
+			patches = append(patches, p.Light+" "+p.Name)
+		}
+		if len(patches) > 0 {
+			desc += ": " + strings.Join(patches, ", ")
+		}
+		points = append(points, MapPoint{"holding", m.Channels + uint16(i), "RW", "uint16", "channel_" + strconv.Itoa(info.Ch), desc})
+	}
+	points = append(points,
+		MapPoint{"holding", m.Scene, "RW", "uint16", "scene", "Scene recall: 1-based index in the sorted scene list, reads the last recalled"},
+		MapPoint{"holding", m.Fade, "RW", "uint16", "scene_fade_ms", "Fade of the next scene recalls in ms"},
+	)
+	for addr, reg := range s.lights {
+		name, _ := s.lightChannel(reg)
+		points = append(points, MapPoint{"holding", addr, "RW", "uint16", reg.light + "/" + name,
+			fmt.Sprintf("%s channel %s (%s)", reg.light, name, scale)})
+	}
+
+	slices.SortFunc(points, func(a, b MapPoint) int {
+		return cmp.Or(cmp.Compare(tableOrder[a.Table], tableOrder[b.Table]), cmp.Compare(a.Address, b.Address))
+	})
+	return points
+}
+
+// WriteCSV writes points as CSV with a header row
+func WriteCSV(w io.Writer, points []MapPoint) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"table", "address", "access", "type", "name", "description"})
+	for _, p := range points {
+		cw.Write([]string{p.Table, strconv.Itoa(int(p.Address)), p.Access, p.Type, p.Name, p.Description})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPoints(t *testing.T) {
+	s := newTestServer(t)
+	s.regMap = &RegisterMap{Channels: 100, Scene: 50, Fade: 51, Coils: 10, Lights: map[string]uint16{"rack1/level1": 1000}}
+	s.lights = s.lightRegisters()
+
+	points := s.Points()
+	find := func(table string, addr uint16) *MapPoint {
+		for i := range points {
+			if points[i].Table == table && points[i].Address == addr {
+				return &points[i]
+			}
+		}
+		return nil
+	}
+	if p := find("coil", 12); p == nil || p.Name != "coil_rack1" {
+		t.Errorf("expected coil_rack1 at 12, got %+v", p)
+	}
+	if p := find("holding", 100); p == nil || p.Name != "channel_1" || !strings.Contains(p.Description, "rack1/level1 red") {
+		t.Errorf("expected channel 1 at 100, got %+v", p)
+	}
+	if p := find("holding", 1001); p == nil || p.Name != "rack1/level1/green" {
+		t.Errorf("expected the green light register at 1001, got %+v", p)
+	}
+	if p := find("input", inUptime); p == nil || p.Type != "uint32" {
+		t.Errorf("expected the uptime as uint32, got %+v", p)
+	}
+	if points[0].Table != "coil" || points[len(points)-1].Address != 1001 {
+		t.Errorf("expected points sorted by table and address, got %+v ... %+v", points[0], points[len(points)-1])
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, points); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(points)+1 || lines[0] != "table,address,access,type,name,description" {
+		t.Errorf("unexpected CSV (%d lines): %q", len(lines), lines[0])
+	}
+}
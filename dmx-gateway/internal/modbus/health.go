@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"encoding/binary"
+
+	"github.com/tbrandon/mbserver"
+)
+
+// Health discrete inputs (FC02, read-only)
+// A PLC interlocks machinery on the lighting system through these flags:
+//   0 = backend OK (watchdog healthy, see /api/health)
+//   1 = last command OK (the last backend write succeeded)
+//   2 = MQTT connected (every broker connection up, 0 without MQTT)
+//   3 = scheduler running (0 without a schedule or while paused)
+
+const (
+	diBackend   = 0
+	diCommand   = 1
+	diMQTT      = 2
+	diScheduler = 3
+	diCount     = 4
+)
+
+// health returns the current values of the discrete inputs
+func (s *Server) health() [diCount]bool {
+	backend := s.state.BackendHealth()
+	var flags [diCount]bool
+	flags[diBackend] = backend.Healthy
+	flags[diCommand] = backend.ConsecutiveFailures == 0
+	flags[diMQTT] = s.cfg.MQTTConnected != nil && s.cfg.MQTTConnected()
+	flags[diScheduler] = s.cfg.SchedulerRunning != nil && s.cfg.SchedulerRunning()
+	return flags
+}
+
+// FC02: Read Discrete Inputs (health flags)
+func (s *Server) handleReadDiscreteInputs(_ *mbserver.Server, frame mbserver.Framer) ([]byte, *mbserver.Exception) {
+	data := frame.GetData()
+	if len(data) < 4 {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
+
+	startAddr := binary.BigEndian.Uint16(data[0:2])
+	quantity := binary.BigEndian.Uint16(data[2:4])
+	if quantity == 0 || quantity > maxReadBits {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
+
+	if int(startAddr)+int(quantity) > diCount {
+		return []byte{}, &mbserver.IllegalDataAddress
+	}
+
+	flags := s.health()
+	resp := make([]byte, 1+(quantity+7)/8)
+	resp[0] = byte(len(resp) - 1) // byte count
+	for i := uint16(0); i < quantity; i++ {
+		if flags[startAddr+i] {
+			resp[1+i/8] |= 1 << (i % 8)
+		}
+	}
+	return resp, &mbserver.Success
+}
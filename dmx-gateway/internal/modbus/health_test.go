@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tbrandon/mbserver"
+)
+
+func TestReadDiscreteInputs(t *testing.T) {
+	s := newTestServer(t)
+
+	// Backend and last command OK, no MQTT or scheduler
+	if resp := s.handleRTU(rtuFrame(7, 2, 0, 0, 0, diCount)); !bytes.Equal(resp, rtuFrame(7, 2, 1, 0b0011)) {
+		t.Errorf("expected flags 0011, got %x", resp)
+	}
+
+	s.cfg.MQTTConnected = func() bool { return true }
+	s.cfg.SchedulerRunning = func() bool { return true }
+	if resp := s.handleRTU(rtuFrame(7, 2, 0, 2, 0, 2)); !bytes.Equal(resp, rtuFrame(7, 2, 1, 0b11)) {
+		t.Errorf("expected MQTT and scheduler flags, got %x", resp)
+	}
+
+	if resp := s.handleRTU(rtuFrame(7, 2, 0, 3, 0, 2)); !bytes.Equal(resp, rtuFrame(7, 2|0x80, byte(mbserver.IllegalDataAddress))) {
+		t.Errorf("expected illegal address past the flags, got %x", resp)
+	}
+}
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Modbus master (sensor polling)
+// modbus.poll lists external Modbus TCP devices (light sensors, energy meters...) read
+// on an interval. Each point is a holding or input register (two for 32-bit formats,
+// high word first), scaled and stored as a named variable in the state (see
+// dmx/variables.go) for schedule rules, the API and the dmx_variable_value metric.
+// A device keeps its connection between polls and reconnects after an error; its
+// failures are logged when they start and when it answers again.
+
+// Poll timing when the device leaves it unset
+const (
+	defaultPollInterval = 5 * time.Second
+	defaultPollTimeout  = time.Second
+)
+
+// Register formats of a point
+const (
+	FormatUint16  = "uint16"
+	FormatInt16   = "int16"
+	FormatUint32  = "uint32"
+	FormatInt32   = "int32"
+	FormatFloat32 = "float32"
+)
+
+// Device is an external Modbus TCP device polled for readings
+type Device struct {
+	Address  string // host:port
+	UnitID   uint8
+	Interval time.Duration
+	Timeout  time.Duration
+	Points   []Point
+}
+
+// Point is one reading of a device
+type Point struct {
+	Name     string // Variable name
+	Register uint16
+	Input    bool    // Input register (FC04), holding register (FC03) otherwise
+	Format   string  // FormatUint16 (default) ... FormatFloat32
+	Scale    float64 // Multiplier, 0 = 1
+}
+
+// words returns the number of registers of the point
+func (p Point) words() uint16 {
+	switch p.Format {
+	case FormatUint32, FormatInt32, FormatFloat32:
+		return 2
+	}
+	return 1
+}
+
+// value decodes the registers of the point and applies its scale
+func (p Point) value(regs []uint16) float64 {
+	var v float64
+	switch p.Format {
+	case FormatInt16:
+		v = float64(int16(regs[0]))
+	case FormatUint32:
+		v = float64(uint32(regs[0])<<16 | uint32(regs[1]))
+	case FormatInt32:
+		v = float64(int32(uint32(regs[0])<<16 | uint32(regs[1])))
+	case FormatFloat32:
+		v = float64(math.Float32frombits(uint32(regs[0])<<16 | uint32(regs[1])))
+	default:
+		v = float64(regs[0])
+	}
+	if p.Scale != 0 {
+		v *= p.Scale
+	}
+	return v
+}
+
+// startPollers starts one poller per configured device
+func (s *Server) startPollers() {
+	for _, d := range s.cfg.Poll {
+		s.wg.Add(1)
+		go s.poll(d)
+		s.logger.Info("Modbus poller started", "device", d.Address, "unit", d.UnitID, "points", len(d.Points))
+	}
+}
+
+// poll reads the points of d on its interval until Stop
+func (s *Server) poll(d Device) {
+	defer s.wg.Done()
+
+	if d.Interval <= 0 {
+		d.Interval = defaultPollInterval
+	}
+	if d.Timeout <= 0 {
+		d.Timeout = defaultPollTimeout
+	}
+	c := &tcpClient{address: d.Address, unit: d.UnitID, timeout: d.Timeout}
+	defer c.close()
+
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		err := s.readPoints(c, d)
+		if err != nil && !failing {
+			s.logger.Warn("Modbus poll failed", "device", d.Address, "unit", d.UnitID, "error", err)
+		} else if err == nil && failing {
+			s.logger.Info("Modbus poll recovered", "device", d.Address, "unit", d.UnitID)
+		}
+		failing = err != nil
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readPoints reads every point of d, stopping at the first error
+func (s *Server) readPoints(c *tcpClient, d Device) error {
+	for _, p := range d.Points {
+		fc, table := uint8(3), "holding"
+		if p.Input {
+			fc, table = 4, "input"
+		}
+		regs, err := c.read(fc, p.Register, p.words())
+		if err != nil {
+			c.close() // Reconnect on the next read
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+		raw := strconv.FormatFloat(p.value(regs), 'f', -1, 64)
+		source := fmt.Sprintf("modbus://%s/%d/%s/%d", d.Address, d.UnitID, table, p.Register)
+		s.state.SetVariable(p.Name, raw, source)
+	}
+	return nil
+}
+
+// tcpClient is a minimal Modbus TCP master reading registers
+type tcpClient struct {
+	address string
+	unit    uint8
+	timeout time.Duration
+	conn    net.Conn
+	tid     uint16 // Last transaction id
+}
+
+// read reads count registers from addr with function fc (3 or 4)
+func (c *tcpClient) read(fc uint8, addr, count uint16) ([]uint16, error) {
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.address, c.timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	c.tid++
+	req := make([]byte, 12)
+	binary.BigEndian.PutUint16(req[0:], c.tid)
+	binary.BigEndian.PutUint16(req[4:], 6) // Length: unit + PDU
+	req[6] = c.unit
+	req[7] = fc
+	binary.BigEndian.PutUint16(req[8:], addr)
+	binary.BigEndian.PutUint16(req[10:], count)
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint16(header[4:])
+	if binary.BigEndian.Uint16(header[0:]) != c.tid || length < 3 || length > 254 {
+		return nil, errors.New("invalid response header")
+	}
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(c.conn, pdu); err != nil {
+		return nil, err
+	}
+	if pdu[0] == fc|0x80 {
+		return nil, fmt.Errorf("exception %d", pdu[1])
+	}
+	if pdu[0] != fc || int(pdu[1]) != int(count)*2 || len(pdu) < 2+int(count)*2 {
+		return nil, errors.New("invalid response")
+	}
+	regs := make([]uint16, count)
+	for i := range regs {
+		regs[i] = binary.BigEndian.Uint16(pdu[2+i*2:])
+	}
+	return regs, nil
+}
+
+// close drops the connection
+func (c *tcpClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeDevice serves registers over Modbus TCP (FC03 and FC04 read the same table)
+func fakeDevice(t *testing.T, regs map[uint16]uint16) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req := make([]byte, 12)
+				for {
+					if _, err := io.ReadFull(conn, req); err != nil {
+						return
+					}
+					addr := binary.BigEndian.Uint16(req[8:])
+					count := binary.BigEndian.Uint16(req[10:])
+					resp := append([]byte{}, req[:8]...)
+					if _, ok := regs[addr]; !ok {
+						resp[7] |= 0x80
+						resp = append(resp, 2) // Illegal data address
+					} else {
+						resp = append(resp, byte(count*2))
+						for i := uint16(0); i < count; i++ {
+							resp = binary.BigEndian.AppendUint16(resp, regs[addr+i])
+						}
+					}
+					binary.BigEndian.PutUint16(resp[4:], uint16(len(resp)-6))
+					conn.Write(resp)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestPoll(t *testing.T) {
+	bits := math.Float32bits(21.5)
+	addr := fakeDevice(t, map[uint16]uint16{
+		0: 1234,
+		1: 0xFFFE, // -2
+		2: uint16(bits >> 16), 3: uint16(bits),
+	})
+	s := newTestServer(t)
+	d := Device{Address: addr, UnitID: 1, Timeout: time.Second, Points: []Point{
+		{Name: "par", Register: 0, Scale: 0.1},
+		{Name: "offset", Register: 1, Input: true, Format: FormatInt16},
+		{Name: "temp", Register: 2, Format: FormatFloat32},
+	}}
+	c := &tcpClient{address: d.Address, unit: d.UnitID, timeout: d.Timeout}
+	defer c.close()
+
+	for range 2 { // Second round on the same connection
+		if err := s.readPoints(c, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]float64{"par": 123.4, "offset": -2, "temp": 21.5} {
+		v, ok := s.state.Variable(name)
+		if !ok || math.Abs(v.Value-want) > 1e-9 {
+			t.Errorf("%s: expected %v, got %+v", name, want, v)
+		}
+	}
+	if v, _ := s.state.Variable("offset"); v.Source != "modbus://"+addr+"/1/input/1" {
+		t.Errorf("unexpected source %q", v.Source)
+	}
+
+	// Exception from the device
+	d.Points = []Point{{Name: "missing", Register: 100}}
+	if err := s.readPoints(c, d); err == nil {
+		t.Error("expected an error for an unmapped register")
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"dmx-gateway/internal/dmx"
+)
+
+// Register map
+// Holding registers and coils are placed by modbus.map, so an existing SCADA template
+// can be served without re-addressing it. The default map is the fixed layout:
+// channels at 0-511, scene recall at 512 and its fade at 513, coils from 0. A light can also get a block
+// of registers, one per channel in config order, written as a light set (curves,
+// arbitration and history apply). Addresses outside the map answer
+// IllegalDataAddress, as does a range crossing one.
+
+// RegisterMap places the holding registers and coils
+type RegisterMap struct {
+	Channels uint16            // First of the 512 channel registers
+	Scene    uint16            // Scene recall register
+	Fade     uint16            // Scene fade register (ms)
+	Coils    uint16            // First coil (enable), then blackout and Config.Coils
+	Lights   map[string]uint16 // Light key -> first register of its block
+}
+
+// DefaultMap is the layout used without modbus.map
+var DefaultMap = RegisterMap{Channels: 0, Scene: 512, Fade: 513, Coils: 0}
+
+type regKind int
+
+const (
+	regChannel regKind = iota + 1
+	regScene
+	regFade
+	regLight
+)
+
+// register is a holding register of the map
+type register struct {
+	kind    regKind
+	channel int    // DMX channel 1-512 (regChannel)
+	light   string // Light key (regLight)
+	index   int    // Channel position in the light (regLight)
+}
+
+// lightRegisters returns the registers of the light blocks by address
+// Block sizes are those of the config the server starts with.
+func (s *Server) lightRegisters() map[uint16]register {
+	regs := make(map[uint16]register)
+	cfg := s.state.GetConfig()
+	for key, first := range s.regMap.Lights {
+		group, name, _ := strings.Cut(key, "/")
+		for i := range cfg.GetLight(group, name) {
+			regs[first+uint16(i)] = register{kind: regLight, light: key, index: i}
+		}
+	}
+	return regs
+}
+
+// registers returns the holding registers of addr..addr+quantity-1, false if one of
+// them is not mapped
+func (s *Server) registers(addr, quantity uint16) ([]register, bool) {
+	if quantity == 0 || int(addr)+int(quantity) > 0x10000 {
+		return nil, false
+	}
+	regs := make([]register, quantity)
+	for i := range regs {
+		a := addr + uint16(i)
+		switch {
+		case a >= s.regMap.Channels && int(a) < int(s.regMap.Channels)+512:
+			regs[i] = register{kind: regChannel, channel: int(a-s.regMap.Channels) + 1}
+		case a == s.regMap.Scene:
+			regs[i] = register{kind: regScene}
+		case a == s.regMap.Fade:
+			regs[i] = register{kind: regFade}
+		default:
+			reg, ok := s.lights[a]
+			if !ok {
+				return nil, false
+			}
+			regs[i] = reg
+		}
+	}
+	return regs, true
+}
+
+// lightChannel returns the channel name of a light register
+func (s *Server) lightChannel(reg register) (string, bool) {
+	group, name, _ := strings.Cut(reg.light, "/")
+	channels := s.state.GetConfig().GetLight(group, name)
+	if reg.index >= len(channels) {
+		return "", false // Channel removed since the server started
+	}
+	return channels[reg.index].Name, true
+}
+
+// ScalePercent maps channel and light registers to 0-1000 (tenths of a percent), the
+// dimmer convention of building management systems: 500 = 50% = 128
+const ScalePercent = "percent"
+
+// toRegister returns the register value of a 0-255 channel value
+func (s *Server) toRegister(v uint8) uint16 {
+	if s.cfg.Scale == ScalePercent {
+		return uint16(math.Round(float64(v) * 1000 / 255))
+	}
+	return uint16(v)
+}
+
+// fromRegister returns the 0-255 channel value of a register value (clamped)
+func (s *Server) fromRegister(value uint16) uint8 {
+	if s.cfg.Scale == ScalePercent {
+		v, _ := dmx.PercentValue(float64(min(value, 1000)) / 10)
+		return v
+	}
+	return uint8(min(value, 255))
+}
+
+// writeRegister writes a channel, light or fade register (values above the scale are
+// clamped)
+func (s *Server) writeRegister(src *dmx.Source, reg register, value uint16) error {
+	v := s.fromRegister(value)
+	switch reg.kind {
+	case regFade:
+		s.mu.Lock()
+		s.sceneFade = value
+		s.mu.Unlock()
+	case regChannel:
+		return src.SetChannel(reg.channel, v)
+	case regLight:
+		name, ok := s.lightChannel(reg)
+		if !ok {
+			return fmt.Errorf("%s: no channel %d", reg.light, reg.index+1)
+		}
+		group, light, _ := strings.Cut(reg.light, "/")
+		return src.SetLight(group, light, map[string]uint8{name: v})
+	}
+	return nil
+}
+
+// coilRange returns the coil number (0 = enable) of addr, false unless
+// addr..addr+quantity-1 are all coils
+func (s *Server) coilRange(addr, quantity uint16) (uint16, bool) {
+	base := s.regMap.Coils
+	if quantity == 0 || addr < base || int(addr-base)+int(quantity) > s.coilCount() {
+		return 0, false
+	}
+	return addr - base, true
+}
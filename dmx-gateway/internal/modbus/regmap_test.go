@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tbrandon/mbserver"
+)
+
+func TestRegisterMap(t *testing.T) {
+	s := newTestServer(t)
+	s.regMap = &RegisterMap{Channels: 100, Scene: 50, Fade: 51, Coils: 10, Lights: map[string]uint16{"rack1/level1": 1000}}
+	s.lights = s.lightRegisters()
+	s.state.Enable()
+
+	// Light block: register 1001 = second channel (green, DMX 2)
+	req := rtuFrame(7, 6, 0x03, 0xE9, 0, 80)
+	if resp := s.handleRTU(req); !bytes.Equal(resp, req) {
+		t.Fatalf("expected echo %x, got %x", req, resp)
+	}
+	if ch := s.state.GetChannels(); ch[1] != 80 {
+		t.Errorf("expected channel 2 = 80, got %d", ch[1])
+	}
+	if resp := s.handleRTU(rtuFrame(7, 3, 0x03, 0xE8, 0, 2)); !bytes.Equal(resp, rtuFrame(7, 3, 4, 0, 0, 0, 80)) {
+		t.Errorf("unexpected light block %x", resp)
+	}
+
+	// Channel 2 moved to register 101
+	if resp := s.handleRTU(rtuFrame(7, 3, 0, 101, 0, 1)); !bytes.Equal(resp, rtuFrame(7, 3, 2, 0, 80)) {
+		t.Errorf("unexpected channel register %x", resp)
+	}
+
+	// Unmapped registers and coils
+	illegal := func(fc uint8) []byte { return rtuFrame(7, fc|0x80, byte(mbserver.IllegalDataAddress)) }
+	if resp := s.handleRTU(rtuFrame(7, 3, 0, 0, 0, 1)); !bytes.Equal(resp, illegal(3)) {
+		t.Errorf("expected illegal address for register 0, got %x", resp)
+	}
+	if resp := s.handleRTU(rtuFrame(7, 3, 0x03, 0xE8, 0, 3)); !bytes.Equal(resp, illegal(3)) {
+		t.Errorf("expected illegal address past the light block, got %x", resp)
+	}
+	if resp := s.handleRTU(rtuFrame(7, 1, 0, 0, 0, 1)); !bytes.Equal(resp, illegal(1)) {
+		t.Errorf("expected illegal address for coil 0, got %x", resp)
+	}
+
+	// Coils from 10: enable
+	if resp := s.handleRTU(rtuFrame(7, 1, 0, 10, 0, 1)); !bytes.Equal(resp, rtuFrame(7, 1, 1, 1)) {
+		t.Errorf("expected enable coil at 10, got %x", resp)
+	}
+}
+
+func TestPercentScale(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.Scale = ScalePercent
+
+	// 500 = 50% = 128
+	if resp := s.handleRTU(rtuFrame(7, 6, 0, 0, 0x01, 0xF4)); resp == nil {
+		t.Fatal("no answer")
+	}
+	if ch := s.state.GetChannels(); ch[0] != 128 {
+		t.Errorf("expected channel 1 = 128, got %d", ch[0])
+	}
+	// 128 reads back as 502 (rounded), 2000 is clamped to 1000
+	s.handleRTU(rtuFrame(7, 6, 0, 1, 0x07, 0xD0))
+	if resp := s.handleRTU(rtuFrame(7, 3, 0, 0, 0, 2)); !bytes.Equal(resp, rtuFrame(7, 3, 4, 0x01, 0xF6, 0x03, 0xE8)) {
+		t.Errorf("unexpected percent registers %x", resp)
+	}
+}
+
+func TestSceneRecallFade(t *testing.T) {
+	s := newTestServer(t)
+	s.state.Enable()
+	s.state.SetChannel(1, 200)
+	if _, err := s.state.SaveScene("bright", nil); err != nil {
+		t.Fatal(err)
+	}
+	s.state.SetChannel(1, 0)
+
+	// Scene 1 with a 2s fade in one FC16 block (scene 512, fade 513)
+	if resp := s.handleRTU(rtuFrame(7, 16, 0x02, 0x00, 0, 2, 4, 0, 1, 0x07, 0xD0)); !bytes.Equal(resp, rtuFrame(7, 16, 0x02, 0x00, 0, 2)) {
+		t.Fatalf("unexpected FC16 answer %x", resp)
+	}
+	if ch := s.state.GetChannels(); ch[0] == 200 {
+		t.Error("expected the recall to fade, channel 1 already at 200")
+	}
+	if resp := s.handleRTU(rtuFrame(7, 3, 0x02, 0x00, 0, 2)); !bytes.Equal(resp, rtuFrame(7, 3, 4, 0, 1, 0x07, 0xD0)) {
+		t.Errorf("expected scene 1 and fade 2000, got %x", resp)
+	}
+}
+
+func TestWriteMultipleRegisters(t *testing.T) {
+	s := newTestServer(t)
+	s.regMap = &RegisterMap{Channels: 0, Scene: 600, Fade: 601, Lights: map[string]uint16{"rack1/level1": 512}}
+	s.lights = s.lightRegisters()
+	updates := s.state.Subscribe()
+	defer s.state.Unsubscribe(updates)
+
+	// Channels 511-512 and the light block (red, green) in one block: one broadcast
+	req := rtuFrame(7, 16, 0x01, 0xFE, 0, 4, 8, 0, 11, 0, 12, 0, 13, 0, 14)
+	if resp := s.handleRTU(req); !bytes.Equal(resp, rtuFrame(7, 16, 0x01, 0xFE, 0, 4)) {
+		t.Fatalf("unexpected FC16 answer %x", resp)
+	}
+	if ch := s.state.GetChannels(); ch[510] != 11 || ch[511] != 12 || ch[0] != 13 || ch[1] != 14 {
+		t.Errorf("expected channels 511-512 = 11, 12 and 1-2 = 13, 14, got %d %d %d %d", ch[510], ch[511], ch[0], ch[1])
+	}
+	states := 0
+	for len(updates) > 0 {
+		if bytes.HasPrefix(<-updates, []byte(`{"type":"state"`)) {
+			states++ // Change events of the traced request aside
+		}
+	}
+	if states != 1 {
+		t.Errorf("expected a single state broadcast, got %d", states)
+	}
+}
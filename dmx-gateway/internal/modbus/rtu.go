@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goburrow/serial"
+	"github.com/tbrandon/mbserver"
+)
+
+// Modbus RTU slave
+// The same register map is served over a serial port (RS-485 on most installs), so a
+// PLC can drive the gateway without a TCP/RTU converter. Frames addressed to another
+// unit are ignored, as the bus is shared; broadcasts (address 0) are applied without
+// an answer. A frame ends once its length (known for the supported function codes) is
+// read, or after rtuSilence without a byte; frames with a bad CRC are dropped.
+//
+// mbserver.ListenRTU is not used: it exits the process when the port cannot be opened
+// and answers every address.
+
+// RTUConfig is the serial port of the RTU slave
+type RTUConfig struct {
+	Device   string // e.g. "/dev/ttyS3"
+	Baud     int
+	Parity   string // "N", "E" or "O"
+	StopBits int
+	UnitID   uint8 // Slave address (1-247)
+	RS485    bool  // Kernel RS-485 mode (RTS drives the transceiver)
+}
+
+const (
+	rtuSilence  = 20 * time.Millisecond // End of an incomplete frame (also the stop poll interval)
+	rtuMaxFrame = 256                   // Largest RTU frame (address + PDU + CRC)
+)
+
+// startRTU opens the serial port and starts answering frames
+func (s *Server) startRTU() error {
+	cfg := s.cfg.RTU
+	port, err := serial.Open(&serial.Config{
+		Address:  cfg.Device,
+		BaudRate: cfg.Baud,
+		DataBits: 8,
+		StopBits: cfg.StopBits,
+		Parity:   cfg.Parity,
+		Timeout:  rtuSilence,
+		RS485:    serial.RS485Config{Enabled: cfg.RS485},
+	})
+	if err != nil {
+		return fmt.Errorf("modbus rtu: %w", err)
+	}
+	s.port = port
+	s.wg.Add(1)
+	go s.serveRTU(port)
+	s.logger.Info("Modbus RTU slave started", "device", cfg.Device, "baud", cfg.Baud,
+		"parity", cfg.Parity, "unit", cfg.UnitID)
+	return nil
+}
+
+// closeRTU closes the port once the RTU loop has stopped
+func (s *Server) closeRTU() {
+	if s.port == nil {
+		return
+	}
+	s.port.Close()
+	s.port = nil
+	s.logger.Info("Modbus RTU slave stopped")
+}
+
+// serveRTU reads frames from port until Stop and answers them
+func (s *Server) serveRTU(port serial.Port) {
+	defer s.wg.Done()
+
+	buf := make([]byte, 0, rtuMaxFrame)
+	chunk := make([]byte, rtuMaxFrame)
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		n, err := port.Read(chunk)
+		if errors.Is(err, serial.ErrTimeout) {
+			if len(buf) > 0 {
+				s.answerRTU(port, buf) // Silence ends a frame of unknown length
+				buf = buf[:0]
+			}
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Modbus RTU read failed", "error", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		buf = append(buf, chunk[:n]...)
+		for {
+			size := rtuFrameSize(buf)
+			if size == 0 || len(buf) < size {
+				break
+			}
+			s.answerRTU(port, buf[:size])
+			buf = append(buf[:0], buf[size:]...)
+		}
+		if len(buf) > rtuMaxFrame {
+			s.logger.Debug("Modbus RTU overrun, frame dropped", "bytes", len(buf))
+			buf = buf[:0]
+		}
+	}
+}
+
+// answerRTU handles one frame and writes the answer, if any, to port
+func (s *Server) answerRTU(port serial.Port, packet []byte) {
+	resp := s.handleRTU(packet)
+	if resp == nil {
+		return
+	}
+	if _, err := port.Write(resp); err != nil {
+		s.logger.Warn("Modbus RTU write failed", "error", err)
+	}
+}
+
+// handleRTU runs one frame and returns the answer (nil = none)
+func (s *Server) handleRTU(packet []byte) []byte {
+	frame, err := mbserver.NewRTUFrame(packet)
+	if err != nil {
+		s.logger.Debug("Modbus RTU frame dropped", "error", err)
+		return nil
+	}
+	if frame.Address != s.cfg.RTU.UnitID && frame.Address != 0 {
+		return nil // Another slave on the bus
+	}
+	resp := s.serve(frame)
+	if frame.Address == 0 {
+		return nil // Broadcasts are not answered
+	}
+	return resp.Bytes()
+}
+
+// rtuFrameSize returns the length of the request frame buf starts with (0 = unknown)
+func rtuFrameSize(buf []byte) int {
+	if len(buf) < 2 {
+		return 0
+	}
+	switch buf[1] {
+	case 1, 2, 3, 4, 5, 6: // Address, function, 2x2 bytes, CRC
+		return 8
+	case 15, 16: // ... then byte count and values
+		if len(buf) < 7 {
+			return 0
+		}
+		return 9 + int(buf[6])
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/tbrandon/mbserver"
+
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := &config.Config{
+		Lights: map[string]map[string][]config.Channel{
+			"rack1": {"level1": {{Ch: 1, Color: "red"}, {Ch: 2, Color: "green"}}},
+		},
+		Presets: map[string]map[string]map[string]uint8{"rack1": {"full": {"red": 255, "green": 255}}},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	return NewServer(&Config{
+		RTU:   &RTUConfig{UnitID: 7},
+		Coils: []Coil{{Target: "rack1", Preset: "full"}},
+	}, state, logger)
+}
+
+// rtuFrame returns an RTU frame with its CRC
+func rtuFrame(address, function uint8, data ...byte) []byte {
+	return (&mbserver.RTUFrame{Address: address, Function: function, Data: data}).Bytes()
+}
+
+func TestHandleRTU(t *testing.T) {
+	s := newTestServer(t)
+
+	// Write single register 0 (channel 1) = 200 on our unit: echoed
+	req := rtuFrame(7, 6, 0, 0, 0, 200)
+	if resp := s.handleRTU(req); !bytes.Equal(resp, req) {
+		t.Errorf("expected echo %x, got %x", req, resp)
+	}
+	if ch := s.state.GetChannels(); ch[0] != 200 {
+		t.Errorf("expected channel 1 = 200, got %d", ch[0])
+	}
+
+	// Another unit: ignored
+	if resp := s.handleRTU(rtuFrame(8, 6, 0, 0, 0, 10)); resp != nil {
+		t.Errorf("expected no answer for unit 8, got %x", resp)
+	}
+	if ch := s.state.GetChannels(); ch[0] != 200 {
+		t.Errorf("expected channel 1 unchanged, got %d", ch[0])
+	}
+
+	// Broadcast: applied, not answered
+	if resp := s.handleRTU(rtuFrame(0, 6, 0, 1, 0, 50)); resp != nil {
+		t.Errorf("expected no answer to a broadcast, got %x", resp)
+	}
+	if ch := s.state.GetChannels(); ch[1] != 50 {
+		t.Errorf("expected channel 2 = 50, got %d", ch[1])
+	}
+
+	// Bad CRC: dropped
+	bad := rtuFrame(7, 6, 0, 0, 0, 1)
+	bad[len(bad)-1] ^= 0xFF
+	if resp := s.handleRTU(bad); resp != nil {
+		t.Errorf("expected no answer to a bad CRC, got %x", resp)
+	}
+
+	// Unsupported function: exception
+	if resp := s.handleRTU(rtuFrame(7, 43, 14, 1, 0)); !bytes.Equal(resp, rtuFrame(7, 43|0x80, byte(mbserver.IllegalFunction))) {
+		t.Errorf("expected illegal function exception, got %x", resp)
+	}
+}
+
+func TestRTUFrameSize(t *testing.T) {
+	for _, tc := range []struct {
+		buf  []byte
+		want int
+	}{
+		{[]byte{1}, 0},
+		{[]byte{1, 3}, 8},
+		{[]byte{1, 16, 0, 0, 0, 2}, 0},
+		{[]byte{1, 16, 0, 0, 0, 2, 4}, 13},
+		{[]byte{1, 43}, 0},
+	} {
+		if got := rtuFrameSize(tc.buf); got != tc.want {
+			t.Errorf("%x: expected %d, got %d", tc.buf, tc.want, got)
+		}
+	}
+}
@@ -6,8 +6,11 @@ package modbus
 import (
 	"encoding/binary"
 	"log/slog"
+	"net"
 	"sync"
+	"time"
 
+	"github.com/goburrow/serial"
 	"github.com/tbrandon/mbserver"
 
 	"dmx-gateway/internal/dmx"
@@ -15,67 +18,152 @@ import (
 
 // Config for Modbus TCP server
 type Config struct {
-	Port string `yaml:"port"` // ":502" or ":5020"
+	Port        string       `yaml:"port"` // ":502" or ":5020"
+	UnitID      uint8        // TCP unit id answered, 0 = any (see tcp.go)
+	RejectUnits bool         // Answer other TCP unit ids with exception 0x0B instead of ignoring them
+	RTU         *RTUConfig   // Serial slave, nil = TCP only (see rtu.go)
+	Coils       []Coil       // Coils 2, 3...
+	Map         *RegisterMap // Register and coil addresses, nil = default layout
+	Scale       string       // "percent": channel registers in tenths of a percent (0-1000)
+
+	WSClients        func() int  // Connected WebSocket clients (telemetry, nil = 0)
+	MQTTConnected    func() bool // Every MQTT connection up (health input, nil = false)
+	SchedulerRunning func() bool // Scheduler running (health input, nil = false)
+	Poll             []Device    // External devices polled for readings (see poll.go)
 }
 
-// Server is the Modbus TCP server for DMX gateway
-// Register mapping:
-//   - Holding registers 0-511 = DMX channels 1-512 (value 0-255)
+// Server is the Modbus TCP (and RTU) server for DMX gateway
+// Register mapping (default addresses, see regmap.go to move them):
+//   - Holding registers 0-511 = DMX channels 1-512 (value 0-255, or 0-1000 with percent scale)
+//   - Holding register 512 = scene recall (write 1-based index from the sorted scene list, reads last recalled)
+//   - Holding register 513 = scene recall fade in ms (kept for the next recalls)
+//   - Optional light blocks = one holding register per channel of a light
 //   - Coil 0 = enable (read/write)
 //   - Coil 1 = blackout (write-only, triggers blackout on write 1)
+//   - Coils 2+ = configured lights and groups (preset / off, see coils.go)
+//   - Input registers 0-8 = telemetry (read-only, see telemetry.go)
+//   - Discrete inputs 0-3 = health flags (read-only, see health.go)
+//
+// Each write frame gets a request id, logged with the MBAP transaction id (TCP) or the
+// unit address (RTU) and traced through history and change events, so a PLC write can
+// be followed to the output.
 type Server struct {
 	cfg    *Config
 	state  *dmx.State
+	src    *dmx.Source
 	logger *slog.Logger
-	mb     *mbserver.Server
 	mu     sync.RWMutex
+
+	handlers map[uint8]handler // Function code -> handler (TCP and RTU)
+
+	listener net.Listener          // TCP listener (nil = none)
+	conns    map[net.Conn]struct{} // Open TCP connections, closed by Stop
+	connsMu  sync.Mutex
+	port     serial.Port    // RTU serial port (nil = none)
+	stop     chan struct{}  // Closed by Stop (TCP and RTU loops, pollers)
+	wg       sync.WaitGroup // TCP and RTU loops, pollers
+
+	regMap *RegisterMap        // Effective map (see regmap.go)
+	lights map[uint16]register // Light block registers by address
+
+	lastScene uint16 // Last scene index recalled through the scene register
+	sceneFade uint16 // Fade of scene recalls in ms (fade register)
 }
 
 // NewServer creates a new Modbus TCP server
 func NewServer(cfg *Config, state *dmx.State, logger *slog.Logger) *Server {
-	return &Server{
+	s := &Server{
 		cfg:    cfg,
 		state:  state,
+		src:    state.Source(dmx.SourceModbus),
 		logger: logger,
+		stop:   make(chan struct{}),
+	}
+	s.handlers = s.functions()
+	s.regMap = cfg.Map
+	if s.regMap == nil {
+		s.regMap = &DefaultMap
 	}
+	s.lights = s.lightRegisters()
+	return s
 }
 
-// Start starts the Modbus TCP server
+// Start starts the Modbus TCP server, and the RTU slave and pollers if configured
+// (on failure, what was started is stopped)
 func (s *Server) Start() error {
-	s.mb = mbserver.NewServer()
-
-	// Register custom handlers
-	s.mb.RegisterFunctionHandler(3, s.handleReadHoldingRegisters)  // FC03
-	s.mb.RegisterFunctionHandler(6, s.handleWriteSingleRegister)   // FC06
-	s.mb.RegisterFunctionHandler(16, s.handleWriteMultipleRegisters) // FC16
-	s.mb.RegisterFunctionHandler(1, s.handleReadCoils)             // FC01
-	s.mb.RegisterFunctionHandler(5, s.handleWriteSingleCoil)       // FC05
+	s.startPollers()
+	if s.cfg.RTU != nil {
+		if err := s.startRTU(); err != nil {
+			s.Stop()
+			return err
+		}
+		if s.cfg.Port == "" {
+			return nil // Serial only
+		}
+	}
 
 	addr := s.cfg.Port
 	if addr == "" {
 		addr = ":502"
 	}
+	if err := s.startTCP(addr); err != nil {
+		s.Stop()
+		return err
+	}
+	return nil
+}
 
-	s.logger.Info("Modbus TCP server starting", "addr", addr)
+// Stop stops the Modbus TCP server, the RTU slave and the pollers
+func (s *Server) Stop() {
+	close(s.stop)
+	s.closeTCP()
+	s.wg.Wait()
+	s.closeRTU()
+}
 
-	go func() {
-		if err := s.mb.ListenTCP(addr); err != nil {
-			s.logger.Error("Modbus TCP server error", "error", err)
-		}
-	}()
+// handler answers the data of a request frame
+type handler = func(*mbserver.Server, mbserver.Framer) ([]byte, *mbserver.Exception)
 
-	return nil
+// serve runs frame through the handler of its function code (TCP and RTU)
+func (s *Server) serve(frame mbserver.Framer) mbserver.Framer {
+	resp := frame.Copy()
+	handler, ok := s.handlers[frame.GetFunction()]
+	if !ok {
+		resp.SetException(&mbserver.IllegalFunction)
+		return resp
+	}
+	data, exception := handler(nil, frame)
+	resp.SetData(data)
+	if exception != &mbserver.Success {
+		resp.SetException(exception)
+	}
+	return resp
 }
 
-// Stop stops the Modbus TCP server
-func (s *Server) Stop() {
-	if s.mb != nil {
-		s.mb.Close()
-		s.logger.Info("Modbus TCP server stopped")
+// functions returns the handler of each supported function code
+func (s *Server) functions() map[uint8]handler {
+	return map[uint8]handler{
+		1:  s.handleReadCoils,              // FC01
+		2:  s.handleReadDiscreteInputs,     // FC02
+		3:  s.handleReadHoldingRegisters,   // FC03
+		4:  s.handleReadInputRegisters,     // FC04
+		5:  s.handleWriteSingleCoil,        // FC05
+		6:  s.handleWriteSingleRegister,    // FC06
+		15: s.handleWriteMultipleCoils,     // FC15
+		16: s.handleWriteMultipleRegisters, // FC16
 	}
 }
 
-// FC03: Read Holding Registers (DMX channels)
+// Largest quantity of a request (Modbus application protocol); more answers an
+// illegal data value exception, checked before the addresses
+const (
+	maxReadBits       = 2000 // FC01, FC02
+	maxReadRegisters  = 125  // FC03, FC04
+	maxWriteBits      = 1968 // FC15
+	maxWriteRegisters = 123  // FC16
+)
+
+// FC03: Read Holding Registers (DMX channels, scene, light blocks)
 func (s *Server) handleReadHoldingRegisters(_ *mbserver.Server, frame mbserver.Framer) ([]byte, *mbserver.Exception) {
 	data := frame.GetData()
 	if len(data) < 4 {
@@ -84,20 +172,40 @@ func (s *Server) handleReadHoldingRegisters(_ *mbserver.Server, frame mbserver.F
 
 	startAddr := binary.BigEndian.Uint16(data[0:2])
 	quantity := binary.BigEndian.Uint16(data[2:4])
+	if quantity == 0 || quantity > maxReadRegisters {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
 
-	if startAddr+quantity > 512 {
+	regs, ok := s.registers(startAddr, quantity)
+	if !ok {
 		return []byte{}, &mbserver.IllegalDataAddress
 	}
 
 	channels := s.state.GetChannels()
+	values := s.state.GetValues()
 
-	// Build response: each register = 1 channel (0-255 in low byte)
+	// Build response: each register = 1 channel (0-255 in low byte, or 0-1000)
 	resp := make([]byte, 1+quantity*2)
 	resp[0] = byte(quantity * 2) // byte count
 
-	for i := uint16(0); i < quantity; i++ {
-		ch := startAddr + i
-		val := uint16(channels[ch])
+	for i, reg := range regs {
+		var val uint16
+		switch reg.kind {
+		case regChannel:
+			val = s.toRegister(channels[reg.channel-1])
+		case regScene:
+			s.mu.RLock()
+			val = s.lastScene
+			s.mu.RUnlock()
+		case regFade:
+			s.mu.RLock()
+			val = s.sceneFade
+			s.mu.RUnlock()
+		case regLight:
+			if name, ok := s.lightChannel(reg); ok {
+				val = s.toRegister(values[reg.light][name])
+			}
+		}
 		binary.BigEndian.PutUint16(resp[1+i*2:], val)
 	}
 
@@ -114,20 +222,23 @@ func (s *Server) handleWriteSingleRegister(_ *mbserver.Server, frame mbserver.Fr
 	addr := binary.BigEndian.Uint16(data[0:2])
 	value := binary.BigEndian.Uint16(data[2:4])
 
-	if addr >= 512 {
+	regs, ok := s.registers(addr, 1)
+	if !ok {
 		return []byte{}, &mbserver.IllegalDataAddress
 	}
-	if value > 255 {
-		value = 255
+	src, log := s.request(frame)
+	if regs[0].kind == regScene {
+		if !s.recallScene(src, log, value) {
+			return []byte{}, &mbserver.IllegalDataValue
+		}
+		return data[:4], &mbserver.Success
 	}
-
-	channel := int(addr) + 1 // DMX channels are 1-indexed
-	if err := s.state.SetChannel(channel, uint8(value)); err != nil {
-		s.logger.Warn("Modbus write failed", "ch", channel, "error", err)
+	if err := s.writeRegister(src, regs[0], value); err != nil {
+		log.Warn("Modbus write failed", "register", addr, "error", err)
 		return []byte{}, &mbserver.SlaveDeviceFailure
 	}
 
-	s.logger.Debug("Modbus write", "ch", channel, "value", value)
+	log.Debug("Modbus write", "register", addr, "value", value)
 
 	// Echo request as response
 	return data[:4], &mbserver.Success
@@ -143,27 +254,59 @@ func (s *Server) handleWriteMultipleRegisters(_ *mbserver.Server, frame mbserver
 	startAddr := binary.BigEndian.Uint16(data[0:2])
 	quantity := binary.BigEndian.Uint16(data[2:4])
 	byteCount := data[4]
+	if quantity == 0 || quantity > maxWriteRegisters {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
 
-	if startAddr+quantity > 512 {
+	regs, ok := s.registers(startAddr, quantity)
+	if !ok {
 		return []byte{}, &mbserver.IllegalDataAddress
 	}
 	if int(byteCount) != int(quantity)*2 || len(data) < 5+int(byteCount) {
 		return []byte{}, &mbserver.IllegalDataValue
 	}
 
-	// Write each channel
-	for i := uint16(0); i < quantity; i++ {
+	// Apply the block as one mutation (one backend write and one broadcast): the fade
+	// register first so that a block with both applies it, then the channels and light
+	// blocks together, then the scene recall
+	src, log := s.request(frame)
+	var scene *uint16
+	start, channels, lights := 0, []uint8(nil), []dmx.TargetValues(nil)
+	for i, reg := range regs {
 		value := binary.BigEndian.Uint16(data[5+i*2:])
-		if value > 255 {
-			value = 255
+		switch reg.kind {
+		case regFade:
+			s.writeRegister(src, reg, value)
+		case regScene:
+			scene = &value
+		case regChannel:
+			if channels == nil {
+				start = reg.channel // Channel registers of a block are consecutive
+			}
+			channels = append(channels, s.fromRegister(value))
+		case regLight:
+			name, ok := s.lightChannel(reg)
+			if !ok {
+				log.Warn("Modbus write failed", "register", startAddr+uint16(i), "error", "channel removed from "+reg.light)
+				return []byte{}, &mbserver.SlaveDeviceFailure
+			}
+			if len(lights) == 0 || lights[len(lights)-1].Target != reg.light {
+				lights = append(lights, dmx.TargetValues{Target: reg.light, Values: make(map[string]uint8)})
+			}
+			lights[len(lights)-1].Values[name] = s.fromRegister(value)
 		}
-		channel := int(startAddr+i) + 1
-		if err := s.state.SetChannel(channel, uint8(value)); err != nil {
-			s.logger.Warn("Modbus write failed", "ch", channel, "error", err)
+	}
+	if channels != nil || lights != nil {
+		if err := src.SetBulk(start, channels, lights); err != nil {
+			log.Warn("Modbus write failed", "start", startAddr, "count", quantity, "error", err)
+			return []byte{}, &mbserver.SlaveDeviceFailure
 		}
 	}
+	if scene != nil && !s.recallScene(src, log, *scene) {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
 
-	s.logger.Debug("Modbus write multiple", "start", startAddr+1, "count", quantity)
+	log.Debug("Modbus write multiple", "start", startAddr, "count", quantity)
 
 	// Response: start addr + quantity
 	resp := make([]byte, 4)
@@ -172,7 +315,38 @@ func (s *Server) handleWriteMultipleRegisters(_ *mbserver.Server, frame mbserver
 	return resp, &mbserver.Success
 }
 
-// FC01: Read Coils (enable status)
+// recallScene recalls a scene by its 1-based index with the fade of the fade register;
+// returns false if the index is unknown
+func (s *Server) recallScene(src *dmx.Source, log *slog.Logger, index uint16) bool {
+	s.mu.RLock()
+	fade := time.Duration(s.sceneFade) * time.Millisecond
+	s.mu.RUnlock()
+	if err := src.RecallSceneIndex(int(index), fade); err != nil {
+		log.Warn("Modbus scene recall failed", "index", index, "error", err)
+		return false
+	}
+	s.mu.Lock()
+	s.lastScene = index
+	s.mu.Unlock()
+	log.Debug("Modbus scene recall", "index", index, "fade", fade)
+	return true
+}
+
+// request returns the write handle traced with a new request id for frame, and a
+// logger carrying that id and the MBAP transaction id or RTU unit address
+func (s *Server) request(frame mbserver.Framer) (*dmx.Source, *slog.Logger) {
+	id := dmx.NewRequestID()
+	log := s.logger.With("request_id", id)
+	switch f := frame.(type) {
+	case *mbserver.TCPFrame:
+		log = log.With("transaction", f.TransactionIdentifier)
+	case *mbserver.RTUFrame:
+		log = log.With("unit", f.Address)
+	}
+	return s.src.WithRequest(id), log
+}
+
+// FC01: Read Coils (enable status, light and group coils)
 func (s *Server) handleReadCoils(_ *mbserver.Server, frame mbserver.Framer) ([]byte, *mbserver.Exception) {
 	data := frame.GetData()
 	if len(data) < 4 {
@@ -181,22 +355,36 @@ func (s *Server) handleReadCoils(_ *mbserver.Server, frame mbserver.Framer) ([]b
 
 	startAddr := binary.BigEndian.Uint16(data[0:2])
 	quantity := binary.BigEndian.Uint16(data[2:4])
+	if quantity == 0 || quantity > maxReadBits {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
 
-	if startAddr+quantity > 2 {
+	first, ok := s.coilRange(startAddr, quantity)
+	if !ok {
 		return []byte{}, &mbserver.IllegalDataAddress
 	}
 
-	// Coil 0 = enabled, Coil 1 = always 0 (blackout is write-only)
-	var coils byte
-	if s.state.IsEnabled() {
-		coils |= 0x01
+	// Coil 0 = enabled, Coil 1 = always 0 (blackout is write-only), Coils 2+ = target on
+	values := s.state.GetValues()
+	resp := make([]byte, 1+(quantity+7)/8)
+	resp[0] = byte(len(resp) - 1) // byte count
+	for i := uint16(0); i < quantity; i++ {
+		var on bool
+		switch addr := first + i; addr {
+		case 0:
+			on = s.state.IsEnabled()
+		case 1:
+		default:
+			on = s.coilOn(values, addr)
+		}
+		if on {
+			resp[1+i/8] |= 1 << (i % 8)
+		}
 	}
-
-	resp := []byte{1, coils} // byte count + coils byte
 	return resp, &mbserver.Success
 }
 
-// FC05: Write Single Coil (enable/disable/blackout)
+// FC05: Write Single Coil (enable/disable/blackout, light and group coils)
 func (s *Server) handleWriteSingleCoil(_ *mbserver.Server, frame mbserver.Framer) ([]byte, *mbserver.Exception) {
 	data := frame.GetData()
 	if len(data) < 4 {
@@ -206,32 +394,50 @@ func (s *Server) handleWriteSingleCoil(_ *mbserver.Server, frame mbserver.Framer
 	addr := binary.BigEndian.Uint16(data[0:2])
 	value := binary.BigEndian.Uint16(data[2:4])
 
-	on := value == 0xFF00
+	coil, ok := s.coilRange(addr, 1)
+	if !ok {
+		return []byte{}, &mbserver.IllegalDataAddress
+	}
+	if value != 0xFF00 && value != 0 {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
+	src, log := s.request(frame)
+	if exception := s.writeCoil(src, log, coil, value == 0xFF00); exception != &mbserver.Success {
+		return []byte{}, exception
+	}
+
+	// Echo request as response
+	return data[:4], &mbserver.Success
+}
 
+// writeCoil switches one coil
+func (s *Server) writeCoil(src *dmx.Source, log *slog.Logger, addr uint16, on bool) *mbserver.Exception {
 	switch addr {
 	case 0: // Enable/disable
 		if on {
-			if err := s.state.Enable(); err != nil {
-				return []byte{}, &mbserver.SlaveDeviceFailure
+			if err := src.Enable(); err != nil {
+				return &mbserver.SlaveDeviceFailure
 			}
-			s.logger.Info("Modbus: DMX enabled")
+			log.Info("Modbus: DMX enabled")
 		} else {
-			if err := s.state.Disable(); err != nil {
-				return []byte{}, &mbserver.SlaveDeviceFailure
+			if err := src.Disable(); err != nil {
+				return &mbserver.SlaveDeviceFailure
 			}
-			s.logger.Info("Modbus: DMX disabled")
+			log.Info("Modbus: DMX disabled")
 		}
 	case 1: // Blackout (only on write 1)
 		if on {
-			if err := s.state.Blackout(); err != nil {
-				return []byte{}, &mbserver.SlaveDeviceFailure
+			if err := src.Blackout(); err != nil {
+				return &mbserver.SlaveDeviceFailure
 			}
-			s.logger.Info("Modbus: Blackout triggered")
+			log.Info("Modbus: Blackout triggered")
 		}
-	default:
-		return []byte{}, &mbserver.IllegalDataAddress
+	default: // Light and group coils (see coils.go)
+		if err := s.switchCoil(src, addr, on); err != nil {
+			log.Warn("Modbus coil failed", "coil", addr, "error", err)
+			return &mbserver.SlaveDeviceFailure
+		}
+		log.Debug("Modbus coil", "coil", addr, "on", on)
 	}
-
-	// Echo request as response
-	return data[:4], &mbserver.Success
+	return &mbserver.Success
 }
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/tbrandon/mbserver"
+)
+
+// Modbus TCP server
+// Frames are delimited by the length of their MBAP header, so a segment may carry part
+// of a frame or several, and answered in order on their connection. With a unit id set,
+// frames addressed to another unit are dropped (the client times out, as behind a
+// Modbus router with no such device), or answered with exception 0x0B, gateway target
+// device failed to respond, when other units are rejected. Frames with a protocol id
+// other than 0 are dropped; a length out of range closes the connection, as framing is
+// lost.
+//
+// mbserver.ListenTCP is not used: it takes each read for one frame and answers every
+// unit.
+
+const (
+	tcpHeader = 7   // MBAP header: transaction, protocol, length, unit
+	tcpMaxLen = 254 // Largest MBAP length (unit + PDU)
+)
+
+// startTCP listens on addr and starts answering connections
+func (s *Server) startTCP(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("modbus tcp: %w", err)
+	}
+	s.listener = ln
+	s.conns = make(map[net.Conn]struct{})
+	s.wg.Add(1)
+	go s.acceptTCP(ln)
+	s.logger.Info("Modbus TCP server started", "addr", ln.Addr().String(), "unit", s.cfg.UnitID)
+	return nil
+}
+
+// closeTCP closes the listener and the open connections (their loops then return)
+func (s *Server) closeTCP() {
+	if s.listener == nil {
+		return
+	}
+	s.listener.Close()
+	s.connsMu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsMu.Unlock()
+	s.logger.Info("Modbus TCP server stopped")
+}
+
+// acceptTCP serves the connections of ln until it is closed
+func (s *Server) acceptTCP(ln net.Listener) {
+	defer s.wg.Done()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-s.stop:
+			default:
+				s.logger.Error("Modbus TCP accept failed", "error", err)
+			}
+			return
+		}
+		s.connsMu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connsMu.Unlock()
+		s.wg.Add(1)
+		go s.serveTCP(conn)
+	}
+}
+
+// serveTCP reads frames from conn until it is closed and answers them
+func (s *Server) serveTCP(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.connsMu.Lock()
+		delete(s.conns, conn)
+		s.connsMu.Unlock()
+		conn.Close()
+	}()
+
+	header := make([]byte, tcpHeader)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return // Closed by the client or Stop
+		}
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		if length < 2 || length > tcpMaxLen {
+			s.logger.Debug("Modbus TCP bad length, connection closed", "remote", conn.RemoteAddr().String(), "length", length)
+			return
+		}
+		packet := make([]byte, tcpHeader-1+length)
+		copy(packet, header)
+		if _, err := io.ReadFull(conn, packet[tcpHeader:]); err != nil {
+			return
+		}
+		resp := s.handleTCP(packet)
+		if resp == nil {
+			continue
+		}
+		if _, err := conn.Write(resp); err != nil {
+			s.logger.Debug("Modbus TCP write failed", "error", err)
+			return
+		}
+	}
+}
+
+// handleTCP runs one frame and returns the answer (nil = none)
+func (s *Server) handleTCP(packet []byte) []byte {
+	frame, err := mbserver.NewTCPFrame(packet)
+	if err != nil || frame.ProtocolIdentifier != 0 {
+		s.logger.Debug("Modbus TCP frame dropped", "error", err)
+		return nil
+	}
+	if unit := s.cfg.UnitID; unit != 0 && frame.Device != unit {
+		if !s.cfg.RejectUnits {
+			return nil
+		}
+		resp := frame.Copy()
+		resp.SetException(&mbserver.GatewayTargetDeviceFailedtoRespond)
+		return resp.Bytes()
+	}
+	return s.serve(frame).Bytes()
+}
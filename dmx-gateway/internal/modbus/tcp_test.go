@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tbrandon/mbserver"
+)
+
+// tcpFrame returns a Modbus TCP frame (transaction 1)
+func tcpFrame(unit, function uint8, data ...byte) []byte {
+	return (&mbserver.TCPFrame{TransactionIdentifier: 1, Device: unit, Function: function, Data: data}).Bytes()
+}
+
+func TestHandleTCP(t *testing.T) {
+	s := newTestServer(t)
+
+	// Any unit is answered without a unit id
+	req := tcpFrame(9, 6, 0, 0, 0, 200)
+	if resp := s.handleTCP(req); !bytes.Equal(resp, req) {
+		t.Errorf("expected echo %x, got %x", req, resp)
+	}
+
+	// Another unit: ignored, or rejected with a gateway exception
+	s.cfg.UnitID = 7
+	if resp := s.handleTCP(tcpFrame(9, 6, 0, 0, 0, 10)); resp != nil {
+		t.Errorf("expected no answer for unit 9, got %x", resp)
+	}
+	s.cfg.RejectUnits = true
+	want := tcpFrame(9, 6|0x80, byte(mbserver.GatewayTargetDeviceFailedtoRespond))
+	if resp := s.handleTCP(tcpFrame(9, 6, 0, 0, 0, 10)); !bytes.Equal(resp, want) {
+		t.Errorf("expected gateway exception %x, got %x", want, resp)
+	}
+	if ch := s.state.GetChannels(); ch[0] != 200 {
+		t.Errorf("expected channel 1 unchanged, got %d", ch[0])
+	}
+
+	// Quantity over the protocol limit: illegal data value, before the address check
+	want = tcpFrame(7, 3|0x80, byte(mbserver.IllegalDataValue))
+	if resp := s.handleTCP(tcpFrame(7, 3, 0, 0, 0, 126)); !bytes.Equal(resp, want) {
+		t.Errorf("expected illegal data value %x, got %x", want, resp)
+	}
+
+	// Unknown protocol id: dropped
+	bad := tcpFrame(7, 3, 0, 0, 0, 1)
+	bad[3] = 1
+	if resp := s.handleTCP(bad); resp != nil {
+		t.Errorf("expected no answer to protocol 1, got %x", resp)
+	}
+}
+
+func TestServeTCP(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.UnitID = 7
+	if err := s.startTCP("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	// Two frames split across writes, the first for another unit (ignored)
+	stream := append(tcpFrame(8, 6, 0, 0, 0, 1), tcpFrame(7, 6, 0, 1, 0, 60)...)
+	conn.Write(stream[:5])
+	time.Sleep(10 * time.Millisecond)
+	conn.Write(stream[5:])
+
+	want := tcpFrame(7, 6, 0, 1, 0, 60)
+	resp := make([]byte, len(want))
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resp, want) {
+		t.Errorf("expected %x, got %x", want, resp)
+	}
+	if ch := s.state.GetChannels(); ch[0] != 0 || ch[1] != 60 {
+		t.Errorf("expected channels 0, 60, got %d, %d", ch[0], ch[1])
+	}
+}
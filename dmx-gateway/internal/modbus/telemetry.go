@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/tbrandon/mbserver"
+)
+
+// Telemetry input registers (FC04, read-only)
+// A PLC supervises the gateway by polling these registers:
+//   0   = output enabled (0/1)
+//   1   = FPS x100 (e.g. 4400 = 44.00)
+//   2-3 = frame count (low 32 bits)
+//   4-5 = backend error count (low 32 bits)
+//   6-7 = uptime in seconds
+//   8   = connected WebSocket clients
+// 32-bit values span two registers, high word first.
+
+const (
+	inEnabled   = 0
+	inFPS       = 1
+	inFrames    = 2
+	inErrors    = 4
+	inUptime    = 6
+	inWSClients = 8
+	inCount     = 9
+)
+
+// startTime is the process start, for the uptime register
+var startTime = time.Now()
+
+// telemetry returns the current values of the input registers
+func (s *Server) telemetry() [inCount]uint16 {
+	var regs [inCount]uint16
+	status := s.state.GetStatus()
+	if status.Enabled {
+		regs[inEnabled] = 1
+	}
+	regs[inFPS] = uint16(min(math.Round(status.FPS*100), math.MaxUint16))
+	putUint32(regs[inFrames:], uint32(status.FrameCount))
+	putUint32(regs[inErrors:], uint32(status.Errors))
+	putUint32(regs[inUptime:], uint32(time.Since(startTime).Seconds()))
+	if s.cfg.WSClients != nil {
+		regs[inWSClients] = uint16(min(s.cfg.WSClients(), math.MaxUint16))
+	}
+	return regs
+}
+
+// putUint32 stores v in two registers, high word first
+func putUint32(regs []uint16, v uint32) {
+	regs[0] = uint16(v >> 16)
+	regs[1] = uint16(v)
+}
+
+// FC04: Read Input Registers (telemetry)
+func (s *Server) handleReadInputRegisters(_ *mbserver.Server, frame mbserver.Framer) ([]byte, *mbserver.Exception) {
+	data := frame.GetData()
+	if len(data) < 4 {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
+
+	startAddr := binary.BigEndian.Uint16(data[0:2])
+	quantity := binary.BigEndian.Uint16(data[2:4])
+	if quantity == 0 || quantity > maxReadRegisters {
+		return []byte{}, &mbserver.IllegalDataValue
+	}
+
+	if int(startAddr)+int(quantity) > inCount {
+		return []byte{}, &mbserver.IllegalDataAddress
+	}
+
+	regs := s.telemetry()
+	resp := make([]byte, 1+quantity*2)
+	resp[0] = byte(quantity * 2) // byte count
+	for i := uint16(0); i < quantity; i++ {
+		binary.BigEndian.PutUint16(resp[1+i*2:], regs[startAddr+i])
+	}
+	return resp, &mbserver.Success
+}
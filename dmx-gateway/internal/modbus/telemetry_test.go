@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package modbus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tbrandon/mbserver"
+)
+
+func TestReadInputRegisters(t *testing.T) {
+	s := newTestServer(t)
+	s.cfg.WSClients = func() int { return 3 }
+	s.state.Enable()
+
+	resp := s.handleRTU(rtuFrame(7, 4, 0, 0, 0, inCount))
+	if len(resp) != 3+2*inCount+2 || resp[2] != 2*inCount {
+		t.Fatalf("unexpected answer %x", resp)
+	}
+	reg := func(i int) uint16 { return uint16(resp[3+2*i])<<8 | uint16(resp[4+2*i]) }
+	if reg(inEnabled) != 1 || reg(inWSClients) != 3 {
+		t.Errorf("expected enabled 1 and 3 clients, got %d and %d", reg(inEnabled), reg(inWSClients))
+	}
+
+	if resp := s.handleRTU(rtuFrame(7, 4, 0, 8, 0, 2)); !bytes.Equal(resp, rtuFrame(7, 4|0x80, byte(mbserver.IllegalDataAddress))) {
+		t.Errorf("expected illegal address past the map, got %x", resp)
+	}
+}
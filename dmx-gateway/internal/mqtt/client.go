@@ -6,12 +6,14 @@ package mqtt
 import (
 	"encoding/json"
 	"log/slog"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 
 	"dmx-gateway/internal/api"
 	"dmx-gateway/internal/dmx"
+	"dmx-gateway/internal/scheduler"
 )
 
 // Config for MQTT client
@@ -21,6 +23,37 @@ type Config struct {
 	Username string `yaml:"username"`     // optional
 	Password string `yaml:"password"`     // optional
 	Prefix   string `yaml:"topic_prefix"` // topic prefix, defaults to "dmx"
+	Targets  []string `yaml:"targets"`    // optional event filter (groups/lights, empty = all)
+	QoS      map[string]byte `yaml:"qos"`    // Topic class -> QoS (see config.MQTTQoSClasses, defaultQoS)
+	Retain   map[string]bool `yaml:"retain"` // Topic class -> retained publishes (see defaultRetain)
+
+	EventIntervalMs int `yaml:"event_interval_ms"` // Minimum interval between state publishes (see throttle.go)
+
+	Inputs []Input // External topics stored as variables (see inputs.go)
+
+	NextEvent func() *scheduler.NextEventInfo // Event due next, nil without scheduler (see schedule.go)
+}
+
+// Per topic class defaults (former hard-coded behavior)
+var (
+	defaultQoS    = map[string]byte{"command": 1}
+	defaultRetain = map[string]bool{"status": true, "light": true}
+)
+
+// qos returns the QoS of a topic class
+func (c *Client) qos(class string) byte {
+	if q, ok := c.cfg.QoS[class]; ok {
+		return q
+	}
+	return defaultQoS[class]
+}
+
+// retain reports whether publishes of a topic class are retained
+func (c *Client) retain(class string) bool {
+	if r, ok := c.cfg.Retain[class]; ok {
+		return r
+	}
+	return defaultRetain[class]
 }
 
 // Client is the MQTT client for DMX gateway
@@ -30,7 +63,12 @@ type Client struct {
 	state     *dmx.State
 	logger    *slog.Logger
 	client    mqtt.Client
+	updates   chan []byte // State subscription forwarded to <prefix>/event
 	stopChan  chan struct{}
+
+	lights     chan []byte       // Unfiltered state subscription for light state topics (see lights.go)
+	lightsMu   sync.Mutex
+	lastStates map[string][]byte // State topic -> last payload published (lights and groups)
 }
 
 // NewClient creates a new MQTT client
@@ -44,14 +82,16 @@ func NewClient(cfg *Config, state *dmx.State, logger *slog.Logger) *Client {
 
 	return &Client{
 		cfg:      cfg,
-		api:      api.NewHandler(state),
+		api:      api.NewHandler(state, dmx.SourceMQTT),
 		state:    state,
-		logger:   logger,
+		logger:   logger.With("broker", cfg.Broker),
 		stopChan: make(chan struct{}),
+
+		lastStates: make(map[string][]byte),
 	}
 }
 
-// Start connects to broker and subscribes to topics
+// Start connects to broker in the background and subscribes to topics once connected
 func (c *Client) Start() error {
 	opts := mqtt.NewClientOptions()
 	opts.AddBroker(c.cfg.Broker)
@@ -68,39 +108,66 @@ func (c *Client) Start() error {
 	opts.SetOnConnectHandler(c.onConnect)
 	opts.SetConnectionLostHandler(c.onConnectionLost)
 
+	// Not waited for: with connect retry the token only completes once connected, and
+	// an unreachable broker must not hold up the caller (onConnect sets up the session)
 	c.client = mqtt.NewClient(opts)
-	token := c.client.Connect()
-	token.Wait()
-	if err := token.Error(); err != nil {
-		return err
-	}
+	c.client.Connect()
 
 	// Start event forwarder
+	c.updates = c.state.Subscribe()
+	if err := c.state.SetFilter(c.updates, c.cfg.Targets); err != nil {
+		c.logger.Warn("MQTT event filter ignored", "error", err)
+	}
 	go c.forwardEvents()
+	c.lights = c.state.Subscribe()
+	go c.forwardLights()
 
-	c.logger.Info("MQTT client started", "broker", c.cfg.Broker, "prefix", c.cfg.Prefix)
+	c.logger.Info("MQTT client started", "prefix", c.cfg.Prefix)
 	return nil
 }
 
 // Stop disconnects from broker
 func (c *Client) Stop() {
 	close(c.stopChan)
-	if c.client != nil && c.client.IsConnected() {
-		c.client.Disconnect(1000)
+	if c.client != nil {
+		c.client.Disconnect(1000) // Also ends connect retries
 	}
 	c.logger.Info("MQTT client stopped")
 }
 
+// Connected reports whether the broker connection is up
+func (c *Client) Connected() bool {
+	return c.client != nil && c.client.IsConnected()
+}
+
 func (c *Client) onConnect(client mqtt.Client) {
 	c.logger.Info("MQTT connected")
 
 	// Subscribe to command topic
 	cmdTopic := c.cfg.Prefix + "/cmd"
-	client.Subscribe(cmdTopic, 1, c.handleCommand)
+	client.Subscribe(cmdTopic, c.qos("command"), c.handleCommand)
 	c.logger.Debug("MQTT subscribed", "topic", cmdTopic)
 
-	// Publish initial status
+	// Per-light and per-group set topics (see lights.go), scene and preset recall
+	// topics (see recall.go)
+	for topic, handler := range map[string]mqtt.MessageHandler{
+		c.lightTopic("+/+", "set"):  c.handleLightSet,
+		c.groupTopic("+", "set"):    c.handleGroupSet,
+		c.cfg.Prefix + "/scene/set": c.handleSceneSet,
+		c.cfg.Prefix + "/preset/#":  c.handlePresetSet,
+	} {
+		client.Subscribe(topic, c.qos("command"), handler)
+		c.logger.Debug("MQTT subscribed", "topic", topic)
+	}
+
+	c.subscribeInputs(client)
+
+	// Publish initial status, inventory, next event and light states
 	c.publishStatus()
+	c.publishInventory(nil)
+	c.PublishNextEvent()
+	c.resetLights()
+	c.clearStaleLights(client)
 }
 
 func (c *Client) onConnectionLost(client mqtt.Client, err error) {
@@ -111,26 +178,51 @@ func (c *Client) onConnectionLost(client mqtt.Client, err error) {
 func (c *Client) handleCommand(client mqtt.Client, msg mqtt.Message) {
 	c.logger.Debug("MQTT command received", "topic", msg.Topic(), "payload", string(msg.Payload()))
 
-	// Use unified API handler
-	resp := c.api.HandleJSON(msg.Payload())
+	// Event filter applies to this client's forwarder, not to the unified API
+	var sub struct {
+		Cmd     string   `json:"cmd"`
+		Targets []string `json:"targets"`
+		replyTo
+	}
+	err := json.Unmarshal(msg.Payload(), &sub)
+	if !c.checkReply(client, sub.replyTo) {
+		return
+	}
+	if err == nil && sub.Cmd == "subscribe" {
+		result := &api.Response{Type: "ok"}
+		if err := c.state.SetFilter(c.updates, sub.Targets); err != nil {
+			result = &api.Response{Type: "error", Error: api.ErrorOf(err, "")}
+		}
+		c.respond(client, sub.replyTo, result)
+		return
+	}
 
-	// Publish response
-	respTopic := c.cfg.Prefix + "/response"
-	client.Publish(respTopic, 0, false, resp)
+	// Use unified API handler
+	result := c.api.HandleData(msg.Payload(), sub.ID)
+	c.logger.Debug("MQTT command", "topic", msg.Topic(), "request_id", result.RequestID)
+	c.respond(client, sub.replyTo, result)
 }
 
 // forwardEvents forwards DMX state changes to MQTT
 func (c *Client) forwardEvents() {
-	updates := c.state.Subscribe()
-	defer c.state.Unsubscribe(updates)
+	defer c.state.Unsubscribe(c.updates)
 
+	t := &throttle{interval: time.Duration(c.cfg.EventIntervalMs) * time.Millisecond}
+	defer t.stop()
 	for {
 		select {
-		case data, ok := <-updates:
+		case data, ok := <-c.updates:
 			if !ok {
 				return
 			}
-			c.publishEvent(data)
+			if stateMessage(data) {
+				data = t.offer(data)
+			}
+			if data != nil {
+				c.publishEvent(data)
+			}
+		case <-t.C():
+			c.publishEvent(t.flush())
 		case <-c.stopChan:
 			return
 		}
@@ -144,7 +236,7 @@ func (c *Client) publishEvent(data []byte) {
 	}
 
 	topic := c.cfg.Prefix + "/event"
-	c.client.Publish(topic, 0, false, data)
+	c.client.Publish(topic, c.qos("event"), c.retain("event"), data)
 }
 
 // MQTTStatusMessage for status publish (typed to avoid map allocation)
@@ -164,5 +256,5 @@ func (c *Client) publishStatus() {
 		Data: c.state.GetStatus(),
 	})
 	topic := c.cfg.Prefix + "/status"
-	c.client.Publish(topic, 0, true, data) // retained
+	c.client.Publish(topic, c.qos("status"), c.retain("status"), data)
 }
@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestStartUnreachable(t *testing.T) {
+	c := NewClient(&Config{Broker: "tcp://127.0.0.1:1"}, testState(t), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Start()
+		c.Stop()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Start blocked on an unreachable broker")
+	}
+	if c.Connected() {
+		t.Error("expected no connection")
+	}
+}
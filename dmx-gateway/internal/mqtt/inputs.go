@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Sensor inputs
+// mqtt.inputs subscribes to external topics (temperature, PAR sensors, occupancy...)
+// and stores each payload as a named variable in the state (see dmx/variables.go),
+// read by the API (/api/variables) and schedule rules. A JSON payload is read at the
+// input's dotted field path ({"sensor": {"temp": 21.5}} with field "sensor.temp").
+
+// Input maps a topic to a variable
+type Input struct {
+	Topic string
+	Name  string
+	Field string // Dotted JSON path, empty = whole payload
+}
+
+// subscribeInputs subscribes to the input topics
+func (c *Client) subscribeInputs(client mqtt.Client) {
+	for _, in := range c.cfg.Inputs {
+		client.Subscribe(in.Topic, c.qos("command"), func(_ mqtt.Client, msg mqtt.Message) {
+			raw, err := inputValue(msg.Payload(), in.Field)
+			if err != nil {
+				c.logger.Warn("MQTT input ignored", "topic", msg.Topic(), "variable", in.Name, "error", err)
+				return
+			}
+			v := c.state.SetVariable(in.Name, raw, msg.Topic())
+			c.logger.Debug("MQTT input", "topic", msg.Topic(), "variable", in.Name, "value", v.Value, "numeric", v.Numeric)
+		})
+		c.logger.Debug("MQTT subscribed", "topic", in.Topic, "variable", in.Name)
+	}
+}
+
+// inputValue extracts the raw value at field from a payload
+func inputValue(payload []byte, field string) (string, error) {
+	if field == "" {
+		return string(payload), nil
+	}
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	for _, key := range strings.Split(field, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("no field %s", field)
+		}
+		if v, ok = obj[key]; !ok {
+			return "", fmt.Errorf("no field %s", field)
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64, bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("field %s is not a value", field)
+}
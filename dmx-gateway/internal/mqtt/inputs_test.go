@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import "testing"
+
+func TestInputValue(t *testing.T) {
+	for _, tc := range []struct {
+		payload, field, want string
+		ok                   bool
+	}{
+		{"21.5", "", "21.5", true},
+		{`{"sensor":{"temp":21.5}}`, "sensor.temp", "21.5", true},
+		{`{"occupancy":true}`, "occupancy", "true", true},
+		{`{"state":"ON"}`, "state", "ON", true},
+		{`{"sensor":{}}`, "sensor.temp", "", false},
+		{`{"sensor":{"temp":[1]}}`, "sensor.temp", "", false},
+		{"21.5", "temp", "", false},
+	} {
+		got, err := inputValue([]byte(tc.payload), tc.field)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("%s @ %q: expected %q (ok %v), got %q (%v)", tc.payload, tc.field, tc.want, tc.ok, got, err)
+		}
+	}
+}
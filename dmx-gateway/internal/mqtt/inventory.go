@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Fixture inventory
+// <prefix>/inventory holds, retained, the same message a WebSocket client gets on
+// connect: {"type":"init","enabled":...,"groups":[...],"lights":{key: {channels, colors,
+// values, tags...}}}, so MQTT-only consumers can build their UI without the HTTP API.
+// It is published on each connection and again when lights are provisioned, removed,
+// masked or unmasked (the gateway then sends subscribers a fresh init message).
+
+// initMessage reports whether data is an init message (WSInitMessage marshals its type first)
+func initMessage(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(`{"type":"init"`))
+}
+
+// publishInventory publishes the retained inventory (nil data = current state)
+func (c *Client) publishInventory(data []byte) {
+	if c.client == nil || !c.client.IsConnected() {
+		return
+	}
+	if data == nil {
+		data, _ = json.Marshal(c.state.GetInitMessage())
+	}
+	c.client.Publish(c.cfg.Prefix+"/inventory", c.qos("status"), true, data)
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+	"dmx-gateway/internal/scheduler"
+)
+
+func testState(t *testing.T) *dmx.State {
+	t.Helper()
+	state, _ := dmx.NewStateWithMock(&config.Config{
+		DMX:    config.DMXConfig{Client: "mock"},
+		Lights: map[string]map[string][]config.Channel{"rack1": {"level1": {{Ch: 1, Color: "blue"}}}},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	state.Enable()
+	return state
+}
+
+// TestMessageTypes checks the detection against the messages subscribers get
+func TestMessageTypes(t *testing.T) {
+	state := testState(t)
+	sub := state.Subscribe()
+	defer state.Unsubscribe(sub)
+
+	if err := state.AddLight("rack1", "level2", []config.Channel{{Ch: 2, Color: "red"}}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.ScheduleConfig{}
+	sched, err := scheduler.New(cfg, state, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sched.RunEvent(cfg, config.ScheduleEvent{ID: "1", Time: "06:00", Set: map[string]map[string]uint8{"rack1": {"blue": 50}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	timeout := time.After(time.Second)
+	for !seen["init"] || !seen["schedule"] || !seen["state"] {
+		select {
+		case data := <-sub:
+			var msg struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal(data, &msg)
+			detected := map[string]bool{"state": stateMessage(data), "init": initMessage(data), "schedule": scheduleMessage(data)}
+			for typ, ok := range detected {
+				if ok != (msg.Type == typ) {
+					t.Errorf("%s message detected as %s: %v", msg.Type, typ, ok)
+				}
+			}
+			seen[msg.Type] = true
+		case <-timeout:
+			t.Fatalf("expected init, schedule and state messages, got %v", seen)
+		}
+	}
+}
+
+func TestPublishInventory(t *testing.T) {
+	c, fake := newTestClient()
+	c.state = testState(t)
+
+	c.publishInventory(nil)
+	var inventory dmx.WSInitMessage
+	if err := json.Unmarshal(fake.take()["dmx/inventory"], &inventory); err != nil {
+		t.Fatal(err)
+	}
+	if inventory.Type != "init" || inventory.Lights["rack1/level1"] == nil || !fake.retained["dmx/inventory"] {
+		t.Errorf("expected the current inventory retained, got %+v", inventory)
+	}
+
+	c.publishInventory([]byte(`{"type":"init","lights":{}}`))
+	if got := string(fake.take()["dmx/inventory"]); got != `{"type":"init","lights":{}}` {
+		t.Errorf("expected the init message forwarded, got %s", got)
+	}
+}
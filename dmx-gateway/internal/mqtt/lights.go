@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"dmx-gateway/internal/api"
+	"dmx-gateway/internal/dmx"
+)
+
+// Per-light and per-group topics
+// Besides <prefix>/cmd, each light and group has its own topics, as most MQTT tooling
+// expects:
+//   <prefix>/light/<group>/<name>/set   {"red": 200, "blue": 0} (optional "fade_ms")
+//   <prefix>/light/<group>/<name>/state {"key": "rack1/level1", "values": {...}}, retained
+//   <prefix>/group/<group>/set          same payload, applied to every light (set on a group)
+//   <prefix>/group/<group>/state        {"group": "rack1", "values": {...}, "uniform": true}
+// A group state holds the highest value of each channel across its lights; uniform
+// tells whether all lights have the same values. Set payloads go through the unified
+// API (undo point, request id); failures are answered on <prefix>/response. States
+// are republished when their values change, and cleared when the light or group is
+// removed.
+//
+// States are retained (unless mqtt.retain.light is false) so a client connecting later
+// (dashboard, Home Assistant after a restart) gets every light's current values at once.
+// All are republished on each (re)connection, and retained states of lights removed
+// while the gateway was offline are cleared: the broker delivers them on subscription
+// during staleWindow. Group states follow the light retain setting.
+
+// staleWindow is how long retained states are checked after a connection
+const staleWindow = 2 * time.Second
+
+// MQTTLightState is the retained payload of a light state topic
+type MQTTLightState struct {
+	Key    string           `json:"key"`
+	Values map[string]uint8 `json:"values"`
+}
+
+// MQTTGroupState is the retained payload of a group state topic
+type MQTTGroupState struct {
+	Group   string           `json:"group"`
+	Values  map[string]uint8 `json:"values"`  // Highest value per channel across the lights
+	Uniform bool             `json:"uniform"` // All lights have the same values
+}
+
+// lightTopic returns the topic of a light ("set" or "state")
+func (c *Client) lightTopic(key, kind string) string {
+	return c.cfg.Prefix + "/light/" + key + "/" + kind
+}
+
+// groupTopic returns the topic of a group ("set" or "state")
+func (c *Client) groupTopic(group, kind string) string {
+	return c.cfg.Prefix + "/group/" + group + "/" + kind
+}
+
+// handleLightSet applies a <prefix>/light/<group>/<name>/set payload
+func (c *Client) handleLightSet(client mqtt.Client, msg mqtt.Message) {
+	key := strings.TrimSuffix(strings.TrimPrefix(msg.Topic(), c.cfg.Prefix+"/light/"), "/set")
+	c.set(client, key, msg.Payload())
+}
+
+// handleGroupSet applies a <prefix>/group/<group>/set payload
+func (c *Client) handleGroupSet(client mqtt.Client, msg mqtt.Message) {
+	group := strings.TrimSuffix(strings.TrimPrefix(msg.Topic(), c.cfg.Prefix+"/group/"), "/set")
+	c.set(client, group, msg.Payload())
+}
+
+// set applies a set payload to a light or group, answering only failures
+func (c *Client) set(client mqtt.Client, target string, payload []byte) {
+	c.logger.Debug("MQTT set received", "target", target, "payload", string(payload))
+
+	req, err := setRequest(target, payload)
+	if err != nil {
+		c.respond(client, replyTo{}, &api.Response{Type: "error", Target: target, Error: api.NewError(api.CodeBadRequest, target, err.Error())})
+		return
+	}
+	resp := c.api.Handle(req)
+	c.logger.Debug("MQTT set", "target", target, "request_id", resp.RequestID)
+	if resp.Error != nil {
+		c.respond(client, replyTo{}, resp)
+	}
+}
+
+// setRequest converts a set payload into a unified API set command
+func setRequest(key string, payload []byte) (*api.Request, error) {
+	var fields map[string]float64
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	req := &api.Request{Cmd: "set", Target: key, Values: make(map[string]uint8, len(fields))}
+	for name, v := range fields {
+		if name == "fade_ms" {
+			req.FadeMs = int(v)
+			continue
+		}
+		if v < 0 || v > 255 || v != math.Trunc(v) {
+			return nil, fmt.Errorf("%s: value must be an integer 0-255", name)
+		}
+		req.Values[name] = uint8(v)
+	}
+	return req, nil
+}
+
+// forwardLights publishes light state topics from an unfiltered state subscription,
+// along with the inventory and schedule topics
+func (c *Client) forwardLights() {
+	defer c.state.Unsubscribe(c.lights)
+
+	t := &throttle{interval: time.Duration(c.cfg.EventIntervalMs) * time.Millisecond}
+	defer t.stop()
+	for {
+		select {
+		case data, ok := <-c.lights:
+			if !ok {
+				return
+			}
+			if stateMessage(data) {
+				c.publishUpdate(t.offer(data))
+			} else if initMessage(data) {
+				c.publishInventory(data) // Lights changed (see inventory.go)
+			} else if scheduleMessage(data) {
+				c.publishSchedule(data) // See schedule.go
+			}
+		case <-t.C():
+			c.publishUpdate(t.flush())
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// publishUpdate publishes the light states of a state update (nil = held back)
+func (c *Client) publishUpdate(data []byte) {
+	var update dmx.StateUpdate
+	if data == nil || json.Unmarshal(data, &update) != nil {
+		return
+	}
+	c.publishLights(update.Values)
+}
+
+// publishLights publishes the retained light and group states whose values changed
+// and clears the states of lights and groups no longer present
+func (c *Client) publishLights(values map[string]map[string]uint8) {
+	c.lightsMu.Lock()
+	defer c.lightsMu.Unlock()
+
+	if c.client == nil || !c.client.IsConnected() {
+		return
+	}
+	states := make(map[string][]byte, len(values)) // Topic -> payload
+	groups := make(map[string]*MQTTGroupState)
+	first := make(map[string]map[string]uint8) // Group -> values of its first light
+	for key, v := range values {
+		states[c.lightTopic(key, "state")], _ = json.Marshal(MQTTLightState{Key: key, Values: v})
+
+		group, _, _ := strings.Cut(key, "/")
+		g := groups[group]
+		if g == nil {
+			g = &MQTTGroupState{Group: group, Values: make(map[string]uint8), Uniform: true}
+			groups[group] = g
+			first[group] = v
+		} else if !maps.Equal(first[group], v) {
+			g.Uniform = false
+		}
+		for name, value := range v {
+			g.Values[name] = max(g.Values[name], value)
+		}
+	}
+	for group, g := range groups {
+		states[c.groupTopic(group, "state")], _ = json.Marshal(g)
+	}
+
+	for topic, data := range states {
+		if bytes.Equal(c.lastStates[topic], data) {
+			continue
+		}
+		c.client.Publish(topic, c.qos("light"), c.retain("light"), data)
+		c.lastStates[topic] = data
+	}
+	for topic := range c.lastStates {
+		if _, ok := states[topic]; !ok {
+			c.client.Publish(topic, c.qos("light"), true, []byte{}) // Clears the retained message
+			delete(c.lastStates, topic)
+		}
+	}
+}
+
+// resetLights republishes every light and group state (after a (re)connection)
+func (c *Client) resetLights() {
+	c.lightsMu.Lock()
+	c.lastStates = make(map[string][]byte)
+	c.lightsMu.Unlock()
+	c.publishLights(c.state.GetValues())
+}
+
+// clearStaleLights clears the retained states of lights and groups that no longer exist
+// Called after resetLights, which published the current ones.
+func (c *Client) clearStaleLights(client mqtt.Client) {
+	topics := map[string]byte{c.lightTopic("+/+", "state"): 0, c.groupTopic("+", "state"): 0}
+	client.SubscribeMultiple(topics, func(client mqtt.Client, msg mqtt.Message) {
+		if !msg.Retained() || len(msg.Payload()) == 0 {
+			return // Live publish or already cleared
+		}
+		c.lightsMu.Lock()
+		_, current := c.lastStates[msg.Topic()]
+		c.lightsMu.Unlock()
+		if current {
+			return
+		}
+		client.Publish(msg.Topic(), 0, true, []byte{})
+		c.logger.Info("MQTT stale state cleared", "topic", msg.Topic())
+	})
+	time.AfterFunc(staleWindow, func() { client.Unsubscribe(slices.Collect(maps.Keys(topics))...) })
+}
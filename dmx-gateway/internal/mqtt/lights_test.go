@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeClient records publishes and subscriptions (other methods are not called)
+type fakeClient struct {
+	mqtt.Client
+	mu        sync.Mutex
+	published map[string][]byte // Topic -> last payload
+	retained  map[string]bool
+	handler   mqtt.MessageHandler // Of the last SubscribeMultiple
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{published: make(map[string][]byte), retained: make(map[string]bool)}
+}
+
+func (f *fakeClient) IsConnected() bool { return true }
+
+func (f *fakeClient) Publish(topic string, qos byte, retained bool, payload any) mqtt.Token {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published[topic] = payload.([]byte)
+	f.retained[topic] = retained
+	return doneToken{}
+}
+
+func (f *fakeClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	f.handler = callback
+	return doneToken{}
+}
+
+func (f *fakeClient) Unsubscribe(topics ...string) mqtt.Token { return doneToken{} }
+
+// take returns and forgets the publishes so far
+func (f *fakeClient) take() map[string][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	published := f.published
+	f.published = make(map[string][]byte)
+	return published
+}
+
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Done() <-chan struct{}          { return closed }
+func (doneToken) Error() error                   { return nil }
+
+var closed = func() chan struct{} { c := make(chan struct{}); close(c); return c }()
+
+// fakeMessage is a received message
+type fakeMessage struct {
+	topic    string
+	payload  []byte
+	retained bool
+}
+
+func (m fakeMessage) Duplicate() bool   { return false }
+func (m fakeMessage) Qos() byte         { return 0 }
+func (m fakeMessage) Retained() bool    { return m.retained }
+func (m fakeMessage) Topic() string     { return m.topic }
+func (m fakeMessage) MessageID() uint16 { return 0 }
+func (m fakeMessage) Payload() []byte   { return m.payload }
+func (m fakeMessage) Ack()              {}
+
+func newTestClient() (*Client, *fakeClient) {
+	fake := newFakeClient()
+	return &Client{
+		cfg:        &Config{Prefix: "dmx"},
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		client:     fake,
+		lastStates: make(map[string][]byte),
+	}, fake
+}
+
+func TestSetRequest(t *testing.T) {
+	for _, tc := range []struct {
+		payload string
+		values  map[string]uint8 // nil = rejected
+		fadeMs  int
+	}{
+		{`{"red": 200, "blue": 0}`, map[string]uint8{"red": 200, "blue": 0}, 0},
+		{`{"white": 255, "fade_ms": 1500}`, map[string]uint8{"white": 255}, 1500},
+		{`{"fade_ms": 500}`, map[string]uint8{}, 500},
+		{`{"red": 12.5}`, nil, 0},
+		{`{"red": 256}`, nil, 0},
+		{`{"red": -1}`, nil, 0},
+		{`{"red": "200"}`, nil, 0},
+		{`[200]`, nil, 0},
+		{`red=200`, nil, 0},
+	} {
+		req, err := setRequest("rack1/level1", []byte(tc.payload))
+		if tc.values == nil {
+			if err == nil {
+				t.Errorf("%s: expected rejection, got %+v", tc.payload, req)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.payload, err)
+			continue
+		}
+		if req.Cmd != "set" || req.Target != "rack1/level1" || !maps.Equal(req.Values, tc.values) || req.FadeMs != tc.fadeMs {
+			t.Errorf("%s: unexpected request %+v", tc.payload, req)
+		}
+	}
+}
+
+func TestPublishLights(t *testing.T) {
+	c, fake := newTestClient()
+	c.publishLights(map[string]map[string]uint8{
+		"rack1/level1": {"blue": 100, "red": 0},
+		"rack1/level2": {"blue": 20, "red": 50},
+		"rack2/level1": {"blue": 10},
+		"rack2/level2": {"blue": 10},
+	})
+	published := fake.take()
+	if len(published) != 6 || !fake.retained["dmx/light/rack1/level1/state"] {
+		t.Fatalf("expected 4 retained light and 2 group states, got %d", len(published))
+	}
+
+	for topic, want := range map[string]MQTTGroupState{
+		"dmx/group/rack1/state": {Group: "rack1", Values: map[string]uint8{"blue": 100, "red": 50}, Uniform: false},
+		"dmx/group/rack2/state": {Group: "rack2", Values: map[string]uint8{"blue": 10}, Uniform: true},
+	} {
+		var got MQTTGroupState
+		if err := json.Unmarshal(published[topic], &got); err != nil {
+			t.Fatalf("%s: %v", topic, err)
+		}
+		if got.Group != want.Group || !maps.Equal(got.Values, want.Values) || got.Uniform != want.Uniform {
+			t.Errorf("%s: expected %+v, got %+v", topic, want, got)
+		}
+	}
+
+	// Only changes are republished, removed lights and groups cleared
+	c.publishLights(map[string]map[string]uint8{
+		"rack1/level1": {"blue": 100, "red": 0},
+		"rack1/level2": {"blue": 20, "red": 60},
+	})
+	published = fake.take()
+	for topic, payload := range map[string]string{
+		"dmx/light/rack1/level2/state": `{"key":"rack1/level2","values":{"blue":20,"red":60}}`,
+		"dmx/group/rack1/state":        `{"group":"rack1","values":{"blue":100,"red":60},"uniform":false}`,
+		"dmx/light/rack2/level1/state": "",
+		"dmx/light/rack2/level2/state": "",
+		"dmx/group/rack2/state":        "",
+	} {
+		if got, ok := published[topic]; !ok || string(got) != payload {
+			t.Errorf("%s: expected %q, got %q", topic, payload, got)
+		}
+	}
+	if len(published) != 5 {
+		t.Errorf("expected 5 publishes, got %v", published)
+	}
+}
+
+func TestClearStaleLights(t *testing.T) {
+	c, fake := newTestClient()
+	c.publishLights(map[string]map[string]uint8{"rack1/level1": {"blue": 100}})
+	fake.take()
+
+	c.clearStaleLights(fake)
+	for _, msg := range []fakeMessage{
+		{"dmx/light/rack1/level1/state", []byte(`{"key":"rack1/level1"}`), true},  // Current
+		{"dmx/light/rack9/level1/state", []byte(`{"key":"rack9/level1"}`), true},  // Stale
+		{"dmx/group/rack9/state", []byte(`{"group":"rack9"}`), true},              // Stale
+		{"dmx/light/rack8/level1/state", []byte(`{"key":"rack8/level1"}`), false}, // Live
+		{"dmx/light/rack7/level1/state", []byte{}, true},                          // Cleared
+	} {
+		fake.handler(fake, msg)
+	}
+
+	published := fake.take()
+	if len(published) != 2 || published["dmx/light/rack9/level1/state"] == nil || len(published["dmx/group/rack9/state"]) != 0 {
+		t.Errorf("expected the rack9 states cleared, got %q", published)
+	}
+	if !fake.retained["dmx/group/rack9/state"] {
+		t.Error("expected a retained clear")
+	}
+}
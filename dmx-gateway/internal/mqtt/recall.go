@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"dmx-gateway/internal/api"
+)
+
+// Scene and preset topics
+// For wall buttons and automations that can only send a word:
+//   <prefix>/scene/set              evening
+//   <prefix>/preset/<target>/set    veg      (target = group or group/light)
+// A JSON payload adds the fade: {"name": "evening", "fade_ms": 2000} (and reply routing,
+// see reply.go). Recalls go through the unified API; failures are answered on
+// <prefix>/response.
+
+// recallPayload is the JSON form of a scene or preset topic payload
+type recallPayload struct {
+	Name   string `json:"name"`
+	FadeMs int    `json:"fade_ms"`
+	replyTo
+}
+
+// parseRecall reads a one-word or JSON recall payload
+func parseRecall(payload []byte) (recallPayload, error) {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) > 0 && payload[0] == '{' {
+		var p recallPayload
+		err := json.Unmarshal(payload, &p)
+		return p, err
+	}
+	return recallPayload{Name: string(payload)}, nil
+}
+
+// handleSceneSet recalls the scene named by a <prefix>/scene/set payload
+func (c *Client) handleSceneSet(client mqtt.Client, msg mqtt.Message) {
+	p, err := parseRecall(msg.Payload())
+	if err != nil {
+		c.respond(client, p.replyTo, &api.Response{Type: "error", Error: api.NewError(api.CodeBadRequest, "", "invalid JSON: "+err.Error())})
+		return
+	}
+	c.recall(client, p.replyTo, &api.Request{Cmd: "scene_recall", Name: p.Name, FadeMs: p.FadeMs})
+}
+
+// presetTarget returns the target of a <prefix>/preset/<target>/set topic
+func (c *Client) presetTarget(topic string) (string, bool) {
+	rest, ok := strings.CutPrefix(topic, c.cfg.Prefix+"/preset/")
+	if !ok {
+		return "", false
+	}
+	target, ok := strings.CutSuffix(rest, "/set")
+	if !ok || target == "" {
+		return "", false
+	}
+	return target, true
+}
+
+// handlePresetSet recalls a preset on the target of a <prefix>/preset/<target>/set topic
+func (c *Client) handlePresetSet(client mqtt.Client, msg mqtt.Message) {
+	target, ok := c.presetTarget(msg.Topic())
+	if !ok {
+		return // Not a set topic
+	}
+	p, err := parseRecall(msg.Payload())
+	if err != nil {
+		c.respond(client, p.replyTo, &api.Response{Type: "error", Target: target, Error: api.NewError(api.CodeBadRequest, target, "invalid JSON: "+err.Error())})
+		return
+	}
+	c.recall(client, p.replyTo, &api.Request{Cmd: "preset", Target: target, Name: p.Name, FadeMs: p.FadeMs})
+}
+
+// recall runs a recall command, answering failures (or always, see reply.go)
+func (c *Client) recall(client mqtt.Client, to replyTo, req *api.Request) {
+	if !c.checkReply(client, to) {
+		return
+	}
+	req.RequestID = to.ID
+	resp := c.api.Handle(req)
+	c.logger.Debug("MQTT recall", "cmd", req.Cmd, "target", req.Target, "name", req.Name, "request_id", resp.RequestID)
+	if resp.Error != nil || to.wanted() {
+		c.respond(client, to, resp)
+	}
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import "testing"
+
+func TestParseRecall(t *testing.T) {
+	for _, tc := range []struct {
+		payload string
+		want    recallPayload
+		invalid bool
+	}{
+		{"evening", recallPayload{Name: "evening"}, false},
+		{"  veg\n", recallPayload{Name: "veg"}, false},
+		{`{"name": "evening", "fade_ms": 2000}`, recallPayload{Name: "evening", FadeMs: 2000}, false},
+		{`{"name": "veg", "id": "job-1"}`, recallPayload{Name: "veg", replyTo: replyTo{ID: "job-1"}}, false},
+		{`{"name": "evening", "fade_ms": "slow"}`, recallPayload{}, true},
+		{`{"name": `, recallPayload{}, true},
+	} {
+		p, err := parseRecall([]byte(tc.payload))
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("%q: expected error, got %+v", tc.payload, p)
+			}
+			continue
+		}
+		if err != nil || p != tc.want {
+			t.Errorf("%q: expected %+v, got %+v (%v)", tc.payload, tc.want, p, err)
+		}
+	}
+}
+
+func TestPresetTarget(t *testing.T) {
+	c := &Client{cfg: &Config{Prefix: "dmx"}}
+	for _, tc := range []struct {
+		topic  string
+		target string // empty = not a set topic
+	}{
+		{"dmx/preset/rack1/set", "rack1"},
+		{"dmx/preset/rack1/level2/set", "rack1/level2"},
+		{"dmx/preset/rack1/state", ""},
+		{"dmx/preset/set", ""},
+		{"other/preset/rack1/set", ""},
+	} {
+		target, ok := c.presetTarget(tc.topic)
+		if ok != (tc.target != "") || target != tc.target {
+			t.Errorf("%s: expected %q, got %q (%v)", tc.topic, tc.target, target, ok)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"dmx-gateway/internal/api"
+)
+
+// Reply routing
+// Requesters can get their reply on their own topic instead of the shared
+// <prefix>/response. The client library speaks MQTT 3.1.1, which has no MQTT 5
+// Response-Topic and Correlation-Data properties, so both travel in the payload:
+//   {"cmd": "status", "response_topic": "app/42/reply", "correlation_data": "abc"}
+// is answered on app/42/reply with "correlation_data": "abc" echoed. Response topics
+// cannot hold wildcards nor point into the gateway's own topics (except below
+// <prefix>/response/), so a command cannot make the gateway send itself a command.
+//
+// Simpler, a command with an "id" (up to 64 letters, digits or ._:-) is answered on
+// <prefix>/ack/<id> with the id echoed, and the id becomes its request id. Scene and
+// preset recalls with an id or a response topic are always answered, not only on failure.
+
+const maxIDLen = 64
+
+// replyTo is where a command wants its response
+type replyTo struct {
+	ID              string `json:"id"`
+	ResponseTopic   string `json:"response_topic"`
+	CorrelationData string `json:"correlation_data"`
+}
+
+// wanted reports whether the requester asked for a reply of its own
+func (to replyTo) wanted() bool {
+	return to.ID != "" || to.ResponseTopic != ""
+}
+
+// reply is a response with the requester's id and correlation data
+type reply struct {
+	*api.Response
+	ID              string `json:"id,omitempty"`
+	CorrelationData string `json:"correlation_data,omitempty"`
+}
+
+// validID reports whether a command id can be used in a topic
+func validID(id string) bool {
+	if len(id) > maxIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '.', c == '_', c == ':', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// replyTopic returns the topic a response goes to
+func (c *Client) replyTopic(to replyTo) (string, error) {
+	topic := to.ResponseTopic
+	switch {
+	case to.ID != "" && !validID(to.ID):
+		return "", fmt.Errorf("id: up to %d letters, digits or ._:- expected", maxIDLen)
+	case topic == "" && to.ID != "":
+		return c.cfg.Prefix + "/ack/" + to.ID, nil
+	case topic == "":
+		return c.cfg.Prefix + "/response", nil
+	case strings.ContainsAny(topic, "+#"):
+		return "", fmt.Errorf("response_topic: wildcards not allowed")
+	case strings.HasPrefix(topic, c.cfg.Prefix+"/") && !strings.HasPrefix(topic, c.cfg.Prefix+"/response/"):
+		return "", fmt.Errorf("response_topic: %s/... is reserved (use %s/response/...)", c.cfg.Prefix, c.cfg.Prefix)
+	}
+	return topic, nil
+}
+
+// checkReply answers a command whose reply routing is invalid; false = not run
+func (c *Client) checkReply(client mqtt.Client, to replyTo) bool {
+	if _, err := c.replyTopic(to); err != nil {
+		c.respond(client, replyTo{CorrelationData: to.CorrelationData}, &api.Response{Type: "error", Error: api.NewError(api.CodeBadRequest, "", err.Error())})
+		return false
+	}
+	return true
+}
+
+// respond publishes a response where the command asked (<prefix>/response by default)
+func (c *Client) respond(client mqtt.Client, to replyTo, resp *api.Response) {
+	topic, err := c.replyTopic(to)
+	if err != nil {
+		topic = c.cfg.Prefix + "/response"
+		resp = &api.Response{Type: "error", Error: api.NewError(api.CodeBadRequest, "", err.Error()), RequestID: resp.RequestID}
+	}
+	data, _ := json.Marshal(reply{Response: resp, ID: to.ID, CorrelationData: to.CorrelationData})
+	client.Publish(topic, c.qos("response"), false, data)
+}
@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import "testing"
+
+func TestReplyTopic(t *testing.T) {
+	c := &Client{cfg: &Config{Prefix: "dmx"}}
+	for _, tc := range []struct {
+		to    replyTo
+		topic string // empty = refused
+	}{
+		{replyTo{}, "dmx/response"},
+		{replyTo{ID: "job-42"}, "dmx/ack/job-42"},
+		{replyTo{ID: "job-42", ResponseTopic: "app/reply"}, "app/reply"},
+		{replyTo{ResponseTopic: "dmx/response/app"}, "dmx/response/app"},
+		{replyTo{ResponseTopic: "dmx/cmd"}, ""},
+		{replyTo{ResponseTopic: "app/+"}, ""},
+		{replyTo{ID: "a/b"}, ""},
+	} {
+		topic, err := c.replyTopic(tc.to)
+		if tc.topic == "" && err == nil {
+			t.Errorf("%+v: expected refusal, got %s", tc.to, topic)
+		}
+		if tc.topic != "" && topic != tc.topic {
+			t.Errorf("%+v: expected %s, got %s (%v)", tc.to, tc.topic, topic, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Schedule topics
+// Each scheduled event run is published on <prefix>/schedule/executed:
+//   {"type":"schedule","id":"3","time":"06:00:00","targets":["rack1"],"result":"ok","next":{...}}
+// ("failed" with "errors" when an action failed), and the event due next is kept,
+// retained, on <prefix>/schedule/next, so external systems can check that the
+// photoperiod ran. The next event is also published on connect and when the scheduler
+// restarts; an empty payload clears it when no event is due.
+
+// scheduleMessage reports whether data is a schedule execution (type marshaled first)
+func scheduleMessage(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(`{"type":"schedule"`))
+}
+
+// publishSchedule publishes a schedule execution and the next event
+func (c *Client) publishSchedule(data []byte) {
+	if c.client == nil || !c.client.IsConnected() {
+		return
+	}
+	c.client.Publish(c.cfg.Prefix+"/schedule/executed", c.qos("event"), false, data)
+
+	var x struct {
+		Next json.RawMessage `json:"next"`
+	}
+	if json.Unmarshal(data, &x) == nil {
+		c.publishNext(x.Next)
+	}
+}
+
+// PublishNextEvent publishes the event due next of the current scheduler
+func (c *Client) PublishNextEvent() {
+	if c.client == nil || !c.client.IsConnected() {
+		return
+	}
+	var data []byte
+	if c.cfg.NextEvent != nil {
+		if next := c.cfg.NextEvent(); next != nil {
+			data, _ = json.Marshal(next)
+		}
+	}
+	c.publishNext(data)
+}
+
+// publishNext publishes the retained next event (empty data = none due)
+func (c *Client) publishNext(data []byte) {
+	if data == nil {
+		data = []byte{}
+	}
+	c.client.Publish(c.cfg.Prefix+"/schedule/next", c.qos("status"), true, data)
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"dmx-gateway/internal/scheduler"
+)
+
+func TestPublishSchedule(t *testing.T) {
+	c, fake := newTestClient()
+	next := &scheduler.NextEventInfo{ID: "2", Time: "18:00:00", In: time.Hour}
+
+	data, _ := json.Marshal(scheduler.Execution{Type: "schedule", Result: "ok", Next: next})
+	c.publishSchedule(data)
+	published := fake.take()
+	if string(published["dmx/schedule/executed"]) != string(data) {
+		t.Errorf("expected the execution published, got %s", published["dmx/schedule/executed"])
+	}
+	var got scheduler.NextEventInfo
+	if err := json.Unmarshal(published["dmx/schedule/next"], &got); err != nil || got.ID != "2" || !fake.retained["dmx/schedule/next"] {
+		t.Errorf("expected the next event retained, got %s", published["dmx/schedule/next"])
+	}
+
+	// No event due next: cleared
+	data, _ = json.Marshal(scheduler.Execution{Type: "schedule", Result: "ok"})
+	c.publishSchedule(data)
+	if payload, ok := fake.take()["dmx/schedule/next"]; !ok || len(payload) != 0 {
+		t.Errorf("expected the next event cleared, got %q", payload)
+	}
+
+	// On connect and scheduler restart
+	c.PublishNextEvent()
+	if payload, ok := fake.take()["dmx/schedule/next"]; !ok || len(payload) != 0 {
+		t.Errorf("expected no next event without scheduler, got %q", payload)
+	}
+	c.cfg.NextEvent = func() *scheduler.NextEventInfo { return next }
+	c.PublishNextEvent()
+	if err := json.Unmarshal(fake.take()["dmx/schedule/next"], &got); err != nil || got.ID != "2" {
+		t.Errorf("expected the next event published, got %+v (%v)", got, err)
+	}
+}
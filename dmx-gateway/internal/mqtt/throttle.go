@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"bytes"
+	"time"
+)
+
+// Event debouncing
+// With mqtt.event_interval_ms, state updates are published at most once per interval
+// on <prefix>/event and the light state topics: the first goes out at once, the ones
+// arriving during the interval are coalesced and only the latest is published when it
+// ends, so a fader drag (50 updates/s) costs a few messages and the settled state is
+// always sent. Other events (blackout, change, backend...) are never held back.
+
+// stateMessage reports whether data is a state update (StateUpdate marshals its type first)
+func stateMessage(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(`{"type":"state"`))
+}
+
+// throttle coalesces messages to one per interval, keeping the latest
+type throttle struct {
+	interval time.Duration
+	last     time.Time   // Last message let through
+	pending  []byte      // Latest message held back
+	timer    *time.Timer // Runs while a message is held back
+}
+
+// offer returns data if it may go out now, nil if it is held back until C fires
+func (t *throttle) offer(data []byte) []byte {
+	if t.interval <= 0 {
+		return data
+	}
+	if t.timer != nil {
+		t.pending = data
+		return nil
+	}
+	if wait := t.interval - time.Since(t.last); wait > 0 {
+		t.pending = data
+		t.timer = time.NewTimer(wait)
+		return nil
+	}
+	t.last = time.Now()
+	return data
+}
+
+// C fires when a held back message is due (nil channel when none is)
+func (t *throttle) C() <-chan time.Time {
+	if t.timer == nil {
+		return nil
+	}
+	return t.timer.C
+}
+
+// flush returns the held back message once C fired
+func (t *throttle) flush() []byte {
+	data := t.pending
+	t.pending = nil
+	t.timer = nil
+	t.last = time.Now()
+	return data
+}
+
+// stop drops a held back message
+func (t *throttle) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
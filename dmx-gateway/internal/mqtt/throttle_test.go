@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package mqtt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleCoalesces(t *testing.T) {
+	th := &throttle{interval: 50 * time.Millisecond}
+	defer th.stop()
+
+	if out := th.offer([]byte("1")); string(out) != "1" {
+		t.Fatalf("expected first message through, got %q", out)
+	}
+	for _, m := range []string{"2", "3", "4"} {
+		if out := th.offer([]byte(m)); out != nil {
+			t.Fatalf("expected %s held back, got %q", m, out)
+		}
+	}
+	select {
+	case <-th.C():
+		if out := th.flush(); string(out) != "4" {
+			t.Errorf("expected latest message flushed, got %q", out)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("held back message never flushed")
+	}
+	if th.C() != nil {
+		t.Error("expected no timer after flush")
+	}
+}
+
+func TestThrottleDisabled(t *testing.T) {
+	th := &throttle{}
+	for _, m := range []string{"1", "2"} {
+		if out := th.offer([]byte(m)); string(out) != m {
+			t.Errorf("expected %s through, got %q", m, out)
+		}
+	}
+}
+
+func TestStateMessage(t *testing.T) {
+	if !stateMessage([]byte(`{"type":"state","enabled":true,"values":{}}`)) || stateMessage([]byte(`{"type":"blackout"}`)) {
+		t.Error("unexpected state message detection")
+	}
+}
@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package remoteproc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Linux remoteproc sysfs interface for the M-core DMX firmware
+//   /sys/class/remoteproc/<device>/state     offline | running | crashed ... (write start/stop)
+//   /sys/class/remoteproc/<device>/firmware  firmware file name under /lib/firmware
+//   /sys/class/remoteproc/<device>/name      remote processor name
+
+const (
+	defaultSysfsRoot   = "/sys/class/remoteproc"
+	defaultFirmwareDir = "/lib/firmware"
+	defaultDevice      = "remoteproc0"
+	stateTimeout       = 5 * time.Second
+)
+
+// Config for the remoteproc manager
+type Config struct {
+	Device      string // e.g. "remoteproc0"
+	FirmwareDir string // Directory holding firmware images (default /lib/firmware)
+	SysfsRoot   string // Override for tests (default /sys/class/remoteproc)
+}
+
+// Info describes the remote processor and its firmware
+type Info struct {
+	Device   string `json:"device"`
+	Name     string `json:"name,omitempty"`
+	State    string `json:"state"`              // running, offline, crashed, unavailable
+	Firmware string `json:"firmware,omitempty"` // Firmware file name
+	Version  string `json:"version,omitempty"`  // Short SHA-256 of the firmware image
+	Size     int64  `json:"size,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Manager controls a remoteproc instance through sysfs
+type Manager struct {
+	dir         string
+	device      string
+	firmwareDir string
+	logger      *slog.Logger
+	mu          sync.Mutex // Serializes start/stop/reload
+}
+
+// New creates a remoteproc manager
+func New(cfg Config, logger *slog.Logger) *Manager {
+	if cfg.Device == "" {
+		cfg.Device = defaultDevice
+	}
+	if cfg.FirmwareDir == "" {
+		cfg.FirmwareDir = defaultFirmwareDir
+	}
+	if cfg.SysfsRoot == "" {
+		cfg.SysfsRoot = defaultSysfsRoot
+	}
+	return &Manager{
+		dir:         filepath.Join(cfg.SysfsRoot, cfg.Device),
+		device:      cfg.Device,
+		firmwareDir: cfg.FirmwareDir,
+		logger:      logger,
+	}
+}
+
+// Info reads the current remoteproc state and firmware identity
+func (m *Manager) Info() Info {
+	info := Info{Device: m.device}
+
+	state, err := m.read("state")
+	if err != nil {
+		info.State = "unavailable"
+		info.Error = err.Error()
+		return info
+	}
+	info.State = state
+	info.Name, _ = m.read("name")
+	info.Firmware, _ = m.read("firmware")
+
+	if info.Firmware != "" {
+		if version, size, err := m.fingerprint(info.Firmware); err == nil {
+			info.Version = version
+			info.Size = size
+		}
+	}
+	return info
+}
+
+// Start boots the remote processor with the current firmware
+func (m *Manager) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.start()
+}
+
+// Stop shuts the remote processor down
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stop()
+}
+
+// Reload stops the remote processor, optionally selects another firmware file, and restarts it
+func (m *Manager) Reload(firmware string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if firmware != "" {
+		if strings.ContainsAny(firmware, "/\\") {
+			return fmt.Errorf("invalid firmware name %q", firmware)
+		}
+		if _, err := os.Stat(filepath.Join(m.firmwareDir, firmware)); err != nil {
+			return fmt.Errorf("firmware: %w", err)
+		}
+	}
+
+	if err := m.stop(); err != nil {
+		return err
+	}
+	if firmware != "" {
+		if err := m.write("firmware", firmware); err != nil {
+			return err
+		}
+	}
+	return m.start()
+}
+
+func (m *Manager) start() error {
+	if state, _ := m.read("state"); state == "running" {
+		return nil
+	}
+	m.logger.Info("remoteproc: starting", "device", m.device)
+	if err := m.write("state", "start"); err != nil {
+		return err
+	}
+	return m.waitState("running")
+}
+
+func (m *Manager) stop() error {
+	if state, _ := m.read("state"); state == "offline" {
+		return nil
+	}
+	m.logger.Info("remoteproc: stopping", "device", m.device)
+	if err := m.write("state", "stop"); err != nil {
+		return err
+	}
+	return m.waitState("offline")
+}
+
+// waitState polls the state attribute until it matches want
+func (m *Manager) waitState(want string) error {
+	deadline := time.Now().Add(stateTimeout)
+	for {
+		state, err := m.read("state")
+		if err != nil {
+			return err
+		}
+		if state == want {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("remoteproc %s: state %q, expected %q", m.device, state, want)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// fingerprint returns a short SHA-256 and the size of a firmware image
+func (m *Manager) fingerprint(name string) (string, int64, error) {
+	f, err := os.Open(filepath.Join(m.firmwareDir, name))
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12], n, nil
+}
+
+func (m *Manager) read(attr string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(m.dir, attr))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (m *Manager) write(attr, value string) error {
+	if err := os.WriteFile(filepath.Join(m.dir, attr), []byte(value), 0644); err != nil {
+		return fmt.Errorf("remoteproc %s/%s: %w", m.device, attr, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package remoteproc
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	root := t.TempDir()
+	fwDir := t.TempDir()
+
+	dev := filepath.Join(root, "remoteproc0")
+	if err := os.MkdirAll(dev, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dev, "state"), []byte("running\n"), 0644)
+	os.WriteFile(filepath.Join(dev, "name"), []byte("m0\n"), 0644)
+	os.WriteFile(filepath.Join(dev, "firmware"), []byte("dmx_mcu.elf\n"), 0644)
+	os.WriteFile(filepath.Join(fwDir, "dmx_mcu.elf"), []byte("firmware image"), 0644)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	return New(Config{SysfsRoot: root, FirmwareDir: fwDir}, logger), dev
+}
+
+func TestInfo(t *testing.T) {
+	m, _ := testManager(t)
+
+	info := m.Info()
+	if info.State != "running" {
+		t.Errorf("expected state 'running', got %q", info.State)
+	}
+	if info.Firmware != "dmx_mcu.elf" || info.Name != "m0" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if len(info.Version) != 12 || info.Size != int64(len("firmware image")) {
+		t.Errorf("expected firmware fingerprint, got version %q size %d", info.Version, info.Size)
+	}
+}
+
+func TestInfoUnavailable(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	m := New(Config{SysfsRoot: t.TempDir()}, logger)
+
+	if info := m.Info(); info.State != "unavailable" {
+		t.Errorf("expected state 'unavailable', got %q", info.State)
+	}
+}
+
+func TestReloadRejectsInvalidFirmware(t *testing.T) {
+	m, _ := testManager(t)
+
+	if err := m.Reload("../etc/passwd"); err == nil {
+		t.Error("expected error for path traversal")
+	}
+	if err := m.Reload("missing.elf"); err == nil {
+		t.Error("expected error for missing firmware")
+	}
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Circadian mode
+// Tunable-white targets (channels with cct) follow a daily CCT/intensity curve: either
+// configured key points (interpolated, wrapping at midnight) or the sun (sunrise to
+// sunset, peaking at solar noon) at the configured location. Every interval the current
+// point is computed and faded to over the interval, so output changes continuously.
+
+// CircadianStatus describes the circadian mode and its current output
+type CircadianStatus struct {
+	Enabled bool   `json:"enabled"`
+	Mode    string `json:"mode"` // "curve" or "sun"
+	CCT     int    `json:"cct"`
+	Level   uint8  `json:"level"`
+	Sunrise string `json:"sunrise,omitempty"` // Sun mode, "HH:MM" (empty during polar day/night)
+	Sunset  string `json:"sunset,omitempty"`
+}
+
+// circadian holds the parsed curve
+type circadian struct {
+	cfg    *config.CircadianConfig
+	points []circadianPoint // Sorted by second of day
+}
+
+type circadianPoint struct {
+	sec   int
+	cct   int
+	level uint8
+}
+
+func newCircadian(cfg *config.CircadianConfig) (*circadian, error) {
+	c := &circadian{cfg: cfg}
+	for _, p := range cfg.Curve {
+		e, err := parseTime(p.Time)
+		if err != nil {
+			return nil, err
+		}
+		c.points = append(c.points, circadianPoint{sec: timeToSeconds(e), cct: p.CCT, level: p.Level})
+	}
+	sort.Slice(c.points, func(i, j int) bool { return c.points[i].sec < c.points[j].sec })
+	return c, nil
+}
+
+// at returns the CCT and level for a time of day
+func (c *circadian) at(now time.Time) (int, uint8) {
+	if len(c.points) == 0 {
+		return c.sunAt(now)
+	}
+
+	// Interpolate between the surrounding points, wrapping at midnight
+	sec := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	i := sort.Search(len(c.points), func(i int) bool { return c.points[i].sec > sec })
+	prev := c.points[(i-1+len(c.points))%len(c.points)]
+	next := c.points[i%len(c.points)]
+
+	span := (next.sec - prev.sec + 86400) % 86400
+	if span == 0 {
+		return prev.cct, prev.level
+	}
+	f := float64((sec-prev.sec+86400)%86400) / float64(span)
+	cct := float64(prev.cct) + f*float64(next.cct-prev.cct)
+	level := float64(prev.level) + f*(float64(next.level)-float64(prev.level))
+	return int(math.Round(cct)), uint8(math.Round(level))
+}
+
+// sunAt follows a half-sine from sunrise to sunset (dark at night)
+func (c *circadian) sunAt(now time.Time) (int, uint8) {
+	rise, set, polar := sunTimes(now, c.cfg.Latitude, c.cfg.Longitude)
+	var f float64
+	switch {
+	case polar > 0:
+		f = 0.5 // Polar day: hold noon
+	case polar < 0 || now.Before(rise) || !now.Before(set):
+		return c.cfg.WarmK, 0
+	default:
+		f = float64(now.Sub(rise)) / float64(set.Sub(rise))
+	}
+
+	k := math.Sin(math.Pi * f)
+	cct := float64(c.cfg.WarmK) + k*float64(c.cfg.CoolK-c.cfg.WarmK)
+	return int(math.Round(cct)), uint8(math.Round(k * float64(c.cfg.Level)))
+}
+
+// sunTimes returns sunrise and sunset of now's date (sunrise equation, ~1 min accuracy)
+// polar is 1 when the sun never sets that day, -1 when it never rises.
+func sunTimes(now time.Time, lat, lon float64) (rise, set time.Time, polar int) {
+	const rad = math.Pi / 180
+
+	noon := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, now.Location())
+	n := math.Round(float64(noon.Unix())/86400 + 2440587.5 - 2451545.0)
+
+	js := n - lon/360 // Mean solar noon
+	m := math.Mod(357.5291+0.98560028*js, 360)
+	center := 1.9148*math.Sin(m*rad) + 0.02*math.Sin(2*m*rad) + 0.0003*math.Sin(3*m*rad)
+	lambda := math.Mod(m+center+180+102.9372, 360)
+	transit := 2451545.0 + js + 0.0053*math.Sin(m*rad) - 0.0069*math.Sin(2*lambda*rad)
+
+	sinDecl := math.Sin(lambda*rad) * math.Sin(23.4397*rad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	cosHour := (math.Sin(-0.833*rad) - math.Sin(lat*rad)*sinDecl) / (math.Cos(lat*rad) * cosDecl)
+	if cosHour < -1 {
+		return time.Time{}, time.Time{}, 1
+	}
+	if cosHour > 1 {
+		return time.Time{}, time.Time{}, -1
+	}
+
+	hour := math.Acos(cosHour) / rad / 360
+	julian := func(j float64) time.Time {
+		return time.Unix(int64(math.Round((j-2440587.5)*86400)), 0).In(now.Location())
+	}
+	return julian(transit - hour), julian(transit + hour), 0
+}
+
+// circadianLoop applies the curve every interval until stopped
+func (s *Scheduler) circadianLoop() {
+	interval := time.Duration(s.circ.cfg.IntervalSec) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.applyCircadian(0)
+	for {
+		select {
+		case <-ticker.C:
+			s.applyCircadian(interval)
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// applyCircadian fades targets to the current curve point
+func (s *Scheduler) applyCircadian(fade time.Duration) {
+	s.mu.RLock()
+	enabled := s.circEnabled
+	s.mu.RUnlock()
+	if !enabled || s.isPaused(time.Now()) {
+		return
+	}
+
+	cct, level := s.circ.at(time.Now().In(s.location))
+	for _, target := range s.circ.cfg.Targets {
+		if err := s.src.SetCCT(target, cct, int(level), fade); err != nil {
+			s.logger.Warn("Circadian update failed", "target", target, "error", err)
+		}
+	}
+}
+
+// Circadian returns the circadian mode status (nil if not configured)
+func (s *Scheduler) Circadian() *CircadianStatus {
+	if s.circ == nil {
+		return nil
+	}
+
+	now := time.Now().In(s.location)
+	status := &CircadianStatus{Mode: "curve"}
+	s.mu.RLock()
+	status.Enabled = s.circEnabled
+	s.mu.RUnlock()
+	status.CCT, status.Level = s.circ.at(now)
+
+	if len(s.circ.points) == 0 {
+		status.Mode = "sun"
+		if rise, set, polar := sunTimes(now, s.circ.cfg.Latitude, s.circ.cfg.Longitude); polar == 0 {
+			status.Sunrise = rise.Format("15:04")
+			status.Sunset = set.Format("15:04")
+		}
+	}
+	return status
+}
+
+// SetCircadian enables or disables the circadian mode (re-enabling applies immediately)
+func (s *Scheduler) SetCircadian(enabled bool) {
+	if s.circ == nil {
+		return
+	}
+	s.mu.Lock()
+	changed := s.circEnabled != enabled
+	s.circEnabled = enabled
+	s.mu.Unlock()
+
+	if changed {
+		s.logger.Info("Circadian mode", "enabled", enabled)
+		if enabled {
+			s.applyCircadian(0)
+		}
+	}
+}
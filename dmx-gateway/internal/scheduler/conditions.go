@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+
+	"dmx-gateway/internal/config"
+)
+
+// Conditions
+// An event with conditions (when) runs only if all hold when it is due, e.g.
+// supplemental lighting only below an ambient lux reading. A condition tests a variable
+// (MQTT inputs, polled Modbus points; false while never received or not numeric), the
+// highest channel value of a target, or the enabled flag. A skipped run is reported as
+// usual with result "skipped" and the unmet condition. Manual runs ignore conditions.
+
+// unmet returns the first condition of e that does not hold ("" = all hold)
+func (s *Scheduler) unmet(e Event) string {
+	for _, cond := range e.When {
+		if !s.holds(cond) {
+			return describe(cond)
+		}
+	}
+	return ""
+}
+
+// holds tests a condition against the current state
+func (s *Scheduler) holds(cond config.Condition) bool {
+	if cond.Enabled != nil {
+		return s.state.IsEnabled() == *cond.Enabled
+	}
+	var value float64
+	if cond.Variable != "" {
+		v, ok := s.state.Variable(cond.Variable)
+		if !ok || !v.Numeric {
+			return false
+		}
+		value = v.Value
+	} else {
+		value = float64(s.level(cond.Level, cond.Color))
+	}
+	return (cond.Below == nil || value < *cond.Below) &&
+		(cond.Above == nil || value > *cond.Above) &&
+		(cond.Equals == nil || value == *cond.Equals)
+}
+
+// level returns the highest value of the channels of target (of color when set)
+func (s *Scheduler) level(target, color string) uint8 {
+	var level uint8
+	for key, values := range s.state.GetValues() {
+		if key != target && !strings.HasPrefix(key, target+"/") {
+			continue
+		}
+		for name, v := range values {
+			if color == "" || name == color {
+				level = max(level, v)
+			}
+		}
+	}
+	return level
+}
+
+// describe returns a condition as text ("lux below 200")
+func describe(cond config.Condition) string {
+	if cond.Enabled != nil {
+		return fmt.Sprintf("enabled %t", *cond.Enabled)
+	}
+	subject := cond.Variable
+	if cond.Level != "" {
+		subject = "level of " + cond.Level
+		if cond.Color != "" {
+			subject += " " + cond.Color
+		}
+	}
+	var parts []string
+	for _, c := range []struct {
+		op    string
+		value *float64
+	}{{"below", cond.Below}, {"above", cond.Above}, {"equals", cond.Equals}} {
+		if c.value != nil {
+			parts = append(parts, fmt.Sprintf("%s %g", c.op, *c.value))
+		}
+	}
+	return subject + " " + strings.Join(parts, " and ")
+}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+)
+
+func TestConditions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state := testState(t)
+
+	lux, level := 200.0, 100.0
+	s, err := New(&config.ScheduleConfig{Events: []config.ScheduleEvent{{
+		Time: "07:00",
+		Set:  map[string]map[string]uint8{"rack1": {"blue": 255}},
+		When: []config.Condition{{Variable: "lux", Below: &lux}, {Level: "house", Color: "white", Above: &level}},
+	}}}, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := s.events[0]
+
+	if x := s.execute(e); x.Result != "skipped" || x.Unmet != "lux below 200" {
+		t.Errorf("expected skipped without lux reading, got %s %q", x.Result, x.Unmet)
+	}
+	state.SetVariable("lux", "150", "test")
+	if x := s.execute(e); x.Result != "skipped" || x.Unmet != "level of house white above 100" {
+		t.Errorf("expected skipped with house off, got %s %q", x.Result, x.Unmet)
+	}
+	state.Source(dmx.SourceHTTP).SetGroup("house", map[string]uint8{"white": 180})
+	if x := s.execute(e); x.Result != "ok" || state.GetChannels()[0] != 255 {
+		t.Errorf("expected the event run, got %s %q", x.Result, x.Unmet)
+	}
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"math"
+	"time"
+
+	"dmx-gateway/internal/metrics"
+)
+
+// DLI targeting
+// With a daily light integral target (mol/m²/day), the photoperiod scales its spectra so
+// the light received over the cycle reaches the target. Every interval the PPFD is added
+// to the integral of the cycle, restarted at lights on: the sensor variable while fresh
+// (measured at the canopy, sunlight included), else the output estimated from ppfd_max,
+// the PPFD of the full spectra. The light still needed is then spread over the rest of
+// the cycle, ramps weighted, giving the scale of the spectra (min_percent to 100 %, by
+// 1 %). Sunlight to come is not forecast: the scale drops as it is measured.
+// Achieved and target are exported as dmx_dli_achieved and dmx_dli_target.
+
+// dliSensorMaxAge is the age after which the sensor is ignored (output estimated)
+const dliSensorMaxAge = 5 * time.Minute
+
+// DLIStatus describes the light integral of the current cycle
+type DLIStatus struct {
+	Target   float64   `json:"target"`   // mol/m²/day
+	Achieved float64   `json:"achieved"` // mol/m² since lights on
+	Scale    int       `json:"scale"`    // Percent of the spectra
+	Measured bool      `json:"measured"` // Last PPFD read from the sensor (false = estimated)
+	Cycle    time.Time `json:"cycle"`    // Lights on of the cycle
+}
+
+// dli is the integral of the current cycle
+type dli struct {
+	cycle    time.Time
+	achieved float64 // mol/m²
+	scale    float64 // 0-1
+	last     time.Time
+	measured bool
+}
+
+// cycle returns the lights on of the cycle now belongs to
+func (p *photoperiod) cycle(now time.Time) time.Time {
+	on := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, p.on, 0, now.Location())
+	if on.After(now) {
+		on = on.AddDate(0, 0, -1)
+	}
+	return on
+}
+
+// remaining returns the light seconds left in the cycle (full-level equivalent)
+func (p *photoperiod) remaining(now, cycle time.Time) float64 {
+	var sec float64
+	for t, end := now, cycle.Add(time.Duration(p.length)*time.Second); t.Before(end); t = t.Add(time.Minute) {
+		_, level := p.at(t)
+		sec += level * time.Minute.Seconds()
+	}
+	return sec
+}
+
+// updateDLI adds the light since the last update and sets the scale of the spectra
+func (s *Scheduler) updateDLI(now time.Time) {
+	cfg := s.photo.cfg.DLI
+	cycle := s.photo.cycle(now)
+	_, level := s.photo.at(now)
+	remaining := s.photo.remaining(now, cycle)
+
+	ppfd, measured := 0.0, false
+	if cfg.Sensor != "" {
+		if v, ok := s.state.Variable(cfg.Sensor); ok && v.Numeric && now.Sub(v.Updated) < dliSensorMaxAge {
+			ppfd, measured = max(v.Value, 0), true
+		}
+	}
+
+	s.mu.Lock()
+	d := &s.dli
+	if !d.cycle.Equal(cycle) {
+		*d = dli{cycle: cycle, scale: 1}
+	}
+	if !measured {
+		ppfd = cfg.PPFDMax * level * d.scale
+	}
+	if !d.last.IsZero() {
+		d.achieved += ppfd * now.Sub(d.last).Seconds() / 1e6
+	}
+	d.last, d.measured = now, measured
+	if remaining > 0 {
+		scale := (cfg.Target - d.achieved) * 1e6 / (cfg.PPFDMax * remaining)
+		d.scale = math.Round(min(max(scale, float64(cfg.MinPercent)/100), 1)*100) / 100
+	}
+	achieved := d.achieved
+	s.mu.Unlock()
+
+	metrics.DLITarget.Set(cfg.Target)
+	metrics.DLIAchieved.Set(achieved)
+}
+
+// dliScale returns the scale of the spectra (1 without DLI target)
+func (s *Scheduler) dliScale() float64 {
+	if s.photo.cfg.DLI == nil {
+		return 1
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.dli.cycle.IsZero() {
+		return 1
+	}
+	return s.dli.scale
+}
+
+// dliStatus returns the integral of the current cycle (nil without DLI target)
+func (s *Scheduler) dliStatus() *DLIStatus {
+	cfg := s.photo.cfg.DLI
+	if cfg == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scale := s.dli.scale
+	if s.dli.cycle.IsZero() {
+		scale = 1
+	}
+	return &DLIStatus{
+		Target:   cfg.Target,
+		Achieved: math.Round(s.dli.achieved*100) / 100,
+		Scale:    int(math.Round(scale * 100)),
+		Measured: s.dli.measured,
+		Cycle:    s.dli.cycle,
+	}
+}
+
+// RestoreDLI carries the integral of a previous scheduler over (config change), when
+// its cycle is still running
+func (s *Scheduler) RestoreDLI(status DLIStatus) {
+	if s.photo == nil || s.photo.cfg.DLI == nil || status.Cycle.IsZero() {
+		return
+	}
+	s.mu.Lock()
+	s.dli = dli{cycle: status.Cycle, achieved: status.Achieved, scale: float64(status.Scale) / 100, last: time.Now()}
+	s.mu.Unlock()
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+func TestDLI(t *testing.T) {
+	// 12h from 06:00 at 500 µmol/m²/s = 21.6 mol/m²: a 10.8 target halves the spectra
+	cfg := &config.PhotoperiodConfig{On: "06:00", Hours: 12, DLI: &config.DLIConfig{Target: 10.8, PPFDMax: 500}}
+	photo, err := newPhotoperiod(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scheduler{location: time.UTC, photo: photo}
+
+	now := time.Date(2025, 6, 1, 6, 0, 0, 0, time.UTC)
+	for ; now.Hour() < 18; now = now.Add(10 * time.Second) {
+		s.updateDLI(now)
+		if status := s.dliStatus(); now.Hour() == 12 && status.Scale != 50 {
+			t.Fatalf("expected 50 %% at noon, got %+v", status)
+		}
+	}
+	if status := s.dliStatus(); math.Abs(status.Achieved-10.8) > 0.1 {
+		t.Errorf("expected 10.8 mol/m², got %+v", status)
+	}
+
+	// Next cycle: counting restarts
+	s.updateDLI(now.Add(12 * time.Hour))
+	if status := s.dliStatus(); status.Achieved != 0 || !status.Cycle.Equal(now.Add(12*time.Hour)) {
+		t.Errorf("expected a new cycle, got %+v", status)
+	}
+}
+
+func TestDLISensor(t *testing.T) {
+	state := testState(t)
+
+	// Sunlight measured above the need: the lights dim to the minimum
+	now := time.Now().UTC()
+	cfg := &config.PhotoperiodConfig{
+		On:    now.Add(-time.Hour).Format("15:04"),
+		Hours: 12,
+		DLI:   &config.DLIConfig{Target: 0.05, PPFDMax: 100, Sensor: "par", MinPercent: 20},
+	}
+	photo, _ := newPhotoperiod(cfg)
+	s := &Scheduler{location: time.UTC, photo: photo, state: state}
+	state.SetVariable("par", "400", "test")
+	s.updateDLI(now)
+	s.updateDLI(now.Add(2 * time.Minute))
+	if status := s.dliStatus(); !status.Measured || status.Scale != 20 || math.Abs(status.Achieved-0.048) > 0.01 {
+		t.Errorf("expected 0.048 mol/m² measured, scale 20 %%, got %+v", status)
+	}
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Holidays
+// On a holiday (office closed, public holiday) events run their holiday program: an
+// event is skipped by default, "only" events run on holidays alone (the alternate
+// program) and "also" events run every day. Holidays are the days and periods of the
+// config, on a date ("2025-12-25") or every year ("12-25"), and the events of an iCal
+// file read when the scheduler is created: an all-day event covers its days up to
+// DTEND (excluded), a timed event the days it spans. Recurring iCal events (RRULE)
+// are not expanded, so export a calendar with its occurrences.
+
+// holiday is a period of days, "YYYY-MM-DD" or "MM-DD" every year
+type holiday struct {
+	from, to string
+	name     string
+}
+
+// holidaySpan bounds the days searched for the next run of an event (a yearly holiday
+// program runs within a year)
+const holidaySpan = 366 + 7
+
+// newHolidays returns the holidays of the config and its calendar file
+func newHolidays(cfg *config.ScheduleConfig, loc *time.Location) ([]holiday, error) {
+	var hs []holiday
+	for _, h := range cfg.Holidays {
+		to := h.To
+		if to == "" {
+			to = h.Date
+		}
+		hs = append(hs, holiday{from: h.Date, to: to, name: h.Name})
+	}
+	if cfg.Calendar != "" {
+		cal, err := readCalendar(cfg.Calendar, loc)
+		if err != nil {
+			return nil, fmt.Errorf("calendar: %w", err)
+		}
+		hs = append(hs, cal...)
+	}
+	return hs, nil
+}
+
+// readCalendar returns the events of an iCal file as holidays
+func readCalendar(path string, loc *time.Location) ([]holiday, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Unfold continuation lines (starting with a space or tab)
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var hs []holiday
+	var start, end, summary string
+	inEvent := false
+	for n, line := range lines {
+		name, value, _ := strings.Cut(line, ":")
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";") // Parameters (VALUE=DATE, TZID) unused
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent, start, end, summary = true, "", "", ""
+		case !inEvent:
+		case name == "DTSTART":
+			start = value
+		case name == "DTEND":
+			end = value
+		case name == "SUMMARY":
+			summary = icalText.Replace(value)
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			h, err := calendarHoliday(start, end, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			h.name = summary
+			hs = append(hs, h)
+		}
+	}
+	return hs, nil
+}
+
+// icalText unescapes iCal text values
+var icalText = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ", `\N`, " ", `\\`, `\`)
+
+// calendarHoliday returns the days covered by an event (end empty = its start day)
+func calendarHoliday(start, end string, loc *time.Location) (holiday, error) {
+	from, err := calendarTime(start, loc)
+	if err != nil {
+		return holiday{}, fmt.Errorf("DTSTART %q: %w", start, err)
+	}
+	to := from
+	if end != "" {
+		if to, err = calendarTime(end, loc); err != nil {
+			return holiday{}, fmt.Errorf("DTEND %q: %w", end, err)
+		}
+		to = to.Add(-time.Second) // DTEND is excluded
+		if to.Before(from) {
+			to = from
+		}
+	}
+	return holiday{from: from.Format(time.DateOnly), to: to.Format(time.DateOnly)}, nil
+}
+
+// calendarTime parses a date ("20251225") or a time, UTC ("20251225T090000Z") or
+// local ("20251225T090000", taken in loc as TZID is not resolved)
+func calendarTime(v string, loc *time.Location) (time.Time, error) {
+	switch {
+	case len(v) == len("20060102"):
+		return time.ParseInLocation("20060102", v, loc)
+	case strings.HasSuffix(v, "Z"):
+		t, err := time.Parse("20060102T150405Z", v)
+		return t.In(loc), err
+	}
+	return time.ParseInLocation("20060102T150405", v, loc)
+}
+
+// Holiday returns the name of the holiday t falls on (ok false = not a holiday)
+func (s *Scheduler) Holiday(t time.Time) (name string, ok bool) {
+	return s.holidayAt(t.In(s.location))
+}
+
+// runsOnHoliday reports whether the event runs on a holiday (off) or a normal day
+func (e Event) runsOnHoliday(off bool) bool {
+	switch e.Holiday {
+	case "only":
+		return off
+	case "also":
+		return true
+	}
+	return !off
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+func TestHolidays(t *testing.T) {
+	ics := filepath.Join(t.TempDir(), "holidays.ics")
+	os.WriteFile(ics, []byte("BEGIN:VCALENDAR\r\n"+
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20250501\r\nDTEND;VALUE=DATE:20250502\r\nSUMMARY:Labour Day\r\nEND:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\nDTSTART:20250804T070000Z\r\nDTEND:20250808T170000Z\r\nSUMMARY:Summer\\, closed\r\n"+
+		"  for works\r\nEND:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0o644)
+
+	cfg := &config.ScheduleConfig{
+		Holidays: []config.Holiday{{Date: "12-24", To: "01-01", Name: "Winter"}, {Date: "2025-07-14"}},
+		Calendar: ics,
+	}
+	hs, err := newHolidays(cfg, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scheduler{location: time.UTC, holidays: hs}
+
+	for _, tc := range []struct {
+		date string
+		want string // Holiday name, "-" = none
+	}{
+		{"2025-05-01", "Labour Day"},
+		{"2025-05-02", "-"}, // DTEND excluded
+		{"2025-08-08", "Summer, closed for works"},
+		{"2025-08-09", "-"},
+		{"2025-07-14", ""},
+		{"2025-12-31", "Winter"},
+		{"2026-01-01", "Winter"}, // Yearly period wraps
+		{"2026-01-02", "-"},
+	} {
+		day, _ := time.Parse(time.DateOnly, tc.date)
+		name, ok := s.Holiday(day.Add(12 * time.Hour))
+		if !ok {
+			name = "-"
+		}
+		if name != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.date, tc.want, name)
+		}
+	}
+
+	// Wednesday 2025-12-24 10:00: normal events skip the holidays, the holiday program
+	// runs on them
+	now := time.Date(2025, 12, 24, 10, 0, 0, 0, time.UTC)
+	office, _ := parseTime("08:00")
+	if next := s.next(office, now); !next.Equal(time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected office event on Jan 2, got %s", next)
+	}
+	office.Holiday = "only"
+	if next := s.next(office, now); !next.Equal(time.Date(2025, 12, 25, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected holiday event on Dec 25, got %s", next)
+	}
+	hourly, _ := parseEvent(config.ScheduleEvent{Cron: "0 * * * *"})
+	if next := s.next(hourly, now); !next.Equal(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected cron event on Jan 2, got %s", next)
+	}
+}
@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"time"
+)
+
+// Jitter
+// For presence simulation (vacation mode), a time event with a jitter runs up to that
+// much earlier or later, at a time drawn for each day. The draw hashes the event and
+// the day with a seed picked when the scheduler is created, so the next run is known
+// in advance (/api/schedule/next) and differs after a restart or config change. A run
+// moved across midnight still belongs to its day: days and holidays apply to it.
+
+// jitter returns the offset of the run of e on the day of t
+func (s *Scheduler) jitter(e Event, t time.Time) time.Duration {
+	span := int64(e.Jitter / time.Second)
+	if span <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, s.seed)
+	h.Write([]byte(e.ID + "@" + formatTime(e) + "@" + t.Format(time.DateOnly)))
+	return time.Duration(int64(h.Sum64()%uint64(2*span+1))-span) * time.Second
+}
+
+// runOn returns the run of a time event on a day, moved by its jitter (ok false = the
+// event does not run that day)
+func (s *Scheduler) runOn(e Event, year int, month time.Month, day int) (t time.Time, ok bool) {
+	t = time.Date(year, month, day, e.Hour, e.Minute, e.Second, 0, s.loc(e))
+	if !e.runsOn(t.Weekday()) {
+		return t, false
+	}
+	if _, off := s.holidayAt(t); !e.runsOnHoliday(off) {
+		return t, false
+	}
+	return t.Add(s.jitter(e, t)), true
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	s := &Scheduler{location: time.UTC, seed: 42}
+	e, _ := parseTime("23:50")
+	e.ID = "lamp"
+	e.Jitter = 30 * time.Minute
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	offsets := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		run := s.next(e, now)
+		day := time.Date(now.Year(), now.Month(), now.Day(), 23, 50, 0, 0, time.UTC)
+		if run.Before(day.Add(-e.Jitter)) || run.After(day.Add(e.Jitter)) {
+			t.Fatalf("run %s out of the jitter of %s", run, day)
+		}
+		if got, ok := s.runOn(e, now.Year(), now.Month(), now.Day()); !ok || !got.Equal(run) {
+			t.Fatalf("expected the run of the day at %s, got %s", run, got)
+		}
+		if again := s.next(e, run); !again.After(day.Add(e.Jitter)) {
+			t.Fatalf("expected no other run on the day of %s, got %s", run, again)
+		}
+		offsets[run.Sub(day)] = true
+		now = day.Add(time.Hour) // Past the latest run of the day
+	}
+	if len(offsets) < 10 {
+		t.Errorf("expected runs to vary by day, got %d offsets", len(offsets))
+	}
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import "time"
+
+// Pause
+// An operator taking manual control (maintenance, a rehearsal) pauses the scheduler:
+// events due meanwhile are skipped, not caught up, and the circadian curve and
+// photoperiod hold. The pause lasts until Resume, or until the time given to Pause;
+// resuming re-applies the circadian point and photoperiod level at once. Each change is broadcast as {"type":"schedule_pause", ...}.
+
+// PauseStatus describes the pause of the scheduler
+type PauseStatus struct {
+	Type   string     `json:"type"` // "schedule_pause"
+	Paused bool       `json:"paused"`
+	Until  *time.Time `json:"until,omitempty"` // Automatic resume (nil = until resumed)
+}
+
+// Pause stops running events until Resume, or until until when not zero
+func (s *Scheduler) Pause(until time.Time) {
+	s.mu.Lock()
+	s.paused = true
+	s.pausedUntil = until
+	s.mu.Unlock()
+	s.rearm()
+	s.logger.Info("Scheduler paused", "until", until)
+	s.state.Broadcast(s.PauseStatus())
+}
+
+// Resume ends a pause
+func (s *Scheduler) Resume() {
+	s.mu.Lock()
+	was := s.paused
+	s.paused = false
+	s.pausedUntil = time.Time{}
+	s.mu.Unlock()
+	if was {
+		s.resumed()
+	}
+}
+
+// resumed reports the end of a pause and catches up with the circadian curve and
+// photoperiod
+func (s *Scheduler) resumed() {
+	s.logger.Info("Scheduler resumed")
+	s.state.Broadcast(s.PauseStatus())
+	if s.circ != nil {
+		s.applyCircadian(0)
+	}
+	if s.photo != nil {
+		s.applyPhotoperiod(0, true)
+	}
+}
+
+// PauseStatus returns the pause of the scheduler
+func (s *Scheduler) PauseStatus() PauseStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := PauseStatus{Type: "schedule_pause", Paused: s.paused}
+	if s.paused && !s.pausedUntil.IsZero() {
+		until := s.pausedUntil
+		status.Until = &until
+	}
+	return status
+}
+
+// isPaused reports whether the scheduler is paused at now, resuming it once the
+// pause has expired
+func (s *Scheduler) isPaused(now time.Time) bool {
+	s.mu.Lock()
+	expired := s.paused && !s.pausedUntil.IsZero() && !now.Before(s.pausedUntil)
+	if expired {
+		s.paused = false
+		s.pausedUntil = time.Time{}
+	}
+	paused := s.paused
+	s.mu.Unlock()
+	if expired {
+		s.resumed()
+	}
+	return paused
+}
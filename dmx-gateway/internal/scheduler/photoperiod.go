@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Photoperiod mode
+// Grow lights follow a daily cycle given by its lights-on time and duration (18/6,
+// 12/12...): a dawn ramp from off to the day spectra, the day, a dusk ramp back to off,
+// then the night, wrapping at midnight. Every interval the level of the cycle is
+// computed, and the spectra scaled by it are faded to when it changed; the day and the
+// night are written once, so a manual change holds until the next phase. With a DLI
+// target, the spectra are scaled down to reach it (see dli.go).
+
+// PhotoperiodStatus describes the photoperiod mode and its current phase
+type PhotoperiodStatus struct {
+	Enabled bool   `json:"enabled"`
+	Phase   string `json:"phase"` // "dawn", "day", "dusk" or "night"
+	Level   int    `json:"level"` // Percent of the day spectra, DLI scale included
+	On      string `json:"on"`    // "HH:MM", start of the dawn ramp
+	Off     string `json:"off"`   // "HH:MM", end of the dusk ramp
+
+	DLI *DLIStatus `json:"dli,omitempty"` // With a DLI target
+}
+
+// photoperiod holds the parsed cycle, in seconds from lights on
+type photoperiod struct {
+	cfg     *config.PhotoperiodConfig
+	on      int // Second of day
+	length  int
+	sunrise int
+	sunset  int
+}
+
+func newPhotoperiod(cfg *config.PhotoperiodConfig) (*photoperiod, error) {
+	e, err := parseTime(cfg.On)
+	if err != nil {
+		return nil, err
+	}
+	return &photoperiod{
+		cfg:     cfg,
+		on:      timeToSeconds(e),
+		length:  int(math.Round(cfg.Hours * 3600)),
+		sunrise: cfg.SunriseMin * 60,
+		sunset:  cfg.SunsetMin * 60,
+	}, nil
+}
+
+// at returns the phase and level (0-1) of the cycle for a time of day
+func (p *photoperiod) at(now time.Time) (string, float64) {
+	sec := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	rel := (sec - p.on + 86400) % 86400
+	switch {
+	case rel < p.sunrise:
+		return "dawn", float64(rel) / float64(p.sunrise)
+	case rel < p.length-p.sunset:
+		return "day", 1
+	case rel < p.length:
+		return "dusk", float64(p.length-rel) / float64(p.sunset)
+	}
+	return "night", 0
+}
+
+// photoperiodLoop applies the cycle every interval until stopped
+func (s *Scheduler) photoperiodLoop() {
+	interval := time.Duration(s.photo.cfg.IntervalSec) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	if s.photo.cfg.DLI != nil {
+		s.updateDLI(time.Now().In(s.location))
+	}
+	s.applyPhotoperiod(0, true)
+	for {
+		select {
+		case <-ticker.C:
+			if s.photo.cfg.DLI != nil {
+				s.updateDLI(time.Now().In(s.location)) // Counted while disabled or paused too
+			}
+			s.applyPhotoperiod(interval, false)
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// applyPhotoperiod fades the spectra to the current level of the cycle, when it changed
+// or when forced
+func (s *Scheduler) applyPhotoperiod(fade time.Duration, force bool) {
+	s.mu.RLock()
+	enabled := s.photoEnabled
+	s.mu.RUnlock()
+	if !enabled || s.isPaused(time.Now()) {
+		return
+	}
+
+	_, level := s.photo.at(time.Now().In(s.location))
+	level *= s.dliScale()
+	s.mu.Lock()
+	changed := force || level != s.photoLevel
+	s.photoLevel = level
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	for target, spectrum := range s.photo.cfg.Spectra {
+		values := make(map[string]uint8, len(spectrum))
+		for color, v := range spectrum {
+			values[color] = uint8(math.Round(float64(v) * level))
+		}
+		group, light, _ := strings.Cut(target, "/")
+		var err error
+		if light == "" {
+			err = s.src.FadeGroup(group, values, fade)
+		} else {
+			err = s.src.FadeLight(group, light, values, fade)
+		}
+		if err != nil {
+			s.logger.Warn("Photoperiod update failed", "target", target, "error", err)
+		}
+	}
+}
+
+// Photoperiod returns the photoperiod mode status (nil if not configured)
+func (s *Scheduler) Photoperiod() *PhotoperiodStatus {
+	if s.photo == nil {
+		return nil
+	}
+
+	phase, level := s.photo.at(time.Now().In(s.location))
+	s.mu.RLock()
+	enabled := s.photoEnabled
+	s.mu.RUnlock()
+	clock := func(sec int) string {
+		return time.Date(0, 1, 1, 0, 0, (sec+86400)%86400, 0, time.UTC).Format("15:04")
+	}
+	return &PhotoperiodStatus{
+		Enabled: enabled,
+		Phase:   phase,
+		Level:   int(math.Round(level * s.dliScale() * 100)),
+		On:      clock(s.photo.on),
+		Off:     clock(s.photo.on + s.photo.length),
+		DLI:     s.dliStatus(),
+	}
+}
+
+// SetPhotoperiod enables or disables the photoperiod mode (re-enabling applies immediately)
+func (s *Scheduler) SetPhotoperiod(enabled bool) {
+	if s.photo == nil {
+		return
+	}
+	s.mu.Lock()
+	changed := s.photoEnabled != enabled
+	s.photoEnabled = enabled
+	s.mu.Unlock()
+
+	if changed {
+		s.logger.Info("Photoperiod mode", "enabled", enabled)
+		if enabled {
+			s.applyPhotoperiod(0, true)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+func TestPhotoperiodAt(t *testing.T) {
+	// 18/6 from 20:00 (over midnight), 1h dawn, 30 min dusk
+	p, err := newPhotoperiod(&config.PhotoperiodConfig{On: "20:00", Hours: 18, SunriseMin: 60, SunsetMin: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		clock string
+		phase string
+		level float64
+	}{
+		{"19:59", "night", 0},
+		{"20:00", "dawn", 0},
+		{"20:30", "dawn", 0.5},
+		{"21:00", "day", 1},
+		{"13:29", "day", 1},
+		{"13:45", "dusk", 0.5},
+		{"14:00", "night", 0},
+	} {
+		now, _ := time.Parse("15:04", tc.clock)
+		if phase, level := p.at(now); phase != tc.phase || level != tc.level {
+			t.Errorf("%s: expected %s %g, got %s %g", tc.clock, tc.phase, tc.level, phase, level)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"sort"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+// Preview
+// The timeline of a date, resolved as the scheduler would run it but without running
+// anything: event runs after days, holidays and jitter (as drawn by this scheduler),
+// sunrise and sunset of the circadian sun mode, and photoperiod phase changes. A pause
+// is ignored. Cron events running more often than maxPreviewRuns a day are truncated.
+
+// maxPreviewRuns bounds the runs of a preview
+const maxPreviewRuns = 1000
+
+// Preview is the timeline of a date
+type Preview struct {
+	Date        string             `json:"date"`              // YYYY-MM-DD
+	Weekday     string             `json:"weekday"`           // "mon"...
+	Holiday     *string            `json:"holiday,omitempty"` // Name (possibly empty) on a holiday
+	Runs        []PreviewRun       `json:"runs"`
+	Truncated   bool               `json:"truncated,omitempty"` // More than maxPreviewRuns runs
+	Sunrise     string             `json:"sunrise,omitempty"`   // Circadian sun mode, "HH:MM"
+	Sunset      string             `json:"sunset,omitempty"`
+	Photoperiod []PhotoperiodPhase `json:"photoperiod,omitempty"`
+}
+
+// PreviewRun is an event run of a preview
+type PreviewRun struct {
+	At time.Time `json:"at"`
+	EventInfo
+}
+
+// PhotoperiodPhase is a photoperiod phase change
+type PhotoperiodPhase struct {
+	At    time.Time `json:"at"`
+	Phase string    `json:"phase"` // "dawn", "day", "dusk" or "night"
+}
+
+// Preview returns the timeline of date ("YYYY-MM-DD", empty = today)
+func (s *Scheduler) Preview(date string) (Preview, error) {
+	day := time.Now().In(s.location)
+	if date != "" {
+		var err error
+		if day, err = time.ParseInLocation(time.DateOnly, date, s.location); err != nil {
+			return Preview{}, err
+		}
+	}
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, s.location)
+	end := start.AddDate(0, 0, 1)
+	p := Preview{Date: start.Format(time.DateOnly), Weekday: config.Weekdays[start.Weekday()], Runs: []PreviewRun{}}
+	if name, ok := s.Holiday(start); ok {
+		p.Holiday = &name
+	}
+
+	type run struct {
+		at    time.Time
+		index int // Event order on a tie (as check runs them)
+	}
+	var runs []run
+	for i, e := range s.events {
+		if e.Cron != nil {
+			n := 0
+			for t := e.Cron.Next(start.Add(-time.Second).In(s.loc(e))); !t.IsZero() && t.Before(end); t = e.Cron.Next(t) {
+				if _, off := s.holidayAt(t); !e.runsOnHoliday(off) {
+					t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()).Add(-time.Second)
+					continue // The day in its zone is left out
+				}
+				if n++; n > maxPreviewRuns {
+					p.Truncated = true
+					break
+				}
+				runs = append(runs, run{t, i})
+			}
+			continue
+		}
+		for d := -2; d <= 2; d++ { // A jitter or the event timezone may move the run of another day
+			if t, ok := s.runOn(e, start.Year(), start.Month(), start.Day()+d); ok && !t.Before(start) && t.Before(end) {
+				runs = append(runs, run{t, i})
+			}
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		if !runs[i].at.Equal(runs[j].at) {
+			return runs[i].at.Before(runs[j].at)
+		}
+		return runs[i].index < runs[j].index
+	})
+	if len(runs) > maxPreviewRuns {
+		runs, p.Truncated = runs[:maxPreviewRuns], true
+	}
+	for _, r := range runs {
+		p.Runs = append(p.Runs, PreviewRun{At: r.at, EventInfo: eventInfo(s.events[r.index])})
+	}
+
+	if s.circ != nil && len(s.circ.points) == 0 {
+		if rise, set, polar := sunTimes(start, s.circ.cfg.Latitude, s.circ.cfg.Longitude); polar == 0 {
+			p.Sunrise = rise.Format("15:04")
+			p.Sunset = set.Format("15:04")
+		}
+	}
+	if s.photo != nil {
+		p.Photoperiod = s.photo.phases(start, end)
+	}
+	return p, nil
+}
+
+// phases returns the phase changes in [start, end), from the cycles started the day
+// before and that day
+func (p *photoperiod) phases(start, end time.Time) []PhotoperiodPhase {
+	var phases []PhotoperiodPhase
+	for d := -1; d <= 0; d++ {
+		on := time.Date(start.Year(), start.Month(), start.Day()+d, 0, 0, p.on, 0, start.Location())
+		for _, c := range []struct {
+			sec   int
+			phase string
+		}{{0, "dawn"}, {p.sunrise, "day"}, {p.length - p.sunset, "dusk"}, {p.length, "night"}} {
+			at := on.Add(time.Duration(c.sec) * time.Second)
+			if n := len(phases); n > 0 && phases[n-1].At.Equal(at) {
+				phases[n-1].Phase = c.phase // No ramp: the phase is skipped
+				continue
+			}
+			if c.phase == "night" && p.length == 86400 {
+				continue // Always on
+			}
+			if !at.Before(start) && at.Before(end) {
+				phases = append(phases, PhotoperiodPhase{At: at, Phase: c.phase})
+			}
+		}
+	}
+	return phases
+}
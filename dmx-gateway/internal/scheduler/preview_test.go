@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"dmx-gateway/internal/config"
+)
+
+func TestPreview(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state := testState(t)
+	s, err := New(&config.ScheduleConfig{
+		Timezone: "UTC",
+		Events: []config.ScheduleEvent{
+			{ID: "office", Time: "08:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Scene: "office"},
+			{ID: "watch", Time: "10:00", Holiday: "only", Scene: "watch"},
+			{ID: "check", Cron: "0 */6 * * *", Scene: "check"},
+		},
+		Holidays:    []config.Holiday{{Date: "12-25", Name: "Christmas"}},
+		Circadian:   &config.CircadianConfig{Latitude: 48.85, Longitude: 2.35},
+		Photoperiod: &config.PhotoperiodConfig{On: "20:00", Hours: 18, SunriseMin: 60},
+	}, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs := func(p Preview) []string {
+		var ids []string
+		for _, r := range p.Runs {
+			ids = append(ids, r.At.Format("15:04")+" "+r.ID)
+		}
+		return ids
+	}
+
+	// Christmas: only the holiday program runs
+	p, err := s.Preview("2025-12-25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Holiday == nil || *p.Holiday != "Christmas" || p.Weekday != "thu" || len(p.Runs) != 1 || p.Runs[0].ID != "watch" {
+		t.Errorf("expected the holiday program on Christmas, got %+v %v", p, runs(p))
+	}
+	if p.Sunrise == "" || p.Sunset == "" {
+		t.Errorf("expected sun times, got %q %q", p.Sunrise, p.Sunset)
+	}
+
+	p, _ = s.Preview("2025-12-26")
+	want := []string{"00:00 check", "06:00 check", "08:00 office", "12:00 check", "18:00 check"}
+	if got := runs(p); len(got) != len(want) || p.Holiday != nil {
+		t.Errorf("expected %v, got %v", want, got)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("run %d: expected %s, got %s", i, want[i], got[i])
+			}
+		}
+	}
+	// Cycle of the 25th ends at 14:00 (no dusk ramp), the next starts at 20:00
+	var phases []string
+	for _, ph := range p.Photoperiod {
+		phases = append(phases, ph.At.Format("15:04")+" "+ph.Phase)
+	}
+	if len(phases) != 3 || phases[0] != "14:00 night" || phases[1] != "20:00 dawn" || phases[2] != "21:00 day" {
+		t.Errorf("unexpected photoperiod phases %v", phases)
+	}
+
+	if p, _ := s.Preview("2025-12-28"); len(p.Runs) != 4 { // Sunday: no office
+		t.Errorf("expected 4 runs on Sunday, got %v", runs(p))
+	}
+	if _, err := s.Preview("28/12/2025"); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
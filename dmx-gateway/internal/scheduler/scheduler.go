@@ -5,35 +5,61 @@ package scheduler
 
 import (
 	"log/slog"
+	"math/rand/v2"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/cron"
 	"dmx-gateway/internal/dmx"
 )
 
-// Event is a parsed schedule event with time components
+// Event is a parsed schedule event with time components, or a cron expression
 type Event struct {
+	ID       string
+	Cron     *cron.Schedule // Replaces the time and days when set
+	CronExpr string
 	Hour     int
 	Minute   int
 	Second   int
+	Days     uint8 // Bit per time.Weekday it runs (0 = every day)
 	Set      map[string]map[string]uint8
 	Blackout bool
+	Scene    string
+	Preset   map[string]string  // target -> preset name
+	Holiday  string             // "only" or "also" (default skipped on holidays, see holidays.go)
+	Jitter   time.Duration      // Runs up to this much earlier or later (see jitter.go)
+	When     []config.Condition // All must hold when due (see conditions.go)
+	Timezone string             // Own timezone as configured (see zones.go)
+	Location *time.Location     // nil = the schedule's
 }
 
 // Scheduler runs scheduled lighting events
 type Scheduler struct {
 	events   []Event
 	state    *dmx.State
+	src      *dmx.Source
 	logger   *slog.Logger
 	location *time.Location
 
-	mu          sync.RWMutex
-	lastRun     string // "HH:MM:SS" of last executed event
-	stopChan    chan struct{}
-	running     bool
+	circ     *circadian   // nil = circadian mode not configured
+	photo    *photoperiod // nil = photoperiod mode not configured
+	holidays []holiday
+	seed     uint64 // Jitter draws
+
+	mu           sync.RWMutex
+	circEnabled  bool
+	photoEnabled bool
+	photoLevel   float64 // Last applied (see photoperiod.go)
+	dli          dli     // See dli.go
+	stopChan     chan struct{}
+	wake         chan struct{} // Plans the loop sleep again (see timer.go)
+	running      bool
+	paused       bool      // See pause.go
+	pausedUntil  time.Time // Automatic resume (zero = until resumed)
 }
 
 // New creates a new scheduler
@@ -49,28 +75,55 @@ func New(cfg *config.ScheduleConfig, state *dmx.State, logger *slog.Logger) (*Sc
 
 	events := make([]Event, 0, len(cfg.Events))
 	for _, e := range cfg.Events {
-		parsed, err := parseTime(e.Time)
+		if e.Disabled {
+			continue
+		}
+		parsed, err := newEvent(cfg, e)
 		if err != nil {
-			logger.Warn("Invalid schedule time", "time", e.Time, "error", err)
+			logger.Warn("Invalid schedule event", "time", e.Time, "cron", e.Cron, "error", err)
 			continue
 		}
-		parsed.Set = e.Set
-		parsed.Blackout = e.Blackout
 		events = append(events, parsed)
 	}
 
-	// Sort by time
-	sort.Slice(events, func(i, j int) bool {
-		return timeToSeconds(events[i]) < timeToSeconds(events[j])
+	// Sort by time, cron events last
+	sort.SliceStable(events, func(i, j int) bool {
+		return sortKey(events[i]) < sortKey(events[j])
 	})
 
-	return &Scheduler{
+	holidays, err := newHolidays(cfg, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scheduler{
 		events:   events,
 		state:    state,
+		src:      state.Source(dmx.SourceScheduler),
 		logger:   logger,
 		location: loc,
+		holidays: holidays,
+		seed:     rand.Uint64(),
 		stopChan: make(chan struct{}),
-	}, nil
+		wake:     make(chan struct{}, 1),
+	}
+	if cfg.Circadian != nil {
+		circ, err := newCircadian(cfg.Circadian)
+		if err != nil {
+			return nil, err
+		}
+		s.circ = circ
+		s.circEnabled = true
+	}
+	if cfg.Photoperiod != nil {
+		photo, err := newPhotoperiod(cfg.Photoperiod)
+		if err != nil {
+			return nil, err
+		}
+		s.photo = photo
+		s.photoEnabled = true
+	}
+	return s, nil
 }
 
 // Start begins the scheduler loop
@@ -84,7 +137,13 @@ func (s *Scheduler) Start() {
 	s.mu.Unlock()
 
 	go s.loop()
-	s.logger.Info("Scheduler started", "events", len(s.events), "timezone", s.location.String())
+	if s.circ != nil {
+		go s.circadianLoop()
+	}
+	if s.photo != nil {
+		go s.photoperiodLoop()
+	}
+	s.logger.Info("Scheduler started", "events", len(s.events), "circadian", s.circ != nil, "photoperiod", s.photo != nil, "timezone", s.location.String())
 }
 
 // Stop stops the scheduler
@@ -101,71 +160,160 @@ func (s *Scheduler) Stop() {
 	s.logger.Info("Scheduler stopped")
 }
 
-// loop checks every second for events to execute
-func (s *Scheduler) loop() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			s.check()
-		case <-s.stopChan:
-			return
+// Running reports whether the scheduler was started and not stopped
+func (s *Scheduler) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// CatchUp runs the last event due today before now, so a restart at 14:00 does not
+// leave the lights as before it (last night's blackout) until the next event. It
+// reports whether an event ran.
+func (s *Scheduler) CatchUp() bool {
+	if s.isPaused(time.Now()) {
+		return false
+	}
+
+	// Latest run in [midnight, now] of its zone, the first in event order on a tie (as
+	// check runs it)
+	var last *Event
+	var at time.Time
+	for i, e := range s.events {
+		now := time.Now().In(s.loc(e))
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		var t time.Time
+		if e.Cron != nil {
+			if _, off := s.holidayAt(now); !e.runsOnHoliday(off) {
+				continue
+			}
+			for next := e.Cron.Next(midnight.Add(-time.Second)); !next.IsZero() && !next.After(now); next = e.Cron.Next(next) {
+				t = next
+			}
+		} else {
+			for d := -1; d <= 0; d++ { // A jitter may move yesterday's run to today
+				if run, ok := s.runOn(e, now.Year(), now.Month(), now.Day()+d); ok && !run.Before(midnight) && !run.After(now) {
+					t = run
+				}
+			}
 		}
+		if !t.IsZero() && (last == nil || t.After(at)) {
+			last, at = &s.events[i], t
+		}
+	}
+	if last == nil {
+		return false
 	}
+	s.logger.Info("Catching up schedule event", "due", at.Format("15:04:05"))
+	s.execute(*last)
+	return true
 }
 
-// check executes any event matching current time
-func (s *Scheduler) check() {
-	now := time.Now().In(s.location)
-	nowStr := now.Format("15:04:05")
-
-	s.mu.Lock()
-	if s.lastRun == nowStr {
-		s.mu.Unlock()
-		return
+// newEvent converts a configured event (of cfg, for its timezone) to a scheduled one
+func newEvent(cfg *config.ScheduleConfig, e config.ScheduleEvent) (Event, error) {
+	event, err := parseEvent(e)
+	if err != nil {
+		return Event{}, err
 	}
-	s.mu.Unlock()
+	event.ID = e.ID
+	event.Days = parseDays(e.Days)
+	event.Set = e.Set
+	event.Blackout = e.Blackout
+	event.Scene = e.Scene
+	event.Preset = e.Preset
+	if e.Holiday != "skip" {
+		event.Holiday = e.Holiday
+	}
+	if e.Jitter != "" {
+		event.Jitter, _ = time.ParseDuration(e.Jitter) // Validated with the config
+	}
+	event.When = e.When
+	if e.Timezone != "" {
+		if event.Location, err = cfg.EventLocation(e.Timezone); err != nil {
+			return Event{}, err
+		}
+		event.Timezone = e.Timezone
+	}
+	return event, nil
+}
 
-	h, m, sec := now.Hour(), now.Minute(), now.Second()
+// RunEvent runs an event of cfg now, disabled or not and whatever its conditions
+// (manual trigger), reported as a scheduled run
+func (s *Scheduler) RunEvent(cfg *config.ScheduleConfig, e config.ScheduleEvent) (Execution, error) {
+	run, err := newEvent(cfg, e)
+	if err != nil {
+		return Execution{}, err
+	}
+	run.When = nil // Not checked
+	s.logger.Info("Running schedule event manually", "id", e.ID)
+	return s.execute(run), nil
+}
 
-	for _, e := range s.events {
-		if e.Hour == h && e.Minute == m && e.Second == sec {
-			s.execute(e)
-			s.mu.Lock()
-			s.lastRun = nowStr
-			s.mu.Unlock()
-			return
+// execute runs a scheduled event, then reports it to subscribers ({"type":"schedule"})
+// and observers (webhooks) with its result and the next event
+func (s *Scheduler) execute(e Event) Execution {
+	x := Execution{Type: "schedule", EventInfo: eventInfo(e), Result: "ok"}
+	var errs []error
+	if x.Unmet = s.unmet(e); x.Unmet != "" {
+		s.logger.Info("Skipping scheduled event", "time", formatTime(e), "cron", e.CronExpr, "unmet", x.Unmet)
+		x.Result = "skipped"
+	} else {
+		s.logger.Info("Executing scheduled event", "time", formatTime(e), "cron", e.CronExpr)
+		errs = s.run(e)
+	}
+	x.Next = s.NextEvent()
+	if len(errs) > 0 {
+		x.Result = "failed"
+		for _, err := range errs {
+			x.Errors = append(x.Errors, err.Error())
 		}
 	}
+	s.state.Broadcast(x)
+	s.state.Emit(dmx.Event{Event: "schedule", Source: dmx.SourceScheduler, Data: x})
+	return x
 }
 
-// execute runs a scheduled event
-func (s *Scheduler) execute(e Event) {
-	s.logger.Info("Executing scheduled event", "time", formatTime(e))
-
+// run applies a scheduled event, returning the failures
+func (s *Scheduler) run(e Event) []error {
+	var errs []error
 	if e.Blackout {
-		if err := s.state.Blackout(); err != nil {
+		if err := s.src.Blackout(); err != nil {
 			s.logger.Error("Schedule blackout failed", "error", err)
+			errs = append(errs, err)
+		}
+		return errs
+	}
+
+	if e.Scene != "" {
+		if err := s.src.RecallScene(e.Scene, 0); err != nil {
+			s.logger.Error("Schedule scene recall failed", "scene", e.Scene, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	for target, preset := range e.Preset {
+		if err := s.src.RecallPreset(target, preset, 0); err != nil {
+			s.logger.Error("Schedule preset failed", "target", target, "preset", preset, "error", err)
+			errs = append(errs, err)
 		}
-		return
 	}
 
 	for target, values := range e.Set {
 		group, light := parseTarget(target)
+		var err error
 		if light == "" {
 			// Set entire group
-			if err := s.state.SetGroup(group, values); err != nil {
-				s.logger.Error("Schedule set group failed", "target", target, "error", err)
-			}
+			err = s.src.SetGroup(group, values)
 		} else {
 			// Set specific light
-			if err := s.state.SetLight(group, light, values); err != nil {
-				s.logger.Error("Schedule set light failed", "target", target, "error", err)
-			}
+			err = s.src.SetLight(group, light, values)
+		}
+		if err != nil {
+			s.logger.Error("Schedule set failed", "target", target, "error", err)
+			errs = append(errs, err)
 		}
 	}
+	return errs
 }
 
 // NextEvent returns the next scheduled event
@@ -174,69 +322,201 @@ func (s *Scheduler) NextEvent() *NextEventInfo {
 		return nil
 	}
 
-	now := time.Now().In(s.location)
-	nowSec := now.Hour()*3600 + now.Minute()*60 + now.Second()
+	now := time.Now().In(s.location).Truncate(time.Second)
 
-	// Find next event today
-	for _, e := range s.events {
-		eSec := timeToSeconds(e)
-		if eSec > nowSec {
-			return &NextEventInfo{
-				Time:     formatTime(e),
-				In:       time.Duration(eSec-nowSec) * time.Second,
-				Blackout: e.Blackout,
-				Targets:  targetList(e.Set),
-			}
+	// Earliest next run, the first in event order on a tie (as check runs it)
+	var next *Event
+	var at time.Time
+	for i, e := range s.events {
+		t := s.next(e, now)
+		if !t.IsZero() && (next == nil || t.Before(at)) {
+			next, at = &s.events[i], t
 		}
 	}
+	if next == nil {
+		return nil
+	}
+	info := nextInfo(*next, at, now)
+	return &info
+}
+
+// maxUpcoming bounds the runs returned by Upcoming
+const maxUpcoming = 100
+
+// Upcoming returns the next count runs of the events, in time order (as NextEvent)
+func (s *Scheduler) Upcoming(count int) []NextEventInfo {
+	count = min(count, maxUpcoming)
+	now := time.Now().In(s.location).Truncate(time.Second)
 
-	// Wrap to first event tomorrow
-	if len(s.events) > 0 {
-		e := s.events[0]
-		eSec := timeToSeconds(e)
-		secsUntil := (24*3600 - nowSec) + eSec
-		return &NextEventInfo{
-			Time:     formatTime(e),
-			In:       time.Duration(secsUntil) * time.Second,
-			Blackout: e.Blackout,
-			Targets:  targetList(e.Set),
+	runs := make([]time.Time, len(s.events)) // Next run of each event (zero = none)
+	for i, e := range s.events {
+		runs[i] = s.next(e, now)
+	}
+	result := make([]NextEventInfo, 0, count)
+	for len(result) < count {
+		first := -1
+		for i, t := range runs {
+			if !t.IsZero() && (first < 0 || t.Before(runs[first])) {
+				first = i
+			}
 		}
+		if first < 0 {
+			break
+		}
+		result = append(result, nextInfo(s.events[first], runs[first], now))
+		runs[first] = s.next(s.events[first], runs[first])
 	}
+	return result
+}
 
-	return nil
+// nextInfo describes the run of e at at
+func nextInfo(e Event, at, now time.Time) NextEventInfo {
+	return NextEventInfo{
+		ID:       e.ID,
+		At:       at,
+		Time:     at.Format("15:04:05"),
+		Cron:     e.CronExpr,
+		In:       at.Sub(now),
+		InStr:    at.Sub(now).String(),
+		Blackout: e.Blackout,
+		Scene:    e.Scene,
+		Targets:  targetList(e.Set),
+	}
+}
+
+// next returns the first run of e after now, holidays considered (zero = none)
+func (s *Scheduler) next(e Event, now time.Time) time.Time {
+	now = now.In(s.loc(e))
+	if e.Cron != nil {
+		// Skip the days the holiday program leaves out
+		for day := 0; day < holidaySpan; day++ {
+			t := e.Cron.Next(now)
+			if t.IsZero() {
+				break
+			}
+			if _, off := s.holidayAt(t); e.runsOnHoliday(off) {
+				return t
+			}
+			now = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()).Add(-time.Second)
+		}
+		return time.Time{}
+	}
+	// Later today, else on the following days (a week on, an event restricted to
+	// today's weekday runs again); with a jitter, yesterday's run may still be due
+	first := 0
+	if e.Jitter > 0 {
+		first = -1
+	}
+	for day := first; day <= holidaySpan; day++ {
+		if t, ok := s.runOn(e, now.Year(), now.Month(), now.Day()+day); ok && t.After(now) {
+			return t
+		}
+	}
+	return time.Time{}
 }
 
 // Events returns all scheduled events
 func (s *Scheduler) Events() []EventInfo {
 	result := make([]EventInfo, len(s.events))
 	for i, e := range s.events {
-		result[i] = EventInfo{
-			Time:     formatTime(e),
-			Blackout: e.Blackout,
-			Targets:  targetList(e.Set),
-		}
+		result[i] = eventInfo(e)
 	}
 	return result
 }
 
 // NextEventInfo describes the next scheduled event
 type NextEventInfo struct {
-	Time     string        `json:"time"`
+	ID       string        `json:"id,omitempty"`
+	At       time.Time     `json:"at"`             // Run, jitter included
+	Time     string        `json:"time"`           // Time of the run
+	Cron     string        `json:"cron,omitempty"` // Expression of a cron event
 	In       time.Duration `json:"in"`
 	InStr    string        `json:"in_str"`
 	Blackout bool          `json:"blackout"`
+	Scene    string        `json:"scene,omitempty"`
 	Targets  []string      `json:"targets,omitempty"`
 }
 
 // EventInfo describes a scheduled event
 type EventInfo struct {
-	Time     string   `json:"time"`
-	Blackout bool     `json:"blackout"`
-	Targets  []string `json:"targets,omitempty"`
+	ID       string             `json:"id,omitempty"`
+	Time     string             `json:"time,omitempty"`
+	Cron     string             `json:"cron,omitempty"`
+	Days     []string           `json:"days,omitempty"`    // Empty = every day
+	Holiday  string             `json:"holiday,omitempty"` // "only" or "also" (empty = skipped on holidays)
+	When     []config.Condition `json:"when,omitempty"`
+	Timezone string             `json:"timezone,omitempty"` // Own timezone (empty = the schedule's)
+	Blackout bool               `json:"blackout"`
+	Scene    string             `json:"scene,omitempty"`
+	Targets  []string           `json:"targets,omitempty"`
+}
+
+// Execution reports a scheduled event run
+type Execution struct {
+	Type string `json:"type"` // "schedule"
+	EventInfo
+	Result string         `json:"result"`           // ok, failed or skipped (condition not met)
+	Unmet  string         `json:"unmet,omitempty"`  // Skipped: first condition not met
+	Errors []string       `json:"errors,omitempty"` // Failed actions
+	Next   *NextEventInfo `json:"next,omitempty"`   // Event due next
 }
 
 // Helper functions
 
+func eventInfo(e Event) EventInfo {
+	return EventInfo{
+		ID:       e.ID,
+		Time:     formatTime(e),
+		Cron:     e.CronExpr,
+		Days:     dayNames(e.Days),
+		Holiday:  e.Holiday,
+		When:     e.When,
+		Timezone: e.Timezone,
+		Blackout: e.Blackout,
+		Scene:    e.Scene,
+		Targets:  targetList(e.Set),
+	}
+}
+
+// runsOn reports whether the event runs on weekday
+func (e Event) runsOn(weekday time.Weekday) bool {
+	return e.Days == 0 || e.Days&(1<<weekday) != 0
+}
+
+// parseDays returns the weekday bits of day names (validated with the config)
+func parseDays(days []string) uint8 {
+	var bits uint8
+	for _, d := range days {
+		if i := slices.Index(config.Weekdays, d); i >= 0 {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
+// dayNames returns the names of weekday bits, Monday first (nil = every day)
+func dayNames(bits uint8) []string {
+	var names []string
+	for i := 1; i <= 7; i++ {
+		if bits&(1<<(i%7)) != 0 {
+			names = append(names, config.Weekdays[i%7])
+		}
+	}
+	return names
+}
+
+// parseEvent parses the time or cron expression of a configured event
+func parseEvent(e config.ScheduleEvent) (Event, error) {
+	if e.Cron != "" {
+		sched, err := cron.Parse(e.Cron)
+		if err != nil {
+			return Event{}, err
+		}
+		return Event{Cron: sched, CronExpr: e.Cron}, nil
+	}
+	return parseTime(e.Time)
+}
+
 func parseTime(s string) (Event, error) {
 	t, err := time.Parse("15:04:05", s)
 	if err != nil {
@@ -254,9 +534,20 @@ func parseTime(s string) (Event, error) {
 }
 
 func formatTime(e Event) string {
+	if e.Cron != nil {
+		return ""
+	}
 	return time.Date(0, 1, 1, e.Hour, e.Minute, e.Second, 0, time.UTC).Format("15:04:05")
 }
 
+// sortKey orders events by time of day, cron events last
+func sortKey(e Event) int {
+	if e.Cron != nil {
+		return 24 * 3600
+	}
+	return timeToSeconds(e)
+}
+
 func timeToSeconds(e Event) int {
 	return e.Hour*3600 + e.Minute*60 + e.Second
 }
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+)
+
+// testState returns an enabled mock state with a blue light in rack1 and a white one in house
+func testState(t *testing.T) *dmx.State {
+	t.Helper()
+	state, _ := dmx.NewStateWithMock(&config.Config{
+		DMX: config.DMXConfig{Client: "mock"},
+		Lights: map[string]map[string][]config.Channel{
+			"rack1": {"level1": {{Ch: 1, Color: "blue"}}},
+			"house": {"main": {{Ch: 2, Color: "white"}}},
+		},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	state.Enable()
+	return state
+}
+
+func TestCatchUp(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state := testState(t)
+
+	s, err := New(&config.ScheduleConfig{Events: []config.ScheduleEvent{
+		{Time: "06:00", Set: map[string]map[string]uint8{"rack1": {"blue": 50}}},
+		{Time: "10:00", Set: map[string]map[string]uint8{"rack1": {"blue": 200}}},
+		{Time: "13:30", Blackout: true},
+	}}, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A zone where it is now 12:xx
+	now := time.Now().UTC()
+	s.location = time.FixedZone("noon", (12-now.Hour())*3600)
+	if !s.CatchUp() {
+		t.Fatal("expected an event caught up")
+	}
+	if ch := state.GetChannels(); ch[0] != 200 {
+		t.Errorf("expected the 10:00 event applied, got channel 1 = %d", ch[0])
+	}
+
+	// Nothing due yet today at 05:xx
+	s.location = time.FixedZone("dawn", (5-now.Hour())*3600)
+	if s.CatchUp() {
+		t.Error("expected no event caught up before 06:00")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state := testState(t)
+
+	s, err := New(&config.ScheduleConfig{Timezone: "UTC", Events: []config.ScheduleEvent{
+		{Time: "06:00:00", Set: map[string]map[string]uint8{"rack1": {"blue": 50}}},
+		{Time: "06:00:02", Set: map[string]map[string]uint8{"rack1": {"blue": 100}}},
+		{Time: "06:00:02", Set: map[string]map[string]uint8{"rack1": {"blue": 150}}}, // Tie: not run
+		{Time: "10:00", Blackout: true},
+	}}, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, min, sec int) time.Time {
+		return time.Date(2025, 6, 2, hour, min, sec, 0, time.UTC)
+	}
+	blue := func() uint8 { return state.GetChannels()[0] }
+
+	// A late wake runs every run missed, in time order
+	if done := s.check(at(5, 59, 59), at(6, 0, 3)); !done.Equal(at(6, 0, 3)) {
+		t.Errorf("expected done at 06:00:03, got %v", done)
+	}
+	if blue() != 100 {
+		t.Errorf("expected the 06:00:02 event applied last, got %d", blue())
+	}
+
+	// A clock stepped back does not repeat them
+	state.SetChannels(1, []uint8{0})
+	if done := s.check(at(6, 0, 3), at(5, 0, 0)); !done.Equal(at(6, 0, 3)) {
+		t.Errorf("expected done kept at 06:00:03, got %v", done)
+	}
+	if blue() != 0 {
+		t.Errorf("expected no run after a step back, got %d", blue())
+	}
+
+	// Runs missed across a jump forward are skipped
+	state.SetChannels(1, []uint8{30})
+	if done := s.check(at(6, 0, 3), at(11, 0, 0)); !done.Equal(at(11, 0, 0)) {
+		t.Errorf("expected done at 11:00, got %v", done)
+	}
+	if blue() != 30 {
+		t.Errorf("expected the 10:00 blackout skipped, got %d", blue())
+	}
+
+	// Sleeps until the next run, maxSleep at most
+	if d := s.sleep(time.Now().In(time.UTC)); d <= 0 || d > maxSleep {
+		t.Errorf("expected a sleep up to %v, got %v", maxSleep, d)
+	}
+}
+
+func TestRunEvent(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state := testState(t)
+
+	cfg := &config.ScheduleConfig{
+		Zones:  map[string]string{"site": "Asia/Tokyo"},
+		Events: []config.ScheduleEvent{{ID: "dusk", Time: "18:00", Blackout: true}},
+	}
+	s, err := New(cfg, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Converted as the scheduler does, conditions left out
+	above := 100.0
+	x, err := s.RunEvent(cfg, config.ScheduleEvent{
+		ID: "dawn", Time: "06:00", Timezone: "site", Jitter: "5m", Disabled: true,
+		When: []config.Condition{{Level: "rack1", Above: &above}},
+		Set:  map[string]map[string]uint8{"rack1": {"blue": 80}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Result != "ok" || x.Timezone != "site" || state.GetChannels()[0] != 80 {
+		t.Errorf("expected the event run in its zone, got %+v", x)
+	}
+	if x.Next == nil || x.Next.ID != "dusk" || x.Next.InStr != x.Next.In.String() {
+		t.Errorf("expected the next event with its delay, got %+v", x.Next)
+	}
+
+	if _, err := s.RunEvent(cfg, config.ScheduleEvent{ID: "bad", Time: "25:00", Blackout: true}); err == nil {
+		t.Error("expected error for an invalid event")
+	}
+	if _, err := s.RunEvent(cfg, config.ScheduleEvent{ID: "far", Time: "06:00", Timezone: "Mars/Base", Blackout: true}); err == nil {
+		t.Error("expected error for an unknown timezone")
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import "time"
+
+// Timer
+// The loop sleeps until the next run of the events (as next places it, DST included)
+// instead of polling. Timers count monotonic time while runs are wall clock times, so
+// a sleep lasts maxSleep at most: a clock stepped by NTP is noticed within that and the
+// run planned again. Each wake executes the runs since the previous one in time order,
+// so a wake late under load skips nothing. A clock stepped back does not repeat runs
+// already done, and after a jump forward of more than maxLate (clock set by hand,
+// resume from suspend) the runs missed are skipped, as across a restart.
+
+const (
+	maxSleep = time.Minute     // Longest sleep between checks of the clock
+	maxLate  = 5 * time.Minute // Longest delay after which missed runs still execute
+)
+
+// loop executes the events at their runs until stopped
+func (s *Scheduler) loop() {
+	done := time.Now().In(s.location) // Runs up to this time are done
+	timer := time.NewTimer(s.sleep(done))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			done = s.check(done, time.Now().In(s.location))
+		case <-s.wake:
+		case <-s.stopChan:
+			return
+		}
+		timer.Reset(s.sleep(done))
+	}
+}
+
+// rearm wakes the loop to plan its sleep again (pause changed)
+func (s *Scheduler) rearm() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sleep returns the time until the loop must wake after done: the next run, the end
+// of a timed pause, or maxSleep at most
+func (s *Scheduler) sleep(done time.Time) time.Duration {
+	now := time.Now()
+	wake := now.Add(maxSleep)
+	for _, e := range s.events {
+		if t := s.next(e, done); !t.IsZero() && t.Before(wake) {
+			wake = t
+		}
+	}
+	s.mu.RLock()
+	if s.paused && !s.pausedUntil.IsZero() && s.pausedUntil.Before(wake) {
+		wake = s.pausedUntil
+	}
+	s.mu.RUnlock()
+	return max(wake.Sub(now), 0)
+}
+
+// check executes the runs after done up to now in time order, the first event in
+// order on a tie, and returns the time up to which runs are done
+func (s *Scheduler) check(done, now time.Time) time.Time {
+	switch {
+	case now.Before(done):
+		if done.Sub(now) > time.Second {
+			s.logger.Warn("Clock stepped back, schedule runs resume after the last one", "from", done, "to", now)
+		}
+		return done
+	case now.Sub(done) > maxLate:
+		s.logger.Warn("Clock jumped forward, skipping missed schedule runs", "from", done, "to", now)
+		s.isPaused(now) // Ends an expired pause
+		return now
+	}
+	if s.isPaused(now) {
+		return now // Events due during a pause are skipped
+	}
+
+	for {
+		var first *Event
+		var at time.Time
+		for i, e := range s.events {
+			if t := s.next(e, done); !t.IsZero() && !t.After(now) && (first == nil || t.Before(at)) {
+				first, at = &s.events[i], t
+			}
+		}
+		if first == nil {
+			return now
+		}
+		s.execute(*first)
+		done = at
+	}
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import "time"
+
+// Zones
+// An event with a timezone (a name, or one of schedule.zones shared by a group of
+// events) runs at its time in that zone: a gateway driving fixtures at several sites,
+// or an event kept in UTC beside civil ones. Its days, holidays and jitter draw follow
+// its own date there; the holiday calendar itself is not shifted.
+
+// loc returns the timezone of an event
+func (s *Scheduler) loc(e Event) *time.Location {
+	if e.Location != nil {
+		return e.Location
+	}
+	return s.location
+}
+
+// holidayAt returns the holiday the date of t falls on, in the location of t
+func (s *Scheduler) holidayAt(t time.Time) (name string, ok bool) {
+	date := t.Format(time.DateOnly)
+	day := date[5:] // MM-DD
+	for _, h := range s.holidays {
+		if len(h.from) == len(day) {
+			if h.from <= h.to && day >= h.from && day <= h.to ||
+				h.from > h.to && (day >= h.from || day <= h.to) { // Wraps at new year
+				return h.name, true
+			}
+		} else if date >= h.from && date <= h.to {
+			return h.name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"dmx-gateway/internal/config"
+)
+
+func TestZones(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state := testState(t)
+	s, err := New(&config.ScheduleConfig{
+		Timezone: "UTC",
+		Zones:    map[string]string{"tokyo": "Asia/Tokyo"},
+		Events: []config.ScheduleEvent{
+			{ID: "local", Time: "08:00", Scene: "office"},
+			{ID: "site", Time: "09:00", Days: []string{"mon"}, Timezone: "tokyo", Scene: "office"},
+			{ID: "ny", Cron: "0 9 * * *", Timezone: "America/New_York", Scene: "office"},
+		},
+		Holidays: []config.Holiday{{Date: "2025-06-03"}},
+	}, state, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sunday 2025-06-01 12:00 UTC: Monday 09:00 in Tokyo is 00:00 UTC
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, want := range []time.Time{
+		time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 6, 1, 13, 0, 0, 0, time.UTC), // 09:00 EDT
+	} {
+		if got := s.next(s.events[i], now); !got.Equal(want) {
+			t.Errorf("event %s: expected next run %v, got %v", s.events[i].ID, want, got)
+		}
+	}
+
+	// The holiday of its date in New York: 2025-06-03 09:00 EDT skipped
+	if got := s.next(s.events[2], time.Date(2025, 6, 2, 14, 0, 0, 0, time.UTC)); !got.Equal(time.Date(2025, 6, 4, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the holiday skipped, got %v", got)
+	}
+
+	p, err := s.Preview("2025-06-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range p.Runs {
+		ids = append(ids, r.At.UTC().Format("15:04")+" "+r.ID)
+	}
+	if want := "[00:00 site 08:00 local 13:00 ny]"; fmt.Sprint(ids) != want {
+		t.Errorf("expected runs %s, got %v", want, ids)
+	}
+	if p.Runs[0].Timezone != "tokyo" {
+		t.Errorf("expected the event timezone shown, got %q", p.Runs[0].Timezone)
+	}
+}
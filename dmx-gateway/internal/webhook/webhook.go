@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+)
+
+// Outbound webhooks
+// Every dmx.Event is posted as JSON to the webhooks of the running config that
+// subscribe to it. The webhooks section is read at each event, so config updates
+// apply at once. A delivery failing (network error or non-2xx answer) is retried
+// after backoff_ms, doubled at each attempt. At most maxInFlight deliveries run at
+// once; events beyond that are dropped with a warning rather than queued.
+
+const maxInFlight = 16
+
+// Dispatcher posts state events to the configured webhooks
+type Dispatcher struct {
+	state  *dmx.State
+	logger *slog.Logger
+	client *http.Client
+	slots  chan struct{} // One per running delivery
+
+	mu       sync.Mutex
+	stopped  bool
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New creates a dispatcher for state's events
+func New(state *dmx.State, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		state:    state,
+		logger:   logger,
+		client:   &http.Client{},
+		slots:    make(chan struct{}, maxInFlight),
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start registers the dispatcher as an event observer
+func (d *Dispatcher) Start() {
+	d.state.OnEvent(d.notify)
+}
+
+// Stop abandons pending retries and waits for running deliveries
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	close(d.stopChan)
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// notify starts a delivery to each webhook subscribed to e
+func (d *Dispatcher) notify(e dmx.Event) {
+	hooks := d.state.GetConfig().Webhooks
+	if len(hooks) == 0 {
+		return
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+	for _, wh := range hooks {
+		if len(wh.Events) > 0 && !slices.Contains(wh.Events, e.Event) {
+			continue
+		}
+		select {
+		case d.slots <- struct{}{}:
+		default:
+			d.logger.Warn("Webhook dropped, too many deliveries in flight", "url", wh.URL, "event", e.Event)
+			continue
+		}
+		d.wg.Add(1)
+		go d.deliver(wh, e.Event, payload)
+	}
+}
+
+// deliver posts payload, retrying with exponential backoff
+func (d *Dispatcher) deliver(wh config.WebhookConfig, event string, payload []byte) {
+	defer func() {
+		<-d.slots
+		d.wg.Done()
+	}()
+
+	backoff := time.Duration(wh.BackoffMs) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := d.post(wh, event, payload)
+		if err == nil {
+			return
+		}
+		if attempt >= wh.Retries {
+			d.logger.Warn("Webhook failed", "url", wh.URL, "event", event, "attempts", attempt+1, "error", err)
+			return
+		}
+		d.logger.Debug("Webhook failed, retrying", "url", wh.URL, "event", event, "in", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-d.stopChan:
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one attempt
+func (d *Dispatcher) post(wh config.WebhookConfig, event string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(wh.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dmx-gateway")
+	req.Header.Set("X-DMX-Event", event)
+	for k, v := range wh.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package webhook
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+)
+
+func TestDispatcher(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []dmx.Event
+		calls    int
+	)
+	done := make(chan struct{}, 8)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // First attempt fails, retried
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k3n" || r.Header.Get("X-DMX-Event") == "" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		var e dmx.Event
+		json.NewDecoder(r.Body).Decode(&e)
+		received = append(received, e)
+		done <- struct{}{}
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{
+		DMX: config.DMXConfig{Client: "mock", TimeoutMs: 100},
+		Lights: map[string]map[string][]config.Channel{
+			"rack1": {"level1": {{Ch: 1, Color: "red"}}},
+		},
+		Webhooks: []config.WebhookConfig{{
+			URL:       ts.URL,
+			Events:    []string{"blackout"},
+			Headers:   map[string]string{"Authorization": "Bearer t0k3n"},
+			Retries:   2,
+			BackoffMs: 10,
+			TimeoutMs: 1000,
+		}},
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	d := New(state, logger)
+	d.Start()
+	defer d.Stop()
+
+	src := state.Source(dmx.SourceMQTT)
+	if err := src.Enable(); err != nil { // Not subscribed
+		t.Fatal(err)
+	}
+	if err := src.Blackout(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 2 || len(received) != 1 {
+		t.Fatalf("expected 1 retry then 1 delivery, got %d calls, %v", calls, received)
+	}
+	if e := received[0]; e.Event != "blackout" || e.Source != dmx.SourceMQTT || e.Time.IsZero() {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
@@ -5,6 +5,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"log/slog"
 	"os"
@@ -16,19 +18,31 @@ import (
 	"dmx-gateway/internal/config"
 	"dmx-gateway/internal/dmx"
 	"dmx-gateway/internal/http"
-	"dmx-gateway/internal/modbus"
-	"dmx-gateway/internal/mqtt"
-	"dmx-gateway/internal/scheduler"
+	"dmx-gateway/internal/remoteproc"
+	"dmx-gateway/internal/webhook"
 )
 
 func main() {
 	var (
 		configPath = flag.String("config", "config.yaml", "Path to configuration file")
 		logLevel   = flag.String("log-level", "INFO", "Log level (DEBUG, INFO, WARN, ERROR)")
-		dryRun     = flag.Bool("dry-run", false, "Validate config and exit")
+		dryRun     = flag.Bool("dry-run", false, "Validate config, print a JSON report and exit")
+		strict     = flag.Bool("strict", false, "Treat config warnings as errors")
+		schema     = flag.Bool("schema", false, "Print the JSON Schema of the config file and exit")
+		debug      = flag.Bool("debug", false, "Serve pprof and expvar under /debug")
 	)
 	flag.Parse()
 
+	if *dryRun {
+		os.Exit(checkConfig(*configPath, *strict))
+	}
+	if *schema {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(config.Schema())
+		os.Exit(0)
+	}
+
 	// Setup slog
 	level := parseLogLevel(*logLevel)
 	opts := &slog.HandlerOptions{Level: level}
@@ -39,11 +53,18 @@ func main() {
 	logger.Info("DMX Gateway starting", "version", "1.0.0")
 
 	// Load configuration
-	cfg, err := config.Load(*configPath)
+	load := config.Load
+	if *strict {
+		load = config.LoadStrict
+	}
+	cfg, err := load(*configPath)
 	if err != nil {
 		logger.Error("Failed to load configuration", "error", err, "path", *configPath)
 		os.Exit(1)
 	}
+	for _, w := range cfg.Warnings() {
+		logger.Warn("Configuration warning", "warning", w)
+	}
 
 	// Count total lights
 	totalLights := 0
@@ -55,11 +76,6 @@ func main() {
 		"lights", totalLights,
 		"http", cfg.Server.HTTP)
 
-	if *dryRun {
-		logger.Info("Dry run mode - configuration is valid")
-		os.Exit(0)
-	}
-
 	// Setup context with signal handling
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -79,8 +95,30 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize state manager
-	state := dmx.NewState(cfg, dmxClient, logger)
+	// Refuse dmx_client builds speaking an incompatible JSON protocol
+	if _, err := dmxClient.Negotiate(); err != nil {
+		logger.Error("Incompatible DMX client", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize state manager with the configured backend active
+	backends := dmx.NewBackends(cfg.DMX, dmxClient, logger)
+	state := dmx.NewState(cfg, backends[cfg.DMX.Backend], logger)
+	for name, b := range backends {
+		state.RegisterBackend(name, b)
+	}
+
+	// Load persisted scenes
+	if cfg.Scenes != nil {
+		if err := state.LoadScenes(cfg.Scenes.File); err != nil {
+			logger.Warn("Failed to load scenes", "error", err, "path", cfg.Scenes.File)
+		}
+	}
+
+	// Pass configured refresh rate to firmware
+	if cfg.DMX.FPS > 0 {
+		state.ApplyRate()
+	}
 
 	// Auto-enable DMX if configured
 	if cfg.DMX.AutoEnable {
@@ -91,62 +129,91 @@ func main() {
 		}
 	}
 
+	// Parked channels hold their value whatever is restored or written afterwards
+	if len(cfg.Park) > 0 {
+		if err := state.Park(cfg.Park); err != nil {
+			logger.Warn("Failed to park channels", "error", err)
+		}
+	}
+
+	// Restore last state after a restart, otherwise bring lights up at known levels
+	restored := false
+	if cfg.Persist != nil && cfg.Persist.RestoreOnBoot {
+		if restored, err = state.RestoreSnapshot(cfg.Persist.File); err != nil {
+			logger.Warn("Failed to restore state", "error", err, "path", cfg.Persist.File)
+		}
+	}
+	if !restored {
+		state.ApplyStartup()
+	}
+	if r := cfg.Recorder; r != nil && r.Autoplay != "" {
+		if err := state.Play(r.Autoplay, r.Loop); err != nil {
+			logger.Warn("Failed to autoplay recording", "error", err, "name", r.Autoplay)
+		}
+	}
+	if cfg.Persist != nil {
+		state.StartPersist(cfg.Persist.File, time.Duration(cfg.Persist.IntervalSec)*time.Second)
+	}
+
 	// Start periodic refresh if configured
 	if cfg.DMX.RefreshMs > 0 {
 		state.StartRefresh(time.Duration(cfg.DMX.RefreshMs) * time.Millisecond)
 	}
 
+	// Start background status polling (cached for API/MQTT status requests, disabled when negative)
+	state.StartStatusPoller(time.Duration(cfg.DMX.StatusPollMs) * time.Millisecond)
+
 	// Start HTTP server with WebSocket
 	httpServer := http.NewServer(cfg, state, logger)
+	if *debug {
+		httpServer.EnableDebug()
+	}
+	// M-core firmware management if configured, set before serving (read unlocked)
+	if cfg.Remoteproc != nil {
+		httpServer.SetFirmware(remoteproc.New(remoteproc.Config{
+			Device:      cfg.Remoteproc.Device,
+			FirmwareDir: cfg.Remoteproc.FirmwareDir,
+		}, logger))
+	}
+
 	if err := httpServer.Start(); err != nil {
 		logger.Error("Failed to start HTTP server", "error", err)
 		os.Exit(1)
 	}
 
-	// Start Modbus TCP server if configured
-	var modbusServer *modbus.Server
-	if cfg.Modbus != nil {
-		modbusServer = modbus.NewServer(&modbus.Config{
-			Port: cfg.Modbus.Port,
-		}, state, logger)
-		if err := modbusServer.Start(); err != nil {
-			logger.Error("Failed to start Modbus server", "error", err)
-			os.Exit(1)
-		}
-	}
+	// Webhooks read their config at each event, nothing to do when none are set
+	hooks := webhook.New(state, logger)
+	hooks.Start()
 
-	// Start MQTT client if configured
-	var mqttClient *mqtt.Client
-	if cfg.MQTT != nil {
-		mqttClient = mqtt.NewClient(&mqtt.Config{
-			Broker:   cfg.MQTT.Broker,
-			ClientID: cfg.MQTT.ClientID,
-			Username: cfg.MQTT.Username,
-			Password: cfg.MQTT.Password,
-			Prefix:   cfg.MQTT.TopicPrefix,
-		}, state, logger)
-		if err := mqttClient.Start(); err != nil {
-			logger.Error("Failed to start MQTT client", "error", err)
-			os.Exit(1)
-		}
+	// Start Modbus, MQTT and scheduler if configured; config updates restart them
+	svc := &services{state: state, http: httpServer, logger: logger}
+	if err := svc.start(cfg); err != nil {
+		logger.Error("Failed to start services", "error", err)
+		os.Exit(1)
 	}
+	httpServer.OnConfigChange(svc.apply)
 
-	// Start scheduler if configured
-	var sched *scheduler.Scheduler
-	if cfg.Schedule != nil && len(cfg.Schedule.Events) > 0 {
-		var err error
-		sched, err = scheduler.New(cfg.Schedule, state, logger)
-		if err != nil {
-			logger.Error("Failed to create scheduler", "error", err)
-			os.Exit(1)
+	// SIGHUP reloads the config file (same as POST /api/reload)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			update, err := httpServer.Reload()
+			if err != nil {
+				logger.Error("Config reload failed", "error", err)
+				continue
+			}
+			logger.Info("Config reloaded", "changed", update.Changed, "restart_required", update.RestartRequired)
+			for _, c := range update.Diff {
+				logger.Info("Config change", "path", c.Path, "op", c.Op)
+			}
 		}
-		sched.Start()
-		httpServer.SetScheduler(sched)
-	}
+	}()
 
 	logger.Info("DMX Gateway ready",
 		"http", cfg.Server.HTTP,
 		"dmx_client", cfg.DMX.Client,
+		"backend", cfg.DMX.Backend,
 		"modbus", cfg.Modbus != nil,
 		"mqtt", cfg.MQTT != nil,
 		"schedule", cfg.Schedule != nil)
@@ -159,21 +226,11 @@ func main() {
 
 	// Stop refresh goroutine
 	state.StopRefresh()
+	state.StopStatusPoller()
 
-	// Stop scheduler
-	if sched != nil {
-		sched.Stop()
-	}
-
-	// Stop MQTT client
-	if mqttClient != nil {
-		mqttClient.Stop()
-	}
-
-	// Stop Modbus server
-	if modbusServer != nil {
-		modbusServer.Stop()
-	}
+	// Stop scheduler, MQTT client and Modbus server
+	svc.stop()
+	hooks.Stop()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
@@ -183,14 +240,64 @@ func main() {
 		logger.Error("HTTP server shutdown error", "error", err)
 	}
 
+	// Drain pending coalesced writes
+	state.Flush()
+
+	// Close an in-progress recording so it stays playable
+	if _, err := state.StopRecording(); err != nil && !errors.Is(err, dmx.ErrNotRecording) {
+		logger.Warn("Failed to stop recording", "error", err)
+	}
+
+	// Final snapshot before output is disabled
+	if cfg.Persist != nil {
+		state.StopPersist(cfg.Persist.File)
+	}
+
 	// Disable DMX output
-	if err := dmxClient.Disable(); err != nil {
+	if err := state.Disable(); err != nil {
 		logger.Warn("Failed to disable DMX on shutdown", "error", err)
 	}
 
 	logger.Info("DMX Gateway stopped")
 }
 
+// configReport is the -dry-run output
+type configReport struct {
+	Valid    bool                `json:"valid"`
+	Error    string              `json:"error,omitempty"`
+	Strict   bool                `json:"strict"`
+	Warnings []string            `json:"warnings"`
+	Groups   int                 `json:"groups"`
+	Lights   int                 `json:"lights"`
+	Patch    *config.PatchReport `json:"patch,omitempty"`
+}
+
+// checkConfig prints the report of the config file as JSON and returns the exit
+// status (1 when invalid, or with warnings when strict)
+func checkConfig(path string, strict bool) int {
+	report := configReport{Strict: strict, Warnings: []string{}}
+	cfg, err := config.Load(path)
+	if err != nil {
+		report.Error = err.Error()
+	} else {
+		report.Warnings = append(report.Warnings, cfg.Warnings()...)
+		report.Valid = !strict || len(report.Warnings) == 0
+		report.Groups = len(cfg.Lights)
+		for _, group := range cfg.Lights {
+			report.Lights += len(group)
+		}
+		patch := cfg.Patch()
+		report.Patch = &patch
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
 func parseLogLevel(level string) slog.Level {
 	switch strings.ToUpper(level) {
 	case "DEBUG":
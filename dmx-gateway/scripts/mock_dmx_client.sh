@@ -42,7 +42,15 @@ inc_frame_count() {
     echo "$count" >> "$STATE_FILE"
 }
 
+# Strip --json flag (output is always JSON-compatible where it matters)
+if [ "$1" = "--json" ]; then
+    shift
+fi
+
 case "$1" in
+    version)
+        echo "{\"status\":\"ok\",\"command\":\"version\",\"version\":\"mock\",\"protocol\":1}"
+        ;;
     enable)
         write_state "enabled"
         echo "OK"
@@ -83,24 +91,25 @@ case "$1" in
         inc_frame_count
         echo "OK"
         ;;
-    status|--json)
-        # Handle both "status" and "--json status"
-        if [ "$1" = "--json" ] && [ "$2" = "status" ]; then
-            :
-        elif [ "$1" = "status" ]; then
-            :
-        else
-            echo "Unknown command" >&2
-            exit 1
-        fi
-
+    status)
         state=$(read_state)
         count=$(read_frame_count)
         enabled="false"
         [ "$state" = "enabled" ] && enabled="true"
 
         # Simulate ~44 FPS
-        echo "{\"enabled\":$enabled,\"frame_count\":$count,\"fps\":44.0}"
+        echo "{\"status\":\"ok\",\"command\":\"get_status\",\"enabled\":$enabled,\"frame_count\":$count,\"fps\":44.0}"
+        ;;
+    timing)
+        # timing [fps] - set or get refresh rate
+        RATE_FILE="/tmp/dmx_mock_rate"
+        if [ -n "$2" ]; then
+            [ "$2" != "0" ] && echo "$2" > "$RATE_FILE"
+            echo "{\"status\":\"ok\",\"command\":\"set_timing\",\"refresh_hz\":$2,\"break_us\":0,\"mab_us\":0}"
+        else
+            rate=$(cat "$RATE_FILE" 2>/dev/null || echo 44)
+            echo "{\"status\":\"ok\",\"command\":\"get_timing\",\"refresh_hz\":$rate,\"break_us\":200,\"mab_us\":12}"
+        fi
         ;;
     get)
         # get <channel> - for debugging
@@ -129,7 +138,7 @@ case "$1" in
         echo "State cleared"
         ;;
     *)
-        echo "Mock DMX Client - Commands: enable, disable, blackout, set, status, get, dump, reset" >&2
+        echo "Mock DMX Client - Commands: enable, disable, blackout, set, status, timing, version, get, dump, reset" >&2
         exit 1
         ;;
 esac
@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+	"dmx-gateway/internal/http"
+	"dmx-gateway/internal/modbus"
+	"dmx-gateway/internal/mqtt"
+	"dmx-gateway/internal/scheduler"
+)
+
+// services holds the integrations started from the config
+// A config update restarts those whose section changed, so the gateway follows
+// the new config without dropping HTTP clients or DMX output.
+type services struct {
+	mu     sync.Mutex
+	state  *dmx.State
+	http   *http.Server
+	logger *slog.Logger
+	modbus *modbus.Server
+	mqtt   []*mqtt.Client // One per broker connection
+	sched  *scheduler.Scheduler
+
+	// Running MQTT clients and scheduler for the Modbus health inputs, read without mu
+	// (held while a restart stops the Modbus server)
+	mqttLive  atomic.Pointer[[]*mqtt.Client]
+	schedLive atomic.Pointer[scheduler.Scheduler]
+}
+
+// start starts every configured integration
+func (sv *services) start(cfg *config.Config) error {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if err := sv.restart(cfg, []string{"modbus", "mqtt", "schedule"}); err != nil {
+		return err
+	}
+	if sv.sched != nil && cfg.Schedule.CatchUp {
+		sv.sched.CatchUp() // Only at startup: a config change keeps the lights as they are
+	}
+	return nil
+}
+
+// apply restarts the integrations whose section changed (http.Server.OnConfigChange)
+func (sv *services) apply(cfg *config.Config, changed []string) error {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	return sv.restart(cfg, changed)
+}
+
+// restart stops and starts again the integrations of sections
+func (sv *services) restart(cfg *config.Config, sections []string) error {
+	if slices.Contains(sections, "modbus") {
+		if sv.modbus != nil {
+			sv.modbus.Stop()
+			sv.modbus = nil
+			sv.http.SetModbus(nil)
+		}
+		if cfg.Modbus != nil {
+			srv := modbus.NewServer(&modbus.Config{
+				Port:        cfg.Modbus.Port,
+				UnitID:      cfg.Modbus.UnitID,
+				RejectUnits: cfg.Modbus.OtherUnits == "reject",
+				RTU:         modbusRTU(cfg.Modbus.RTU),
+				Coils:       modbusCoils(cfg.Modbus.Coils),
+				Map:         modbusMap(cfg.Modbus.Map),
+				Scale:       cfg.Modbus.Scale,
+				Poll:        modbusDevices(cfg.Modbus.Poll),
+
+				WSClients:        sv.http.WSClients,
+				MQTTConnected:    sv.mqttConnected,
+				SchedulerRunning: sv.schedulerRunning,
+			}, sv.state, sv.logger)
+			if err := srv.Start(); err != nil {
+				return err
+			}
+			sv.modbus = srv
+			sv.http.SetModbus(srv)
+		}
+	}
+
+	if slices.Contains(sections, "mqtt") {
+		for _, client := range sv.mqtt {
+			client.Stop()
+		}
+		sv.mqtt = nil
+		sv.mqttLive.Store(nil)
+		if cfg.MQTT != nil {
+			for _, m := range cfg.MQTT.Connections() {
+				client := mqtt.NewClient(&mqtt.Config{
+					Broker:   m.Broker,
+					ClientID: m.ClientID,
+					Username: m.Username,
+					Password: m.Password,
+					Prefix:   m.TopicPrefix,
+					Targets:  m.Targets,
+					QoS:      m.QoS,
+					Retain:   m.Retain,
+
+					EventIntervalMs: m.EventIntervalMs,
+					Inputs:          mqttInputs(m.Inputs),
+					NextEvent:       sv.nextEvent,
+				}, sv.state, sv.logger)
+				if err := client.Start(); err != nil {
+					return err
+				}
+				sv.mqtt = append(sv.mqtt, client)
+			}
+			clients := slices.Clone(sv.mqtt)
+			sv.mqttLive.Store(&clients)
+		}
+	}
+
+	if slices.Contains(sections, "schedule") {
+		var pause scheduler.PauseStatus // Kept across the restart, as the light integral
+		var dli *scheduler.DLIStatus
+		if sv.sched != nil {
+			pause = sv.sched.PauseStatus()
+			if photo := sv.sched.Photoperiod(); photo != nil {
+				dli = photo.DLI
+			}
+			sv.sched.Stop()
+			sv.sched = nil
+			sv.schedLive.Store(nil)
+			sv.http.SetScheduler(nil)
+		}
+		if cfg.Schedule != nil && (len(cfg.Schedule.Events) > 0 || cfg.Schedule.Circadian != nil || cfg.Schedule.Photoperiod != nil) {
+			sched, err := scheduler.New(cfg.Schedule, sv.state, sv.logger)
+			if err != nil {
+				return err
+			}
+			if pause.Paused {
+				var until time.Time
+				if pause.Until != nil {
+					until = *pause.Until
+				}
+				sched.Pause(until)
+			}
+			if dli != nil {
+				sched.RestoreDLI(*dli)
+			}
+			sched.Start()
+			sv.sched = sched
+			sv.schedLive.Store(sched)
+			sv.http.SetScheduler(sched)
+		}
+		for _, client := range sv.mqtt {
+			client.PublishNextEvent() // Or clears it without scheduler
+		}
+	}
+	return nil
+}
+
+// mqttConnected reports whether every MQTT connection is up (false without MQTT)
+func (sv *services) mqttConnected() bool {
+	clients := sv.mqttLive.Load()
+	if clients == nil || len(*clients) == 0 {
+		return false
+	}
+	for _, client := range *clients {
+		if !client.Connected() {
+			return false
+		}
+	}
+	return true
+}
+
+// nextEvent returns the event due next of the running scheduler (nil without)
+func (sv *services) nextEvent() *scheduler.NextEventInfo {
+	if sched := sv.schedLive.Load(); sched != nil {
+		return sched.NextEvent()
+	}
+	return nil
+}
+
+// schedulerRunning reports whether a scheduler is running, not paused
+func (sv *services) schedulerRunning() bool {
+	sched := sv.schedLive.Load()
+	return sched != nil && sched.Running() && !sched.PauseStatus().Paused
+}
+
+// stop stops every running integration
+func (sv *services) stop() {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	if sv.sched != nil {
+		sv.sched.Stop()
+	}
+	for _, client := range sv.mqtt {
+		client.Stop()
+	}
+	if sv.modbus != nil {
+		sv.modbus.Stop()
+	}
+}
+
+// mqttInputs converts the configured MQTT inputs
+func mqttInputs(inputs []config.MQTTInput) []mqtt.Input {
+	result := make([]mqtt.Input, len(inputs))
+	for i, in := range inputs {
+		result[i] = mqtt.Input{Topic: in.Topic, Name: in.Name, Field: in.Field}
+	}
+	return result
+}
+
+// modbusRTU converts the configured Modbus RTU slave (nil = none)
+func modbusRTU(rtu *config.ModbusRTUConfig) *modbus.RTUConfig {
+	if rtu == nil {
+		return nil
+	}
+	return &modbus.RTUConfig{
+		Device:   rtu.Device,
+		Baud:     rtu.Baud,
+		Parity:   rtu.Parity,
+		StopBits: rtu.StopBits,
+		UnitID:   rtu.UnitID,
+		RS485:    rtu.RS485,
+	}
+}
+
+// modbusCoils converts the configured light and group coils
+func modbusCoils(coils []config.ModbusCoil) []modbus.Coil {
+	result := make([]modbus.Coil, len(coils))
+	for i, c := range coils {
+		result[i] = modbus.Coil{Target: c.Target, Preset: c.Preset, Fade: time.Duration(c.FadeMs) * time.Millisecond}
+	}
+	return result
+}
+
+// modbusMap converts the configured register map (nil = default layout)
+func modbusMap(m *config.ModbusMapConfig) *modbus.RegisterMap {
+	if m == nil {
+		return nil
+	}
+	result := &modbus.RegisterMap{
+		Channels: uint16(m.Channels),
+		Scene:    uint16(m.Channels + 512),
+		Coils:    uint16(m.Coils),
+		Lights:   make(map[string]uint16, len(m.Lights)),
+	}
+	if m.Scene != nil {
+		result.Scene = uint16(*m.Scene)
+	}
+	result.Fade = result.Scene + 1
+	if m.Fade != nil {
+		result.Fade = uint16(*m.Fade)
+	}
+	for key, first := range m.Lights {
+		result.Lights[key] = uint16(first)
+	}
+	return result
+}
+
+// modbusDevices converts the configured polled devices
+func modbusDevices(devices []config.ModbusDevice) []modbus.Device {
+	result := make([]modbus.Device, len(devices))
+	for i, d := range devices {
+		result[i] = modbus.Device{
+			Address:  d.Address,
+			UnitID:   d.UnitID,
+			Interval: time.Duration(d.IntervalMs) * time.Millisecond,
+			Timeout:  time.Duration(d.TimeoutMs) * time.Millisecond,
+			Points:   make([]modbus.Point, len(d.Points)),
+		}
+		for j, p := range d.Points {
+			result[i].Points[j] = modbus.Point{
+				Name:     p.Name,
+				Register: uint16(p.Register),
+				Input:    p.Type == "input",
+				Format:   p.Format,
+				Scale:    p.Scale,
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2025 Pierre Jay
+
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dmx-gateway/internal/config"
+	"dmx-gateway/internal/dmx"
+	"dmx-gateway/internal/http"
+)
+
+// freePort returns a local TCP address nothing listens on
+func freePort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// TestApplyRollback checks that an integration failing to start leaves the previous
+// config running, Modbus included
+func TestApplyRollback(t *testing.T) {
+	port := freePort(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := "modbus:\n  port: \"" + port + "\"\nlights:\n  rack1:\n    level1:\n      - { ch: 1, color: blue }\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	state, _ := dmx.NewStateWithMock(cfg, logger)
+	server := http.NewServer(cfg, state, logger)
+	svc := &services{state: state, http: server, logger: logger}
+	if err := svc.start(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer svc.stop()
+	server.OnConfigChange(svc.apply)
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func() int {
+		req := httptest.NewRequest("PUT", "/api/config/modbus", strings.NewReader(`{"port":"`+taken.Addr().String()+`"}`))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := put(); code == nethttp.StatusOK {
+		t.Fatal("expected the update rejected, the port being taken")
+	}
+	if got := state.GetConfig().Modbus.Port; got != port {
+		t.Errorf("expected the previous port running, got %s", got)
+	}
+	if conn, err := net.Dial("tcp", port); err != nil {
+		t.Errorf("expected Modbus restarted on %s: %v", port, err)
+	} else {
+		conn.Close()
+	}
+	saved, _ := os.ReadFile(path)
+	if string(saved) != data {
+		t.Errorf("expected the file unchanged, got:\n%s", saved)
+	}
+
+	// Once the port is free the same update applies
+	taken.Close()
+	if code := put(); code != nethttp.StatusOK {
+		t.Errorf("expected the retry applied, got %d", code)
+	}
+}